@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/config"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// main hashes every plaintext password left in the user storage. Users are
+// also migrated transparently on their next successful login, so running this
+// is only needed to get rid of plaintext records at once. The storage is found
+// through the same configuration as the server's, backend included.
+//
+// With the file backend the server must be stopped first: it keeps the users
+// in memory and would overwrite the migrated hashes on its next write. The
+// migration refuses to run while something answers on the server's address.
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	userStorage, closeStorage, err := openUserStorage(cfg)
+	if err != nil {
+		log.Fatalf("Error loading users: %v", err)
+	}
+	defer closeStorage()
+	userService := service.NewUserService(userStorage)
+
+	migrated, err := userService.MigrateLegacyPasswords()
+	if err != nil {
+		log.Fatalf("Error migrating passwords: %v", err)
+	}
+	log.Printf("Migrated %d legacy password(s)", migrated)
+}
+
+// openUserStorage opens the users of the configured storage backend
+func openUserStorage(cfg *config.Config) (storage.UserStorage, func() error, error) {
+	switch cfg.Storage.Backend {
+	case "sqlite":
+		db, err := storage.OpenSQLite(cfg.Storage.Path(cfg.Storage.SQLitePath))
+		if err != nil {
+			return nil, nil, err
+		}
+		return storage.NewSQLiteUserStorage(db), db.Close, nil
+	case "file":
+		if serverRunning(cfg.Server.Address) {
+			return nil, nil, fmt.Errorf("a server is listening on %s; stop it first, or it will overwrite the migrated passwords", cfg.Server.Address)
+		}
+		userStorage, err := storage.NewFileUserStorage(cfg.Storage.Path("users.json"))
+		if err != nil {
+			return nil, nil, err
+		}
+		return userStorage, func() error { return nil }, nil
+	}
+	return nil, nil, fmt.Errorf("unknown storage backend %q, expected \"file\" or \"sqlite\"", cfg.Storage.Backend)
+}
+
+// serverRunning reports whether something accepts connections on the
+// server's address, assuming it runs on this machine
+func serverRunning(address string) bool {
+	connection, err := net.DialTimeout("tcp", address, time.Second)
+	if err != nil {
+		return false
+	}
+	connection.Close()
+	return true
+}
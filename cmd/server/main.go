@@ -1,47 +1,34 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	_ "github.com/mth-ribeiro-dev/finance-api-go.git/docs"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/config"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/handler"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/logging"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/metrics"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
-	"gopkg.in/yaml.v2"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
-type Config struct {
-	SMTP struct {
-		Host     string `yaml:"host"`
-		Port     int    `yaml:"port"`
-		Username string `yaml:"username"`
-		Password string `yaml:"password"`
-	} `yaml:"smtp"`
-}
-
-func loadConfig(filename string) (*Config, error) {
-	config := &Config{}
-
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	decoder := yaml.NewDecoder(file)
-	err = decoder.Decode(config)
-	if err != nil {
-		return nil, err
-	}
-
-	return config, nil
-}
-
 // @title MyFinance API
 // @version 0.3.3
 // @description This is a REST API for managing personal finances developed in Go.
@@ -55,12 +42,36 @@ func loadConfig(filename string) (*Config, error) {
 // @host localhost:8081
 // @BasePath /api/v1
 // @schemes http
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and the access token.
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.SlogLevel())
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	slog.SetDefault(logger)
 
-	router := gin.Default()
+	// Errors answers what the handlers and Recover report, so it wraps
+	// Recover; the request ID comes first so every log line and error
+	// response carries it
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.Logger(), middleware.Metrics(), middleware.Errors(), middleware.Recover())
+	router.NoRoute(func(context *gin.Context) {
+		_ = context.Error(service.ErrRouteNotFound)
+	})
 
 	configCors := cors.DefaultConfig()
-	configCors.AllowOrigins = []string{"http://localhost:8080"}
+	configCors.AllowOrigins = cfg.Server.CORSOrigins
 	configCors.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
 	configCors.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
 	configCors.AllowCredentials = true
@@ -68,30 +79,109 @@ func main() {
 
 	router.Use(cors.New(configCors))
 
-	setupServices(router)
+	jobs := setupServices(router, cfg)
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	err := router.Run(":8081")
-	if err != nil {
-		return
+	server := &http.Server{
+		Addr:         cfg.Server.Address,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	if !serve(server, jobs, cfg.Server.ShutdownTimeout) {
+		os.Exit(1)
+	}
+}
+
+// serve runs the server until it fails or the process is asked to stop, then
+// shuts everything down in order. It reports whether it all went cleanly.
+func serve(server *http.Server, jobs *backgroundJobs, shutdownTimeout time.Duration) bool {
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	clean := true
+	select {
+	case err := <-serverErrors:
+		slog.Error("Error starting the server", "error", err)
+		clean = false
+	case <-signals.Done():
+		slog.Info("Shutting down, press Ctrl+C again to force")
+	}
+	// A second signal kills the process right away
+	stopSignals()
+
+	// Requests in flight are drained first, so the background jobs and the
+	// storage are only stopped once nothing else can write
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Error draining requests", "error", err)
+		clean = false
+	}
+	if err := jobs.stop(ctx); err != nil {
+		slog.Error("Error stopping background jobs", "error", err)
+		clean = false
 	}
+	slog.Info("Server stopped")
+	return clean
 }
 
-// setupServices configures and sets up all the services for the API
-func setupServices(router *gin.Engine) {
+// setupServices configures and sets up all the services for the API and
+// starts the background jobs
+func setupServices(router *gin.Engine, cfg *config.Config) *backgroundJobs {
+	// Token service setup
+	secret := cfg.Auth.Secret
+	if secret == "" {
+		slog.Warn("No JWT secret configured (auth.jwt_secret or MYFINANCE_JWT_SECRET), using a random secret; sessions will not survive a restart")
+		secret = randomSecret()
+	}
+	tokenService := service.NewTokenService(secret, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+
+	stores := setupStorage(cfg.Storage)
+
+	// Currency service setup
+	currencyService := service.NewCurrencyService(stores.rates, stores.users)
+	currencyHandler := handler.NewCurrencyHandler(currencyService)
+
+	// Account service setup
+	accountService := service.NewAccountService(stores.accounts, stores.finance, stores.recurring, stores.imports, currencyService)
+	accountHandler := handler.NewAccountHandler(accountService)
 
 	// Finance service setup
-	financeStorage := storage.NewFileFinanceStorage("finances.json")
-	financeService := service.NewFinanceService(financeStorage)
+	financeService := service.NewFinanceService(stores.finance, stores.accounts, stores.categories, currencyService)
 	financeHandler := handler.NewFinanceHandler(financeService)
+	reportHandler := handler.NewReportHandler(financeService)
+
+	// Recurring transactions setup; due occurrences are created in the background
+	recurringService := service.NewRecurringService(stores.recurring, financeService, stores.users)
+	recurringHandler := handler.NewRecurringHandler(recurringService)
 
-	// User service setup
-	userStorage := storage.NewFileUserStorage("users.json")
-	userService := service.NewUserService(userStorage)
-	userHandler := handler.NewUserHandler(userService)
+	// Category catalog setup
+	categoryService := service.NewCategoryService(stores.categories, stores.finance, stores.recurring, stores.budgets, stores.alerts)
+	categoryHandler := handler.NewCategoryHandler(categoryService)
 
-	cfg := config.GetConfig()
+	// Bank statement import setup
+	importService := service.NewImportService(stores.imports, financeService)
+	importHandler := handler.NewImportHandler(importService)
+
+	// Budget service setup
+	budgetService := service.NewBudgetService(stores.budgets, financeService)
+	budgetHandler := handler.NewBudgetHandler(budgetService)
+
+	// User service setup; every authenticated request checks the user is
+	// still active, and the admin routes are limited to the configured users
+	userService := service.NewUserService(stores.users)
+	userDataService := service.NewUserDataService(userService, stores.finance, stores.accounts, stores.categories,
+		stores.recurring, stores.budgets, stores.alerts, stores.imports)
+	userHandler := handler.NewUserHandler(userService, userDataService, tokenService)
+	requireAuth := middleware.RequireAuth(tokenService, userService)
+	requireAdmin := middleware.RequireAdmin(cfg.Auth.AdminUserIDs)
 
 	// Configurar o serviço de email
 	emailService := service.NewEmailService(
@@ -103,21 +193,295 @@ func setupServices(router *gin.Engine) {
 
 	emailHandler := handler.NewEmailHandler(emailService)
 
+	// Health probes; the SMTP server is only checked when asked to, so a mail
+	// outage does not take the API out of rotation
+	healthService := service.NewHealthService()
+	healthService.AddCheck("storage", stores.ready)
+	if cfg.SMTP.CheckReady {
+		healthService.AddCheck("smtp", emailService.Ping)
+	}
+	healthHandler := handler.NewHealthHandler(healthService)
+
+	// Domain gauges, counted on every scrape
+	if err := metrics.RegisterCount("transactions", "Number of stored transactions.", stores.finance.Count); err != nil {
+		fatal("Error registering metrics", err)
+	}
+	err := metrics.RegisterCount("users", "Number of registered users.", func() (int, error) {
+		users, err := stores.users.List()
+		count := 0
+		for _, user := range users {
+			if !user.Purged {
+				count++
+			}
+		}
+		return count, err
+	})
+	if err != nil {
+		fatal("Error registering metrics", err)
+	}
+
+	// Alert service setup; rules are checked whenever a transaction is saved
+	// and the emails are delivered in the background
+	alertService := service.NewAlertService(stores.alerts, financeService, stores.users, emailService)
+	alertHandler := handler.NewAlertHandler(alertService)
+	financeService.Observers = append(financeService.Observers, alertService)
+
+	// Background workers start once every service is wired
+	jobs := newBackgroundJobs(stores.close)
+	jobs.start(func(ctx context.Context) { recurringService.Run(ctx, cfg.Scheduler.Interval) })
+	jobs.start(alertService.Run)
+
+	// Probe and metrics routes, outside the versioned API
+	router.GET("/healthz", healthHandler.Live)
+	router.GET("/readyz", healthHandler.Ready)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	v1 := router.Group("/api/v1")
 	{
 		// Finance routes
-		v1.POST("/transactions", financeHandler.AddTransaction)
-		v1.GET("/transactions/:userId", financeHandler.GetTransactions)
-		v1.GET("/balance/:userId", financeHandler.GetBalance)
-		v1.PUT("/transactions/:id", financeHandler.UpdateTransaction)
-		v1.DELETE("/transactions/:id", financeHandler.DeleteTransaction)
+		v1.POST("/transactions", requireAuth, financeHandler.AddTransaction)
+		v1.GET("/transactions", requireAuth, financeHandler.GetTransactions)
+		v1.GET("/transactions/export", requireAuth, financeHandler.ExportTransactions)
+		v1.GET("/balance", requireAuth, financeHandler.GetBalance)
+		v1.PUT("/transactions/:id", requireAuth, financeHandler.UpdateTransaction)
+		v1.DELETE("/transactions/:id", requireAuth, financeHandler.DeleteTransaction)
+		v1.POST("/transactions/import/ofx", requireAuth, importHandler.ImportOFX)
+
+		// Account routes
+		v1.POST("/accounts", requireAuth, accountHandler.AddAccount)
+		v1.GET("/accounts", requireAuth, accountHandler.GetAccounts)
+		v1.GET("/accounts/:id", requireAuth, accountHandler.GetAccount)
+		v1.PUT("/accounts/:id", requireAuth, accountHandler.UpdateAccount)
+		v1.DELETE("/accounts/:id", requireAuth, accountHandler.DeleteAccount)
+
+		// Category routes
+		v1.POST("/categories", requireAuth, categoryHandler.AddCategory)
+		v1.GET("/categories", requireAuth, categoryHandler.GetCategories)
+		v1.GET("/categories/:id", requireAuth, categoryHandler.GetCategory)
+		v1.PUT("/categories/:id", requireAuth, categoryHandler.UpdateCategory)
+		v1.DELETE("/categories/:id", requireAuth, categoryHandler.DeleteCategory)
+		v1.POST("/categories/:id/merge", requireAuth, categoryHandler.MergeCategory)
+
+		// Import routes
+		v1.POST("/imports/csv", requireAuth, importHandler.ImportCSV)
+		v1.POST("/imports/profiles", requireAuth, importHandler.AddProfile)
+		v1.GET("/imports/profiles", requireAuth, importHandler.GetProfiles)
+		v1.GET("/imports/profiles/:id", requireAuth, importHandler.GetProfile)
+		v1.PUT("/imports/profiles/:id", requireAuth, importHandler.UpdateProfile)
+		v1.DELETE("/imports/profiles/:id", requireAuth, importHandler.DeleteProfile)
+
+		// Recurring transaction routes
+		v1.POST("/recurring", requireAuth, recurringHandler.AddRule)
+		v1.GET("/recurring", requireAuth, recurringHandler.GetRules)
+		v1.GET("/recurring/:id", requireAuth, recurringHandler.GetRule)
+		v1.PUT("/recurring/:id", requireAuth, recurringHandler.UpdateRule)
+		v1.DELETE("/recurring/:id", requireAuth, recurringHandler.DeleteRule)
+		v1.GET("/recurring/:id/preview", requireAuth, recurringHandler.PreviewRule)
+		v1.PUT("/recurring/:id/occurrences/:date", requireAuth, recurringHandler.EditOccurrence)
+		v1.DELETE("/recurring/:id/occurrences/:date", requireAuth, recurringHandler.RestoreOccurrence)
+		v1.POST("/recurring/:id/occurrences/:date/skip", requireAuth, recurringHandler.SkipOccurrence)
+
+		// Budget routes
+		v1.POST("/budgets", requireAuth, budgetHandler.AddBudget)
+		v1.GET("/budgets", requireAuth, budgetHandler.GetBudgets)
+		v1.GET("/budgets/status", requireAuth, budgetHandler.GetBudgetStatus)
+		v1.GET("/budgets/:id", requireAuth, budgetHandler.GetBudget)
+		v1.PUT("/budgets/:id", requireAuth, budgetHandler.UpdateBudget)
+		v1.DELETE("/budgets/:id", requireAuth, budgetHandler.DeleteBudget)
+
+		// Alert routes
+		v1.POST("/alerts", requireAuth, alertHandler.AddRule)
+		v1.GET("/alerts", requireAuth, alertHandler.GetRules)
+		v1.GET("/alerts/:id", requireAuth, alertHandler.GetRule)
+		v1.PUT("/alerts/:id", requireAuth, alertHandler.UpdateRule)
+		v1.DELETE("/alerts/:id", requireAuth, alertHandler.DeleteRule)
+
+		// Report routes
+		v1.GET("/reports/summary", requireAuth, reportHandler.GetSummary)
+		v1.GET("/reports/tags", requireAuth, reportHandler.GetTagTotals)
+		v1.GET("/reports/balance-history", requireAuth, reportHandler.GetBalanceHistory)
+
+		// Exchange rate routes; rates are shared by every user, so only admins
+		// import them
+		v1.GET("/exchange-rates", requireAuth, currencyHandler.GetRates)
+		v1.POST("/exchange-rates/import", requireAuth, requireAdmin, currencyHandler.ImportRates)
 
 		// User routes
 		v1.POST("/users", userHandler.AddUser)
 		v1.POST("/users/auth", userHandler.AuthenticateUser)
-		v1.DELETE("/users/:id", userHandler.DeleteUser)
+		v1.POST("/users/refresh", userHandler.RefreshToken)
+		v1.GET("/users/me", requireAuth, userHandler.GetProfile)
+		v1.PATCH("/users/me", requireAuth, userHandler.UpdateProfile)
+		v1.GET("/users/me/export", requireAuth, userHandler.ExportData)
+		v1.PUT("/users/me/base-currency", requireAuth, userHandler.SetBaseCurrency)
+		v1.PUT("/users/me/alerts", requireAuth, userHandler.SetAlertsOptOut)
+		v1.DELETE("/users/:id", requireAuth, userHandler.DeleteUser)
+
+		// Admin routes
+		v1.POST("/admin/users/:id/reactivate", requireAuth, requireAdmin, userHandler.ReactivateUser)
+		v1.GET("/admin/users/:id/export", requireAuth, requireAdmin, userHandler.ExportUserData)
+		v1.DELETE("/admin/users/:id", requireAuth, requireAdmin, userHandler.PurgeUser)
 
 		// Email routes
 		v1.POST("/send-email", emailHandler.SendEmail)
 	}
+	return jobs
+}
+
+// backgroundJobs runs the workers started by setupServices and stops them
+// before the storage they write to is closed
+type backgroundJobs struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	close   func() error
+}
+
+func newBackgroundJobs(closeStorage func() error) *backgroundJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &backgroundJobs{ctx: ctx, cancel: cancel, close: closeStorage}
+}
+
+// start runs a worker in the background until the jobs are stopped
+func (jobs *backgroundJobs) start(worker func(ctx context.Context)) {
+	jobs.running.Add(1)
+	go func() {
+		defer jobs.running.Done()
+		worker(jobs.ctx)
+	}()
+}
+
+// stop cancels the workers, waits for them to return until ctx expires and
+// closes the storage
+func (jobs *backgroundJobs) stop(ctx context.Context) error {
+	jobs.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		jobs.running.Wait()
+		close(stopped)
+	}()
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		err = errors.New("background jobs did not stop in time")
+	}
+	return errors.Join(err, jobs.close())
+}
+
+// storages holds the storage of every entity, all from the same backend,
+// checks that it can still be written and closes it
+type storages struct {
+	finance    storage.FinanceStorage
+	users      storage.UserStorage
+	rates      storage.ExchangeRateStorage
+	accounts   storage.AccountStorage
+	categories storage.CategoryStorage
+	imports    storage.ImportProfileStorage
+	recurring  storage.RecurringStorage
+	budgets    storage.BudgetStorage
+	alerts     storage.AlertStorage
+	ready      service.HealthCheck
+	close      func() error
+}
+
+// setupStorage builds the storage backend selected in the configuration
+func setupStorage(cfg config.StorageConfig) storages {
+	// Data files are kept in the data directory, created on first start
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		fatal("Error creating data directory", err)
+	}
+
+	switch cfg.Backend {
+	case "sqlite":
+		db, err := storage.OpenSQLite(cfg.Path(cfg.SQLitePath))
+		if err != nil {
+			fatal("Error opening SQLite database", err)
+		}
+		if err := metrics.Registry.Register(collectors.NewDBStatsCollector(db, "myfinance")); err != nil {
+			fatal("Error registering metrics", err)
+		}
+		return storages{
+			finance:    storage.NewSQLiteFinanceStorage(db),
+			users:      storage.NewSQLiteUserStorage(db),
+			rates:      storage.NewSQLiteExchangeRateStorage(db),
+			accounts:   storage.NewSQLiteAccountStorage(db),
+			categories: storage.NewSQLiteCategoryStorage(db),
+			imports:    storage.NewSQLiteImportProfileStorage(db),
+			recurring:  storage.NewSQLiteRecurringStorage(db),
+			budgets:    storage.NewSQLiteBudgetStorage(db),
+			alerts:     storage.NewSQLiteAlertStorage(db),
+			ready:      func(ctx context.Context) error { return storage.CheckSQLite(ctx, db) },
+			close:      db.Close,
+		}
+	case "file":
+		financeStorage, err := storage.NewFileFinanceStorage(cfg.Path("finances.json"))
+		if err != nil {
+			fatal("Error loading transactions, refusing to start", err)
+		}
+		userStorage, err := storage.NewFileUserStorage(cfg.Path("users.json"))
+		if err != nil {
+			fatal("Error loading users, refusing to start", err)
+		}
+		rateStorage, err := storage.NewFileExchangeRateStorage(cfg.Path("exchange_rates.json"))
+		if err != nil {
+			fatal("Error loading exchange rates, refusing to start", err)
+		}
+		accountStorage, err := storage.NewFileAccountStorage(cfg.Path("accounts.json"))
+		if err != nil {
+			fatal("Error loading accounts, refusing to start", err)
+		}
+		categoryStorage, err := storage.NewFileCategoryStorage(cfg.Path("categories.json"))
+		if err != nil {
+			fatal("Error loading categories, refusing to start", err)
+		}
+		importStorage, err := storage.NewFileImportProfileStorage(cfg.Path("import_profiles.json"))
+		if err != nil {
+			fatal("Error loading import profiles, refusing to start", err)
+		}
+		recurringStorage, err := storage.NewFileRecurringStorage(cfg.Path("recurring.json"))
+		if err != nil {
+			fatal("Error loading recurring rules, refusing to start", err)
+		}
+		budgetStorage, err := storage.NewFileBudgetStorage(cfg.Path("budgets.json"))
+		if err != nil {
+			fatal("Error loading budgets, refusing to start", err)
+		}
+		alertStorage, err := storage.NewFileAlertStorage(cfg.Path("alerts.json"))
+		if err != nil {
+			fatal("Error loading alert rules, refusing to start", err)
+		}
+		return storages{
+			finance:    financeStorage,
+			users:      userStorage,
+			rates:      rateStorage,
+			accounts:   accountStorage,
+			categories: categoryStorage,
+			imports:    importStorage,
+			recurring:  recurringStorage,
+			budgets:    budgetStorage,
+			alerts:     alertStorage,
+			ready:      func(context.Context) error { return storage.CheckWritable(cfg.DataDir) },
+			// Every change is written to its file before the call returns
+			close: func() error { return nil },
+		}
+	default:
+		fatal("Unknown storage backend", fmt.Errorf("%q, expected \"file\" or \"sqlite\"", cfg.Backend))
+		return storages{}
+	}
+}
+
+// fatal logs why the server cannot start and exits
+func fatal(message string, err error) {
+	slog.Error(message, "error", err)
+	os.Exit(1)
+}
+
+// randomSecret generates a throwaway signing key for when no secret is configured
+func randomSecret() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
 }
@@ -1,26 +1,381 @@
 package config
 
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFile is the configuration file read when no other one is given; the
+// server also starts without it
+const DefaultFile = "config.yaml"
+
 // Config holds all configuration for the application
 type Config struct {
-	SMTP SMTPConfig
+	Server    ServerConfig    `yaml:"server"`
+	SMTP      SMTPConfig      `yaml:"smtp"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Log       LogConfig       `yaml:"log"`
 }
 
-// SMTPConfig holds SMTP-specific configuration
+// ServerConfig holds the address the API listens on, the origins allowed to
+// call it from a browser and the HTTP timeouts. A zero read, write or idle
+// timeout means none; ShutdownTimeout is how long in-flight requests and
+// background jobs are given to finish when the server is stopped.
+type ServerConfig struct {
+	Address         string        `yaml:"address"`
+	CORSOrigins     []string      `yaml:"cors_origins"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// SMTPConfig holds SMTP-specific configuration. With CheckReady the
+// readiness probe also requires the SMTP server to accept connections.
 type SMTPConfig struct {
-	Host     string
-	Port     int
-	Username string
-	Password string
-}
-
-// GetConfig returns the application configuration
-func GetConfig() *Config {
-	return &Config{
-		SMTP: SMTPConfig{
-			Host:     "",
-			Port:     0,
-			Username: "",
-			Password: "",
+	Host       string `yaml:"host"`
+	Port       int    `yaml:"port"`
+	Username   string `yaml:"username"`
+	Password   string `yaml:"password"`
+	CheckReady bool   `yaml:"check_ready"`
+}
+
+// AuthConfig holds the settings used to sign and validate session tokens.
+// AdminUserIDs lists the users allowed to call the admin routes; they are
+// given by ID because, unlike emails, IDs cannot be claimed by other users.
+type AuthConfig struct {
+	Secret          string        `yaml:"jwt_secret"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	AdminUserIDs    []int         `yaml:"admin_user_ids"`
+}
+
+// StorageConfig selects the persistence backend. Backend is either "file"
+// (JSON files in DataDir) or "sqlite"; a relative SQLitePath is also kept in
+// DataDir.
+type StorageConfig struct {
+	Backend    string `yaml:"backend"`
+	DataDir    string `yaml:"data_dir"`
+	SQLitePath string `yaml:"sqlite_path"`
+}
+
+// Path resolves a data file name inside the data directory. Absolute paths
+// are returned unchanged.
+func (storage StorageConfig) Path(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(storage.DataDir, filename)
+}
+
+// SchedulerConfig controls the background jobs. Interval is how often due
+// recurring transactions are created.
+type SchedulerConfig struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+// LogConfig controls the server logs. Level is debug, info, warn or error
+// and Format is "text" or "json".
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// SlogLevel returns Level as a slog level; Validate makes sure it parses
+func (log LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(log.Level))
+	return level
+}
+
+// defaults returns the configuration used for everything left unset
+func defaults() Config {
+	return Config{
+		Server: ServerConfig{
+			Address:     ":8081",
+			CORSOrigins: []string{"http://localhost:8080"},
+			// Uploads and exports of large statements can take a while
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    2 * time.Minute,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
 		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 7 * 24 * time.Hour,
+		},
+		Storage: StorageConfig{
+			Backend:    "file",
+			SQLitePath: "myfinance.db",
+		},
+		Scheduler: SchedulerConfig{
+			Interval: time.Hour,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, a YAML file, MYFINANCE_* environment variables and the
+// command-line flags in args, and validates the result. The file is given
+// with -config or MYFINANCE_CONFIG and defaults to DefaultFile. Passwords and
+// secrets have no flag, since flags are visible to every user of the machine.
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("myfinance", flag.ContinueOnError)
+	file := flags.String("config", "", "YAML configuration file (default "+DefaultFile+")")
+	address := flags.String("addr", "", "address to listen on, such as :8081")
+	dataDir := flags.String("data-dir", "", "directory of the data files (default ~/myfinance)")
+	backend := flags.String("storage", "", `storage backend, "file" or "sqlite"`)
+	sqlitePath := flags.String("sqlite-path", "", "SQLite database file, relative to the data directory")
+	corsOrigins := flags.String("cors-origins", "", "comma-separated origins allowed to call the API from a browser")
+	smtpHost := flags.String("smtp-host", "", "SMTP server host")
+	smtpPort := flags.Int("smtp-port", 0, "SMTP server port")
+	smtpUsername := flags.String("smtp-username", "", "SMTP username, also the sender of emails")
+	logLevel := flags.String("log-level", "", "minimum level logged: debug, info, warn or error")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaults()
+	if err := loadFile(&cfg, *file); err != nil {
+		return nil, err
+	}
+	if err := loadEnv(&cfg); err != nil {
+		return nil, err
+	}
+	// Only the flags given on the command line override the other sources
+	flags.Visit(func(set *flag.Flag) {
+		switch set.Name {
+		case "addr":
+			cfg.Server.Address = *address
+		case "data-dir":
+			cfg.Storage.DataDir = *dataDir
+		case "storage":
+			cfg.Storage.Backend = *backend
+		case "sqlite-path":
+			cfg.Storage.SQLitePath = *sqlitePath
+		case "cors-origins":
+			cfg.Server.CORSOrigins = splitList(*corsOrigins)
+		case "smtp-host":
+			cfg.SMTP.Host = *smtpHost
+		case "smtp-port":
+			cfg.SMTP.Port = *smtpPort
+		case "smtp-username":
+			cfg.SMTP.Username = *smtpUsername
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		}
+	})
+
+	dir, err := resolveDataDir(cfg.Storage.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Storage.DataDir = dir
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// resolveDataDir makes the data directory absolute, defaulting to ~/myfinance. A
+// leading ~ stands for the home directory, as it would in a shell.
+func resolveDataDir(dir string) (string, error) {
+	if dir == "" {
+		dir = filepath.Join("~", "myfinance")
+	}
+	if dir == "~" || strings.HasPrefix(dir, "~"+string(filepath.Separator)) {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolving the data directory: %w", err)
+		}
+		dir = filepath.Join(homeDir, dir[1:])
+	}
+	return filepath.Abs(dir)
+}
+
+// loadFile reads the YAML file over cfg. A missing file is only an error when
+// it was asked for explicitly; unknown keys are rejected to catch typos.
+func loadFile(cfg *Config, filename string) error {
+	explicit := filename != ""
+	if !explicit {
+		filename = getEnv("MYFINANCE_CONFIG", DefaultFile)
+		explicit = filename != DefaultFile
+	}
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading configuration file: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	return nil
+}
+
+// loadEnv overrides cfg with the MYFINANCE_* environment variables that are set
+func loadEnv(cfg *Config) error {
+	texts := map[string]*string{
+		"MYFINANCE_ADDR":          &cfg.Server.Address,
+		"MYFINANCE_DATA_DIR":      &cfg.Storage.DataDir,
+		"MYFINANCE_STORAGE":       &cfg.Storage.Backend,
+		"MYFINANCE_SQLITE_PATH":   &cfg.Storage.SQLitePath,
+		"MYFINANCE_SMTP_HOST":     &cfg.SMTP.Host,
+		"MYFINANCE_SMTP_USERNAME": &cfg.SMTP.Username,
+		"MYFINANCE_SMTP_PASSWORD": &cfg.SMTP.Password,
+		"MYFINANCE_JWT_SECRET":    &cfg.Auth.Secret,
+		"MYFINANCE_LOG_LEVEL":     &cfg.Log.Level,
+		"MYFINANCE_LOG_FORMAT":    &cfg.Log.Format,
+	}
+	for key, target := range texts {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			*target = value
+		}
+	}
+	if value, ok := os.LookupEnv("MYFINANCE_CORS_ORIGINS"); ok && value != "" {
+		cfg.Server.CORSOrigins = splitList(value)
+	}
+	if value, ok := os.LookupEnv("MYFINANCE_SMTP_PORT"); ok && value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("MYFINANCE_SMTP_PORT must be a number, got %q", value)
+		}
+		cfg.SMTP.Port = port
+	}
+	if value, ok := os.LookupEnv("MYFINANCE_SMTP_CHECK_READY"); ok && value != "" {
+		checkReady, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("MYFINANCE_SMTP_CHECK_READY must be true or false, got %q", value)
+		}
+		cfg.SMTP.CheckReady = checkReady
+	}
+	if value, ok := os.LookupEnv("MYFINANCE_ADMIN_USER_IDS"); ok && value != "" {
+		var ids []int
+		for _, item := range splitList(value) {
+			id, err := strconv.Atoi(item)
+			if err != nil {
+				return fmt.Errorf("MYFINANCE_ADMIN_USER_IDS must be a comma-separated list of user IDs, got %q", value)
+			}
+			ids = append(ids, id)
+		}
+		cfg.Auth.AdminUserIDs = ids
+	}
+
+	durations := map[string]*time.Duration{
+		"MYFINANCE_READ_TIMEOUT":       &cfg.Server.ReadTimeout,
+		"MYFINANCE_WRITE_TIMEOUT":      &cfg.Server.WriteTimeout,
+		"MYFINANCE_IDLE_TIMEOUT":       &cfg.Server.IdleTimeout,
+		"MYFINANCE_SHUTDOWN_TIMEOUT":   &cfg.Server.ShutdownTimeout,
+		"MYFINANCE_ACCESS_TOKEN_TTL":   &cfg.Auth.AccessTokenTTL,
+		"MYFINANCE_REFRESH_TOKEN_TTL":  &cfg.Auth.RefreshTokenTTL,
+		"MYFINANCE_SCHEDULER_INTERVAL": &cfg.Scheduler.Interval,
+	}
+	for key, target := range durations {
+		value, ok := os.LookupEnv(key)
+		if !ok || value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a duration such as 30m, got %q", key, value)
+		}
+		*target = duration
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (cfg *Config) Validate() error {
+	var problems []error
+	if _, _, err := net.SplitHostPort(cfg.Server.Address); err != nil {
+		problems = append(problems, fmt.Errorf("server address %q must be host:port or :port", cfg.Server.Address))
+	}
+	for _, origin := range cfg.Server.CORSOrigins {
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+			parsed.User != nil || parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+			problems = append(problems, fmt.Errorf("CORS origin %q must be a scheme and host such as https://example.com", origin))
+		}
+	}
+	if cfg.Server.ReadTimeout < 0 || cfg.Server.WriteTimeout < 0 || cfg.Server.IdleTimeout < 0 {
+		problems = append(problems, errors.New("server timeouts cannot be negative"))
+	}
+	if cfg.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, errors.New("shutdown timeout must be positive"))
+	}
+	if cfg.SMTP.Port < 0 || cfg.SMTP.Port > 65535 {
+		problems = append(problems, fmt.Errorf("SMTP port %d is out of range", cfg.SMTP.Port))
+	}
+	if cfg.SMTP.Host != "" && cfg.SMTP.Port == 0 {
+		problems = append(problems, errors.New("SMTP port is required when an SMTP host is set"))
+	}
+	if cfg.SMTP.CheckReady && cfg.SMTP.Host == "" {
+		problems = append(problems, errors.New("SMTP host is required to check it for readiness"))
+	}
+	if cfg.Auth.AccessTokenTTL <= 0 || cfg.Auth.RefreshTokenTTL <= 0 {
+		problems = append(problems, errors.New("token lifetimes must be positive"))
+	} else if cfg.Auth.RefreshTokenTTL < cfg.Auth.AccessTokenTTL {
+		problems = append(problems, errors.New("refresh tokens cannot expire before access tokens"))
+	}
+	for _, id := range cfg.Auth.AdminUserIDs {
+		if id <= 0 {
+			problems = append(problems, fmt.Errorf("admin user ID %d must be positive", id))
+		}
+	}
+	switch cfg.Storage.Backend {
+	case "file":
+	case "sqlite":
+		if cfg.Storage.SQLitePath == "" {
+			problems = append(problems, errors.New("SQLite path is required with the sqlite backend"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("storage backend %q must be \"file\" or \"sqlite\"", cfg.Storage.Backend))
+	}
+	if cfg.Scheduler.Interval <= 0 {
+		problems = append(problems, errors.New("scheduler interval must be positive"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+		problems = append(problems, fmt.Errorf("log level %q must be debug, info, warn or error", cfg.Log.Level))
+	}
+	if cfg.Log.Format != "text" && cfg.Log.Format != "json" {
+		problems = append(problems, fmt.Errorf("log format %q must be \"text\" or \"json\"", cfg.Log.Format))
+	}
+	return errors.Join(problems...)
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// splitList splits a comma-separated list, dropping blank entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolate clears the MYFINANCE_* variables and points the home directory at
+// a temporary one, returning it
+func isolate(t *testing.T) string {
+	t.Helper()
+	for _, key := range []string{
+		"MYFINANCE_CONFIG", "MYFINANCE_ADDR", "MYFINANCE_DATA_DIR", "MYFINANCE_STORAGE", "MYFINANCE_SQLITE_PATH",
+		"MYFINANCE_CORS_ORIGINS", "MYFINANCE_SMTP_HOST", "MYFINANCE_SMTP_PORT", "MYFINANCE_SMTP_USERNAME",
+		"MYFINANCE_SMTP_PASSWORD", "MYFINANCE_JWT_SECRET", "MYFINANCE_ACCESS_TOKEN_TTL",
+		"MYFINANCE_REFRESH_TOKEN_TTL", "MYFINANCE_SCHEDULER_INTERVAL", "MYFINANCE_READ_TIMEOUT",
+		"MYFINANCE_WRITE_TIMEOUT", "MYFINANCE_IDLE_TIMEOUT", "MYFINANCE_SHUTDOWN_TIMEOUT", "MYFINANCE_SMTP_CHECK_READY",
+		"MYFINANCE_LOG_LEVEL", "MYFINANCE_LOG_FORMAT", "MYFINANCE_ADMIN_USER_IDS",
+	} {
+		t.Setenv(key, "")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	return home
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write configuration: %v", err)
+	}
+	return filename
+}
+
+func TestLoadDefaults(t *testing.T) {
+	home := isolate(t)
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := defaults()
+	expected.Storage.DataDir = filepath.Join(home, "myfinance")
+	if !reflect.DeepEqual(*cfg, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, *cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	isolate(t)
+	filename := writeConfig(t, `
+server:
+  address: ":9000"
+  cors_origins: ["https://app.example.com"]
+smtp:
+  host: smtp.example.com
+  port: 587
+  username: file-user
+auth:
+  jwt_secret: from-file
+  access_token_ttl: 5m
+  admin_user_ids: [1]
+storage:
+  backend: sqlite
+  data_dir: data
+scheduler:
+  interval: 30m
+`)
+	t.Setenv("MYFINANCE_ADDR", ":9100")
+	t.Setenv("MYFINANCE_SMTP_USERNAME", "env-user")
+	t.Setenv("MYFINANCE_SMTP_PASSWORD", "env-password")
+	t.Setenv("MYFINANCE_SCHEDULER_INTERVAL", "10m")
+	t.Setenv("MYFINANCE_WRITE_TIMEOUT", "0s")
+	t.Setenv("MYFINANCE_LOG_FORMAT", "json")
+	t.Setenv("MYFINANCE_ADMIN_USER_IDS", "2, 5")
+
+	cfg, err := Load([]string{"-config", filename, "-addr", "127.0.0.1:9200", "-cors-origins", "http://a.test, http://b.test", "-log-level", "warn"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Flags beat the environment, which beats the file, which beats the defaults
+	if cfg.Server.Address != "127.0.0.1:9200" || !reflect.DeepEqual(cfg.Server.CORSOrigins, []string{"http://a.test", "http://b.test"}) {
+		t.Errorf("Expected the flags to win, got %+v", cfg.Server)
+	}
+	if cfg.SMTP != (SMTPConfig{Host: "smtp.example.com", Port: 587, Username: "env-user", Password: "env-password"}) {
+		t.Errorf("Expected SMTP from the file and the environment, got %+v", cfg.SMTP)
+	}
+	if cfg.Auth.Secret != "from-file" || cfg.Auth.AccessTokenTTL != 5*time.Minute || cfg.Auth.RefreshTokenTTL != 7*24*time.Hour {
+		t.Errorf("Expected the file's token settings over the defaults, got %+v", cfg.Auth)
+	}
+	if !reflect.DeepEqual(cfg.Auth.AdminUserIDs, []int{2, 5}) {
+		t.Errorf("Expected the admins from the environment, got %v", cfg.Auth.AdminUserIDs)
+	}
+	if cfg.Server.WriteTimeout != 0 || cfg.Server.ReadTimeout != 30*time.Second {
+		t.Errorf("Expected the write timeout to be turned off, got %+v", cfg.Server)
+	}
+	if cfg.Log.Format != "json" || cfg.Log.SlogLevel() != slog.LevelWarn {
+		t.Errorf("Expected the log format from the environment and the level from the flag, got %+v", cfg.Log)
+	}
+	if cfg.Scheduler.Interval != 10*time.Minute || cfg.Storage.Backend != "sqlite" {
+		t.Errorf("Expected the interval from the environment and the backend from the file, got %+v", cfg)
+	}
+
+	// A relative data directory is resolved once, against the working directory
+	workingDir, _ := os.Getwd()
+	if cfg.Storage.DataDir != filepath.Join(workingDir, "data") {
+		t.Errorf("Expected an absolute data directory, got %s", cfg.Storage.DataDir)
+	}
+	if path := cfg.Storage.Path(cfg.Storage.SQLitePath); path != filepath.Join(workingDir, "data", "myfinance.db") {
+		t.Errorf("Expected the database inside the data directory, got %s", path)
+	}
+	if path := cfg.Storage.Path("/var/lib/myfinance.db"); path != "/var/lib/myfinance.db" {
+		t.Errorf("Expected absolute paths to be kept, got %s", path)
+	}
+}
+
+func TestLoadExpandsHomeDirectory(t *testing.T) {
+	home := isolate(t)
+	t.Setenv("MYFINANCE_DATA_DIR", "~/finance-data")
+
+	cfg, err := Load(nil)
+	if err != nil || cfg.Storage.DataDir != filepath.Join(home, "finance-data") {
+		t.Errorf("Expected the data directory in the home directory, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestLoadConfigFromEnvironment(t *testing.T) {
+	isolate(t)
+	t.Setenv("MYFINANCE_CONFIG", writeConfig(t, "storage:\n  backend: sqlite\n"))
+
+	cfg, err := Load(nil)
+	if err != nil || cfg.Storage.Backend != "sqlite" {
+		t.Errorf("Expected the file named in MYFINANCE_CONFIG to be read, got %+v (%v)", cfg, err)
+	}
+
+	t.Setenv("MYFINANCE_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := Load(nil); err == nil {
+		t.Error("Expected a missing configuration file to be an error when it is named")
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		args  []string
+		error string
+	}{
+		{name: "unknown key", file: "smtp:\n  hots: smtp.example.com\n", error: "field hots not found"},
+		{name: "invalid duration", env: map[string]string{"MYFINANCE_SCHEDULER_INTERVAL": "hourly"}, error: "MYFINANCE_SCHEDULER_INTERVAL must be a duration"},
+		{name: "invalid port", env: map[string]string{"MYFINANCE_SMTP_PORT": "smtp"}, error: "MYFINANCE_SMTP_PORT must be a number"},
+		{name: "unknown flag", args: []string{"-port", "80"}, error: "flag provided but not defined"},
+		{name: "unknown backend", args: []string{"-storage", "postgres"}, error: `storage backend "postgres"`},
+		{name: "address", args: []string{"-addr", "8081"}, error: "server address"},
+		{name: "origin", args: []string{"-cors-origins", "localhost:8080"}, error: "CORS origin"},
+		{name: "origin path", args: []string{"-cors-origins", "http://localhost:8080/app"}, error: "CORS origin"},
+		{name: "invalid boolean", env: map[string]string{"MYFINANCE_SMTP_CHECK_READY": "sometimes"}, error: "MYFINANCE_SMTP_CHECK_READY must be true or false"},
+		{name: "smtp without port", args: []string{"-smtp-host", "smtp.example.com"}, error: "SMTP port is required"},
+		{name: "smtp check without host", file: "smtp:\n  check_ready: true\n", error: "SMTP host is required"},
+		{name: "token lifetimes", file: "auth:\n  access_token_ttl: 2h\n  refresh_token_ttl: 1h\n", error: "refresh tokens cannot expire"},
+		{name: "interval", file: "scheduler:\n  interval: 0s\n", error: "scheduler interval must be positive"},
+		{name: "timeouts", file: "server:\n  write_timeout: -1s\n", error: "server timeouts cannot be negative"},
+		{name: "log level", env: map[string]string{"MYFINANCE_LOG_LEVEL": "verbose"}, error: `log level "verbose"`},
+		{name: "log format", file: "log:\n  format: xml\n", error: `log format "xml"`},
+		{name: "admin IDs", env: map[string]string{"MYFINANCE_ADMIN_USER_IDS": "1,admin"}, error: "MYFINANCE_ADMIN_USER_IDS must be a comma-separated list"},
+		{name: "admin ID", file: "auth:\n  admin_user_ids: [0]\n", error: "admin user ID 0 must be positive"},
+		{name: "shutdown timeout", env: map[string]string{"MYFINANCE_SHUTDOWN_TIMEOUT": "0s"}, error: "shutdown timeout must be positive"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isolate(t)
+			args := test.args
+			if test.file != "" {
+				args = append([]string{"-config", writeConfig(t, test.file)}, args...)
+			}
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			if _, err := Load(args); err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("Expected an error containing %q, got %v", test.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := defaults()
+	cfg.Server.Address = "nowhere"
+	cfg.Storage.Backend = "memory"
+	cfg.Scheduler.Interval = -time.Minute
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if problems := strings.Split(err.Error(), "\n"); len(problems) != 3 {
+		t.Errorf("Expected three problems, got %q", err)
+	}
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"net/http"
+	"strings"
+)
+
+type AccountHandler struct {
+	Accounts *service.AccountService
+}
+
+func NewAccountHandler(accounts *service.AccountService) *AccountHandler {
+	return &AccountHandler{Accounts: accounts}
+}
+
+// bindAccount reads and validates an account from the request body, failing
+// the request when the body is invalid
+func bindAccount(context *gin.Context) (model.Account, bool) {
+	var account model.Account
+	if !bindJSON(context, &account, "Opening date must be in the format yyyy-mm-dd") {
+		return account, false
+	}
+
+	account.Name = strings.TrimSpace(account.Name)
+	if account.Name == "" {
+		invalid(context, "Name is required")
+		return account, false
+	}
+	if !service.IsValidAccountType(account.Type) {
+		invalid(context, "Type must be one of "+strings.Join(model.AccountTypes, ", "))
+		return account, false
+	}
+	if account.Currency != "" {
+		currency, err := model.ParseCurrency(account.Currency)
+		if err != nil {
+			invalid(context, "Currency must be a three-letter code such as BRL or USD")
+			return account, false
+		}
+		account.Currency = currency
+	}
+	return account, true
+}
+
+// AddAccount godoc
+// @Summary Add an account
+// @Description Add an account, such as a checking account, credit card or wallet, for the authenticated user. The currency defaults to the user's base currency and the opening date to today.
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param account body model.Account true "Account object"
+// @Success 201 {object} model.Account
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /accounts [post]
+func (handler *AccountHandler) AddAccount(context *gin.Context) {
+	account, ok := bindAccount(context)
+	if !ok {
+		return
+	}
+
+	account.UserID = middleware.UserID(context)
+	account, err := handler.Accounts.AddAccount(account)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusCreated, account)
+}
+
+// GetAccounts godoc
+// @Summary List accounts
+// @Description List the authenticated user's accounts
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.Account
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /accounts [get]
+func (handler *AccountHandler) GetAccounts(context *gin.Context) {
+	accounts, err := handler.Accounts.ListAccounts(middleware.UserID(context))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	if accounts == nil {
+		accounts = []model.Account{}
+	}
+	context.JSON(http.StatusOK, accounts)
+}
+
+// GetAccount godoc
+// @Summary Get an account
+// @Description Get an account owned by the authenticated user
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Account ID"
+// @Success 200 {object} model.Account
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /accounts/{id} [get]
+func (handler *AccountHandler) GetAccount(context *gin.Context) {
+	account, err := handler.Accounts.GetAccount(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, account)
+}
+
+// UpdateAccount godoc
+// @Summary Update an account
+// @Description Update an account owned by the authenticated user. The currency cannot change once the account has transactions.
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Account ID"
+// @Param account body model.Account true "Updated account object"
+// @Success 200 {object} model.Account
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /accounts/{id} [put]
+func (handler *AccountHandler) UpdateAccount(context *gin.Context) {
+	updated, ok := bindAccount(context)
+	if !ok {
+		return
+	}
+
+	account, err := handler.Accounts.UpdateAccount(middleware.UserID(context), context.Param("id"), updated)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, account)
+}
+
+// DeleteAccount godoc
+// @Summary Delete an account
+// @Description Delete an account owned by the authenticated user. Accounts with transactions, or used by a recurring rule or an import profile, cannot be deleted.
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Account ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /accounts/{id} [delete]
+func (handler *AccountHandler) DeleteAccount(context *gin.Context) {
+	err := handler.Accounts.DeleteAccount(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
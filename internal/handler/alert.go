@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"net/http"
+	"strings"
+)
+
+type AlertHandler struct {
+	Alerts *service.AlertService
+}
+
+func NewAlertHandler(alerts *service.AlertService) *AlertHandler {
+	return &AlertHandler{Alerts: alerts}
+}
+
+// bindAlertRule reads and validates an alert rule from the request body,
+// failing the request when the body is invalid
+func bindAlertRule(context *gin.Context) (model.AlertRule, bool) {
+	var rule model.AlertRule
+	if !bindJSON(context, &rule, "") {
+		return rule, false
+	}
+
+	if !service.IsValidAlertKind(rule.Kind) {
+		invalid(context, "Kind must be one of "+strings.Join(model.AlertKinds, ", "))
+		return rule, false
+	}
+	rule.Category = strings.TrimSpace(rule.Category)
+	if rule.Kind == "category_limit" && rule.Category == "" {
+		invalid(context, "Category is required for category_limit alerts")
+		return rule, false
+	}
+	if rule.Kind != "low_balance" && rule.Threshold <= 0 {
+		invalid(context, "Threshold must be positive")
+		return rule, false
+	}
+	return rule, true
+}
+
+// AddRule godoc
+// @Summary Add an alert rule
+// @Description Add a rule that emails the authenticated user when a transaction they add or update makes a category's monthly expenses exceed the threshold (category_limit), is a single expense over the threshold (large_expense) or leaves the balance below the threshold (low_balance). Thresholds are in the user's base currency, and each rule fires at most once per month.
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rule body model.AlertRule true "Alert rule"
+// @Success 201 {object} model.AlertRule
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /alerts [post]
+func (handler *AlertHandler) AddRule(context *gin.Context) {
+	rule, ok := bindAlertRule(context)
+	if !ok {
+		return
+	}
+
+	rule.UserID = middleware.UserID(context)
+	rule, err := handler.Alerts.AddRule(rule)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusCreated, rule)
+}
+
+// GetRules godoc
+// @Summary List alert rules
+// @Description List the authenticated user's alert rules
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.AlertRule
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /alerts [get]
+func (handler *AlertHandler) GetRules(context *gin.Context) {
+	rules, err := handler.Alerts.ListRules(middleware.UserID(context))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	if rules == nil {
+		rules = []model.AlertRule{}
+	}
+	context.JSON(http.StatusOK, rules)
+}
+
+// GetRule godoc
+// @Summary Get an alert rule
+// @Description Get an alert rule owned by the authenticated user
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Success 200 {object} model.AlertRule
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /alerts/{id} [get]
+func (handler *AlertHandler) GetRule(context *gin.Context) {
+	rule, err := handler.Alerts.GetRule(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, rule)
+}
+
+// UpdateRule godoc
+// @Summary Update an alert rule
+// @Description Update an alert rule owned by the authenticated user. The updated rule can fire again this month.
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Param rule body model.AlertRule true "Updated alert rule"
+// @Success 200 {object} model.AlertRule
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /alerts/{id} [put]
+func (handler *AlertHandler) UpdateRule(context *gin.Context) {
+	updated, ok := bindAlertRule(context)
+	if !ok {
+		return
+	}
+
+	rule, err := handler.Alerts.UpdateRule(middleware.UserID(context), context.Param("id"), updated)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule godoc
+// @Summary Delete an alert rule
+// @Description Delete an alert rule owned by the authenticated user
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /alerts/{id} [delete]
+func (handler *AlertHandler) DeleteRule(context *gin.Context) {
+	err := handler.Alerts.DeleteRule(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted successfully"})
+}
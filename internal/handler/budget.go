@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type BudgetHandler struct {
+	Budgets *service.BudgetService
+}
+
+func NewBudgetHandler(budgets *service.BudgetService) *BudgetHandler {
+	return &BudgetHandler{Budgets: budgets}
+}
+
+// isValidMonth reports whether value is a month in the yyyy-mm format
+func isValidMonth(value string) bool {
+	_, err := time.Parse("2006-01", value)
+	return err == nil
+}
+
+// bindBudget reads and validates a budget from the request body, failing the
+// request when the body is invalid
+func bindBudget(context *gin.Context) (model.Budget, bool) {
+	var budget model.Budget
+	if !bindJSON(context, &budget, "") {
+		return budget, false
+	}
+
+	budget.Category = strings.TrimSpace(budget.Category)
+	if budget.Limit <= 0 {
+		invalid(context, "Limit must be positive")
+		return budget, false
+	}
+	if budget.StartMonth != "" && !isValidMonth(budget.StartMonth) {
+		invalid(context, "Start month must be in the format yyyy-mm")
+		return budget, false
+	}
+	return budget, true
+}
+
+// AddBudget godoc
+// @Summary Add a budget
+// @Description Add a monthly spending limit for a category, or for all expenses when the category is omitted. Limits are in the user's base currency and apply from start_month (default: the current month). With rollover, the unused part of each month is added to the next.
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param budget body model.Budget true "Budget object"
+// @Success 201 {object} model.Budget
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /budgets [post]
+func (handler *BudgetHandler) AddBudget(context *gin.Context) {
+	budget, ok := bindBudget(context)
+	if !ok {
+		return
+	}
+
+	budget.UserID = middleware.UserID(context)
+	budget, err := handler.Budgets.AddBudget(budget)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusCreated, budget)
+}
+
+// GetBudgets godoc
+// @Summary List budgets
+// @Description List the authenticated user's budgets
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.Budget
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /budgets [get]
+func (handler *BudgetHandler) GetBudgets(context *gin.Context) {
+	budgets, err := handler.Budgets.ListBudgets(middleware.UserID(context))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	if budgets == nil {
+		budgets = []model.Budget{}
+	}
+	context.JSON(http.StatusOK, budgets)
+}
+
+// GetBudgetStatus godoc
+// @Summary Get budget status
+// @Description Compare every budget with the expenses of a month: the limit, the amount carried over, what was spent and what remains, in the user's base currency
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param month query string false "Month (yyyy-mm), defaults to the current month"
+// @Success 200 {object} model.BudgetReport
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /budgets/status [get]
+func (handler *BudgetHandler) GetBudgetStatus(context *gin.Context) {
+	month := context.Query("month")
+	if month != "" && !isValidMonth(month) {
+		invalid(context, "Month must be in the format yyyy-mm")
+		return
+	}
+
+	report, err := handler.Budgets.Status(middleware.UserID(context), month)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, report)
+}
+
+// GetBudget godoc
+// @Summary Get a budget
+// @Description Get a budget owned by the authenticated user
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Budget ID"
+// @Success 200 {object} model.Budget
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /budgets/{id} [get]
+func (handler *BudgetHandler) GetBudget(context *gin.Context) {
+	budget, err := handler.Budgets.GetBudget(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, budget)
+}
+
+// UpdateBudget godoc
+// @Summary Update a budget
+// @Description Update a budget owned by the authenticated user
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Budget ID"
+// @Param budget body model.Budget true "Updated budget object"
+// @Success 200 {object} model.Budget
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /budgets/{id} [put]
+func (handler *BudgetHandler) UpdateBudget(context *gin.Context) {
+	updated, ok := bindBudget(context)
+	if !ok {
+		return
+	}
+
+	budget, err := handler.Budgets.UpdateBudget(middleware.UserID(context), context.Param("id"), updated)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, budget)
+}
+
+// DeleteBudget godoc
+// @Summary Delete a budget
+// @Description Delete a budget owned by the authenticated user
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Budget ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /budgets/{id} [delete]
+func (handler *BudgetHandler) DeleteBudget(context *gin.Context) {
+	err := handler.Budgets.DeleteBudget(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Budget deleted successfully"})
+}
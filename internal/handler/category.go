@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"net/http"
+	"strings"
+)
+
+type CategoryHandler struct {
+	Categories *service.CategoryService
+}
+
+func NewCategoryHandler(categories *service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{Categories: categories}
+}
+
+// bindCategory reads and validates a category from the request body, failing
+// the request when the body is invalid
+func bindCategory(context *gin.Context) (model.Category, bool) {
+	var category model.Category
+	if !bindJSON(context, &category, "") {
+		return category, false
+	}
+
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		invalid(context, "Name is required")
+		return category, false
+	}
+	if !service.IsValidCategoryType(category.Type) {
+		invalid(context, "Type must be 'income' or 'expense'")
+		return category, false
+	}
+	return category, true
+}
+
+// AddCategory godoc
+// @Summary Add a category
+// @Description Add a category to the authenticated user's catalog, optionally nested under a parent of the same type. Once a user has a category, transactions must use one of theirs.
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param category body model.Category true "Category object"
+// @Success 201 {object} model.Category
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /categories [post]
+func (handler *CategoryHandler) AddCategory(context *gin.Context) {
+	category, ok := bindCategory(context)
+	if !ok {
+		return
+	}
+
+	category.UserID = middleware.UserID(context)
+	category, err := handler.Categories.AddCategory(category)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusCreated, category)
+}
+
+// GetCategories godoc
+// @Summary List categories
+// @Description List the authenticated user's categories
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.Category
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /categories [get]
+func (handler *CategoryHandler) GetCategories(context *gin.Context) {
+	categories, err := handler.Categories.ListCategories(middleware.UserID(context))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	if categories == nil {
+		categories = []model.Category{}
+	}
+	context.JSON(http.StatusOK, categories)
+}
+
+// GetCategory godoc
+// @Summary Get a category
+// @Description Get a category owned by the authenticated user
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Category ID"
+// @Success 200 {object} model.Category
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /categories/{id} [get]
+func (handler *CategoryHandler) GetCategory(context *gin.Context) {
+	category, err := handler.Categories.GetCategory(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, category)
+}
+
+// UpdateCategory godoc
+// @Summary Update a category
+// @Description Update a category owned by the authenticated user. Renaming a category renames it on its transactions, recurring rules, budgets and alert rules.
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Category ID"
+// @Param category body model.Category true "Updated category object"
+// @Success 200 {object} model.Category
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /categories/{id} [put]
+func (handler *CategoryHandler) UpdateCategory(context *gin.Context) {
+	updated, ok := bindCategory(context)
+	if !ok {
+		return
+	}
+
+	category, err := handler.Categories.UpdateCategory(middleware.UserID(context), context.Param("id"), updated)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Delete a category that has no subcategories and is not used by any transaction or recurring rule
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Category ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /categories/{id} [delete]
+func (handler *CategoryHandler) DeleteCategory(context *gin.Context) {
+	err := handler.Categories.DeleteCategory(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// MergeCategory godoc
+// @Summary Merge a category into another
+// @Description Move the category's transactions, recurring rules, budgets, alert rules and subcategories to another category of the same type, then delete it. A budget of the category is dropped when the target has its own.
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Category ID"
+// @Param mergeInfo body object true "Target category, e.g. {\"into_id\": 3}"
+// @Success 200 {object} model.Category
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /categories/{id}/merge [post]
+func (handler *CategoryHandler) MergeCategory(context *gin.Context) {
+	var mergeInfo struct {
+		IntoID int `json:"into_id" binding:"required"`
+	}
+
+	if err := context.ShouldBindJSON(&mergeInfo); err != nil {
+		fail(context, service.ErrInvalidInput)
+		return
+	}
+
+	category, err := handler.Categories.MergeCategory(middleware.UserID(context), context.Param("id"), mergeInfo.IntoID)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, category)
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"io"
+	"net/http"
+)
+
+type CurrencyHandler struct {
+	Currencies *service.CurrencyService
+}
+
+func NewCurrencyHandler(currencies *service.CurrencyService) *CurrencyHandler {
+	return &CurrencyHandler{Currencies: currencies}
+}
+
+// ImportRates godoc
+// @Summary Import exchange rates
+// @Description Import dated exchange rates from a CSV file with the header date,from,to,rate. The file can be sent as the "file" field of a multipart form or as the raw request body. Rates already stored for the same date and pair are replaced; nothing is imported if any line is invalid. Rates are shared by every user, so only administrators can import them.
+// @Tags currencies
+// @Accept text/csv
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file false "CSV file"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /exchange-rates/import [post]
+func (handler *CurrencyHandler) ImportRates(context *gin.Context) {
+	var reader io.Reader = context.Request.Body
+	if file, err := context.FormFile("file"); err == nil {
+		opened, err := file.Open()
+		if err != nil {
+			invalid(context, "Failed to read uploaded file")
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	}
+
+	imported, err := handler.Currencies.ImportRatesCSV(reader)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message":  "Exchange rates imported successfully",
+		"imported": imported,
+	})
+}
+
+// GetRates godoc
+// @Summary List exchange rates
+// @Description List the stored exchange rates ordered by date, optionally restricted to a currency pair
+// @Tags currencies
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Source currency, e.g. USD"
+// @Param to query string false "Target currency, e.g. BRL"
+// @Success 200 {array} model.ExchangeRate
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /exchange-rates [get]
+func (handler *CurrencyHandler) GetRates(context *gin.Context) {
+	from, to := context.Query("from"), context.Query("to")
+	var err error
+	if from != "" {
+		if from, err = model.ParseCurrency(from); err != nil {
+			invalid(context, "from must be a three-letter currency code")
+			return
+		}
+	}
+	if to != "" {
+		if to, err = model.ParseCurrency(to); err != nil {
+			invalid(context, "to must be a three-letter currency code")
+			return
+		}
+	}
+
+	rates, err := handler.Currencies.ListRates(from, to)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	if rates == nil {
+		rates = []model.ExchangeRate{}
+	}
+	context.JSON(http.StatusOK, rates)
+}
@@ -23,20 +23,20 @@ func NewEmailHandler(emailService *service.EmailService) *EmailHandler {
 // @Produce json
 // @Param emailData body model.EmailData true "Email data"
 // @Success 200 {object} map[string]string "message":"Email sent successfully"
-// @Failure 400 {object} map[string]string "error":"Invalid email data"
-// @Failure 500 {object} map[string]string "error":"Failed to send email"
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
 // @Router /send-email [post]
 func (h *EmailHandler) SendEmail(c *gin.Context) {
 	var emailData model.EmailData
 
 	if err := c.ShouldBindJSON(&emailData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email data"})
+		invalid(c, "Invalid email data")
 		return
 	}
 
 	err := h.emailService.SendEmail(emailData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email"})
+		fail(c, err)
 		return
 	}
 
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"strings"
+)
+
+// fail hands err to middleware.Errors, which writes the error response
+func fail(context *gin.Context, err error) {
+	_ = context.Error(err)
+}
+
+// invalid fails the request with an invalid input error described by message
+func invalid(context *gin.Context, message string) {
+	fail(context, service.ErrInvalidInput.WithMessage(message))
+}
+
+// bindJSON reads the request body into target, failing the request when it
+// cannot. dateMessage describes the expected date format for bodies with
+// dates and is empty for the others.
+func bindJSON(context *gin.Context, target any, dateMessage string) bool {
+	err := context.ShouldBindJSON(target)
+	if err == nil {
+		return true
+	}
+
+	var syntaxErr *json.SyntaxError
+	var unmarshalTypeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr) || errors.As(err, &unmarshalTypeErr):
+		fail(context, service.ErrInvalidJSON)
+	case dateMessage != "" && strings.Contains(err.Error(), "date"):
+		invalid(context, dateMessage)
+	default:
+		fail(context, service.ErrInvalidInput)
+	}
+	return false
+}
@@ -1,14 +1,16 @@
 package handler
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
 	"net/http"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type FinanceHandle struct {
@@ -21,140 +23,327 @@ func NewFinanceHandler(finance *service.FinanceService) *FinanceHandle {
 
 // AddTransaction godoc
 // @Summary Add a new transaction
-// @Description Add a new financial transaction for a user
+// @Description Add a new financial transaction for the authenticated user, optionally in one of their accounts. Without a currency the transaction is recorded in the account's currency, or in the user's base currency when it has no account. A "transfer" moves money from account_id to to_account_id: it is stored as a linked pair of transactions, converted to the destination currency when needed, and the source half is returned.
 // @Tags finance
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param transaction body model.Transaction true "Transaction object"
 // @Success 201 {object} model.Transaction
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
 // @Router /transactions [post]
 func (handler *FinanceHandle) AddTransaction(context *gin.Context) {
 	var transaction model.Transaction
-	if err := context.ShouldBindJSON(&transaction); err != nil {
-		var syntaxErr *json.SyntaxError
-		var unmarshalTypeErr *json.UnmarshalTypeError
-
-		if errors.As(err, &syntaxErr) || errors.As(err, &unmarshalTypeErr) {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON syntax"})
-			return
-		}
+	if !bindJSON(context, &transaction, "Date must be in the format yyyy-mm-dd") {
+		return
+	}
 
-		if strings.Contains(err.Error(), "date") {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Date must be in the format yyyy-mm-dd"})
+	if !isValidTransactionType(transaction.Type) {
+		invalid(context, "Type must be 'income', 'expense' or 'transfer'")
+		return
+	}
+	if transaction.Currency != "" {
+		currency, err := model.ParseCurrency(transaction.Currency)
+		if err != nil {
+			invalid(context, "Currency must be a three-letter code such as BRL or USD")
 			return
 		}
-
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
-		return
+		transaction.Currency = currency
 	}
-
-	if transaction.Type != "income" && transaction.Type != "expense" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Type must be 'income' or 'expense'"})
+	if err := validateTags(transaction.Tags); err != nil {
+		invalid(context, err.Error())
 		return
 	}
 
+	transaction.UserID = middleware.UserID(context)
+	// Only the scheduler links transactions to recurring rules, and only
+	// imports carry the bank's identifier
+	transaction.RecurringID, transaction.ExternalID = 0, ""
 	transaction, err := handler.Finance.AddTransaction(transaction)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add transaction"})
+		fail(context, err)
 		return
 	}
 	context.JSON(http.StatusCreated, transaction)
 }
 
+const (
+	maxTags      = 20
+	maxTagLength = 50
+)
+
+// validateTags limits the number and length of tags. Commas are rejected
+// because they separate tags in the tags query parameter.
+func validateTags(tags []string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("A transaction can have at most %d tags", maxTags)
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(strings.TrimSpace(tag)) > maxTagLength {
+			return fmt.Errorf("Tags can have at most %d characters", maxTagLength)
+		}
+		if strings.Contains(tag, ",") {
+			return errors.New("Tags cannot contain commas")
+		}
+	}
+	return nil
+}
+
+func isValidTransactionType(transactionType string) bool {
+	return transactionType == "income" || transactionType == "expense" || transactionType == "transfer"
+}
+
 // GetTransactions godoc
-// @Summary Get user transactions
-// @Description Get all transactions for a specific user
+// @Summary List user transactions
+// @Description List the authenticated user's transactions with optional filters, sorting and pagination
 // @Tags finance
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Success 200 {array} model.Transaction
-// @Failure 400 {object} map[string]string
-// @Router /transactions/{userId} [get]
+// @Security BearerAuth
+// @Param from query string false "Start date (yyyy-mm-dd), inclusive"
+// @Param to query string false "End date (yyyy-mm-dd), inclusive"
+// @Param type query string false "Transaction type" Enums(income, expense, transfer)
+// @Param category query string false "Category (case-insensitive)"
+// @Param account_id query int false "Account ID"
+// @Param tags query string false "Comma-separated tags (case-insensitive)"
+// @Param tag_match query string false "Keep transactions with any of the tags (default) or all of them" Enums(any, all)
+// @Param min_amount query number false "Minimum amount"
+// @Param max_amount query number false "Maximum amount"
+// @Param q query string false "Text to search for in the description"
+// @Param sort query string false "Sort field" Enums(date, amount, category, type, description, id)
+// @Param order query string false "Sort direction (defaults to desc for date, asc otherwise)" Enums(asc, desc)
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Number of transactions to skip"
+// @Success 200 {object} model.TransactionPage
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /transactions [get]
 func (handler *FinanceHandle) GetTransactions(context *gin.Context) {
-	userIDStr := context.Param("userId")
-	userID, err := strconv.Atoi(userIDStr)
+	query, err := parseTransactionQuery(context)
+	if err != nil {
+		invalid(context, err.Error())
+		return
+	}
+
+	page, err := handler.Finance.ListTransactions(middleware.UserID(context), query)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, page)
+}
+
+// exportContentTypes maps each of model.ExportFormats to the content type
+// of its files
+var exportContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/json; charset=utf-8",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ExportTransactions godoc
+// @Summary Export transactions
+// @Description Download the authenticated user's transactions as a CSV, JSON or XLSX file, oldest first. Every row carries the running balance after it, converted to the user's base currency and continuing from the balance before the period. Split transactions take one row per category. The file is streamed, so large exports start downloading right away.
+// @Tags finance
+// @Produce text/csv
+// @Produce json
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param format query string false "File format (default csv)" Enums(csv, json, xlsx)
+// @Param from query string false "Start date (yyyy-mm-dd), inclusive"
+// @Param to query string false "End date (yyyy-mm-dd), inclusive"
+// @Param type query string false "Transaction type" Enums(income, expense, transfer)
+// @Param category query string false "Category (case-insensitive)"
+// @Param account_id query int false "Account ID"
+// @Param tags query string false "Comma-separated tags (case-insensitive)"
+// @Param tag_match query string false "Keep transactions with any of the tags (default) or all of them" Enums(any, all)
+// @Success 200 {object} model.TransactionExport "The JSON format; CSV and XLSX files have the same columns as a transaction plus balance and balance_currency"
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /transactions/export [get]
+func (handler *FinanceHandle) ExportTransactions(context *gin.Context) {
+	format := context.DefaultQuery("format", "csv")
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		invalid(context, "format must be 'csv', 'json' or 'xlsx'")
+		return
+	}
+	filter, err := parseTransactionFilter(context)
+	if err != nil {
+		invalid(context, err.Error())
+		return
+	}
 
+	export, err := handler.Finance.ExportTransactions(middleware.UserID(context), filter)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		fail(context, err)
 		return
 	}
 
-	transactions := handler.Finance.GetTransactionByUserId(userID)
-	context.JSON(http.StatusOK, transactions)
+	context.Header("Content-Type", contentType)
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions.%s"`, format))
+	context.Status(http.StatusOK)
+	if err := service.WriteExport(context.Writer, format, export); err != nil {
+		// The download has already started and is cut short; the error is
+		// left for the logger
+		_ = context.Error(err)
+	}
+}
+
+// parseTransactionFilter reads the date range, type, category, account and tag query parameters
+func parseTransactionFilter(context *gin.Context) (model.TransactionFilter, error) {
+	var filter model.TransactionFilter
+	var err error
+
+	if from := context.Query("from"); from != "" {
+		if filter.From, err = model.ParseDateOnly(from); err != nil {
+			return filter, errors.New("from must be in the format yyyy-mm-dd")
+		}
+	}
+	if to := context.Query("to"); to != "" {
+		if filter.To, err = model.ParseDateOnly(to); err != nil {
+			return filter, errors.New("to must be in the format yyyy-mm-dd")
+		}
+	}
+	filter.Type = context.Query("type")
+	if filter.Type != "" && !isValidTransactionType(filter.Type) {
+		return filter, errors.New("type must be 'income', 'expense' or 'transfer'")
+	}
+	filter.Category = context.Query("category")
+	if value := context.Query("account_id"); value != "" {
+		if filter.AccountID, err = strconv.Atoi(value); err != nil || filter.AccountID < 1 {
+			return filter, errors.New("account_id must be a positive integer")
+		}
+	}
+	if value := context.Query("tags"); value != "" {
+		filter.Tags = model.NormalizeTags(strings.Split(value, ","))
+	}
+	switch context.Query("tag_match") {
+	case "", "any":
+	case "all":
+		filter.AllTags = true
+	default:
+		return filter, errors.New("tag_match must be 'any' or 'all'")
+	}
+	return filter, nil
+}
+
+// parseTransactionQuery reads the filter, search, sort and pagination query parameters
+func parseTransactionQuery(context *gin.Context) (model.TransactionQuery, error) {
+	filter, err := parseTransactionFilter(context)
+	if err != nil {
+		return model.TransactionQuery{}, err
+	}
+	query := model.TransactionQuery{
+		TransactionFilter: filter,
+		Search:            context.Query("q"),
+		SortBy:            context.Query("sort"),
+		SortOrder:         context.Query("order"),
+	}
+
+	if value := context.Query("min_amount"); value != "" {
+		amount, err := model.ParseMoney(value)
+		if err != nil {
+			return query, errors.New("min_amount must be a number")
+		}
+		query.MinAmount = &amount
+	}
+	if value := context.Query("max_amount"); value != "" {
+		amount, err := model.ParseMoney(value)
+		if err != nil {
+			return query, errors.New("max_amount must be a number")
+		}
+		query.MaxAmount = &amount
+	}
+	if query.SortBy != "" && !service.IsValidSortField(query.SortBy) {
+		return query, errors.New("sort must be one of date, amount, category, type, description, id")
+	}
+	if query.SortOrder != "" && query.SortOrder != "asc" && query.SortOrder != "desc" {
+		return query, errors.New("order must be 'asc' or 'desc'")
+	}
+	if value := context.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 {
+			return query, errors.New("limit must be a positive integer")
+		}
+	}
+	if value := context.Query("offset"); value != "" {
+		if query.Offset, err = strconv.Atoi(value); err != nil || query.Offset < 0 {
+			return query, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return query, nil
 }
 
 // GetBalance godoc
 // @Summary Get user balance
-// @Description Get the current balance for a specific user
+// @Description Get the current balance for the authenticated user converted to their base currency, together with the balance of each currency and of each account. Account opening balances are included.
 // @Tags finance
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Success 200 {object} map[string]float64
-// @Failure 400 {object} map[string]string
-// @Router /balance/{userId} [get]
+// @Security BearerAuth
+// @Success 200 {object} model.Balance
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /balance [get]
 func (handler *FinanceHandle) GetBalance(context *gin.Context) {
-	userIDStr := context.Param("userId")
-	userID, err := strconv.Atoi(userIDStr)
-
+	balance, err := handler.Finance.GetBalanceByUserId(middleware.UserID(context))
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		fail(context, err)
 		return
 	}
-
-	balance := handler.Finance.GetBalanceByUserId(userID)
-	context.JSON(http.StatusOK, gin.H{"balance": balance})
+	context.JSON(http.StatusOK, balance)
 }
 
 // UpdateTransaction godoc
 // @Summary Update a transaction
-// @Description Update an existing financial transaction
+// @Description Update an existing financial transaction owned by the authenticated user. Updating either half of a transfer rewrites both; for transfers account_id is the source and to_account_id the destination, and both keep their current value when left out.
 // @Tags finance
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "Transaction ID"
 // @Param transaction body model.Transaction true "Updated transaction object"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
 // @Router /transactions/{id} [put]
 func (handler *FinanceHandle) UpdateTransaction(context *gin.Context) {
 	id := context.Param("id")
 	var updatedTransaction model.Transaction
-	if err := context.ShouldBindJSON(&updatedTransaction); err != nil {
-		var syntaxErr *json.SyntaxError
-		var unmarshalTypeErr *json.UnmarshalTypeError
-
-		if errors.As(err, &syntaxErr) || errors.As(err, &unmarshalTypeErr) {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON syntax"})
-			return
-		}
+	if !bindJSON(context, &updatedTransaction, "Date must be in the format yyyy-mm-dd") {
+		return
+	}
 
-		if strings.Contains(err.Error(), "date") {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Date must be in the format yyyy-mm-dd"})
+	if !isValidTransactionType(updatedTransaction.Type) {
+		invalid(context, "Type must be 'income', 'expense' or 'transfer'")
+		return
+	}
+	if updatedTransaction.Currency != "" {
+		currency, err := model.ParseCurrency(updatedTransaction.Currency)
+		if err != nil {
+			invalid(context, "Currency must be a three-letter code such as BRL or USD")
 			return
 		}
-
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
-		return
+		updatedTransaction.Currency = currency
 	}
-
-	if updatedTransaction.Type != "income" && updatedTransaction.Type != "expense" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Type must be 'income' or 'expense'"})
+	if err := validateTags(updatedTransaction.Tags); err != nil {
+		invalid(context, err.Error())
 		return
 	}
 
-	err := handler.Finance.UpdateTransaction(id, updatedTransaction)
+	err := handler.Finance.UpdateTransaction(middleware.UserID(context), id, updatedTransaction)
 	if err != nil {
-		if err.Error() == "transaction not found" {
-			context.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
-		} else {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction"})
-		}
+		fail(context, err)
 		return
 	}
 
@@ -163,30 +352,29 @@ func (handler *FinanceHandle) UpdateTransaction(context *gin.Context) {
 
 // DeleteTransaction godoc
 // @Summary Delete a transaction
-// @Description Delete an existing financial transaction
+// @Description Delete an existing financial transaction owned by the authenticated user. Deleting either half of a transfer deletes both.
 // @Tags finance
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "Transaction ID"
 // @Success 200 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
 // @Router /transactions/{id} [delete]
 func (handler *FinanceHandle) DeleteTransaction(context *gin.Context) {
 	id := context.Param("id")
 	_, err := strconv.Atoi(id)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		invalid(context, "Invalid transaction ID")
 		return
 	}
 
-	err = handler.Finance.DeleteTransaction(id)
+	err = handler.Finance.DeleteTransaction(middleware.UserID(context), id)
 	if err != nil {
-		if err.Error() == "transaction not found" {
-			context.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
-		} else {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transaction"})
-		}
+		fail(context, err)
 		return
 	}
 
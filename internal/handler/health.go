@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"net/http"
+)
+
+// HealthHandler serves the probes of orchestrators and load balancers. They
+// live outside /api/v1 and are left out of the API documentation.
+type HealthHandler struct {
+	healthService *service.HealthService
+}
+
+func NewHealthHandler(healthService *service.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
+}
+
+// Live answers as long as the process is up and serving requests
+func (handler *HealthHandler) Live(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready answers 200 when the storage, and the SMTP server if configured, can
+// be used and 503 with the failing checks otherwise
+func (handler *HealthHandler) Ready(context *gin.Context) {
+	readiness := handler.healthService.Ready(context.Request.Context())
+	status := http.StatusOK
+	if readiness.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	context.JSON(status, readiness)
+}
@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxImportSize bounds the size of an uploaded bank statement
+const maxImportSize = 5 << 20
+
+type ImportHandler struct {
+	Imports *service.ImportService
+}
+
+func NewImportHandler(imports *service.ImportService) *ImportHandler {
+	return &ImportHandler{Imports: imports}
+}
+
+// bindImportProfile reads and validates an import profile from the request
+// body, failing the request when the body is invalid
+func bindImportProfile(context *gin.Context) (model.ImportProfile, bool) {
+	var profile model.ImportProfile
+	if !bindJSON(context, &profile, "") {
+		return profile, false
+	}
+
+	if strings.TrimSpace(profile.Name) == "" {
+		invalid(context, "Name is required")
+		return profile, false
+	}
+	if strings.TrimSpace(profile.DateColumn) == "" || strings.TrimSpace(profile.AmountColumn) == "" {
+		invalid(context, "date_column and amount_column are required")
+		return profile, false
+	}
+	return profile, true
+}
+
+// AddProfile godoc
+// @Summary Add an import profile
+// @Description Save how a bank's CSV export is laid out: the delimiter, the header columns holding the date, amount, description and category, the date format (dd, mm and yyyy), the decimal separator and whether negative amounts are expenses (negative_expense) or income (positive_expense). Defaults are ",", "yyyy-mm-dd", "." and negative_expense.
+// @Tags imports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param profile body model.ImportProfile true "Import profile object"
+// @Success 201 {object} model.ImportProfile
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /imports/profiles [post]
+func (handler *ImportHandler) AddProfile(context *gin.Context) {
+	profile, ok := bindImportProfile(context)
+	if !ok {
+		return
+	}
+
+	profile.UserID = middleware.UserID(context)
+	profile, err := handler.Imports.AddProfile(profile)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusCreated, profile)
+}
+
+// GetProfiles godoc
+// @Summary List import profiles
+// @Description List the authenticated user's import profiles
+// @Tags imports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.ImportProfile
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /imports/profiles [get]
+func (handler *ImportHandler) GetProfiles(context *gin.Context) {
+	profiles, err := handler.Imports.ListProfiles(middleware.UserID(context))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	if profiles == nil {
+		profiles = []model.ImportProfile{}
+	}
+	context.JSON(http.StatusOK, profiles)
+}
+
+// GetProfile godoc
+// @Summary Get an import profile
+// @Description Get an import profile owned by the authenticated user
+// @Tags imports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Import profile ID"
+// @Success 200 {object} model.ImportProfile
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /imports/profiles/{id} [get]
+func (handler *ImportHandler) GetProfile(context *gin.Context) {
+	profile, err := handler.Imports.GetProfile(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, profile)
+}
+
+// UpdateProfile godoc
+// @Summary Update an import profile
+// @Description Replace an import profile owned by the authenticated user
+// @Tags imports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Import profile ID"
+// @Param profile body model.ImportProfile true "Updated import profile object"
+// @Success 200 {object} model.ImportProfile
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /imports/profiles/{id} [put]
+func (handler *ImportHandler) UpdateProfile(context *gin.Context) {
+	updated, ok := bindImportProfile(context)
+	if !ok {
+		return
+	}
+
+	profile, err := handler.Imports.UpdateProfile(middleware.UserID(context), context.Param("id"), updated)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, profile)
+}
+
+// DeleteProfile godoc
+// @Summary Delete an import profile
+// @Description Delete an import profile owned by the authenticated user
+// @Tags imports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Import profile ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /imports/profiles/{id} [delete]
+func (handler *ImportHandler) DeleteProfile(context *gin.Context) {
+	err := handler.Imports.DeleteProfile(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Import profile deleted successfully"})
+}
+
+// ImportCSV godoc
+// @Summary Import a CSV bank statement
+// @Description Read a bank statement with one of the user's import profiles. The file can be sent as the "file" field of a multipart form or as the raw request body. By default the parsed rows are only returned for review, each with its transaction or the error that kept it from being read, and with duplicate_of set when it probably repeats an existing transaction (same date, type, amount and currency). With commit=true the rows are saved together, and only if none has an error; duplicates are left out unless include_duplicates=true.
+// @Tags imports
+// @Accept text/csv
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param profile_id query int true "Import profile ID"
+// @Param commit query bool false "Save the rows instead of previewing them"
+// @Param include_duplicates query bool false "Also save rows flagged as duplicates"
+// @Param file formData file false "CSV file"
+// @Success 200 {object} model.ImportResult
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 413 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /imports/csv [post]
+func (handler *ImportHandler) ImportCSV(context *gin.Context) {
+	profileID := context.Query("profile_id")
+	if profileID == "" {
+		invalid(context, "profile_id is required")
+		return
+	}
+	commit, includeDuplicates, ok := importFlags(context)
+	if !ok {
+		return
+	}
+
+	reader, ok := statementReader(context)
+	if !ok {
+		return
+	}
+	defer reader.Close()
+
+	result, err := handler.Imports.ImportCSV(middleware.UserID(context), profileID, reader, commit, includeDuplicates)
+	respondImportResult(context, result, err)
+}
+
+// ImportOFX godoc
+// @Summary Import an OFX or QFX statement
+// @Description Read the transactions of an OFX or QFX bank statement (OFX 1.x SGML or 2.x XML), optionally into one of the user's accounts. The file can be sent as the "file" field of a multipart form or as the raw request body. Negative amounts become expenses and positive amounts income, in the statement's currency. Transactions keep their FITID as external_id: rows already imported into the same account with the same FITID are flagged with already_imported and left out like other duplicates, so overlapping statements can be imported safely. Preview, commit and duplicate handling work as in the CSV import.
+// @Tags imports
+// @Accept application/x-ofx
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param account_id query int false "Account ID"
+// @Param commit query bool false "Save the rows instead of previewing them"
+// @Param include_duplicates query bool false "Also save rows flagged as likely duplicates"
+// @Param file formData file false "OFX or QFX file"
+// @Success 200 {object} model.ImportResult
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 413 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /transactions/import/ofx [post]
+func (handler *ImportHandler) ImportOFX(context *gin.Context) {
+	var accountID int
+	if value := context.Query("account_id"); value != "" {
+		var err error
+		if accountID, err = strconv.Atoi(value); err != nil || accountID < 1 {
+			invalid(context, "account_id must be a positive integer")
+			return
+		}
+	}
+	commit, includeDuplicates, ok := importFlags(context)
+	if !ok {
+		return
+	}
+
+	reader, ok := statementReader(context)
+	if !ok {
+		return
+	}
+	defer reader.Close()
+
+	result, err := handler.Imports.ImportOFX(middleware.UserID(context), accountID, reader, commit, includeDuplicates)
+	respondImportResult(context, result, err)
+}
+
+// importFlags reads the commit and include_duplicates query parameters, both
+// false when left out. It answers the request itself when either is not a
+// boolean.
+func importFlags(context *gin.Context) (commit, includeDuplicates, ok bool) {
+	if commit, ok = queryBool(context, "commit"); !ok {
+		return false, false, false
+	}
+	if includeDuplicates, ok = queryBool(context, "include_duplicates"); !ok {
+		return false, false, false
+	}
+	return commit, includeDuplicates, true
+}
+
+// queryBool reads an optional boolean query parameter
+func queryBool(context *gin.Context, name string) (value, ok bool) {
+	query := context.Query(name)
+	if query == "" {
+		return false, true
+	}
+	value, err := strconv.ParseBool(query)
+	if err != nil {
+		invalid(context, name+" must be true or false")
+		return false, false
+	}
+	return value, true
+}
+
+// statementReader returns the uploaded statement, limited to maxImportSize:
+// the "file" field of a multipart form, or else the raw request body. It
+// fails the request when the upload cannot be read.
+func statementReader(context *gin.Context) (io.ReadCloser, bool) {
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, maxImportSize)
+	// Only multipart forms carry a file field; parsing any other body as a
+	// form would consume it
+	if !strings.HasPrefix(context.ContentType(), "multipart/") {
+		return context.Request.Body, true
+	}
+	file, err := context.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		fail(context, fileTooLarge(err))
+		return nil, false
+	}
+	if err != nil {
+		invalid(context, "A statement file is required in the 'file' field")
+		return nil, false
+	}
+	opened, err := file.Open()
+	if err != nil {
+		invalid(context, "Failed to read uploaded file")
+		return nil, false
+	}
+	return opened, true
+}
+
+// fileTooLarge turns the error of an upload over maxImportSize into
+// service.ErrFileTooLarge, returning other errors unchanged
+func fileTooLarge(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return service.ErrFileTooLarge.WithMessage("The file must not exceed 5 MB")
+	}
+	return err
+}
+
+// respondImportResult writes the result of a statement import. When some rows
+// are invalid the error carries the result as its details.
+func respondImportResult(context *gin.Context, result model.ImportResult, err error) {
+	if err != nil {
+		fail(context, fileTooLarge(err))
+		return
+	}
+	context.JSON(http.StatusOK, result)
+}
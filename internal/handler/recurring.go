@@ -0,0 +1,325 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type RecurringHandler struct {
+	Recurring *service.RecurringService
+}
+
+func NewRecurringHandler(recurring *service.RecurringService) *RecurringHandler {
+	return &RecurringHandler{Recurring: recurring}
+}
+
+// bindRecurringRule reads and validates a rule from the request body, failing
+// the request when the body is invalid
+func bindRecurringRule(context *gin.Context) (model.RecurringRule, bool) {
+	var rule model.RecurringRule
+	if !bindJSON(context, &rule, "Dates must be in the format yyyy-mm-dd") {
+		return rule, false
+	}
+
+	if !service.IsValidFrequency(rule.Frequency) {
+		invalid(context, "Frequency must be one of "+strings.Join(model.RecurringFrequencies, ", "))
+		return rule, false
+	}
+	if rule.StartDate.IsZero() {
+		invalid(context, "Start date is required")
+		return rule, false
+	}
+	if rule.Interval < 0 || rule.Count < 0 {
+		invalid(context, "Interval and count cannot be negative")
+		return rule, false
+	}
+	if !isValidTransactionType(rule.Type) {
+		invalid(context, "Invalid transaction type")
+		return rule, false
+	}
+	if rule.Amount <= 0 {
+		invalid(context, "Amount must be positive")
+		return rule, false
+	}
+	if rule.Currency != "" {
+		currency, err := model.ParseCurrency(rule.Currency)
+		if err != nil {
+			invalid(context, "Currency must be a three-letter code such as BRL or USD")
+			return rule, false
+		}
+		rule.Currency = currency
+	}
+	return rule, true
+}
+
+// occurrenceDate reads the occurrence date from the path, failing the request
+// when it is invalid
+func occurrenceDate(context *gin.Context) (model.DateOnly, bool) {
+	date, err := model.ParseDateOnly(context.Param("date"))
+	if err != nil || date.IsZero() {
+		invalid(context, "Occurrence date must be in the format yyyy-mm-dd")
+		return model.DateOnly{}, false
+	}
+	return date, true
+}
+
+// AddRule godoc
+// @Summary Add a recurring rule
+// @Description Add a rule that creates a transaction on a schedule, starting on start_date and repeating every interval days, weeks, months or years until end_date or count occurrences. Monthly and yearly rules fall on the last day of shorter months. Due occurrences are created in the background.
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rule body model.RecurringRule true "Recurring rule"
+// @Success 201 {object} model.RecurringRule
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /recurring [post]
+func (handler *RecurringHandler) AddRule(context *gin.Context) {
+	rule, ok := bindRecurringRule(context)
+	if !ok {
+		return
+	}
+
+	rule.UserID = middleware.UserID(context)
+	rule, err := handler.Recurring.AddRule(rule)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusCreated, rule)
+}
+
+// GetRules godoc
+// @Summary List recurring rules
+// @Description List the authenticated user's recurring rules
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.RecurringRule
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /recurring [get]
+func (handler *RecurringHandler) GetRules(context *gin.Context) {
+	rules, err := handler.Recurring.ListRules(middleware.UserID(context))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	if rules == nil {
+		rules = []model.RecurringRule{}
+	}
+	context.JSON(http.StatusOK, rules)
+}
+
+// GetRule godoc
+// @Summary Get a recurring rule
+// @Description Get a recurring rule owned by the authenticated user
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Success 200 {object} model.RecurringRule
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /recurring/{id} [get]
+func (handler *RecurringHandler) GetRule(context *gin.Context) {
+	rule, err := handler.Recurring.GetRule(middleware.UserID(context), context.Param("id"))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, rule)
+}
+
+// UpdateRule godoc
+// @Summary Update a recurring rule
+// @Description Replace the schedule and transaction of a recurring rule. Transactions already created are not changed.
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Param rule body model.RecurringRule true "Updated recurring rule"
+// @Success 200 {object} model.RecurringRule
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /recurring/{id} [put]
+func (handler *RecurringHandler) UpdateRule(context *gin.Context) {
+	updated, ok := bindRecurringRule(context)
+	if !ok {
+		return
+	}
+
+	rule, err := handler.Recurring.UpdateRule(middleware.UserID(context), context.Param("id"), updated)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule godoc
+// @Summary Delete a recurring rule
+// @Description Delete a recurring rule. Transactions it already created are kept.
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /recurring/{id} [delete]
+func (handler *RecurringHandler) DeleteRule(context *gin.Context) {
+	if err := handler.Recurring.DeleteRule(middleware.UserID(context), context.Param("id")); err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Recurring rule deleted successfully"})
+}
+
+// PreviewRule godoc
+// @Summary Preview upcoming occurrences
+// @Description List the next occurrences of a recurring rule that have not been created yet, with their edits applied. Skipped occurrences are included and flagged.
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Param limit query int false "Number of occurrences (default 12, max 366)"
+// @Success 200 {array} model.Occurrence
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /recurring/{id}/preview [get]
+func (handler *RecurringHandler) PreviewRule(context *gin.Context) {
+	limit := 0
+	if value := context.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			invalid(context, "Limit must be a non-negative integer")
+			return
+		}
+		limit = parsed
+	}
+
+	occurrences, err := handler.Recurring.Preview(middleware.UserID(context), context.Param("id"), limit)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, occurrences)
+}
+
+// EditOccurrence godoc
+// @Summary Edit a single occurrence
+// @Description Change the amount, category or description of one pending occurrence of a recurring rule, or skip it, without changing the rule. Replaces any earlier edit of that occurrence.
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Param date path string true "Occurrence date (yyyy-mm-dd)"
+// @Param occurrence body model.OccurrenceOverride true "Fields to change; the date is taken from the path"
+// @Success 200 {object} model.RecurringRule
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /recurring/{id}/occurrences/{date} [put]
+func (handler *RecurringHandler) EditOccurrence(context *gin.Context) {
+	date, ok := occurrenceDate(context)
+	if !ok {
+		return
+	}
+	var override model.OccurrenceOverride
+	if err := context.ShouldBindJSON(&override); err != nil {
+		fail(context, service.ErrInvalidJSON)
+		return
+	}
+	if override.Amount != nil && *override.Amount <= 0 {
+		invalid(context, "Amount must be positive")
+		return
+	}
+	override.Date = date
+
+	rule, err := handler.Recurring.SetOverride(middleware.UserID(context), context.Param("id"), override)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, rule)
+}
+
+// SkipOccurrence godoc
+// @Summary Skip a single occurrence
+// @Description Skip one pending occurrence of a recurring rule so no transaction is created for it
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Param date path string true "Occurrence date (yyyy-mm-dd)"
+// @Success 200 {object} model.RecurringRule
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /recurring/{id}/occurrences/{date}/skip [post]
+func (handler *RecurringHandler) SkipOccurrence(context *gin.Context) {
+	date, ok := occurrenceDate(context)
+	if !ok {
+		return
+	}
+
+	override := model.OccurrenceOverride{Date: date, Skip: true}
+	rule, err := handler.Recurring.SetOverride(middleware.UserID(context), context.Param("id"), override)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, rule)
+}
+
+// RestoreOccurrence godoc
+// @Summary Restore a single occurrence
+// @Description Undo the edit or skip of one pending occurrence of a recurring rule
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Param date path string true "Occurrence date (yyyy-mm-dd)"
+// @Success 200 {object} model.RecurringRule
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /recurring/{id}/occurrences/{date} [delete]
+func (handler *RecurringHandler) RestoreOccurrence(context *gin.Context) {
+	date, ok := occurrenceDate(context)
+	if !ok {
+		return
+	}
+
+	rule, err := handler.Recurring.DeleteOverride(middleware.UserID(context), context.Param("id"), date)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, rule)
+}
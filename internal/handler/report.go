@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type ReportHandler struct {
+	Finance *service.FinanceService
+}
+
+func NewReportHandler(finance *service.FinanceService) *ReportHandler {
+	return &ReportHandler{Finance: finance}
+}
+
+// GetSummary godoc
+// @Summary Get income and expense summary
+// @Description Get income, expense and net totals of the authenticated user grouped by month, by category and by month and category. Totals are converted to the user's base currency; by_currency holds the unconverted totals of each currency.
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (yyyy-mm-dd), inclusive"
+// @Param to query string false "End date (yyyy-mm-dd), inclusive"
+// @Param type query string false "Transaction type" Enums(income, expense)
+// @Param category query string false "Category (case-insensitive)"
+// @Param account_id query int false "Account ID"
+// @Param tags query string false "Comma-separated tags (case-insensitive)"
+// @Param tag_match query string false "Keep transactions with any of the tags (default) or all of them" Enums(any, all)
+// @Success 200 {object} model.SummaryReport
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /reports/summary [get]
+func (handler *ReportHandler) GetSummary(context *gin.Context) {
+	filter, err := parseTransactionFilter(context)
+	if err != nil {
+		invalid(context, err.Error())
+		return
+	}
+
+	report, err := handler.Finance.SummaryReport(middleware.UserID(context), filter)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, report)
+}
+
+// GetTagTotals godoc
+// @Summary Get totals by tag
+// @Description Get income, expense and net totals of the authenticated user for every tag, converted to the user's base currency. A transaction with several tags counts towards each of them; untagged holds the totals of transactions without tags.
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (yyyy-mm-dd), inclusive"
+// @Param to query string false "End date (yyyy-mm-dd), inclusive"
+// @Param type query string false "Transaction type" Enums(income, expense)
+// @Param category query string false "Category (case-insensitive)"
+// @Param account_id query int false "Account ID"
+// @Param tags query string false "Comma-separated tags (case-insensitive)"
+// @Param tag_match query string false "Keep transactions with any of the tags (default) or all of them" Enums(any, all)
+// @Success 200 {object} model.TagReport
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /reports/tags [get]
+func (handler *ReportHandler) GetTagTotals(context *gin.Context) {
+	filter, err := parseTransactionFilter(context)
+	if err != nil {
+		invalid(context, err.Error())
+		return
+	}
+
+	report, err := handler.Finance.TagReport(middleware.UserID(context), filter)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, report)
+}
+
+// GetBalanceHistory godoc
+// @Summary Get running balance over time
+// @Description Get the authenticated user's running balance, in their base currency, at the end of every day or month with activity. The balance always covers every transaction, so only the period and interval can be chosen; other parameters are rejected.
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (yyyy-mm-dd), inclusive"
+// @Param to query string false "End date (yyyy-mm-dd), inclusive"
+// @Param interval query string false "Grouping interval (default day)" Enums(day, month)
+// @Success 200 {object} model.BalanceHistory
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /reports/balance-history [get]
+func (handler *ReportHandler) GetBalanceHistory(context *gin.Context) {
+	if err := onlyQueryParameters(context, "from", "to", "interval"); err != nil {
+		invalid(context, err.Error())
+		return
+	}
+	filter, err := parseTransactionFilter(context)
+	if err != nil {
+		invalid(context, err.Error())
+		return
+	}
+
+	interval := context.DefaultQuery("interval", "day")
+	if interval != "day" && interval != "month" {
+		invalid(context, "interval must be 'day' or 'month'")
+		return
+	}
+
+	history, err := handler.Finance.BalanceHistory(middleware.UserID(context), filter.From, filter.To, interval)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, history)
+}
+
+// onlyQueryParameters rejects query parameters other than names, so filters
+// an endpoint does not apply are not silently ignored
+func onlyQueryParameters(context *gin.Context, names ...string) error {
+	for name := range context.Request.URL.Query() {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("unknown query parameter %q; only %s are accepted", name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
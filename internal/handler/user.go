@@ -1,9 +1,9 @@
 package handler
 
 import (
-	"encoding/json"
-	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/middleware"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
 	"net/http"
@@ -11,65 +11,69 @@ import (
 )
 
 type UserHandler struct {
-	User *service.UserService
+	User   *service.UserService
+	Data   *service.UserDataService
+	Tokens *service.TokenService
 }
 
-func NewUserHandler(userService *service.UserService) *UserHandler {
-	return &UserHandler{User: userService}
+func NewUserHandler(userService *service.UserService, userDataService *service.UserDataService, tokenService *service.TokenService) *UserHandler {
+	return &UserHandler{User: userService, Data: userDataService, Tokens: tokenService}
 }
 
 // AddUser godoc
 // @Summary Register a new user
-// @Description Register a new user in the system
+// @Description Register a new user in the system. The email must be a valid address and the password cannot be empty or longer than 72 bytes. The base currency defaults to BRL.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param user body model.User true "User object"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
 // @Router /users [post]
 func (handler *UserHandler) AddUser(context *gin.Context) {
 	var newUser model.User
-	if err := context.ShouldBindJSON(&newUser); err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeErr *json.UnmarshalTypeError
+	if !bindJSON(context, &newUser, "") {
+		return
+	}
 
-		if errors.As(err, &syntaxError) || errors.As(err, &unmarshalTypeErr) {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+	if newUser.BaseCurrency != "" {
+		currency, err := model.ParseCurrency(newUser.BaseCurrency)
+		if err != nil {
+			invalid(context, "Base currency must be a three-letter code such as BRL or USD")
 			return
 		}
-
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
-		return
+		newUser.BaseCurrency = currency
 	}
 
 	user, err := handler.User.AddUser(newUser)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add user"})
+		fail(context, err)
 		return
 	}
 
 	context.JSON(http.StatusOK, gin.H{
 		"message": "Add users successful",
 		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
+			"id":            user.ID,
+			"name":          user.Name,
+			"email":         user.Email,
+			"base_currency": user.BaseCurrency,
 		},
 	})
 }
 
 // AuthenticateUser godoc
 // @Summary Authenticate a user
-// @Description Authenticate a user with email and password
+// @Description Authenticate a user with email and password and issue an access and refresh token
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param loginInfo body object true "Login information"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 401 {object} map[string]string
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
 // @Router /users/auth [post]
 func (handler *UserHandler) AuthenticateUser(context *gin.Context) {
 	var loginInfo struct {
@@ -78,54 +82,386 @@ func (handler *UserHandler) AuthenticateUser(context *gin.Context) {
 	}
 
 	if err := context.ShouldBindJSON(&loginInfo); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login data"})
+		invalid(context, "Invalid login data")
 		return
 	}
 
-	user, authenticated := handler.User.Authenticate(loginInfo.Email, loginInfo.Password)
+	user, authenticated := handler.User.Authenticate(context.Request.Context(), loginInfo.Email, loginInfo.Password)
 	if !authenticated {
-		context.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		fail(context, service.ErrInvalidCredentials)
+		return
+	}
+
+	tokens, err := handler.Tokens.IssueTokens(*user)
+	if err != nil {
+		fail(context, err)
 		return
 	}
 
 	context.JSON(http.StatusOK, gin.H{
 		"message": "Authentication successful",
 		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
+			"id":            user.ID,
+			"name":          user.Name,
+			"email":         user.Email,
+			"base_currency": user.BaseCurrency,
 		},
+		"tokens": tokens,
 	})
 }
 
+// RefreshToken godoc
+// @Summary Refresh session tokens
+// @Description Exchange a valid refresh token for a new access and refresh token
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param refreshInfo body object true "Refresh token"
+// @Success 200 {object} model.TokenPair
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/refresh [post]
+func (handler *UserHandler) RefreshToken(context *gin.Context) {
+	var refreshInfo struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := context.ShouldBindJSON(&refreshInfo); err != nil {
+		invalid(context, "Invalid refresh data")
+		return
+	}
+
+	userID, err := handler.Tokens.ParseRefreshToken(refreshInfo.RefreshToken)
+	if err != nil {
+		fail(context, service.ErrInvalidRefreshToken)
+		return
+	}
+
+	user, active := handler.User.GetActiveUser(userID)
+	if !active {
+		fail(context, service.ErrInvalidRefreshToken)
+		return
+	}
+
+	tokens, err := handler.Tokens.IssueTokens(*user)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, tokens)
+}
+
+// GetProfile godoc
+// @Summary Get the profile
+// @Description Get the authenticated user's profile
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.UserProfile
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/me [get]
+func (handler *UserHandler) GetProfile(context *gin.Context) {
+	user, err := handler.User.GetUser(middleware.UserID(context))
+	if err != nil {
+		fail(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, user.Profile())
+}
+
+// UpdateProfile godoc
+// @Summary Update the profile
+// @Description Change the authenticated user's name, email or both; fields left out are kept. The email must not belong to another user.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param profile body model.UserUpdate true "Profile fields to change"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/me [patch]
+func (handler *UserHandler) UpdateProfile(context *gin.Context) {
+	var update model.UserUpdate
+	if !bindJSON(context, &update, "") {
+		return
+	}
+
+	user, err := handler.User.UpdateProfile(middleware.UserID(context), update)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "Profile updated successfully",
+		"user":    user.Profile(),
+	})
+}
+
+// ExportData godoc
+// @Summary Export all user data
+// @Description Download everything stored for the authenticated user as a JSON file: profile, accounts, categories, transactions, recurring rules, budgets, alert rules and import profiles
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.UserExport
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/me/export [get]
+func (handler *UserHandler) ExportData(context *gin.Context) {
+	handler.export(context, middleware.UserID(context))
+}
+
+// export answers with everything stored for the user, as a file download
+func (handler *UserHandler) export(context *gin.Context, userID int) {
+	export, err := handler.Data.Export(userID)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="myfinance-user-%d.json"`, userID))
+	context.JSON(http.StatusOK, export)
+}
+
 // DeleteUser godoc
 // @Summary Delete a user
-// @Description Delete an existing user from the system
+// @Description Deactivate the authenticated user, keeping their data so an administrator can reactivate them. With purge=true the user and everything stored for them is erased instead, which cannot be undone.
 // @Tags users
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path int true "User ID"
+// @Param purge query bool false "Erase the user and all their data"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
 // @Router /users/{id} [delete]
 func (handler *UserHandler) DeleteUser(context *gin.Context) {
 	id := context.Param("id")
-	_, err := strconv.Atoi(id)
+	userID, err := strconv.Atoi(id)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		invalid(context, "Invalid user ID")
+		return
+	}
+
+	purge := false
+	if value := context.Query("purge"); value != "" {
+		if purge, err = strconv.ParseBool(value); err != nil {
+			invalid(context, "purge must be true or false")
+			return
+		}
+	}
+
+	if userID != middleware.UserID(context) {
+		fail(context, service.ErrForbidden.WithMessage("Cannot delete another user"))
 		return
 	}
 
+	if purge {
+		handler.purge(context, userID)
+		return
+	}
 	err = handler.User.DeleteUser(id)
 	if err != nil {
-		if err.Error() == "user not found" {
-			context.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		} else {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
-		}
+		fail(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "User deactivated successfully"})
+}
+
+// purge erases the user and everything stored for them
+func (handler *UserHandler) purge(context *gin.Context, userID int) {
+	if err := handler.Data.Purge(context.Request.Context(), userID); err != nil {
+		fail(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "User and all their data purged successfully"})
+}
+
+// adminUserID reads the ID of the user an administrator acts on
+func adminUserID(context *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(context.Param("id"))
+	if err != nil {
+		invalid(context, "Invalid user ID")
+		return 0, false
+	}
+	return userID, true
+}
+
+// ReactivateUser godoc
+// @Summary Reactivate a user
+// @Description Let a deactivated user sign in again. Administrators only; purged users cannot be reactivated.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /admin/users/{id}/reactivate [post]
+func (handler *UserHandler) ReactivateUser(context *gin.Context) {
+	userID, ok := adminUserID(context)
+	if !ok {
+		return
+	}
+
+	user, err := handler.User.ReactivateUser(userID)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "User reactivated successfully",
+		"user":    user.Profile(),
+	})
+}
+
+// ExportUserData godoc
+// @Summary Export a user's data
+// @Description Download everything stored for any user, active or deactivated, as a JSON file. Administrators only.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} model.UserExport
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /admin/users/{id}/export [get]
+func (handler *UserHandler) ExportUserData(context *gin.Context) {
+	userID, ok := adminUserID(context)
+	if !ok {
 		return
 	}
+	handler.export(context, userID)
+}
 
+// PurgeUser godoc
+// @Summary Purge a user
+// @Description Erase any user, active or deactivated, and everything stored for them. Administrators only; this cannot be undone.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /admin/users/{id} [delete]
+func (handler *UserHandler) PurgeUser(context *gin.Context) {
+	userID, ok := adminUserID(context)
+	if !ok {
+		return
+	}
+	handler.purge(context, userID)
+}
+
+// SetBaseCurrency godoc
+// @Summary Change the base currency
+// @Description Change the currency the authenticated user's balance and reports are converted to
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param currencyInfo body object true "Base currency, e.g. {\"base_currency\": \"USD\"}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/me/base-currency [put]
+func (handler *UserHandler) SetBaseCurrency(context *gin.Context) {
+	var currencyInfo struct {
+		BaseCurrency string `json:"base_currency" binding:"required"`
+	}
+
+	if !bindJSON(context, &currencyInfo, "") {
+		return
+	}
+
+	currency, err := model.ParseCurrency(currencyInfo.BaseCurrency)
+	if err != nil {
+		invalid(context, "Base currency must be a three-letter code such as BRL or USD")
+		return
+	}
+
+	user, err := handler.User.SetBaseCurrency(middleware.UserID(context), currency)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "Base currency updated successfully",
+		"user": gin.H{
+			"id":            user.ID,
+			"name":          user.Name,
+			"email":         user.Email,
+			"base_currency": user.BaseCurrency,
+		},
+	})
+}
+
+// SetAlertsOptOut godoc
+// @Summary Turn alert emails off or on
+// @Description Opt the authenticated user out of every alert email, or back in. Alert rules are kept either way.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param alertsInfo body object true "Opt-out flag, e.g. {\"alerts_opt_out\": true}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/me/alerts [put]
+func (handler *UserHandler) SetAlertsOptOut(context *gin.Context) {
+	var alertsInfo struct {
+		AlertsOptOut *bool `json:"alerts_opt_out" binding:"required"`
+	}
+
+	if !bindJSON(context, &alertsInfo, "") {
+		return
+	}
+
+	user, err := handler.User.SetAlertsOptOut(middleware.UserID(context), *alertsInfo.AlertsOptOut)
+	if err != nil {
+		fail(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "Alert settings updated successfully",
+		"user": gin.H{
+			"id":             user.ID,
+			"name":           user.Name,
+			"email":          user.Email,
+			"alerts_opt_out": user.AlertsOptOut,
+		},
+	})
 }
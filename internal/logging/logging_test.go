@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestNewAddsRequestID(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := New(&output, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.With("component", "test").InfoContext(ctx, "Handled request")
+	logger.DebugContext(ctx, "Hidden below the level")
+	logger.Info("Outside a request")
+
+	lines := bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected two lines, got %q", output.String())
+	}
+	var first, second map[string]any
+	if err := json.Unmarshal(lines[0], &first); err != nil {
+		t.Fatalf("Failed to decode %q: %v", lines[0], err)
+	}
+	if first["request_id"] != "abc123" || first["component"] != "test" {
+		t.Errorf("Expected the request ID and the logger's attributes, got %v", first)
+	}
+	if err := json.Unmarshal(lines[1], &second); err != nil {
+		t.Fatalf("Failed to decode %q: %v", lines[1], err)
+	}
+	if _, ok := second["request_id"]; ok {
+		t.Errorf("Expected no request ID outside a request, got %v", second)
+	}
+}
+
+func TestNewRejectsUnknownFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,101 @@
+// Package metrics holds the Prometheus collectors of the API, served at /metrics
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const namespace = "myfinance"
+
+// Registry holds every collector served by Handler, including the Go runtime
+// and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// RequestDuration observes every request under the gin route it matched,
+	// so paths with IDs do not each get their own series
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to answer HTTP requests, by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Time taken to load or save a data file or SQLite table, by file or table and operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"file", "operation"})
+
+	storageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "errors_total",
+		Help:      "Data file or SQLite table loads and saves that failed, by file or table and operation.",
+	}, []string{"file", "operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestDuration,
+		storageDuration,
+		storageErrors,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format. A collector that
+// fails is logged with the default slog logger and left out instead of
+// failing the whole scrape.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+		ErrorLog:      slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// ObserveStorage records how long an operation on a data file or SQLite
+// table took and counts it as an error when err is set
+func ObserveStorage(file, operation string, duration time.Duration, err error) {
+	storageDuration.WithLabelValues(file, operation).Observe(duration.Seconds())
+	if err != nil {
+		storageErrors.WithLabelValues(file, operation).Inc()
+	}
+}
+
+// RegisterCount exposes a gauge named myfinance_<name> whose value is read
+// from count on every scrape
+func RegisterCount(name, help string, count func() (int, error)) error {
+	return Registry.Register(countCollector{
+		desc:  prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, nil, nil),
+		count: count,
+	})
+}
+
+// countCollector reports the current number of some kind of record
+type countCollector struct {
+	desc  *prometheus.Desc
+	count func() (int, error)
+}
+
+func (collector countCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.desc
+}
+
+func (collector countCollector) Collect(values chan<- prometheus.Metric) {
+	count, err := collector.count()
+	if err != nil {
+		values <- prometheus.NewInvalidMetric(collector.desc, err)
+		return
+	}
+	values <- prometheus.MustNewConstMetric(collector.desc, prometheus.GaugeValue, float64(count))
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerServesCountsAndStorage(t *testing.T) {
+	if err := RegisterCount("test_records", "Records counted by the test.", func() (int, error) { return 42, nil }); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := RegisterCount("test_broken", "A count that fails.", func() (int, error) { return 0, errors.New("storage down") }); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := RegisterCount("test_records", "Registered twice.", func() (int, error) { return 0, nil }); err == nil {
+		t.Error("Expected an error registering the same gauge twice")
+	}
+	ObserveStorage("test.json", "save", 3*time.Millisecond, nil)
+	ObserveStorage("test.json", "save", time.Millisecond, errors.New("disk full"))
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if recorder.Code != 200 {
+		t.Fatalf("Expected 200 despite the failing count, got %d", recorder.Code)
+	}
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"myfinance_test_records 42",
+		`myfinance_storage_operation_duration_seconds_count{file="test.json",operation="save"} 2`,
+		`myfinance_storage_errors_total{file="test.json",operation="save"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "myfinance_test_broken") {
+		t.Errorf("Expected the failing count to be left out, got\n%s", body)
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"slices"
+	"strings"
+)
+
+const userIDKey = "userID"
+
+// RequireAuth rejects requests without a valid bearer access token, or whose
+// user was deactivated since the token was issued, and stores the
+// authenticated user ID in the gin context
+func RequireAuth(tokens *service.TokenService, users *service.UserService) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		header := context.GetHeader("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			context.Abort()
+			_ = context.Error(service.ErrMissingToken)
+			return
+		}
+
+		userID, err := tokens.ParseAccessToken(token)
+		if err != nil {
+			context.Abort()
+			_ = context.Error(service.ErrInvalidToken)
+			return
+		}
+
+		active, err := users.IsActive(userID)
+		if err != nil || !active {
+			context.Abort()
+			if err == nil {
+				err = service.ErrInvalidToken
+			}
+			_ = context.Error(err)
+			return
+		}
+
+		context.Set(userIDKey, userID)
+		context.Next()
+	}
+}
+
+// RequireAdmin rejects requests of users other than adminIDs. It runs after
+// RequireAuth.
+func RequireAdmin(adminIDs []int) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if !slices.Contains(adminIDs, UserID(context)) {
+			context.Abort()
+			_ = context.Error(service.ErrForbidden.WithMessage("Only administrators can do this"))
+			return
+		}
+		context.Next()
+	}
+}
+
+// UserID returns the authenticated user ID set by RequireAuth
+func UserID(context *gin.Context) int {
+	return context.GetInt(userIDKey)
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+)
+
+// authRouter serves /me to any active user and /admin to the first one
+func authRouter(t *testing.T) (*gin.Engine, *service.TokenService, *service.UserService) {
+	t.Helper()
+	users, err := storage.NewFileUserStorage(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatalf("Failed to open user storage: %v", err)
+	}
+	userService := service.NewUserService(users)
+	tokens := service.NewTokenService("secret", time.Minute, time.Hour)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Errors())
+	requireAuth := RequireAuth(tokens, userService)
+	ok := func(context *gin.Context) { context.Status(http.StatusNoContent) }
+	router.GET("/me", requireAuth, ok)
+	router.GET("/admin", requireAuth, RequireAdmin([]int{1}), ok)
+	return router, tokens, userService
+}
+
+func authGet(router *gin.Engine, path, token string) int {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestRequireAuthRejectsDeactivatedUsers(t *testing.T) {
+	router, tokens, users := authRouter(t)
+	user, err := users.AddUser(model.User{Name: "John Doe", Email: "john@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to add user: %v", err)
+	}
+	pair, err := tokens.IssueTokens(user)
+	if err != nil {
+		t.Fatalf("Failed to issue tokens: %v", err)
+	}
+
+	if status := authGet(router, "/me", ""); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", status)
+	}
+	if status := authGet(router, "/me", pair.AccessToken); status != http.StatusNoContent {
+		t.Errorf("Expected an active user to get through, got %d", status)
+	}
+	if err := users.DeleteUser("1"); err != nil {
+		t.Fatalf("Failed to deactivate user: %v", err)
+	}
+	if status := authGet(router, "/me", pair.AccessToken); status != http.StatusUnauthorized {
+		t.Errorf("Expected the token of a deactivated user to be rejected, got %d", status)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	router, tokens, users := authRouter(t)
+	for _, email := range []string{"admin@example.com", "john@example.com"} {
+		if _, err := users.AddUser(model.User{Name: "User", Email: email, Password: "password123"}); err != nil {
+			t.Fatalf("Failed to add user: %v", err)
+		}
+	}
+
+	for userID, expected := range map[int]int{1: http.StatusNoContent, 2: http.StatusForbidden} {
+		pair, err := tokens.IssueTokens(model.User{ID: userID})
+		if err != nil {
+			t.Fatalf("Failed to issue tokens: %v", err)
+		}
+		if status := authGet(router, "/admin", pair.AccessToken); status != expected {
+			t.Errorf("User %d: expected %d, got %d", userID, expected, status)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/logging"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+	"net/http"
+)
+
+// statusByKind maps the kinds of service errors to HTTP status codes
+var statusByKind = map[service.Kind]int{
+	service.KindInternal:      http.StatusInternalServerError,
+	service.KindInvalid:       http.StatusBadRequest,
+	service.KindUnauthorized:  http.StatusUnauthorized,
+	service.KindForbidden:     http.StatusForbidden,
+	service.KindNotFound:      http.StatusNotFound,
+	service.KindConflict:      http.StatusConflict,
+	service.KindTooLarge:      http.StatusRequestEntityTooLarge,
+	service.KindUnprocessable: http.StatusUnprocessableEntity,
+}
+
+// Errors answers the last error a handler added with context.Error, unless
+// the handler already wrote a response. A *service.Error is answered with
+// the status of its kind, its code, message and details; any other error is
+// an internal error whose text is only logged.
+func Errors() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Next()
+
+		last := context.Errors.Last()
+		if last == nil || context.Writer.Written() {
+			return
+		}
+
+		known := service.ErrInternal
+		message := known.Message
+		var serviceError *service.Error
+		if errors.As(last.Err, &serviceError) {
+			known = serviceError
+			// Wrapped errors such as ErrInvalidImportFile add what was wrong
+			message = last.Err.Error()
+		}
+		status, ok := statusByKind[known.Kind]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		context.JSON(status, model.ErrorResponse{Error: model.ErrorBody{
+			Code:      known.Code,
+			Message:   message,
+			Details:   known.Details,
+			RequestID: logging.RequestID(context.Request.Context()),
+		}})
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/service"
+)
+
+func errorRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Errors(), Recover())
+	router.GET("/not-found", func(context *gin.Context) {
+		_ = context.Error(service.ErrTransactionNotFound)
+	})
+	router.GET("/wrapped", func(context *gin.Context) {
+		_ = context.Error(fmt.Errorf("%w: no rows found", service.ErrInvalidImportFile))
+	})
+	router.GET("/details", func(context *gin.Context) {
+		_ = context.Error(service.ErrInvalidImportRows.WithDetails(map[string]int{"invalid": 2}))
+	})
+	router.GET("/internal", func(context *gin.Context) {
+		_ = context.Error(errors.New("disk on fire"))
+	})
+	router.GET("/panic", func(context *gin.Context) {
+		panic("boom")
+	})
+	router.GET("/written", func(context *gin.Context) {
+		context.JSON(http.StatusAccepted, gin.H{"message": "kept"})
+		_ = context.Error(errors.New("logged only"))
+	})
+	return router
+}
+
+func TestErrorsWritesEnvelope(t *testing.T) {
+	router := errorRouter()
+	tests := []struct {
+		path    string
+		status  int
+		code    string
+		message string
+		details bool
+	}{
+		{"/not-found", http.StatusNotFound, "transaction_not_found", "Transaction not found", false},
+		{"/wrapped", http.StatusBadRequest, "invalid_import_file", "Invalid import file: no rows found", false},
+		{"/details", http.StatusUnprocessableEntity, "invalid_import_rows", "Nothing was imported because some rows are invalid", true},
+		{"/internal", http.StatusInternalServerError, "internal_error", "Internal server error", false},
+		{"/panic", http.StatusInternalServerError, "internal_error", "Internal server error", false},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		request.Header.Set(RequestIDHeader, "client-id.1")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, recorder.Code)
+		}
+		var response model.ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to decode %q: %v", tt.path, recorder.Body.String(), err)
+		}
+		body := response.Error
+		if body.Code != tt.code || body.Message != tt.message || (body.Details != nil) != tt.details {
+			t.Errorf("%s: expected %s %q, got %+v", tt.path, tt.code, tt.message, body)
+		}
+		if body.RequestID != "client-id.1" || recorder.Header().Get(RequestIDHeader) != "client-id.1" {
+			t.Errorf("%s: expected the client's request ID, got %q", tt.path, body.RequestID)
+		}
+	}
+}
+
+func TestErrorsKeepsWrittenResponse(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	errorRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/written", nil))
+	if recorder.Code != http.StatusAccepted || recorder.Body.String() != `{"message":"kept"}` {
+		t.Errorf("Expected the handler's response, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestRequestIDReplacesInvalidHeader(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/not-found", nil)
+	request.Header.Set(RequestIDHeader, "not valid\n")
+	recorder := httptest.NewRecorder()
+	errorRouter().ServeHTTP(recorder, request)
+
+	requestID := recorder.Header().Get(RequestIDHeader)
+	if requestID == "" || requestID == "not valid\n" || !validRequestID.MatchString(requestID) {
+		t.Errorf("Expected a generated request ID, got %q", requestID)
+	}
+}
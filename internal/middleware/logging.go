@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/logging"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits the request IDs accepted from clients, so they are
+// safe to log and echo back
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an ID, reusing a valid X-Request-ID sent by
+// the client, echoes it in the response and stores it in the request context
+// for the logs and error responses
+func RequestID() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		requestID := context.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		context.Header(RequestIDHeader, requestID)
+		context.Request = context.Request.WithContext(logging.WithRequestID(context.Request.Context(), requestID))
+		context.Next()
+	}
+}
+
+func newRequestID() string {
+	buffer := make([]byte, 8)
+	_, _ = rand.Read(buffer)
+	return hex.EncodeToString(buffer)
+}
+
+// Logger logs one line per request, at the error level for server errors
+func Logger() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		context.Next()
+
+		status := context.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", context.Request.Method),
+			slog.String("route", context.FullPath()),
+			slog.String("path", context.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", context.ClientIP()),
+			slog.Int("bytes", context.Writer.Size()),
+		}
+		if last := context.Errors.Last(); last != nil {
+			attrs = append(attrs, slog.String("error", last.Err.Error()))
+		}
+		slog.LogAttrs(context.Request.Context(), level, "Request handled", attrs...)
+	}
+}
+
+// Recover turns a panic in a handler into an internal error response and
+// logs it with its stack
+func Recover() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			err, ok := recovered.(error)
+			if !ok {
+				err = fmt.Errorf("%v", recovered)
+			}
+			slog.ErrorContext(context.Request.Context(), "Handler panicked", "error", err, "stack", string(debug.Stack()))
+			_ = context.Error(fmt.Errorf("panic: %w", err))
+			context.Abort()
+		}()
+		context.Next()
+	}
+}
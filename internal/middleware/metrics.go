@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/metrics"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests that matched no route, keeping arbitrary
+// paths out of the metrics
+const unmatchedRoute = "unmatched"
+
+// Metrics records the latency and status of every request under the route
+// it matched
+func Metrics() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		context.Next()
+
+		route := context.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.RequestDuration.
+			WithLabelValues(route, context.Request.Method, strconv.Itoa(context.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,26 @@
+package model
+
+// AccountTypes lists the accepted account types
+var AccountTypes = []string{"checking", "savings", "credit_card", "cash", "other"}
+
+// Account is a pool of money owned by a user, such as a bank account, a
+// credit card or a wallet. The opening balance is what the account held on
+// OpeningDate, before any of its transactions were recorded.
+type Account struct {
+	ID             int      `json:"id"`
+	UserID         int      `json:"user_id"`
+	Name           string   `json:"name" example:"Checking"`
+	Type           string   `json:"type" example:"checking"`
+	Currency       string   `json:"currency" example:"BRL"`
+	OpeningBalance Money    `json:"opening_balance" swaggertype:"number" example:"1500.00"`
+	OpeningDate    DateOnly `json:"opening_date" swaggertype:"string" example:"2023-01-01"`
+}
+
+// AccountBalance is the balance of a single account in its own currency,
+// opening balance included
+type AccountBalance struct {
+	AccountID int    `json:"account_id"`
+	Name      string `json:"name"`
+	Currency  string `json:"currency"`
+	Balance   Money  `json:"balance" swaggertype:"number"`
+}
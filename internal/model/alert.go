@@ -0,0 +1,21 @@
+package model
+
+// AlertKinds lists the accepted alert rule kinds:
+//   - category_limit: the month's expenses in Category exceed Threshold
+//   - large_expense: a single expense is over Threshold
+//   - low_balance: the balance drops below Threshold
+var AlertKinds = []string{"category_limit", "large_expense", "low_balance"}
+
+// AlertRule emails its user when a transaction they add or update meets the
+// rule's condition. Thresholds are in the user's base currency. A rule fires
+// at most once per month: FiredPeriods are the latest months (yyyy-mm) it
+// fired in, oldest first, and LastPeriod is the latest of them.
+type AlertRule struct {
+	ID           int      `json:"id"`
+	UserID       int      `json:"user_id"`
+	Kind         string   `json:"kind" example:"category_limit"`
+	Category     string   `json:"category,omitempty" example:"Food"`
+	Threshold    Money    `json:"threshold" swaggertype:"number" example:"800.00"`
+	LastPeriod   string   `json:"last_period,omitempty" example:"2023-06"`
+	FiredPeriods []string `json:"fired_periods,omitempty" example:"2023-05,2023-06"`
+}
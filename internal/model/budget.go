@@ -0,0 +1,34 @@
+package model
+
+// Budget is a monthly spending limit for one category, or for all expenses
+// when Category is empty. Limits are in the user's base currency and apply
+// from StartMonth onwards. With Rollover, the unused part of a month's limit
+// is added to the next month.
+type Budget struct {
+	ID         int    `json:"id"`
+	UserID     int    `json:"user_id"`
+	Category   string `json:"category,omitempty" example:"Food"`
+	Limit      Money  `json:"limit" swaggertype:"number" example:"800.00"`
+	Rollover   bool   `json:"rollover"`
+	StartMonth string `json:"start_month" example:"2023-01"`
+}
+
+// BudgetStatus compares a budget with the expenses of a month. Available is
+// the limit plus the amount carried over from earlier months.
+type BudgetStatus struct {
+	BudgetID    int    `json:"budget_id"`
+	Category    string `json:"category,omitempty"`
+	Limit       Money  `json:"limit" swaggertype:"number"`
+	CarriedOver Money  `json:"carried_over" swaggertype:"number"`
+	Available   Money  `json:"available" swaggertype:"number"`
+	Spent       Money  `json:"spent" swaggertype:"number"`
+	Remaining   Money  `json:"remaining" swaggertype:"number"`
+	Overspent   bool   `json:"overspent"`
+}
+
+// BudgetReport holds the status of every budget of a user in a month
+type BudgetReport struct {
+	Month    string         `json:"month" example:"2023-06"`
+	Currency string         `json:"currency" example:"BRL"`
+	Budgets  []BudgetStatus `json:"budgets"`
+}
@@ -0,0 +1,18 @@
+package model
+
+// CategoryTypes lists the accepted category types; a category only applies
+// to transactions of the same type
+var CategoryTypes = []string{"income", "expense"}
+
+// Category is an entry of a user's category catalog. Names are unique per
+// user, ignoring case. A category may be nested under a parent of the same
+// type; ParentID is 0 for top-level categories.
+type Category struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"user_id"`
+	Name     string `json:"name" example:"Groceries"`
+	Type     string `json:"type" example:"expense"`
+	ParentID int    `json:"parent_id,omitempty" example:"3"`
+	Color    string `json:"color,omitempty" example:"#4caf50"`
+	Icon     string `json:"icon,omitempty" example:"cart"`
+}
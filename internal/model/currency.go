@@ -0,0 +1,150 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of users and transactions created before
+// currencies were recorded, and of new ones that do not name a currency
+const DefaultCurrency = "BRL"
+
+// ParseCurrency validates a three-letter ISO 4217 style code and returns it in
+// upper case
+func ParseCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", fmt.Errorf("invalid currency %q", code)
+	}
+	for _, letter := range code {
+		if letter < 'A' || letter > 'Z' {
+			return "", fmt.Errorf("invalid currency %q", code)
+		}
+	}
+	return code, nil
+}
+
+// ExchangeRate is the value of one unit of From expressed in To, valid from
+// Date until a newer rate for the same pair
+type ExchangeRate struct {
+	ID   int      `json:"id"`
+	Date DateOnly `json:"date"`
+	From string   `json:"from" example:"USD"`
+	To   string   `json:"to" example:"BRL"`
+	Rate Rate     `json:"rate" swaggertype:"number" example:"5.4321"`
+}
+
+// Rate is a positive decimal conversion factor. It keeps the decimal text it
+// was parsed from so rates are stored and returned exactly as imported.
+type Rate string
+
+// ParseRate parses a positive decimal rate such as "5.4321"
+func ParseRate(value string) (Rate, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.ContainsAny(value, "/_xXbBoOpP") {
+		return "", fmt.Errorf("invalid rate %q", value)
+	}
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok || rat.Sign() <= 0 {
+		return "", fmt.Errorf("invalid rate %q", value)
+	}
+	// Text such as "+5" or ".5" is not a valid JSON number; rewrite it
+	if !json.Valid([]byte(value)) {
+		value = strings.TrimRight(strings.TrimRight(rat.FloatString(12), "0"), ".")
+	}
+	return Rate(value), nil
+}
+
+func (rate Rate) rat() *big.Rat {
+	rat, ok := new(big.Rat).SetString(string(rate))
+	if !ok {
+		return new(big.Rat)
+	}
+	return rat
+}
+
+// Convert multiplies amount by the rate, rounding to the nearest cent. It
+// returns ErrAmountOutOfRange when the result does not fit in a Money.
+func (rate Rate) Convert(amount Money) (Money, error) {
+	return convertedMoney(new(big.Rat).Mul(big.NewRat(int64(amount), 1), rate.rat()))
+}
+
+// ConvertBack divides amount by the rate, converting in the opposite
+// direction of the pair, rounding to the nearest cent
+func (rate Rate) ConvertBack(amount Money) (Money, error) {
+	divisor := rate.rat()
+	if divisor.Sign() == 0 {
+		return 0, nil
+	}
+	return convertedMoney(new(big.Rat).Quo(big.NewRat(int64(amount), 1), divisor))
+}
+
+// convertedMoney rounds a converted number of cents
+func convertedMoney(cents *big.Rat) (Money, error) {
+	money, ok := roundToCents(cents)
+	if !ok {
+		return 0, ErrAmountOutOfRange
+	}
+	return money, nil
+}
+
+// MarshalJSON encodes the rate as a JSON number
+func (rate Rate) MarshalJSON() ([]byte, error) {
+	if rate == "" {
+		return []byte("0"), nil
+	}
+	return []byte(rate), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string
+func (rate *Rate) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := ParseRate(text)
+	if err != nil {
+		return err
+	}
+	*rate = parsed
+	return nil
+}
+
+// Value stores the rate as its decimal text
+func (rate Rate) Value() (driver.Value, error) {
+	return string(rate), nil
+}
+
+// Scan reads a rate stored as decimal text
+func (rate *Rate) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case string:
+		*rate = Rate(value)
+		return nil
+	case []byte:
+		*rate = Rate(value)
+		return nil
+	default:
+		return errors.New("rate must be stored as decimal text")
+	}
+}
+
+// CurrencyBalance is a balance in a single currency
+type CurrencyBalance struct {
+	Currency string `json:"currency" example:"USD"`
+	Balance  Money  `json:"balance" swaggertype:"number"`
+}
+
+// Balance is a user's balance converted to their base currency, together with
+// the unconverted balance of every currency and account they hold
+type Balance struct {
+	Currency   string            `json:"currency" example:"BRL"`
+	Balance    Money             `json:"balance" swaggertype:"number"`
+	ByCurrency []CurrencyBalance `json:"by_currency"`
+	ByAccount  []AccountBalance  `json:"by_account"`
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseCurrency(t *testing.T) {
+	for input, expected := range map[string]string{"usd": "USD", " BRL ": "BRL", "Eur": "EUR"} {
+		currency, err := ParseCurrency(input)
+		if err != nil || currency != expected {
+			t.Errorf("ParseCurrency(%q): expected %s, got %q (%v)", input, expected, currency, err)
+		}
+	}
+	for _, input := range []string{"", "US", "USDT", "U$D", "12A"} {
+		if _, err := ParseCurrency(input); err == nil {
+			t.Errorf("ParseCurrency(%q): expected an error", input)
+		}
+	}
+}
+
+func TestRateConversion(t *testing.T) {
+	rate, err := ParseRate("5.4321")
+	if err != nil {
+		t.Fatalf("Failed to parse rate: %v", err)
+	}
+	if converted, err := rate.Convert(10_00); err != nil || converted != 54_32 {
+		t.Errorf("Expected 10.00 * 5.4321 = 54.32, got %s (%v)", converted, err)
+	}
+	if converted, err := rate.ConvertBack(54_32); err != nil || converted != 10_00 {
+		t.Errorf("Expected 54.32 / 5.4321 = 10.00, got %s (%v)", converted, err)
+	}
+	if converted, err := rate.Convert(-10_00); err != nil || converted != -54_32 {
+		t.Errorf("Expected -54.32, got %s (%v)", converted, err)
+	}
+	if converted, err := rate.Convert(math.MaxInt64); !errors.Is(err, ErrAmountOutOfRange) {
+		t.Errorf("Expected an overflowing conversion to fail, got %s (%v)", converted, err)
+	}
+	if converted, err := Rate("0.0001").ConvertBack(math.MinInt64); !errors.Is(err, ErrAmountOutOfRange) {
+		t.Errorf("Expected an overflowing conversion to fail, got %s (%v)", converted, err)
+	}
+
+	for input, expected := range map[string]Rate{"+5": "5", ".25": "0.25", "0.0001": "0.0001"} {
+		if rate, err := ParseRate(input); err != nil || rate != expected {
+			t.Errorf("ParseRate(%q): expected %s, got %s (%v)", input, expected, rate, err)
+		}
+	}
+
+	for _, input := range []string{"", "0", "-1", "abc", "1/2"} {
+		if _, err := ParseRate(input); err == nil {
+			t.Errorf("ParseRate(%q): expected an error", input)
+		}
+	}
+}
+
+func TestRateJSON(t *testing.T) {
+	var exchangeRate ExchangeRate
+	err := json.Unmarshal([]byte(`{"date": "2023-06-01", "from": "USD", "to": "BRL", "rate": 5.10}`), &exchangeRate)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal exchange rate: %v", err)
+	}
+	if exchangeRate.Rate != "5.10" {
+		t.Errorf("Expected the rate text to be kept as 5.10, got %s", exchangeRate.Rate)
+	}
+
+	encoded, err := json.Marshal(exchangeRate)
+	if err != nil {
+		t.Fatalf("Failed to marshal exchange rate: %v", err)
+	}
+	expected := `{"id":0,"date":"2023-06-01","from":"USD","to":"BRL","rate":5.10}`
+	if string(encoded) != expected {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+}
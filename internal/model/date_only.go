@@ -27,3 +27,22 @@ func (date DateOnly) MarshalJSON() ([]byte, error) {
 func (date DateOnly) toTime() time.Time {
 	return time.Time(date)
 }
+
+// IsZero reports whether the date is unset
+func (date DateOnly) IsZero() bool {
+	return time.Time(date).IsZero()
+}
+
+// String formats the date as yyyy-mm-dd
+func (date DateOnly) String() string {
+	return time.Time(date).Format(dateLayout)
+}
+
+// ParseDateOnly parses a yyyy-mm-dd string into a DateOnly
+func ParseDateOnly(value string) (DateOnly, error) {
+	timeParse, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return DateOnly{}, err
+	}
+	return DateOnly(timeParse), nil
+}
@@ -0,0 +1,16 @@
+package model
+
+// ErrorResponse is the body of every failed request
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes a failure: Code is stable for programs to match on,
+// Message is meant for people and RequestID matches the X-Request-ID header
+// and the server logs
+type ErrorBody struct {
+	Code      string `json:"code" example:"transaction_not_found"`
+	Message   string `json:"message" example:"Transaction not found"`
+	Details   any    `json:"details,omitempty" swaggertype:"object"`
+	RequestID string `json:"request_id" example:"3f2a9c1e7b4d8a60"`
+}
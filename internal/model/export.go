@@ -0,0 +1,22 @@
+package model
+
+// ExportFormats lists the file formats transactions can be exported to
+var ExportFormats = []string{"csv", "json", "xlsx"}
+
+// ExportRow is an exported transaction followed by the running balance after
+// it, in the export's currency
+type ExportRow struct {
+	Transaction
+	Balance Money `json:"balance" swaggertype:"number"`
+}
+
+// TransactionExport is the JSON file of an export, with the transactions in
+// date order. Balances are converted to Currency, the user's base currency;
+// the opening balance sums up the matching transactions before the exported
+// period, and the closing balance comes after the transactions.
+type TransactionExport struct {
+	Currency       string      `json:"currency"`
+	OpeningBalance Money       `json:"opening_balance" swaggertype:"number"`
+	Rows           []ExportRow `json:"transactions"`
+	ClosingBalance Money       `json:"closing_balance" swaggertype:"number"`
+}
@@ -0,0 +1,8 @@
+package model
+
+// Readiness is the outcome of the readiness probe: Status is "ready" or
+// "unavailable" and Checks holds "ok" or the error of every dependency
+type Readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
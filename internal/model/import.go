@@ -0,0 +1,56 @@
+package model
+
+// SignConventions lists how an import profile reads the sign of an amount.
+// With "negative_expense" negative amounts are expenses and positive ones are
+// income, as in most checking account statements; "positive_expense" is the
+// reverse, as in most credit card statements.
+var SignConventions = []string{"negative_expense", "positive_expense"}
+
+// DecimalSeparators lists the accepted decimal separators; the other one is
+// read as a thousands separator
+var DecimalSeparators = []string{".", ","}
+
+// ImportProfile describes the layout of a bank's CSV export so it can be
+// imported again and again. Columns are named after the file's header, which
+// comes after SkipRows leading lines; the description and category columns
+// are optional. DateFormat spells the date with dd, mm and yyyy. Imported
+// transactions go to AccountID when it is set.
+type ImportProfile struct {
+	ID                int    `json:"id"`
+	UserID            int    `json:"user_id"`
+	Name              string `json:"name" example:"My bank"`
+	Delimiter         string `json:"delimiter" example:";"`
+	SkipRows          int    `json:"skip_rows,omitempty" example:"0"`
+	DateColumn        string `json:"date_column" example:"Data"`
+	AmountColumn      string `json:"amount_column" example:"Valor"`
+	DescriptionColumn string `json:"description_column,omitempty" example:"Histórico"`
+	CategoryColumn    string `json:"category_column,omitempty"`
+	DateFormat        string `json:"date_format" example:"dd/mm/yyyy"`
+	DecimalSeparator  string `json:"decimal_separator" example:","`
+	SignConvention    string `json:"sign_convention" example:"negative_expense"`
+	AccountID         int    `json:"account_id,omitempty" example:"1"`
+}
+
+// ImportRow is one line of an imported file. Lines that could not be read
+// carry Error instead of a transaction; DuplicateOf is the ID of an existing
+// transaction the line probably repeats. AlreadyImported tells that the
+// duplicate was found through the bank's own identifier in the same account.
+type ImportRow struct {
+	Line            int          `json:"line"`
+	Transaction     *Transaction `json:"transaction,omitempty"`
+	DuplicateOf     int          `json:"duplicate_of,omitempty"`
+	AlreadyImported bool         `json:"already_imported,omitempty"`
+	Error           string       `json:"error,omitempty"`
+}
+
+// ImportResult describes the rows of an imported file. Valid counts the rows
+// without errors, duplicates included. Committed tells whether the rows were
+// saved, in which case Imported transactions were created.
+type ImportResult struct {
+	Rows       []ImportRow `json:"rows"`
+	Valid      int         `json:"valid"`
+	Invalid    int         `json:"invalid"`
+	Duplicates int         `json:"duplicates"`
+	Imported   int         `json:"imported"`
+	Committed  bool        `json:"committed"`
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in cents (hundredths of the currency unit). Keeping
+// amounts as integers makes sums exact no matter how many are added up.
+type Money int64
+
+const centsPerUnit = 100
+
+// ErrAmountOutOfRange is returned when an amount does not fit in a Money
+var ErrAmountOutOfRange = errors.New("amount is out of range")
+
+// ParseMoney parses a decimal amount such as "12.34" or "-5". Values with more
+// than two decimal places are rounded half away from zero.
+func ParseMoney(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	// big.Rat also understands fractions, base prefixes and exponents; only
+	// plain decimal notation is a valid amount
+	if value == "" || strings.ContainsAny(value, "/_xXbBoOpPeE") {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	money, ok := roundToCents(rat.Mul(rat, big.NewRat(centsPerUnit, 1)))
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrAmountOutOfRange, value)
+	}
+	return money, nil
+}
+
+// roundToCents rounds a number of cents half away from zero. It reports false
+// when the result does not fit in a Money.
+func roundToCents(cents *big.Rat) (Money, bool) {
+	// Round half away from zero: add or subtract 1/2 and truncate
+	rounded := new(big.Rat).Set(cents)
+	half := big.NewRat(1, 2)
+	if rounded.Sign() < 0 {
+		rounded.Sub(rounded, half)
+	} else {
+		rounded.Add(rounded, half)
+	}
+	whole := new(big.Int).Quo(rounded.Num(), rounded.Denom())
+	if !whole.IsInt64() {
+		return 0, false
+	}
+	return Money(whole.Int64()), true
+}
+
+// String formats the amount with exactly two decimal places
+func (money Money) String() string {
+	sign := ""
+	cents := int64(money)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/centsPerUnit, cents%centsPerUnit)
+}
+
+// MarshalJSON encodes the amount as a JSON number with two decimal places
+func (money Money) MarshalJSON() ([]byte, error) {
+	return []byte(money.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string. Amounts written as
+// floats by older versions (e.g. 10.1) are parsed from their decimal text, so
+// they load without rounding errors.
+func (money *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*money = parsed
+	return nil
+}
+
+// Value stores the amount as an integer number of cents
+func (money Money) Value() (driver.Value, error) {
+	return int64(money), nil
+}
+
+// Scan reads an integer number of cents
+func (money *Money) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case int64:
+		*money = Money(value)
+		return nil
+	case nil:
+		*money = 0
+		return nil
+	default:
+		return errors.New("money must be stored as an integer number of cents")
+	}
+}
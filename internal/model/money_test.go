@@ -0,0 +1,108 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Money
+	}{
+		{"0", 0},
+		{"12.34", 1234},
+		{"-12.34", -1234},
+		{"0.1", 10},
+		{"0.29", 29},
+		{"3000", 300000},
+		{"0.005", 1},
+		{"-0.005", -1},
+		{"0.0049", 0},
+		{" 7.5 ", 750},
+	}
+
+	for _, tt := range tests {
+		money, err := ParseMoney(tt.input)
+		if err != nil {
+			t.Errorf("ParseMoney(%q): unexpected error %v", tt.input, err)
+			continue
+		}
+		if money != tt.expected {
+			t.Errorf("ParseMoney(%q): expected %d cents, got %d", tt.input, tt.expected, money)
+		}
+	}
+}
+
+func TestParseMoneyRejectsInvalidInput(t *testing.T) {
+	for _, input := range []string{"", "abc", "1/3", "0x10", "1,50", "NaN", "1e3", "1E-2", "1e400000000000"} {
+		if _, err := ParseMoney(input); err == nil {
+			t.Errorf("ParseMoney(%q): expected an error", input)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := map[Money]string{
+		0:       "0.00",
+		5:       "0.05",
+		-5:      "-0.05",
+		1234:    "12.34",
+		300000:  "3000.00",
+		-123456: "-1234.56",
+	}
+	for money, expected := range tests {
+		if money.String() != expected {
+			t.Errorf("Expected %d cents to format as %s, got %s", int64(money), expected, money.String())
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var transaction Transaction
+	err := json.Unmarshal([]byte(`{"amount": 10.1, "date": "2023-06-15"}`), &transaction)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal legacy float amount: %v", err)
+	}
+	if transaction.Amount != 1010 {
+		t.Errorf("Expected 1010 cents, got %d", transaction.Amount)
+	}
+
+	err = json.Unmarshal([]byte(`{"amount": "99.99", "date": "2023-06-15"}`), &transaction)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal string amount: %v", err)
+	}
+	if transaction.Amount != 9999 {
+		t.Errorf("Expected 9999 cents, got %d", transaction.Amount)
+	}
+
+	encoded, err := json.Marshal(struct {
+		Amount Money `json:"amount"`
+	}{Amount: 1010})
+	if err != nil {
+		t.Fatalf("Failed to marshal money: %v", err)
+	}
+	if string(encoded) != `{"amount":10.10}` {
+		t.Errorf("Expected amount to be encoded as a number with two decimals, got %s", encoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": true}`), &transaction); err == nil {
+		t.Error("Expected an error for a non-numeric amount")
+	}
+}
+
+func TestMoneySumsWithoutDrift(t *testing.T) {
+	var floatTotal float64
+	var moneyTotal Money
+	cent, _ := ParseMoney("0.1")
+	for i := 0; i < 10000; i++ {
+		floatTotal += 0.1
+		moneyTotal += cent
+	}
+	if floatTotal == 1000 {
+		t.Skip("float64 happened to be exact; nothing to compare against")
+	}
+	if moneyTotal.String() != "1000.00" {
+		t.Errorf("Expected 1000.00, got %s", moneyTotal)
+	}
+}
@@ -0,0 +1,128 @@
+package model
+
+import "time"
+
+// RecurringFrequencies lists the accepted recurrence frequencies
+var RecurringFrequencies = []string{"daily", "weekly", "monthly", "yearly"}
+
+// RecurringRule describes a transaction repeated on a schedule, such as rent
+// or a salary. Occurrences start on StartDate and repeat every Interval
+// periods until EndDate or until Count occurrences, whichever comes first.
+// Monthly and yearly rules keep the day of StartDate; in shorter months they
+// fall on the last day instead.
+type RecurringRule struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Frequency string    `json:"frequency" example:"monthly"`
+	Interval  int       `json:"interval" example:"1"`
+	StartDate DateOnly  `json:"start_date" swaggertype:"string" example:"2023-01-31"`
+	EndDate   *DateOnly `json:"end_date,omitempty" swaggertype:"string" example:"2023-12-31"`
+	Count     int       `json:"count,omitempty"`
+
+	// The transaction created on every occurrence
+	Type        string `json:"type" example:"expense"`
+	Amount      Money  `json:"amount" swaggertype:"number" example:"1500.00"`
+	Currency    string `json:"currency,omitempty" example:"BRL"`
+	Category    string `json:"category" example:"Rent"`
+	Description string `json:"description" example:"Monthly rent"`
+	AccountID   int    `json:"account_id,omitempty"`
+	ToAccountID int    `json:"to_account_id,omitempty"`
+
+	// LastDate is the latest occurrence already turned into a transaction
+	// or skipped; only later occurrences are still pending
+	LastDate  *DateOnly            `json:"last_date,omitempty" swaggertype:"string"`
+	Overrides []OccurrenceOverride `json:"overrides,omitempty"`
+}
+
+// OccurrenceOverride changes or skips a single pending occurrence of a rule
+type OccurrenceOverride struct {
+	Date        DateOnly `json:"date" swaggertype:"string" example:"2023-02-28"`
+	Skip        bool     `json:"skip,omitempty"`
+	Amount      *Money   `json:"amount,omitempty" swaggertype:"number"`
+	Category    *string  `json:"category,omitempty"`
+	Description *string  `json:"description,omitempty"`
+}
+
+// Occurrence is a pending occurrence of a rule with its override applied
+type Occurrence struct {
+	Date        DateOnly `json:"date" swaggertype:"string" example:"2023-02-28"`
+	Type        string   `json:"type"`
+	Amount      Money    `json:"amount" swaggertype:"number"`
+	Currency    string   `json:"currency,omitempty"`
+	Category    string   `json:"category"`
+	Description string   `json:"description"`
+	Skipped     bool     `json:"skipped"`
+	Overridden  bool     `json:"overridden"`
+}
+
+// OccurrenceDate returns the date of the occurrence with the given zero-based
+// index, ignoring EndDate and Count
+func (rule RecurringRule) OccurrenceDate(index int) DateOnly {
+	start := time.Time(rule.StartDate)
+	interval := max(rule.Interval, 1)
+	switch rule.Frequency {
+	case "weekly":
+		return DateOnly(start.AddDate(0, 0, 7*index*interval))
+	case "monthly":
+		return DateOnly(addMonthsClamped(start, index*interval))
+	case "yearly":
+		return DateOnly(addMonthsClamped(start, 12*index*interval))
+	default:
+		return DateOnly(start.AddDate(0, 0, index*interval))
+	}
+}
+
+// addMonthsClamped adds months to date, moving to the last day of the target
+// month when it is shorter than the day of date (January 31 + 1 month is
+// February 28 or 29)
+func addMonthsClamped(date time.Time, months int) time.Time {
+	year, month, day := date.Date()
+	firstOfMonth := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, date.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	return firstOfMonth.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+// Ended reports whether the occurrence with the given index and date falls
+// after the end of the rule
+func (rule RecurringRule) Ended(index int, date DateOnly) bool {
+	if rule.Count > 0 && index >= rule.Count {
+		return true
+	}
+	return rule.EndDate != nil && time.Time(date).After(time.Time(*rule.EndDate))
+}
+
+// Override returns the override of the occurrence on date, if any
+func (rule RecurringRule) Override(date DateOnly) (OccurrenceOverride, bool) {
+	for _, override := range rule.Overrides {
+		if override.Date == date {
+			return override, true
+		}
+	}
+	return OccurrenceOverride{}, false
+}
+
+// Occurrence builds the occurrence on date with its override applied
+func (rule RecurringRule) Occurrence(date DateOnly) Occurrence {
+	occurrence := Occurrence{
+		Date:        date,
+		Type:        rule.Type,
+		Amount:      rule.Amount,
+		Currency:    rule.Currency,
+		Category:    rule.Category,
+		Description: rule.Description,
+	}
+	if override, ok := rule.Override(date); ok {
+		occurrence.Overridden = true
+		occurrence.Skipped = override.Skip
+		if override.Amount != nil {
+			occurrence.Amount = *override.Amount
+		}
+		if override.Category != nil {
+			occurrence.Category = *override.Category
+		}
+		if override.Description != nil {
+			occurrence.Description = *override.Description
+		}
+	}
+	return occurrence
+}
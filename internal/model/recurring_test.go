@@ -0,0 +1,63 @@
+package model
+
+import "testing"
+
+func TestOccurrenceDate(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency string
+		interval  int
+		start     string
+		index     int
+		expected  string
+	}{
+		{"first occurrence", "monthly", 1, "2023-01-31", 0, "2023-01-31"},
+		{"daily", "daily", 1, "2023-01-30", 3, "2023-02-02"},
+		{"every other week", "weekly", 2, "2023-01-02", 2, "2023-01-30"},
+		{"short month", "monthly", 1, "2023-01-31", 1, "2023-02-28"},
+		{"leap year", "monthly", 1, "2024-01-31", 1, "2024-02-29"},
+		{"back to a long month", "monthly", 1, "2023-01-31", 2, "2023-03-31"},
+		{"30 day month", "monthly", 1, "2023-01-31", 3, "2023-04-30"},
+		{"quarterly", "monthly", 3, "2023-11-30", 1, "2024-02-29"},
+		{"yearly leap day", "yearly", 1, "2024-02-29", 1, "2025-02-28"},
+		{"yearly back on leap day", "yearly", 1, "2024-02-29", 4, "2028-02-29"},
+		{"zero interval means one", "daily", 0, "2023-01-01", 1, "2023-01-02"},
+	}
+	for _, tt := range tests {
+		start, _ := ParseDateOnly(tt.start)
+		rule := RecurringRule{Frequency: tt.frequency, Interval: tt.interval, StartDate: start}
+		if got := rule.OccurrenceDate(tt.index).String(); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestRecurringRuleEnded(t *testing.T) {
+	start, _ := ParseDateOnly("2023-01-01")
+	end, _ := ParseDateOnly("2023-03-01")
+	byCount := RecurringRule{Frequency: "monthly", StartDate: start, Count: 2}
+	if byCount.Ended(1, byCount.OccurrenceDate(1)) || !byCount.Ended(2, byCount.OccurrenceDate(2)) {
+		t.Error("Expected a count of 2 to end after the second occurrence")
+	}
+	byDate := RecurringRule{Frequency: "monthly", StartDate: start, EndDate: &end}
+	if byDate.Ended(2, byDate.OccurrenceDate(2)) || !byDate.Ended(3, byDate.OccurrenceDate(3)) {
+		t.Error("Expected the end date to be the last possible occurrence")
+	}
+}
+
+func TestRecurringRuleOccurrenceAppliesOverride(t *testing.T) {
+	start, _ := ParseDateOnly("2023-01-05")
+	second, _ := ParseDateOnly("2023-02-05")
+	amount := Money(5500_00)
+	rule := RecurringRule{Frequency: "monthly", StartDate: start, Type: "income", Amount: 5000_00,
+		Category: "Salary", Description: "Salary",
+		Overrides: []OccurrenceOverride{{Date: second, Amount: &amount}}}
+
+	if occurrence := rule.Occurrence(start); occurrence.Amount != 5000_00 || occurrence.Overridden {
+		t.Errorf("Expected the rule's transaction, got %+v", occurrence)
+	}
+	occurrence := rule.Occurrence(second)
+	if occurrence.Amount != 5500_00 || !occurrence.Overridden || occurrence.Category != "Salary" {
+		t.Errorf("Expected only the amount to change, got %+v", occurrence)
+	}
+}
@@ -0,0 +1,71 @@
+package model
+
+type ReportTotals struct {
+	Income  Money `json:"income" swaggertype:"number"`
+	Expense Money `json:"expense" swaggertype:"number"`
+	Net     Money `json:"net" swaggertype:"number"`
+}
+
+type MonthSummary struct {
+	Month string `json:"month"`
+	ReportTotals
+}
+
+type CategorySummary struct {
+	Category string `json:"category"`
+	ReportTotals
+}
+
+type MonthCategorySummary struct {
+	Month    string `json:"month"`
+	Category string `json:"category"`
+	ReportTotals
+}
+
+// CurrencySummary holds the totals of a single currency before conversion
+type CurrencySummary struct {
+	Currency string `json:"currency"`
+	ReportTotals
+}
+
+// SummaryReport holds totals converted to Currency, the user's base currency.
+// ByCurrency has the unconverted totals of every currency involved.
+type SummaryReport struct {
+	Currency        string                 `json:"currency"`
+	Totals          ReportTotals           `json:"totals"`
+	ByCurrency      []CurrencySummary      `json:"by_currency"`
+	ByMonth         []MonthSummary         `json:"by_month"`
+	ByCategory      []CategorySummary      `json:"by_category"`
+	ByMonthCategory []MonthCategorySummary `json:"by_month_category"`
+}
+
+type TagSummary struct {
+	Tag string `json:"tag"`
+	ReportTotals
+}
+
+// TagReport holds totals converted to Currency, the user's base currency.
+// A transaction with several tags counts towards each of them, so the tag
+// totals can add up to more than the overall amount; Untagged covers the
+// transactions without tags.
+type TagReport struct {
+	Currency string       `json:"currency"`
+	Tags     []TagSummary `json:"tags"`
+	Untagged ReportTotals `json:"untagged"`
+}
+
+type BalancePoint struct {
+	Period  string `json:"period"`
+	Income  Money  `json:"income" swaggertype:"number"`
+	Expense Money  `json:"expense" swaggertype:"number"`
+	Balance Money  `json:"balance" swaggertype:"number"`
+}
+
+// BalanceHistory amounts are converted to Currency, the user's base currency
+type BalanceHistory struct {
+	Currency       string         `json:"currency"`
+	Interval       string         `json:"interval"`
+	OpeningBalance Money          `json:"opening_balance" swaggertype:"number"`
+	ClosingBalance Money          `json:"closing_balance" swaggertype:"number"`
+	Points         []BalancePoint `json:"points"`
+}
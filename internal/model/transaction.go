@@ -1,11 +1,93 @@
 package model
 
+import (
+	"slices"
+	"strings"
+)
+
 type Transaction struct {
 	ID          int      `json:"id"`
 	Type        string   `json:"type"`
-	Amount      float64  `json:"amount"`
+	Amount      Money    `json:"amount" swaggertype:"number" example:"12.34"`
+	Currency    string   `json:"currency" example:"BRL"`
 	Category    string   `json:"category"`
 	Date        DateOnly `json:"date"`
 	Description string   `json:"description"`
 	UserID      int      `json:"user_id"`
+	AccountID   int      `json:"account_id,omitempty"`
+	// Transfers only. ToAccountID names the destination account when a
+	// transfer is created or updated; the two stored halves point at each
+	// other through LinkedID and TransferRole is "source" or "destination".
+	ToAccountID  int    `json:"to_account_id,omitempty"`
+	LinkedID     int    `json:"linked_id,omitempty"`
+	TransferRole string `json:"transfer_role,omitempty"`
+	// RecurringID is the rule that created the transaction, if any. It is set
+	// by the scheduler only.
+	RecurringID int `json:"recurring_id,omitempty"`
+	// CategoryID is the catalog entry Category was resolved to, if any
+	CategoryID int `json:"category_id,omitempty"`
+	// Tags are free-form labels, stored trimmed, lowercased and without duplicates
+	Tags []string `json:"tags,omitempty" example:"trip-2026,reimbursable"`
+	// Splits divide an income or expense across categories; their amounts
+	// add up to Amount and replace Category in per-category totals
+	Splits []Split `json:"splits,omitempty"`
+	// ExternalID is the bank's identifier of an imported transaction, such
+	// as the FITID of an OFX statement. It is set by imports only.
+	ExternalID string `json:"external_id,omitempty"`
+}
+
+// Split is one line of a transaction divided across several categories
+type Split struct {
+	Category   string `json:"category" example:"Household"`
+	CategoryID int    `json:"category_id,omitempty"`
+	Amount     Money  `json:"amount" swaggertype:"number" example:"25.50"`
+	Note       string `json:"note,omitempty" example:"Detergent"`
+}
+
+// CurrencyOrDefault returns the transaction's currency, or DefaultCurrency
+// for transactions recorded without one
+func (transaction Transaction) CurrencyOrDefault() string {
+	if transaction.Currency == "" {
+		return DefaultCurrency
+	}
+	return transaction.Currency
+}
+
+// InCategory reports whether the transaction, or one of its split lines when
+// it is split, is in category, ignoring case
+func (transaction Transaction) InCategory(category string) bool {
+	if len(transaction.Splits) == 0 {
+		return strings.EqualFold(transaction.Category, category)
+	}
+	return slices.ContainsFunc(transaction.Splits, func(split Split) bool { return strings.EqualFold(split.Category, category) })
+}
+
+// Portions returns one copy of the transaction per split line, carrying the
+// line's category and amount. Transactions without splits are returned as
+// the only portion.
+func (transaction Transaction) Portions() []Transaction {
+	if len(transaction.Splits) == 0 {
+		return []Transaction{transaction}
+	}
+	portions := make([]Transaction, 0, len(transaction.Splits))
+	for _, split := range transaction.Splits {
+		portion := transaction
+		portion.Category, portion.CategoryID, portion.Amount = split.Category, split.CategoryID, split.Amount
+		portion.Splits = nil
+		portions = append(portions, portion)
+	}
+	return portions
+}
+
+// NormalizeTags trims and lowercases tags, dropping empty ones and
+// duplicates while keeping the original order
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
 }
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"slices"
+	"strings"
+)
+
+// TransactionFilter narrows down the transactions returned by a storage
+// backend. Zero values mean "no restriction".
+type TransactionFilter struct {
+	From      DateOnly
+	To        DateOnly
+	Type      string
+	Category  string
+	AccountID int
+	// Tags keeps transactions with any of the tags, or with all of them
+	// when AllTags is set
+	Tags    []string
+	AllTags bool
+}
+
+// hasTag reports whether the transaction carries tag, ignoring case
+func hasTag(transaction Transaction, tag string) bool {
+	return slices.ContainsFunc(transaction.Tags, func(candidate string) bool { return strings.EqualFold(candidate, tag) })
+}
+
+// Matches reports whether the transaction satisfies every set criterion.
+// Category and tag comparisons are case-insensitive, and split transactions
+// match the categories of their lines.
+func (filter TransactionFilter) Matches(transaction Transaction) bool {
+	if !filter.From.IsZero() && transaction.Date.toTime().Before(filter.From.toTime()) {
+		return false
+	}
+	if !filter.To.IsZero() && transaction.Date.toTime().After(filter.To.toTime()) {
+		return false
+	}
+	if filter.Type != "" && transaction.Type != filter.Type {
+		return false
+	}
+	if filter.Category != "" && !transaction.InCategory(filter.Category) {
+		return false
+	}
+	if filter.AccountID != 0 && transaction.AccountID != filter.AccountID {
+		return false
+	}
+	if filter.AllTags {
+		for _, tag := range filter.Tags {
+			if !hasTag(transaction, tag) {
+				return false
+			}
+		}
+	} else if len(filter.Tags) > 0 && !slices.ContainsFunc(filter.Tags, func(tag string) bool { return hasTag(transaction, tag) }) {
+		return false
+	}
+	return true
+}
+
+// TransactionQuery describes a page of a user's transactions: the storage
+// filter plus the criteria only applied in the service layer
+type TransactionQuery struct {
+	TransactionFilter
+	MinAmount *Money
+	MaxAmount *Money
+	Search    string
+	SortBy    string
+	SortOrder string
+	Limit     int
+	Offset    int
+}
+
+// Matches reports whether the transaction satisfies every filter of the query
+func (query TransactionQuery) Matches(transaction Transaction) bool {
+	if !query.TransactionFilter.Matches(transaction) {
+		return false
+	}
+	if query.MinAmount != nil && transaction.Amount < *query.MinAmount {
+		return false
+	}
+	if query.MaxAmount != nil && transaction.Amount > *query.MaxAmount {
+		return false
+	}
+	if query.Search != "" && !strings.Contains(strings.ToLower(transaction.Description), strings.ToLower(query.Search)) {
+		return false
+	}
+	return true
+}
+
+type TransactionPage struct {
+	Items  []Transaction `json:"items"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
@@ -1,9 +1,57 @@
 package model
 
+import "time"
+
 type User struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Status   bool   `json:"status"`
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	Status       bool   `json:"status"`
+	BaseCurrency string `json:"base_currency" example:"BRL"`
+	AlertsOptOut bool   `json:"alerts_opt_out"`
+	// Purged marks a user whose data was erased. The record is kept with
+	// nothing but its ID so the ID is never given to another user.
+	Purged bool `json:"purged,omitempty" swaggerignore:"true"`
+}
+
+// UserProfile is the part of a user shown back to them, without the password
+type UserProfile struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name" example:"John Doe"`
+	Email        string `json:"email" example:"john@example.com"`
+	Status       bool   `json:"status"`
+	BaseCurrency string `json:"base_currency" example:"BRL"`
+	AlertsOptOut bool   `json:"alerts_opt_out"`
+}
+
+// Profile returns the user's profile
+func (user User) Profile() UserProfile {
+	return UserProfile{
+		ID:           user.ID,
+		Name:         user.Name,
+		Email:        user.Email,
+		Status:       user.Status,
+		BaseCurrency: user.BaseCurrency,
+		AlertsOptOut: user.AlertsOptOut,
+	}
+}
+
+// UserUpdate holds the profile fields a user can change; nil fields are kept
+type UserUpdate struct {
+	Name  *string `json:"name" example:"John Doe"`
+	Email *string `json:"email" example:"john@example.com"`
+}
+
+// UserExport holds everything stored for a user, as handed to them on request
+type UserExport struct {
+	ExportedAt     time.Time       `json:"exported_at"`
+	User           UserProfile     `json:"user"`
+	Accounts       []Account       `json:"accounts"`
+	Categories     []Category      `json:"categories"`
+	Transactions   []Transaction   `json:"transactions"`
+	RecurringRules []RecurringRule `json:"recurring_rules"`
+	Budgets        []Budget        `json:"budgets"`
+	AlertRules     []AlertRule     `json:"alert_rules"`
+	ImportProfiles []ImportProfile `json:"import_profiles"`
 }
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Errors returned by the account service
+var (
+	ErrAccountNotFound       = newError(KindNotFound, "account_not_found", "Account not found")
+	ErrAccountCurrencyLocked = newError(KindConflict, "account_currency_locked", "The currency of an account with transactions cannot change")
+	ErrAccountInUse          = newError(KindConflict, "account_in_use", "Cannot delete an account with transactions")
+)
+
+type AccountService struct {
+	Storage        storage.AccountStorage
+	Transactions   storage.FinanceStorage
+	Recurring      storage.RecurringStorage
+	ImportProfiles storage.ImportProfileStorage
+	Currencies     *CurrencyService
+}
+
+func NewAccountService(storage storage.AccountStorage, transactions storage.FinanceStorage, recurring storage.RecurringStorage,
+	importProfiles storage.ImportProfileStorage, currencies *CurrencyService) *AccountService {
+	return &AccountService{
+		Storage:        storage,
+		Transactions:   transactions,
+		Recurring:      recurring,
+		ImportProfiles: importProfiles,
+		Currencies:     currencies,
+	}
+}
+
+// IsValidAccountType reports whether accountType is one of model.AccountTypes
+func IsValidAccountType(accountType string) bool {
+	return slices.Contains(model.AccountTypes, accountType)
+}
+
+// AddAccount stores a new account. Accounts without a currency use the user's
+// base currency and accounts without an opening date open today.
+func (accountService *AccountService) AddAccount(account model.Account) (model.Account, error) {
+	if account.Currency == "" {
+		currency, err := accountService.Currencies.BaseCurrency(account.UserID)
+		if err != nil {
+			return model.Account{}, err
+		}
+		account.Currency = currency
+	}
+	if account.OpeningDate.IsZero() {
+		account.OpeningDate = today()
+	}
+	return accountService.Storage.Insert(account)
+}
+
+func (accountService *AccountService) ListAccounts(userID int) ([]model.Account, error) {
+	return accountService.Storage.FindByUser(userID)
+}
+
+// GetAccount loads an account owned by the user. Accounts of other users are
+// reported as not found.
+func (accountService *AccountService) GetAccount(userID int, idString string) (model.Account, error) {
+	id, _ := strconv.Atoi(idString)
+	account, err := accountService.Storage.Get(id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && account.UserID != userID) {
+		return model.Account{}, ErrAccountNotFound
+	}
+	return account, err
+}
+
+// hasTransactions reports whether any transaction is recorded in the account
+func (accountService *AccountService) hasTransactions(account model.Account) (bool, error) {
+	transactions, err := accountService.Transactions.FindByUser(account.UserID, model.TransactionFilter{AccountID: account.ID})
+	if err != nil {
+		return false, err
+	}
+	return len(transactions) > 0, nil
+}
+
+// UpdateAccount replaces an account's details. The currency of an account
+// cannot change once transactions have been recorded in it.
+func (accountService *AccountService) UpdateAccount(userID int, idString string, updated model.Account) (model.Account, error) {
+	account, err := accountService.GetAccount(userID, idString)
+	if err != nil {
+		return model.Account{}, err
+	}
+	updated.ID = account.ID
+	updated.UserID = userID
+	if updated.Currency == "" {
+		updated.Currency = account.Currency
+	}
+	if updated.OpeningDate.IsZero() {
+		updated.OpeningDate = account.OpeningDate
+	}
+	if updated.Currency != account.Currency {
+		used, err := accountService.hasTransactions(account)
+		if err != nil {
+			return model.Account{}, err
+		}
+		if used {
+			return model.Account{}, ErrAccountCurrencyLocked
+		}
+	}
+	if err := accountService.Storage.Update(updated); err != nil {
+		return model.Account{}, err
+	}
+	return updated, nil
+}
+
+// DeleteAccount removes an account that has no transactions and that no
+// recurring rule or import profile uses, since those would fail on every run
+func (accountService *AccountService) DeleteAccount(userID int, idString string) error {
+	account, err := accountService.GetAccount(userID, idString)
+	if err != nil {
+		return err
+	}
+	used, err := accountService.hasTransactions(account)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrAccountInUse
+	}
+
+	rules, err := accountService.Recurring.FindByUser(account.UserID)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(rules, func(rule model.RecurringRule) bool {
+		return rule.AccountID == account.ID || rule.ToAccountID == account.ID
+	}) {
+		return ErrAccountInUse.WithMessage("Cannot delete an account used by a recurring rule")
+	}
+	profiles, err := accountService.ImportProfiles.FindByUser(account.UserID)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(profiles, func(profile model.ImportProfile) bool { return profile.AccountID == account.ID }) {
+		return ErrAccountInUse.WithMessage("Cannot delete an account used by an import profile")
+	}
+	return accountService.Storage.Delete(account.ID)
+}
+
+// today returns the current date without a time of day
+func today() model.DateOnly {
+	now := time.Now()
+	return model.DateOnly(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+}
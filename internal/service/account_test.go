@@ -0,0 +1,183 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestAddAccountDefaults(t *testing.T) {
+	accountService := newTestServices(t).accounts
+
+	account, err := accountService.AddAccount(model.Account{UserID: 2, Name: "Wallet", Type: "cash"})
+	if err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	if account.ID == 0 || account.Currency != "USD" || account.OpeningDate.IsZero() {
+		t.Errorf("Expected an ID, the base currency USD and an opening date, got %+v", account)
+	}
+}
+
+func TestAccountOwnership(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.accounts.Insert, model.Account{UserID: 1, Name: "Checking", Type: "checking", Currency: "BRL"})
+	accountService := services.accounts
+
+	if _, err := accountService.GetAccount(2, "1"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected 'account not found' for another user's account, got %v", err)
+	}
+	if _, err := accountService.UpdateAccount(2, "1", model.Account{Name: "Mine"}); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected 'account not found' when updating another user's account, got %v", err)
+	}
+	if err := accountService.DeleteAccount(2, "1"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected 'account not found' when deleting another user's account, got %v", err)
+	}
+	if accounts, _ := services.storages.accounts.FindByUser(1); len(accounts) != 1 || accounts[0].Name != "Checking" {
+		t.Errorf("Expected the account to be untouched, got %+v", accounts)
+	}
+}
+
+func TestAccountWithTransactions(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.accounts.Insert,
+		model.Account{UserID: 1, Name: "Checking", Type: "checking", Currency: "BRL"},
+		model.Account{UserID: 1, Name: "Empty", Type: "savings", Currency: "BRL"},
+	)
+	seed(t, services.storages.finance.Insert, model.Transaction{UserID: 1, AccountID: 1, Type: "income", Amount: 10_00})
+	accountService := services.accounts
+
+	if err := accountService.DeleteAccount(1, "1"); !errors.Is(err, ErrAccountInUse) {
+		t.Errorf("Expected 'account has transactions', got %v", err)
+	}
+	if _, err := accountService.UpdateAccount(1, "1", model.Account{Name: "Checking", Type: "checking", Currency: "USD"}); !errors.Is(err, ErrAccountCurrencyLocked) {
+		t.Errorf("Expected 'account currency cannot change', got %v", err)
+	}
+
+	updated, err := accountService.UpdateAccount(1, "1", model.Account{Name: "Main checking", Type: "checking"})
+	if err != nil {
+		t.Fatalf("Failed to rename account: %v", err)
+	}
+	if updated.Name != "Main checking" || updated.Currency != "BRL" {
+		t.Errorf("Expected the rename to keep the currency, got %+v", updated)
+	}
+
+	if err := accountService.DeleteAccount(1, "2"); err != nil {
+		t.Errorf("Failed to delete unused account: %v", err)
+	}
+	if accounts, _ := services.storages.accounts.FindByUser(1); len(accounts) != 1 {
+		t.Errorf("Expected 1 account left, got %d", len(accounts))
+	}
+}
+
+func TestAccountUsedByRulesAndProfiles(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.accounts.Insert,
+		model.Account{UserID: 1, Name: "Checking", Type: "checking", Currency: "BRL"},
+		model.Account{UserID: 1, Name: "Savings", Type: "savings", Currency: "BRL"},
+		model.Account{UserID: 1, Name: "Card", Type: "credit_card", Currency: "BRL"},
+	)
+	rules := seed(t, services.storages.recurring.Insert, model.RecurringRule{UserID: 1, Type: "transfer", AccountID: 1, ToAccountID: 2})
+	profiles := seed(t, services.storages.imports.Insert, model.ImportProfile{UserID: 1, AccountID: 3})
+	accountService := services.accounts
+
+	for _, id := range []string{"1", "2", "3"} {
+		if err := accountService.DeleteAccount(1, id); !errors.Is(err, ErrAccountInUse) {
+			t.Errorf("Account %s: expected 'account in use', got %v", id, err)
+		}
+	}
+	if accounts, _ := services.storages.accounts.FindByUser(1); len(accounts) != 3 {
+		t.Errorf("Expected every account to be kept, got %+v", accounts)
+	}
+
+	services.storages.recurring.Delete(rules[0].ID)
+	services.storages.imports.Delete(profiles[0].ID)
+	if err := accountService.DeleteAccount(1, "2"); err != nil {
+		t.Errorf("Failed to delete unused account: %v", err)
+	}
+}
+
+func TestAddTransactionToAccount(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.accounts.Insert,
+		model.Account{UserID: 1, Name: "Dollar account", Type: "checking", Currency: "USD"},
+		model.Account{UserID: 2, Name: "Someone else's", Type: "checking", Currency: "BRL"},
+	)
+	financeService := services.finance
+
+	transaction, err := financeService.AddTransaction(model.Transaction{UserID: 1, AccountID: 1, Type: "expense", Amount: 5_00})
+	if err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	if transaction.Currency != "USD" {
+		t.Errorf("Expected the account currency USD, got %q", transaction.Currency)
+	}
+
+	_, err = financeService.AddTransaction(model.Transaction{UserID: 1, AccountID: 1, Type: "expense", Amount: 5_00, Currency: "BRL"})
+	if !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected 'currency does not match account', got %v", err)
+	}
+	_, err = financeService.AddTransaction(model.Transaction{UserID: 1, AccountID: 2, Type: "expense", Amount: 5_00})
+	if !errors.Is(err, ErrUnknownAccount) {
+		t.Errorf("Expected 'account not found' for another user's account, got %v", err)
+	}
+}
+
+func TestGetBalanceByAccount(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.accounts.Insert,
+		model.Account{UserID: 1, Name: "Checking", Type: "checking", Currency: "BRL", OpeningBalance: 1000_00, OpeningDate: mustParseDate(t, "2023-01-01")},
+		model.Account{UserID: 1, Name: "Credit card", Type: "credit_card", Currency: "BRL", OpeningBalance: -300_00, OpeningDate: mustParseDate(t, "2023-01-01")},
+		model.Account{UserID: 1, Name: "Savings", Type: "savings", Currency: "BRL"},
+	)
+	seed(t, services.storages.finance.Insert,
+		model.Transaction{UserID: 1, AccountID: 1, Type: "income", Amount: 3000_00, Currency: "BRL", Date: mustParseDate(t, "2023-06-05")},
+		model.Transaction{UserID: 1, AccountID: 2, Type: "expense", Amount: 200_00, Currency: "BRL", Date: mustParseDate(t, "2023-06-06")},
+		model.Transaction{UserID: 1, Type: "expense", Amount: 50_00, Currency: "BRL", Date: mustParseDate(t, "2023-06-07")},
+	)
+	financeService := services.finance
+
+	balance, err := financeService.GetBalanceByUserId(1)
+	if err != nil {
+		t.Fatalf("Failed to get balance: %v", err)
+	}
+	if balance.Balance != 3450_00 {
+		t.Errorf("Expected a total balance of 3450.00 including opening balances, got %s", balance.Balance)
+	}
+	expected := map[int]model.Money{1: 4000_00, 2: -500_00, 3: 0}
+	if len(balance.ByAccount) != len(expected) {
+		t.Fatalf("Expected %d account balances, got %+v", len(expected), balance.ByAccount)
+	}
+	for _, accountBalance := range balance.ByAccount {
+		if accountBalance.Balance != expected[accountBalance.AccountID] {
+			t.Errorf("Expected account %d to hold %s, got %s", accountBalance.AccountID, expected[accountBalance.AccountID], accountBalance.Balance)
+		}
+	}
+
+	history, err := financeService.BalanceHistory(1, mustParseDate(t, "2023-06-01"), model.DateOnly{}, "day")
+	if err != nil {
+		t.Fatalf("Failed to build balance history: %v", err)
+	}
+	if history.OpeningBalance != 700_00 || history.ClosingBalance != 3450_00 {
+		t.Errorf("Expected the history to open at 700.00 and close at 3450.00, got %s and %s", history.OpeningBalance, history.ClosingBalance)
+	}
+}
+
+func TestUpdateTransactionKeepsAccount(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.accounts.Insert, model.Account{UserID: 1, Name: "Dollar account", Type: "checking", Currency: "USD"})
+	seed(t, services.storages.finance.Insert, model.Transaction{UserID: 1, AccountID: 1, Type: "expense", Amount: 5_00, Currency: "USD"})
+	financeService := services.finance
+
+	if err := financeService.UpdateTransaction(1, "1", model.Transaction{Type: "expense", Amount: 7_00}); err != nil {
+		t.Fatalf("Failed to update transaction: %v", err)
+	}
+	if updated, _ := services.storages.finance.Get(1); updated.AccountID != 1 || updated.Currency != "USD" {
+		t.Errorf("Expected the account and currency to be kept, got %+v", updated)
+	}
+
+	err := financeService.UpdateTransaction(1, "1", model.Transaction{Type: "expense", Amount: 7_00, AccountID: 9})
+	if !errors.Is(err, ErrUnknownAccount) {
+		t.Errorf("Expected 'account not found', got %v", err)
+	}
+}
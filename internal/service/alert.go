@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// ErrAlertRuleNotFound is returned for rules that do not exist or belong to
+// another user
+var ErrAlertRuleNotFound = newError(KindNotFound, "alert_rule_not_found", "Alert rule not found")
+
+// alertQueueSize is how many alert emails can wait for delivery before new
+// ones are dropped
+const alertQueueSize = 100
+
+// maxFiredPeriods is how many months an alert rule remembers firing in
+const maxFiredPeriods = 12
+
+// Mailer delivers plain text emails; EmailService sends them over SMTP
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// alertTemplates holds a subject and a body template for every alert kind
+var alertTemplates = template.Must(template.New("alerts").Parse(`
+{{define "category_limit.subject"}}Spending on {{.Category}} is over {{.Threshold}} {{.Currency}}{{end}}
+{{define "category_limit.body"}}Your expenses in {{.Category}} for {{.Period}} reached {{.Amount}} {{.Currency}}, above your alert limit of {{.Threshold}} {{.Currency}}.{{end}}
+
+{{define "large_expense.subject"}}Large expense of {{.Amount}} {{.Currency}}{{end}}
+{{define "large_expense.body"}}An expense of {{.Amount}} {{.Currency}} was recorded on {{.Date}}{{with .Description}} ({{.}}){{end}}, above your alert threshold of {{.Threshold}} {{.Currency}}.{{end}}
+
+{{define "low_balance.subject"}}Your balance is below {{.Threshold}} {{.Currency}}{{end}}
+{{define "low_balance.body"}}Your balance is now {{.Amount}} {{.Currency}}, below your alert threshold of {{.Threshold}} {{.Currency}}.{{end}}
+
+{{define "email"}}Hello {{.Name}},
+
+{{.Message}}
+
+This alert will not be sent again before next month. You can turn off all alerts in your account settings.
+{{end}}`))
+
+// alertData is what the alert templates are rendered with
+type alertData struct {
+	Name        string
+	Category    string
+	Currency    string
+	Period      string
+	Date        string
+	Description string
+	Threshold   model.Money
+	Amount      model.Money
+}
+
+// alertEmail is an alert waiting to be delivered
+type alertEmail struct {
+	to      string
+	subject string
+	body    string
+}
+
+type AlertService struct {
+	Storage storage.AlertStorage
+	Finance *FinanceService
+	Users   storage.UserStorage
+	Mailer  Mailer
+
+	// evaluating serializes the rule checks of each user so concurrent saves
+	// cannot fire the same rule twice in a period
+	evaluating userLocks
+	outbox     chan alertEmail
+}
+
+// userLocks hands out a mutex per user, so work for one user never waits on
+// another. A mutex is dropped once nobody holds or waits for it.
+type userLocks struct {
+	mu    sync.Mutex
+	locks map[int]*userLock
+}
+
+type userLock struct {
+	sync.Mutex
+	// users counts who holds or waits for the mutex
+	users int
+}
+
+// lock takes the user's mutex and returns the function releasing it
+func (locks *userLocks) lock(userID int) (unlock func()) {
+	locks.mu.Lock()
+	if locks.locks == nil {
+		locks.locks = map[int]*userLock{}
+	}
+	lock, ok := locks.locks[userID]
+	if !ok {
+		lock = &userLock{}
+		locks.locks[userID] = lock
+	}
+	lock.users++
+	locks.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		locks.mu.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(locks.locks, userID)
+		}
+		locks.mu.Unlock()
+	}
+}
+
+func NewAlertService(storage storage.AlertStorage, finance *FinanceService, users storage.UserStorage, mailer Mailer) *AlertService {
+	return &AlertService{
+		Storage: storage,
+		Finance: finance,
+		Users:   users,
+		Mailer:  mailer,
+		outbox:  make(chan alertEmail, alertQueueSize),
+	}
+}
+
+// IsValidAlertKind reports whether kind is one of model.AlertKinds
+func IsValidAlertKind(kind string) bool {
+	return slices.Contains(model.AlertKinds, kind)
+}
+
+// AddRule stores a new alert rule
+func (alertService *AlertService) AddRule(rule model.AlertRule) (model.AlertRule, error) {
+	rule.LastPeriod, rule.FiredPeriods = "", nil
+	if rule.Kind != "category_limit" {
+		rule.Category = ""
+	}
+	return alertService.Storage.Insert(rule)
+}
+
+func (alertService *AlertService) ListRules(userID int) ([]model.AlertRule, error) {
+	return alertService.Storage.FindByUser(userID)
+}
+
+// GetRule loads an alert rule owned by the user. Rules of other users are
+// reported as not found.
+func (alertService *AlertService) GetRule(userID int, idString string) (model.AlertRule, error) {
+	id, _ := strconv.Atoi(idString)
+	rule, err := alertService.Storage.Get(id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && rule.UserID != userID) {
+		return model.AlertRule{}, ErrAlertRuleNotFound
+	}
+	return rule, err
+}
+
+// UpdateRule replaces an alert rule. The updated rule may fire again in any
+// period.
+func (alertService *AlertService) UpdateRule(userID int, idString string, updated model.AlertRule) (model.AlertRule, error) {
+	rule, err := alertService.GetRule(userID, idString)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	updated.ID = rule.ID
+	updated.UserID = userID
+	updated.LastPeriod, updated.FiredPeriods = "", nil
+	if updated.Kind != "category_limit" {
+		updated.Category = ""
+	}
+	if err := alertService.Storage.Update(updated); err != nil {
+		return model.AlertRule{}, err
+	}
+	return updated, nil
+}
+
+func (alertService *AlertService) DeleteRule(userID int, idString string) error {
+	rule, err := alertService.GetRule(userID, idString)
+	if err != nil {
+		return err
+	}
+	return alertService.Storage.Delete(rule.ID)
+}
+
+// TransactionSaved evaluates the user's alert rules after one of their
+// transactions is added or updated and queues an email for each rule that
+// fires. Errors are logged so they never fail the save itself.
+func (alertService *AlertService) TransactionSaved(transaction model.Transaction) {
+	if err := alertService.evaluate(transaction); err != nil {
+		slog.Error("Error evaluating alerts", "user_id", transaction.UserID, "error", err)
+	}
+}
+
+func (alertService *AlertService) evaluate(transaction model.Transaction) error {
+	defer alertService.evaluating.lock(transaction.UserID)()
+
+	user, err := alertService.Users.Get(transaction.UserID)
+	if err != nil {
+		return err
+	}
+	if !user.Status || user.AlertsOptOut {
+		return nil
+	}
+	rules, err := alertService.Storage.FindByUser(user.ID)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	currency, err := alertService.Finance.Currencies.BaseCurrency(user.ID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, rule := range rules {
+		data := alertData{
+			Name:        user.Name,
+			Category:    rule.Category,
+			Currency:    currency,
+			Period:      time.Time(transaction.Date).Format(monthLayout),
+			Date:        transaction.Date.String(),
+			Description: transaction.Description,
+			Threshold:   rule.Threshold,
+		}
+		if rule.Kind == "low_balance" {
+			// The balance is the current one, whatever the transaction's date
+			data.Period = currentMonth()
+		}
+		if firedIn(rule, data.Period) {
+			continue
+		}
+
+		fired, err := alertService.check(rule, transaction, &data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alert rule %d: %w", rule.ID, err))
+			continue
+		}
+		if !fired {
+			continue
+		}
+		email, err := renderAlert(rule.Kind, user.Email, data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alert rule %d: %w", rule.ID, err))
+			continue
+		}
+		markFired(&rule, data.Period)
+		if err := alertService.Storage.Update(rule); err != nil {
+			errs = append(errs, fmt.Errorf("alert rule %d: %w", rule.ID, err))
+			continue
+		}
+		alertService.enqueue(email)
+	}
+	return errors.Join(errs...)
+}
+
+// firedIn reports whether the rule already fired in period. Once the rule
+// remembers maxFiredPeriods months, earlier months count as fired too, so a
+// forgotten month never sends its email twice.
+func firedIn(rule model.AlertRule, period string) bool {
+	// Rules saved before FiredPeriods was kept only have LastPeriod
+	if period == rule.LastPeriod || slices.Contains(rule.FiredPeriods, period) {
+		return true
+	}
+	return len(rule.FiredPeriods) >= maxFiredPeriods && period < rule.FiredPeriods[0]
+}
+
+// markFired records that the rule fired in period, forgetting the oldest
+// months beyond maxFiredPeriods
+func markFired(rule *model.AlertRule, period string) {
+	periods := append(slices.Clone(rule.FiredPeriods), period)
+	if rule.LastPeriod != "" {
+		periods = append(periods, rule.LastPeriod)
+	}
+	slices.Sort(periods)
+	periods = slices.Compact(periods)
+	rule.FiredPeriods = periods[max(len(periods)-maxFiredPeriods, 0):]
+	rule.LastPeriod = rule.FiredPeriods[len(rule.FiredPeriods)-1]
+}
+
+// check reports whether the rule's condition holds after the transaction was
+// saved, recording the amount compared with the threshold in data
+func (alertService *AlertService) check(rule model.AlertRule, transaction model.Transaction, data *alertData) (bool, error) {
+	switch rule.Kind {
+	case "large_expense":
+		if transaction.Type != "expense" {
+			return false, nil
+		}
+		amount, err := alertService.Finance.Currencies.Convert(transaction.Amount, transaction.CurrencyOrDefault(), data.Currency, transaction.Date)
+		if err != nil {
+			return false, err
+		}
+		data.Amount = amount
+		return amount > rule.Threshold, nil
+
+	case "category_limit":
+		if transaction.Type != "expense" || !transaction.InCategory(rule.Category) {
+			return false, nil
+		}
+		spent, err := alertService.monthSpending(transaction.UserID, rule.Category, time.Time(transaction.Date))
+		if err != nil {
+			return false, err
+		}
+		data.Amount = spent
+		return spent > rule.Threshold, nil
+
+	case "low_balance":
+		balance, err := alertService.Finance.GetBalanceByUserId(transaction.UserID)
+		if err != nil {
+			return false, err
+		}
+		data.Amount = balance.Balance
+		return balance.Balance < rule.Threshold, nil
+	}
+	return false, nil
+}
+
+// monthSpending totals the user's expenses in category during the month of
+// date, in their base currency; of split expenses only the lines in category
+// count
+func (alertService *AlertService) monthSpending(userID int, category string, date time.Time) (model.Money, error) {
+	start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	filter := model.TransactionFilter{
+		From:     model.DateOnly(start),
+		To:       model.DateOnly(start.AddDate(0, 1, -1)),
+		Type:     "expense",
+		Category: category,
+	}
+	candidates, err := alertService.Finance.Storage.FindByUser(userID, filter)
+	if err != nil {
+		return 0, err
+	}
+	_, expenses, err := alertService.Finance.inBaseCurrency(userID, reportPortions(candidates, filter))
+	if err != nil {
+		return 0, err
+	}
+	var spent model.Money
+	for _, expense := range expenses {
+		spent += expense.Amount
+	}
+	return spent, nil
+}
+
+// renderAlert fills the templates of an alert kind
+func renderAlert(kind, to string, data alertData) (alertEmail, error) {
+	var subject, message, body strings.Builder
+	if err := alertTemplates.ExecuteTemplate(&subject, kind+".subject", data); err != nil {
+		return alertEmail{}, err
+	}
+	if err := alertTemplates.ExecuteTemplate(&message, kind+".body", data); err != nil {
+		return alertEmail{}, err
+	}
+	err := alertTemplates.ExecuteTemplate(&body, "email", struct{ Name, Message string }{data.Name, message.String()})
+	if err != nil {
+		return alertEmail{}, err
+	}
+	return alertEmail{to: to, subject: subject.String(), body: body.String()}, nil
+}
+
+// enqueue hands an alert to the delivery worker without blocking the save
+// that triggered it
+func (alertService *AlertService) enqueue(email alertEmail) {
+	select {
+	case alertService.outbox <- email:
+	default:
+		slog.Warn("Alert queue is full, dropping alert", "subject", email.subject, "to", email.to)
+	}
+}
+
+// Run delivers queued alerts until ctx is cancelled. The alerts still queued
+// then, such as those raised by the last requests before a shutdown, are
+// delivered before it returns.
+func (alertService *AlertService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case email := <-alertService.outbox:
+					alertService.deliver(email)
+				default:
+					return
+				}
+			}
+		case email := <-alertService.outbox:
+			alertService.deliver(email)
+		}
+	}
+}
+
+func (alertService *AlertService) deliver(email alertEmail) {
+	if err := alertService.Mailer.Send(email.to, email.subject, email.body); err != nil {
+		slog.Error("Error sending alert", "subject", email.subject, "to", email.to, "error", err)
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// queuedAlerts drains the alerts waiting for delivery
+func queuedAlerts(alertService *AlertService) []alertEmail {
+	var emails []alertEmail
+	for {
+		select {
+		case email := <-alertService.outbox:
+			emails = append(emails, email)
+		default:
+			return emails
+		}
+	}
+}
+
+func TestCategoryLimitAlertFiresOncePerMonth(t *testing.T) {
+	services := newTestServices(t)
+	alertService, finance := services.alerts, services.finance
+	alertService.AddRule(model.AlertRule{UserID: 1, Kind: "category_limit", Category: "Food", Threshold: 300_00})
+
+	add := func(amount model.Money, date string) {
+		t.Helper()
+		_, err := finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: amount, Category: "food", Date: mustParseDate(t, date)})
+		if err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	add(200_00, "2023-06-02")
+	if emails := queuedAlerts(alertService); len(emails) != 0 {
+		t.Fatalf("Expected no alert below the limit, got %+v", emails)
+	}
+
+	add(150_00, "2023-06-10")
+	emails := queuedAlerts(alertService)
+	if len(emails) != 1 {
+		t.Fatalf("Expected one alert, got %d", len(emails))
+	}
+	if emails[0].to != "john@example.com" || !strings.Contains(emails[0].subject, "Food") ||
+		!strings.Contains(emails[0].body, "Hello John") || !strings.Contains(emails[0].body, "reached 350.00 BRL") {
+		t.Errorf("Unexpected alert email: %+v", emails[0])
+	}
+
+	add(100_00, "2023-06-20")
+	if emails := queuedAlerts(alertService); len(emails) != 0 {
+		t.Errorf("Expected the rule to fire only once in June, got %+v", emails)
+	}
+
+	add(400_00, "2023-07-01")
+	if emails := queuedAlerts(alertService); len(emails) != 1 {
+		t.Errorf("Expected the rule to fire again in July, got %d", len(emails))
+	}
+}
+
+func TestCategoryLimitAlertFiresForBackdatedMonths(t *testing.T) {
+	services := newTestServices(t)
+	alertService, finance := services.alerts, services.finance
+	rule, _ := alertService.AddRule(model.AlertRule{UserID: 1, Kind: "category_limit", Category: "Food", Threshold: 300_00})
+
+	add := func(amount model.Money, date string) []alertEmail {
+		t.Helper()
+		_, err := finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: amount, Category: "Food", Date: mustParseDate(t, date)})
+		if err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+		return queuedAlerts(alertService)
+	}
+
+	if emails := add(400_00, "2023-07-05"); len(emails) != 1 {
+		t.Fatalf("Expected an alert for July, got %d", len(emails))
+	}
+	if emails := add(350_00, "2023-06-15"); len(emails) != 1 || !strings.Contains(emails[0].body, "for 2023-06") {
+		t.Fatalf("Expected an alert for the backdated June expense, got %+v", emails)
+	}
+	if emails := add(50_00, "2023-06-20"); len(emails) != 0 {
+		t.Errorf("Expected the rule to fire only once in June, got %+v", emails)
+	}
+	if emails := add(50_00, "2023-07-20"); len(emails) != 0 {
+		t.Errorf("Expected the rule to fire only once in July, got %+v", emails)
+	}
+
+	rule, _ = services.storages.alerts.Get(rule.ID)
+	if rule.LastPeriod != "2023-07" || !slices.Equal(rule.FiredPeriods, []string{"2023-06", "2023-07"}) {
+		t.Errorf("Expected the rule to have fired in June and July, got %q %v", rule.LastPeriod, rule.FiredPeriods)
+	}
+}
+
+func TestMarkFiredForgetsOldestMonths(t *testing.T) {
+	// Rules saved before FiredPeriods was kept only have LastPeriod
+	rule := model.AlertRule{LastPeriod: "2022-12"}
+	for month := 1; month <= maxFiredPeriods; month++ {
+		markFired(&rule, fmt.Sprintf("2023-%02d", month))
+	}
+	if len(rule.FiredPeriods) != maxFiredPeriods || rule.FiredPeriods[0] != "2023-01" || rule.LastPeriod != "2023-12" {
+		t.Fatalf("Expected the latest %d months, got %q %v", maxFiredPeriods, rule.LastPeriod, rule.FiredPeriods)
+	}
+	for period, fired := range map[string]bool{"2022-06": true, "2023-03": true, "2024-01": false} {
+		if firedIn(rule, period) != fired {
+			t.Errorf("Expected firedIn(%s) to be %v", period, fired)
+		}
+	}
+	if !firedIn(model.AlertRule{LastPeriod: "2023-06"}, "2023-06") || firedIn(model.AlertRule{LastPeriod: "2023-06"}, "2023-05") {
+		t.Error("Expected a legacy rule to have fired only in its last period")
+	}
+}
+
+func TestCategoryLimitAlertCountsSplitLines(t *testing.T) {
+	services := newTestServices(t)
+	alertService, finance := services.alerts, services.finance
+	alertService.AddRule(model.AlertRule{UserID: 1, Kind: "category_limit", Category: "Household", Threshold: 100_00})
+
+	finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 200_00, Category: "Supermarket", Date: mustParseDate(t, "2023-06-02"),
+		Splits: []model.Split{{Category: "Food", Amount: 120_00}, {Category: "Household", Amount: 80_00}}})
+	if emails := queuedAlerts(alertService); len(emails) != 0 {
+		t.Fatalf("Expected no alert for an 80.00 household line, got %+v", emails)
+	}
+
+	finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 90_00, Category: "Supermarket", Date: mustParseDate(t, "2023-06-09"),
+		Splits: []model.Split{{Category: "Food", Amount: 60_00}, {Category: "Household", Amount: 30_00}}})
+	emails := queuedAlerts(alertService)
+	if len(emails) != 1 || !strings.Contains(emails[0].body, "reached 110.00 BRL") {
+		t.Errorf("Expected one alert for 110.00 in household lines, got %+v", emails)
+	}
+}
+
+func TestLargeExpenseAlertConvertsCurrency(t *testing.T) {
+	services := newTestServices(t)
+	alertService, finance := services.alerts, services.finance
+	alertService.AddRule(model.AlertRule{UserID: 1, Kind: "large_expense", Threshold: 400_00})
+
+	finance.AddTransaction(model.Transaction{UserID: 1, Type: "income", Amount: 1000_00, Currency: "BRL", Date: mustParseDate(t, "2023-06-02")})
+	finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 70_00, Currency: "USD", Date: mustParseDate(t, "2023-06-02")})
+	if emails := queuedAlerts(alertService); len(emails) != 0 {
+		t.Fatalf("Expected no alert for income or 350 BRL, got %+v", emails)
+	}
+
+	finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 90_00, Currency: "USD",
+		Description: "New phone", Date: mustParseDate(t, "2023-06-03")})
+	emails := queuedAlerts(alertService)
+	if len(emails) != 1 || !strings.Contains(emails[0].body, "450.00 BRL was recorded on 2023-06-03 (New phone)") {
+		t.Errorf("Expected an alert for 450 BRL, got %+v", emails)
+	}
+}
+
+func TestLowBalanceAlertOnUpdate(t *testing.T) {
+	services := newTestServices(t)
+	alertService, finance := services.alerts, services.finance
+	alertService.AddRule(model.AlertRule{UserID: 1, Kind: "low_balance", Threshold: 100_00})
+
+	finance.AddTransaction(model.Transaction{UserID: 1, Type: "income", Amount: 500_00, Date: mustParseDate(t, "2023-06-01")})
+	expense, _ := finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 300_00, Date: mustParseDate(t, "2023-06-02")})
+	if emails := queuedAlerts(alertService); len(emails) != 0 {
+		t.Fatalf("Expected no alert with a balance of 200, got %+v", emails)
+	}
+
+	expense.Amount = 450_00
+	if err := finance.UpdateTransaction(1, "2", expense); err != nil {
+		t.Fatalf("Failed to update transaction: %v", err)
+	}
+	emails := queuedAlerts(alertService)
+	if len(emails) != 1 || !strings.Contains(emails[0].body, "balance is now 50.00 BRL") {
+		t.Errorf("Expected a low balance alert, got %+v", emails)
+	}
+}
+
+func TestAlertsOptOut(t *testing.T) {
+	services := newTestServices(t)
+	alertService, finance := services.alerts, services.finance
+	rule, _ := alertService.AddRule(model.AlertRule{UserID: 1, Kind: "large_expense", Threshold: 10_00})
+	john, _ := services.storages.users.Get(1)
+	john.AlertsOptOut = true
+	services.storages.users.Update(john)
+
+	finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 100_00, Date: mustParseDate(t, "2023-06-02")})
+	if emails := queuedAlerts(alertService); len(emails) != 0 {
+		t.Errorf("Expected no alerts after opting out, got %+v", emails)
+	}
+	if rule, _ = services.storages.alerts.Get(rule.ID); rule.LastPeriod != "" {
+		t.Error("Expected the rule not to be marked as fired")
+	}
+}
+
+func TestUpdateAlertRuleResetsPeriod(t *testing.T) {
+	services := newTestServices(t)
+	alertService := services.alerts
+	rule, _ := alertService.AddRule(model.AlertRule{UserID: 1, Kind: "low_balance", Category: "ignored", Threshold: 10_00})
+	if rule.Category != "" {
+		t.Errorf("Expected the category to be dropped from a low balance rule, got %q", rule.Category)
+	}
+	rule.LastPeriod, rule.FiredPeriods = "2023-06", []string{"2023-06"}
+	services.storages.alerts.Update(rule)
+
+	updated, err := alertService.UpdateRule(1, "1", model.AlertRule{Kind: "low_balance", Threshold: 20_00})
+	if err != nil || updated.LastPeriod != "" || updated.FiredPeriods != nil || updated.Threshold != 20_00 {
+		t.Errorf("Expected the updated rule to be able to fire again, got %+v (%v)", updated, err)
+	}
+	if _, err := alertService.UpdateRule(2, "1", updated); !errors.Is(err, ErrAlertRuleNotFound) {
+		t.Errorf("Expected rules of other users to be hidden, got %v", err)
+	}
+}
+
+type recordingMailer struct {
+	sent []string
+}
+
+func (m *recordingMailer) Send(to, subject, body string) error {
+	m.sent = append(m.sent, subject)
+	return nil
+}
+
+func TestAlertRunDeliversQueueOnShutdown(t *testing.T) {
+	services := newTestServices(t)
+	alertService, finance := services.alerts, services.finance
+	mailer := &recordingMailer{}
+	alertService.Mailer = mailer
+	alertService.AddRule(model.AlertRule{UserID: 1, Kind: "large_expense", Threshold: 10_00})
+
+	for _, date := range []string{"2023-06-02", "2023-07-02"} {
+		finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 100_00, Date: mustParseDate(t, date)})
+	}
+
+	// The server is already shutting down when the worker gets to the queue
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	alertService.Run(ctx)
+	if len(mailer.sent) != 2 || len(queuedAlerts(alertService)) != 0 {
+		t.Errorf("Expected both queued alerts to be delivered, got %q", mailer.sent)
+	}
+}
+
+func TestUserLocksOnlySerializeTheSameUser(t *testing.T) {
+	var locks userLocks
+	unlockJohn := locks.lock(1)
+
+	// Another user is not kept waiting
+	done := make(chan struct{})
+	go func() {
+		locks.lock(2)()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected another user's lock not to wait")
+	}
+
+	// The same user waits for the lock to be released
+	acquired := make(chan func())
+	go func() { acquired <- locks.lock(1) }()
+	select {
+	case <-acquired:
+		t.Fatal("Expected the same user's lock to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlockJohn()
+	(<-acquired)()
+
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected released locks to be dropped, got %d", len(locks.locks))
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned when budgets are looked up or saved
+var (
+	ErrBudgetNotFound = newError(KindNotFound, "budget_not_found", "Budget not found")
+	ErrBudgetExists   = newError(KindConflict, "budget_exists", "A budget for this category already exists")
+)
+
+type BudgetService struct {
+	Storage storage.BudgetStorage
+	Finance *FinanceService
+}
+
+func NewBudgetService(storage storage.BudgetStorage, finance *FinanceService) *BudgetService {
+	return &BudgetService{
+		Storage: storage,
+		Finance: finance,
+	}
+}
+
+// currentMonth returns today's month in the yyyy-mm format
+func currentMonth() string {
+	return time.Time(today()).Format(monthLayout)
+}
+
+// checkUnique makes sure the user has no other budget for the same category;
+// categories are compared ignoring case
+func (budgetService *BudgetService) checkUnique(budget model.Budget) error {
+	budgets, err := budgetService.Storage.FindByUser(budget.UserID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range budgets {
+		if existing.ID != budget.ID && strings.EqualFold(existing.Category, budget.Category) {
+			return ErrBudgetExists
+		}
+	}
+	return nil
+}
+
+// AddBudget stores a new budget. Budgets without a start month apply from
+// the current month.
+func (budgetService *BudgetService) AddBudget(budget model.Budget) (model.Budget, error) {
+	if budget.StartMonth == "" {
+		budget.StartMonth = currentMonth()
+	}
+	if err := budgetService.checkUnique(budget); err != nil {
+		return model.Budget{}, err
+	}
+	return budgetService.Storage.Insert(budget)
+}
+
+func (budgetService *BudgetService) ListBudgets(userID int) ([]model.Budget, error) {
+	return budgetService.Storage.FindByUser(userID)
+}
+
+// GetBudget loads a budget owned by the user. Budgets of other users are
+// reported as not found.
+func (budgetService *BudgetService) GetBudget(userID int, idString string) (model.Budget, error) {
+	id, _ := strconv.Atoi(idString)
+	budget, err := budgetService.Storage.Get(id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && budget.UserID != userID) {
+		return model.Budget{}, ErrBudgetNotFound
+	}
+	return budget, err
+}
+
+// UpdateBudget replaces a budget's category, limit, rollover and start month
+func (budgetService *BudgetService) UpdateBudget(userID int, idString string, updated model.Budget) (model.Budget, error) {
+	budget, err := budgetService.GetBudget(userID, idString)
+	if err != nil {
+		return model.Budget{}, err
+	}
+	updated.ID = budget.ID
+	updated.UserID = userID
+	if updated.StartMonth == "" {
+		updated.StartMonth = budget.StartMonth
+	}
+	if err := budgetService.checkUnique(updated); err != nil {
+		return model.Budget{}, err
+	}
+	if err := budgetService.Storage.Update(updated); err != nil {
+		return model.Budget{}, err
+	}
+	return updated, nil
+}
+
+func (budgetService *BudgetService) DeleteBudget(userID int, idString string) error {
+	budget, err := budgetService.GetBudget(userID, idString)
+	if err != nil {
+		return err
+	}
+	return budgetService.Storage.Delete(budget.ID)
+}
+
+// monthlySpending holds the expenses of each month, in total and by
+// lowercased category
+type monthlySpending struct {
+	total      map[string]model.Money
+	byCategory map[string]map[string]model.Money
+}
+
+func (spending monthlySpending) of(budget model.Budget, month string) model.Money {
+	if budget.Category == "" {
+		return spending.total[month]
+	}
+	return spending.byCategory[month][strings.ToLower(budget.Category)]
+}
+
+// Status compares every budget of the user with the expenses of month
+// (yyyy-mm, the current month when empty), converted to the user's base
+// currency. Budgets starting after month are left out. For budgets with
+// rollover, the unused part of each month since the start month is carried
+// over; overspending is never carried. Split expenses count towards the
+// categories of their lines.
+func (budgetService *BudgetService) Status(userID int, month string) (model.BudgetReport, error) {
+	if month == "" {
+		month = currentMonth()
+	}
+	target, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return model.BudgetReport{}, err
+	}
+	budgets, err := budgetService.Storage.FindByUser(userID)
+	if err != nil {
+		return model.BudgetReport{}, err
+	}
+
+	// Rollover needs the spending of every month since the earliest start
+	from := target
+	for _, budget := range budgets {
+		start, err := time.Parse(monthLayout, budget.StartMonth)
+		if err == nil && budget.Rollover && start.Before(from) {
+			from = start
+		}
+	}
+	filter := model.TransactionFilter{
+		From: model.DateOnly(from),
+		To:   model.DateOnly(target.AddDate(0, 1, -1)),
+		Type: "expense",
+	}
+	candidates, err := budgetService.Finance.Storage.FindByUser(userID, filter)
+	if err != nil {
+		return model.BudgetReport{}, err
+	}
+	currency, expenses, err := budgetService.Finance.inBaseCurrency(userID, reportPortions(candidates, filter))
+	if err != nil {
+		return model.BudgetReport{}, err
+	}
+
+	spending := monthlySpending{total: map[string]model.Money{}, byCategory: map[string]map[string]model.Money{}}
+	for _, expense := range expenses {
+		expenseMonth := time.Time(expense.Date).Format(monthLayout)
+		spending.total[expenseMonth] += expense.Amount
+		if spending.byCategory[expenseMonth] == nil {
+			spending.byCategory[expenseMonth] = map[string]model.Money{}
+		}
+		spending.byCategory[expenseMonth][strings.ToLower(expense.Category)] += expense.Amount
+	}
+
+	report := model.BudgetReport{Month: month, Currency: currency, Budgets: []model.BudgetStatus{}}
+	for _, budget := range budgets {
+		start, err := time.Parse(monthLayout, budget.StartMonth)
+		if err != nil || start.After(target) {
+			continue
+		}
+		var carried model.Money
+		if budget.Rollover {
+			for current := start; current.Before(target); current = current.AddDate(0, 1, 0) {
+				unused := budget.Limit + carried - spending.of(budget, current.Format(monthLayout))
+				carried = max(unused, 0)
+			}
+		}
+		status := model.BudgetStatus{
+			BudgetID:    budget.ID,
+			Category:    budget.Category,
+			Limit:       budget.Limit,
+			CarriedOver: carried,
+			Available:   budget.Limit + carried,
+			Spent:       spending.of(budget, month),
+		}
+		status.Remaining = status.Available - status.Spent
+		status.Overspent = status.Remaining < 0
+		report.Budgets = append(report.Budgets, status)
+	}
+	return report, nil
+}
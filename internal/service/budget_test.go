@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// budgetTransactions are John's expenses from April to June 2023, with
+// income and another user's expense that budgets must leave out
+func budgetTransactions(t *testing.T) []model.Transaction {
+	return []model.Transaction{
+		{UserID: 1, Type: "expense", Amount: 500_00, Currency: "BRL", Category: "Food", Date: mustParseDate(t, "2023-04-10")},
+		{UserID: 1, Type: "expense", Amount: 900_00, Currency: "BRL", Category: "food", Date: mustParseDate(t, "2023-05-10")},
+		{UserID: 1, Type: "expense", Amount: 100_00, Currency: "USD", Category: "Food", Date: mustParseDate(t, "2023-06-02")},
+		{UserID: 1, Type: "expense", Amount: 1200_00, Currency: "BRL", Category: "Rent", Date: mustParseDate(t, "2023-06-05")},
+		{UserID: 1, Type: "income", Amount: 5000_00, Currency: "BRL", Category: "Food", Date: mustParseDate(t, "2023-06-05")},
+		{UserID: 2, Type: "expense", Amount: 999_00, Currency: "BRL", Category: "Food", Date: mustParseDate(t, "2023-06-05")},
+	}
+}
+
+func TestBudgetStatus(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, budgetTransactions(t)...)
+	budgetService := services.budgets
+	overall, _ := budgetService.AddBudget(model.Budget{UserID: 1, Limit: 1500_00, StartMonth: "2023-04"})
+	food, _ := budgetService.AddBudget(model.Budget{UserID: 1, Category: "FOOD", Limit: 800_00, Rollover: true, StartMonth: "2023-04"})
+	budgetService.AddBudget(model.Budget{UserID: 1, Category: "Travel", Limit: 100_00, StartMonth: "2023-07"})
+
+	report, err := budgetService.Status(1, "2023-06")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Month != "2023-06" || report.Currency != "BRL" || len(report.Budgets) != 2 {
+		t.Fatalf("Expected the two budgets started by June in BRL, got %+v", report)
+	}
+
+	// 100 USD at 5 BRL plus the 1200 rent; income does not count
+	expectedOverall := model.BudgetStatus{BudgetID: overall.ID, Limit: 1500_00, Available: 1500_00,
+		Spent: 1700_00, Remaining: -200_00, Overspent: true}
+	if report.Budgets[0] != expectedOverall {
+		t.Errorf("Expected %+v, got %+v", expectedOverall, report.Budgets[0])
+	}
+
+	// April leaves 300 unused; May uses 900 of 1100, carrying 200 into June
+	expectedFood := model.BudgetStatus{BudgetID: food.ID, Category: "FOOD", Limit: 800_00, CarriedOver: 200_00,
+		Available: 1000_00, Spent: 500_00, Remaining: 500_00}
+	if report.Budgets[1] != expectedFood {
+		t.Errorf("Expected %+v, got %+v", expectedFood, report.Budgets[1])
+	}
+}
+
+func TestBudgetRolloverNeverCarriesOverspending(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, budgetTransactions(t)...)
+	budgetService := services.budgets
+	budgetService.AddBudget(model.Budget{UserID: 1, Category: "Food", Limit: 400_00, Rollover: true, StartMonth: "2023-04"})
+
+	report, err := budgetService.Status(1, "2023-06")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status := report.Budgets[0]; status.CarriedOver != 0 || status.Remaining != -100_00 {
+		t.Errorf("Expected nothing carried after overspending, got %+v", status)
+	}
+}
+
+func TestBudgetStatusCountsSplitLines(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, budgetTransactions(t)...)
+	budgetService := services.budgets
+	seed(t, services.storages.finance.Insert, model.Transaction{UserID: 1, Type: "expense", Amount: 300_00, Currency: "BRL",
+		Category: "Supermarket", Date: mustParseDate(t, "2023-06-10"),
+		Splits: []model.Split{{Category: "Food", Amount: 250_00}, {Category: "Household", Amount: 50_00}}})
+	budgetService.AddBudget(model.Budget{UserID: 1, Category: "Food", Limit: 800_00, StartMonth: "2023-06"})
+	budgetService.AddBudget(model.Budget{UserID: 1, Category: "Household", Limit: 100_00, StartMonth: "2023-06"})
+	budgetService.AddBudget(model.Budget{UserID: 1, Category: "Supermarket", Limit: 100_00, StartMonth: "2023-06"})
+
+	report, err := budgetService.Status(1, "2023-06")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// 100 USD at 5 BRL plus the Food line of the split expense
+	spent := map[string]model.Money{"Food": 750_00, "Household": 50_00, "Supermarket": 0}
+	for _, status := range report.Budgets {
+		if status.Spent != spent[status.Category] {
+			t.Errorf("Expected %s to have spent %v, got %v", status.Category, spent[status.Category], status.Spent)
+		}
+	}
+}
+
+func TestBudgetUniquePerCategory(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, budgetTransactions(t)...)
+	budgetService := services.budgets
+	food, _ := budgetService.AddBudget(model.Budget{UserID: 1, Category: "Food", Limit: 800_00})
+	if food.StartMonth != currentMonth() {
+		t.Errorf("Expected the budget to start this month, got %q", food.StartMonth)
+	}
+
+	if _, err := budgetService.AddBudget(model.Budget{UserID: 1, Category: "food", Limit: 100_00}); !errors.Is(err, ErrBudgetExists) {
+		t.Errorf("Expected a duplicate category to be rejected, got %v", err)
+	}
+	if _, err := budgetService.AddBudget(model.Budget{UserID: 2, Category: "Food", Limit: 100_00}); err != nil {
+		t.Errorf("Expected other users to have their own budgets, got %v", err)
+	}
+
+	food.Limit = 900_00
+	if _, err := budgetService.UpdateBudget(1, "1", food); err != nil {
+		t.Errorf("Expected a budget to keep its own category, got %v", err)
+	}
+	if _, err := budgetService.UpdateBudget(2, "1", food); !errors.Is(err, ErrBudgetNotFound) {
+		t.Errorf("Expected budgets of other users to be hidden, got %v", err)
+	}
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Errors returned when categories are looked up, saved, deleted or merged
+var (
+	ErrCategoryNotFound       = newError(KindNotFound, "category_not_found", "Category not found")
+	ErrTargetCategoryNotFound = newError(KindInvalid, "target_category_not_found", "Target category not found")
+	ErrParentCategoryNotFound = newError(KindInvalid, "parent_category_not_found", "Parent category not found")
+	ErrParentCategoryType     = newError(KindInvalid, "parent_category_type_mismatch", "A category must have the same type as its parent")
+	ErrCategoryCycle          = newError(KindInvalid, "category_cycle", "A category cannot be nested under itself or its subcategories")
+	ErrCategorySelfMerge      = newError(KindInvalid, "category_self_merge", "A category cannot be merged into itself")
+	ErrCategoryMergeType      = newError(KindInvalid, "category_merge_type_mismatch", "Only categories of the same type can be merged")
+	ErrCategoryExists         = newError(KindConflict, "category_exists", "A category with this name already exists")
+	ErrCategoryTypeLocked     = newError(KindConflict, "category_type_locked", "The type of a category in use or with subcategories cannot change")
+	ErrCategoryHasChildren    = newError(KindConflict, "category_has_subcategories", "Delete or move the subcategories first")
+	ErrCategoryInUse          = newError(KindConflict, "category_in_use", "The category is used by transactions or recurring rules; merge it into another category instead")
+)
+
+type CategoryService struct {
+	Storage      storage.CategoryStorage
+	Transactions storage.FinanceStorage
+	Recurring    storage.RecurringStorage
+	Budgets      storage.BudgetStorage
+	Alerts       storage.AlertStorage
+}
+
+func NewCategoryService(storage storage.CategoryStorage, transactions storage.FinanceStorage, recurring storage.RecurringStorage,
+	budgets storage.BudgetStorage, alerts storage.AlertStorage) *CategoryService {
+	return &CategoryService{
+		Storage:      storage,
+		Transactions: transactions,
+		Recurring:    recurring,
+		Budgets:      budgets,
+		Alerts:       alerts,
+	}
+}
+
+// IsValidCategoryType reports whether categoryType is one of model.CategoryTypes
+func IsValidCategoryType(categoryType string) bool {
+	return slices.Contains(model.CategoryTypes, categoryType)
+}
+
+// findCategory looks a category up by name, ignoring case and surrounding
+// spaces, or by ID when name is empty
+func findCategory(categories []model.Category, name string, id int) (model.Category, bool) {
+	name = strings.TrimSpace(name)
+	for _, category := range categories {
+		if (name != "" && strings.EqualFold(category.Name, name)) || (name == "" && category.ID == id) {
+			return category, true
+		}
+	}
+	return model.Category{}, false
+}
+
+// refersTo reports whether a category name and ID, of a transaction or a
+// split line, point at category: through the ID or, for entries recorded
+// before the category existed, through the name
+func refersTo(name string, id int, category model.Category) bool {
+	if id != 0 {
+		return id == category.ID
+	}
+	return strings.EqualFold(strings.TrimSpace(name), category.Name)
+}
+
+// usesCategory reports whether a transaction or one of its split lines
+// belongs to category
+func usesCategory(transaction model.Transaction, category model.Category) bool {
+	if transaction.Type != category.Type {
+		return false
+	}
+	return refersTo(transaction.Category, transaction.CategoryID, category) ||
+		slices.ContainsFunc(transaction.Splits, func(split model.Split) bool { return refersTo(split.Category, split.CategoryID, category) })
+}
+
+// validateCategory checks that the name is unused and that the parent is a
+// category of the same user and type that does not descend from category
+func (categoryService *CategoryService) validateCategory(category *model.Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	categories, err := categoryService.Storage.FindByUser(category.UserID)
+	if err != nil {
+		return err
+	}
+	if existing, found := findCategory(categories, category.Name, 0); found && existing.ID != category.ID {
+		return ErrCategoryExists
+	}
+	if category.ParentID == 0 {
+		return nil
+	}
+
+	parent, found := findCategory(categories, "", category.ParentID)
+	if !found {
+		return ErrParentCategoryNotFound
+	}
+	if parent.Type != category.Type {
+		return ErrParentCategoryType
+	}
+	if parent.ID == category.ID || descendsFrom(categories, parent, category.ID) {
+		return ErrCategoryCycle
+	}
+	return nil
+}
+
+// AddCategory stores a new category in the user's catalog
+func (categoryService *CategoryService) AddCategory(category model.Category) (model.Category, error) {
+	category.ID = 0
+	if err := categoryService.validateCategory(&category); err != nil {
+		return model.Category{}, err
+	}
+	return categoryService.Storage.Insert(category)
+}
+
+func (categoryService *CategoryService) ListCategories(userID int) ([]model.Category, error) {
+	return categoryService.Storage.FindByUser(userID)
+}
+
+// GetCategory loads a category owned by the user. Categories of other users
+// are reported as not found.
+func (categoryService *CategoryService) GetCategory(userID int, idString string) (model.Category, error) {
+	id, _ := strconv.Atoi(idString)
+	category, err := categoryService.Storage.Get(id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && category.UserID != userID) {
+		return model.Category{}, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+// ruleUsesCategory reports whether a recurring rule, or one of its
+// occurrence overrides, belongs to category
+func ruleUsesCategory(rule model.RecurringRule, category model.Category) bool {
+	if rule.Type != category.Type {
+		return false
+	}
+	return strings.EqualFold(rule.Category, category.Name) ||
+		slices.ContainsFunc(rule.Overrides, func(override model.OccurrenceOverride) bool {
+			return override.Category != nil && strings.EqualFold(*override.Category, category.Name)
+		})
+}
+
+// inUse reports whether any transaction or recurring rule refers to category
+func (categoryService *CategoryService) inUse(category model.Category) (bool, error) {
+	transactions, err := categoryService.Transactions.FindByUser(category.UserID, model.TransactionFilter{})
+	if err != nil {
+		return false, err
+	}
+	if slices.ContainsFunc(transactions, func(transaction model.Transaction) bool { return usesCategory(transaction, category) }) {
+		return true, nil
+	}
+	rules, err := categoryService.Recurring.FindByUser(category.UserID)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(rules, func(rule model.RecurringRule) bool { return ruleUsesCategory(rule, category) }), nil
+}
+
+// hasSubcategories reports whether any category is nested under category
+func (categoryService *CategoryService) hasSubcategories(category model.Category) (bool, error) {
+	categories, err := categoryService.Storage.FindByUser(category.UserID)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(categories, func(existing model.Category) bool { return existing.ParentID == category.ID }), nil
+}
+
+// repoint moves the transactions, split lines, recurring rules and their
+// overrides, budgets and category alerts of from to to. The transactions are
+// rewritten together; the others are updated one at a time, so an
+// interrupted call can simply be repeated.
+func (categoryService *CategoryService) repoint(from, to model.Category) error {
+	transactions, err := categoryService.Transactions.FindByUser(from.UserID, model.TransactionFilter{})
+	if err != nil {
+		return err
+	}
+	var changed []model.Transaction
+	for _, transaction := range transactions {
+		if !usesCategory(transaction, from) {
+			continue
+		}
+		if refersTo(transaction.Category, transaction.CategoryID, from) {
+			transaction.Category, transaction.CategoryID = to.Name, to.ID
+		}
+		transaction.Splits = slices.Clone(transaction.Splits)
+		for index, split := range transaction.Splits {
+			if refersTo(split.Category, split.CategoryID, from) {
+				transaction.Splits[index].Category, transaction.Splits[index].CategoryID = to.Name, to.ID
+			}
+		}
+		changed = append(changed, transaction)
+	}
+	if len(changed) > 0 {
+		if err := categoryService.Transactions.UpdateMany(changed); err != nil {
+			return err
+		}
+	}
+
+	rules, err := categoryService.Recurring.FindByUser(from.UserID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if !ruleUsesCategory(rule, from) {
+			continue
+		}
+		if strings.EqualFold(rule.Category, from.Name) {
+			rule.Category = to.Name
+		}
+		rule.Overrides = slices.Clone(rule.Overrides)
+		for index, override := range rule.Overrides {
+			if override.Category != nil && strings.EqualFold(*override.Category, from.Name) {
+				rule.Overrides[index].Category = &to.Name
+			}
+		}
+		if err := categoryService.Recurring.Update(rule); err != nil {
+			return err
+		}
+	}
+
+	// Budgets and alerts only track expenses
+	if from.Type != "expense" {
+		return nil
+	}
+	return categoryService.repointLimits(from, to)
+}
+
+// repointLimits moves the budgets and category limit alerts of from to to.
+// Since a category has one budget at most, a budget of from is dropped when
+// to already has its own.
+func (categoryService *CategoryService) repointLimits(from, to model.Category) error {
+	budgets, err := categoryService.Budgets.FindByUser(from.UserID)
+	if err != nil {
+		return err
+	}
+	targetHasBudget := slices.ContainsFunc(budgets, func(budget model.Budget) bool {
+		return strings.EqualFold(budget.Category, to.Name) && !strings.EqualFold(budget.Category, from.Name)
+	})
+	for _, budget := range budgets {
+		if !strings.EqualFold(budget.Category, from.Name) {
+			continue
+		}
+		if targetHasBudget {
+			err = categoryService.Budgets.Delete(budget.ID)
+		} else {
+			budget.Category = to.Name
+			err = categoryService.Budgets.Update(budget)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	rules, err := categoryService.Alerts.FindByUser(from.UserID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.Kind == "category_limit" && strings.EqualFold(rule.Category, from.Name) {
+			rule.Category = to.Name
+			if err := categoryService.Alerts.Update(rule); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateCategory replaces a category's details. Renaming a category renames
+// it on its transactions, recurring rules, budgets and alerts too. The type of a category
+// cannot change once it is used or has subcategories.
+func (categoryService *CategoryService) UpdateCategory(userID int, idString string, updated model.Category) (model.Category, error) {
+	category, err := categoryService.GetCategory(userID, idString)
+	if err != nil {
+		return model.Category{}, err
+	}
+	updated.ID = category.ID
+	updated.UserID = userID
+	if updated.Type != category.Type {
+		used, err := categoryService.inUse(category)
+		if err != nil {
+			return model.Category{}, err
+		}
+		nested, err := categoryService.hasSubcategories(category)
+		if err != nil {
+			return model.Category{}, err
+		}
+		if used || nested {
+			return model.Category{}, ErrCategoryTypeLocked
+		}
+	}
+	if err := categoryService.validateCategory(&updated); err != nil {
+		return model.Category{}, err
+	}
+	if err := categoryService.Storage.Update(updated); err != nil {
+		return model.Category{}, err
+	}
+	if updated.Name != category.Name && updated.Type == category.Type {
+		if err := categoryService.repoint(category, updated); err != nil {
+			return model.Category{}, err
+		}
+	}
+	return updated, nil
+}
+
+// DeleteCategory removes a category that has no subcategories and is not
+// used by any transaction or recurring rule
+func (categoryService *CategoryService) DeleteCategory(userID int, idString string) error {
+	category, err := categoryService.GetCategory(userID, idString)
+	if err != nil {
+		return err
+	}
+	nested, err := categoryService.hasSubcategories(category)
+	if err != nil {
+		return err
+	}
+	if nested {
+		return ErrCategoryHasChildren
+	}
+	used, err := categoryService.inUse(category)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrCategoryInUse
+	}
+	return categoryService.Storage.Delete(category.ID)
+}
+
+// MergeCategory folds the category into another one of the same type: its
+// transactions, recurring rules, budgets and alerts move to the target, its subcategories are
+// nested under the target, and the category is deleted. A target nested
+// under the category first takes the category's place in the hierarchy.
+func (categoryService *CategoryService) MergeCategory(userID int, idString string, targetID int) (model.Category, error) {
+	source, err := categoryService.GetCategory(userID, idString)
+	if err != nil {
+		return model.Category{}, err
+	}
+	target, err := categoryService.GetCategory(userID, strconv.Itoa(targetID))
+	if err != nil {
+		return model.Category{}, ErrTargetCategoryNotFound
+	}
+	if source.ID == target.ID {
+		return model.Category{}, ErrCategorySelfMerge
+	}
+	if source.Type != target.Type {
+		return model.Category{}, ErrCategoryMergeType
+	}
+
+	categories, err := categoryService.Storage.FindByUser(userID)
+	if err != nil {
+		return model.Category{}, err
+	}
+	if descendsFrom(categories, target, source.ID) {
+		target.ParentID = source.ParentID
+		if err := categoryService.Storage.Update(target); err != nil {
+			return model.Category{}, err
+		}
+	}
+	if err := categoryService.repoint(source, target); err != nil {
+		return model.Category{}, err
+	}
+	for _, child := range categories {
+		if child.ParentID == source.ID && child.ID != target.ID {
+			child.ParentID = target.ID
+			if err := categoryService.Storage.Update(child); err != nil {
+				return model.Category{}, err
+			}
+		}
+	}
+	if err := categoryService.Storage.Delete(source.ID); err != nil {
+		return model.Category{}, err
+	}
+	return target, nil
+}
+
+// descendsFrom reports whether category is nested, at any depth, under the
+// category with ancestorID
+func descendsFrom(categories []model.Category, category model.Category, ancestorID int) bool {
+	for category.ParentID != 0 {
+		if category.ParentID == ancestorID {
+			return true
+		}
+		parent, found := findCategory(categories, "", category.ParentID)
+		if !found {
+			return false
+		}
+		category = parent
+	}
+	return false
+}
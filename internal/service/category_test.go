@@ -0,0 +1,306 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// categoryCatalog gives user 1 the expense categories Food, Groceries (under
+// Food) and Rent and the income category Salary; user 2 has a Food category
+func categoryCatalog() []model.Category {
+	return []model.Category{
+		{UserID: 1, Name: "Food", Type: "expense"},
+		{UserID: 1, Name: "Groceries", Type: "expense", ParentID: 1},
+		{UserID: 1, Name: "Rent", Type: "expense"},
+		{UserID: 1, Name: "Salary", Type: "income"},
+		{UserID: 2, Name: "Food", Type: "expense"},
+	}
+}
+
+func TestAddCategory(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert, categoryCatalog()...)
+	categoryService := services.categories
+
+	tests := []struct {
+		name     string
+		category model.Category
+		wantName string
+		wantErr  error
+	}{
+		{"nested", model.Category{UserID: 1, Name: " Restaurants ", Type: "expense", ParentID: 1, Color: "#ff9800"}, "Restaurants", nil},
+		{"duplicate name ignoring case", model.Category{UserID: 1, Name: "groceries", Type: "expense"}, "", ErrCategoryExists},
+		{"same name as another user's", model.Category{UserID: 2, Name: "Rent", Type: "expense"}, "Rent", nil},
+		{"parent of another user", model.Category{UserID: 2, Name: "Snacks", Type: "expense", ParentID: 1}, "", ErrParentCategoryNotFound},
+		{"parent of another type", model.Category{UserID: 1, Name: "Bonus", Type: "income", ParentID: 1}, "", ErrParentCategoryType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			added, err := categoryService.AddCategory(test.category)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Expected error %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if added.ID == 0 || added.Name != test.wantName {
+				t.Errorf("Expected a stored category named %q, got %+v", test.wantName, added)
+			}
+		})
+	}
+}
+
+func TestUpdateCategoryRejectsCycles(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert, categoryCatalog()...)
+	categoryService := services.categories
+
+	_, err := categoryService.UpdateCategory(1, "1", model.Category{Name: "Food", Type: "expense", ParentID: 2})
+	if !errors.Is(err, ErrCategoryCycle) {
+		t.Errorf("Expected a cycle to be rejected, got %v", err)
+	}
+	_, err = categoryService.UpdateCategory(1, "1", model.Category{Name: "Food", Type: "expense", ParentID: 1})
+	if !errors.Is(err, ErrCategoryCycle) {
+		t.Errorf("Expected a self parent to be rejected, got %v", err)
+	}
+	if _, err := categoryService.UpdateCategory(2, "1", model.Category{Name: "Food", Type: "expense"}); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("Expected another user's category to be reported as not found, got %v", err)
+	}
+}
+
+func TestUpdateCategoryRenamesTransactionsAndRules(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert, categoryCatalog()...)
+	categoryService := services.categories
+	date := mustParseDate(t, "2023-06-10")
+	if _, err := services.finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 1200_00, Category: "rent", Date: date}); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	other := "rent"
+	rules := seed(t, services.storages.recurring.Insert,
+		model.RecurringRule{UserID: 1, Type: "expense", Category: "Rent", Amount: 1200_00},
+		model.RecurringRule{UserID: 1, Type: "expense", Category: "Food", Amount: 50_00,
+			Overrides: []model.OccurrenceOverride{{Date: date, Category: &other}}},
+	)
+
+	if _, err := categoryService.UpdateCategory(1, "3", model.Category{Name: "Housing", Type: "expense"}); err != nil {
+		t.Fatalf("Failed to rename category: %v", err)
+	}
+	if transaction, _ := services.storages.finance.Get(1); transaction.Category != "Housing" || transaction.CategoryID != 3 {
+		t.Errorf("Expected the transaction to follow the rename, got %+v", transaction)
+	}
+	if rent, _ := services.storages.recurring.Get(rules[0].ID); rent.Category != "Housing" {
+		t.Errorf("Expected the recurring rule to follow the rename, got %q", rent.Category)
+	}
+	food, _ := services.storages.recurring.Get(rules[1].ID)
+	if len(food.Overrides) != 1 || food.Category != "Food" || *food.Overrides[0].Category != "Housing" {
+		t.Errorf("Expected only the override to follow the rename, got %+v", food)
+	}
+
+	_, err := categoryService.UpdateCategory(1, "3", model.Category{Name: "Housing", Type: "income"})
+	if !errors.Is(err, ErrCategoryTypeLocked) {
+		t.Errorf("Expected the type of a used category to be locked, got %v", err)
+	}
+}
+
+func TestDeleteCategory(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert, categoryCatalog()...)
+	categoryService := services.categories
+	if _, err := services.finance.AddTransaction(model.Transaction{UserID: 1, Type: "income", Amount: 5000_00, Category: "Salary", Date: mustParseDate(t, "2023-06-05")}); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+
+	if err := categoryService.DeleteCategory(1, "1"); !errors.Is(err, ErrCategoryHasChildren) {
+		t.Errorf("Expected a parent category to be kept, got %v", err)
+	}
+	if err := categoryService.DeleteCategory(1, "4"); !errors.Is(err, ErrCategoryInUse) {
+		t.Errorf("Expected a used category to be kept, got %v", err)
+	}
+	// An occurrence override also keeps the category in use
+	rent := "Rent"
+	rules := seed(t, services.storages.recurring.Insert, model.RecurringRule{UserID: 1, Type: "expense", Category: "Food", Amount: 50_00,
+		Overrides: []model.OccurrenceOverride{{Date: mustParseDate(t, "2023-07-10"), Category: &rent}}})
+	if err := categoryService.DeleteCategory(1, "3"); !errors.Is(err, ErrCategoryInUse) {
+		t.Errorf("Expected a category used by an override to be kept, got %v", err)
+	}
+
+	services.storages.recurring.Delete(rules[0].ID)
+	if err := categoryService.DeleteCategory(1, "3"); err != nil {
+		t.Errorf("Expected an unused category to be deleted, got %v", err)
+	}
+}
+
+func TestUpdateCategoryRenamesBudgetsAndAlerts(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert, categoryCatalog()...)
+	categoryService := services.categories
+	budgets := seed(t, services.storages.budgets.Insert,
+		model.Budget{UserID: 1, Category: "rent", Limit: 1200_00},
+		model.Budget{UserID: 2, Category: "Rent"},
+	)
+	alerts := seed(t, services.storages.alerts.Insert,
+		model.AlertRule{UserID: 1, Kind: "category_limit", Category: "Rent", Threshold: 1000_00},
+		model.AlertRule{UserID: 1, Kind: "large_expense", Threshold: 500_00},
+	)
+
+	if _, err := categoryService.UpdateCategory(1, "3", model.Category{Name: "Housing", Type: "expense"}); err != nil {
+		t.Fatalf("Failed to rename category: %v", err)
+	}
+	johns, _ := services.storages.budgets.Get(budgets[0].ID)
+	janes, _ := services.storages.budgets.Get(budgets[1].ID)
+	if johns.Category != "Housing" || janes.Category != "Rent" {
+		t.Errorf("Expected only the user's budget to follow the rename, got %+v and %+v", johns, janes)
+	}
+	limit, _ := services.storages.alerts.Get(alerts[0].ID)
+	large, _ := services.storages.alerts.Get(alerts[1].ID)
+	if limit.Category != "Housing" || large.Category != "" {
+		t.Errorf("Expected the category alert to follow the rename, got %+v and %+v", limit, large)
+	}
+}
+
+func TestMergeCategoryMovesBudgetsAndAlerts(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert, categoryCatalog()...)
+	categoryService := services.categories
+	budgets := seed(t, services.storages.budgets.Insert,
+		model.Budget{UserID: 1, Category: "Food", Limit: 600_00},
+		model.Budget{UserID: 1, Category: "Rent", Limit: 1200_00},
+	)
+	alerts := seed(t, services.storages.alerts.Insert,
+		model.AlertRule{UserID: 1, Kind: "category_limit", Category: "Groceries", Threshold: 300_00})
+
+	// Groceries has no budget of its own; its alert moves to Food
+	if _, err := categoryService.MergeCategory(1, "2", 1); err != nil {
+		t.Fatalf("Failed to merge category: %v", err)
+	}
+	if alert, _ := services.storages.alerts.Get(alerts[0].ID); alert.Category != "Food" {
+		t.Errorf("Expected the alert to move to Food, got %+v", alert)
+	}
+
+	// Rent has its own budget, which is kept instead of Food's
+	if _, err := categoryService.MergeCategory(1, "1", 3); err != nil {
+		t.Fatalf("Failed to merge category: %v", err)
+	}
+	left, _ := services.storages.budgets.FindByUser(1)
+	if len(left) != 1 || left[0].ID != budgets[1].ID || left[0].Category != "Rent" {
+		t.Errorf("Expected only Rent's budget to be left, got %+v", left)
+	}
+	if alert, _ := services.storages.alerts.Get(alerts[0].ID); alert.Category != "Rent" {
+		t.Errorf("Expected the alert to follow the second merge, got %+v", alert)
+	}
+}
+
+func TestMergeCategory(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert, categoryCatalog()...)
+	categoryService := services.categories
+	date := mustParseDate(t, "2023-06-10")
+	if _, err := services.finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 80_00, Category: "Food", Date: date}); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	// Recorded as free text before the catalog existed
+	seed(t, services.storages.finance.Insert, model.Transaction{UserID: 1, Type: "expense", Amount: 20_00, Category: "food ", Date: date})
+	rules := seed(t, services.storages.recurring.Insert, model.RecurringRule{UserID: 1, Type: "expense", Category: "food", Amount: 50_00})
+
+	_, err := categoryService.MergeCategory(1, "1", 4)
+	if !errors.Is(err, ErrCategoryMergeType) {
+		t.Errorf("Expected merging into an income category to fail, got %v", err)
+	}
+	if errors.Is(err, ErrCategoryTypeMismatch) {
+		t.Errorf("Expected the merge error to have a code of its own, got %v", err)
+	}
+	if _, err := categoryService.MergeCategory(1, "1", 5); !errors.Is(err, ErrTargetCategoryNotFound) {
+		t.Errorf("Expected merging into another user's category to fail, got %v", err)
+	}
+
+	// Groceries is nested under Food, so it takes Food's place at the top
+	target, err := categoryService.MergeCategory(1, "1", 2)
+	if err != nil {
+		t.Fatalf("Failed to merge category: %v", err)
+	}
+	if target.ID != 2 || target.ParentID != 0 {
+		t.Errorf("Expected Groceries to become a top-level category, got %+v", target)
+	}
+	transactions, _ := services.storages.finance.FindByUser(1, model.TransactionFilter{})
+	for _, transaction := range transactions {
+		if transaction.Category != "Groceries" || transaction.CategoryID != 2 {
+			t.Errorf("Expected the transaction to move to Groceries, got %+v", transaction)
+		}
+	}
+	if rule, _ := services.storages.recurring.Get(rules[0].ID); rule.Category != "Groceries" {
+		t.Errorf("Expected the recurring rule to move to Groceries, got %q", rule.Category)
+	}
+	if _, err := categoryService.GetCategory(1, "1"); err == nil {
+		t.Error("Expected the merged category to be deleted")
+	}
+}
+
+func TestMergeCategoryMovesSplitLines(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert, categoryCatalog()...)
+	categoryService := services.categories
+	_, err := services.finance.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 100_00, Date: mustParseDate(t, "2023-06-10"),
+		Splits: []model.Split{{Category: "Rent", Amount: 90_00}, {Category: "Groceries", Amount: 10_00}}})
+	if err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+
+	if err := categoryService.DeleteCategory(1, "3"); !errors.Is(err, ErrCategoryInUse) {
+		t.Errorf("Expected a category used by a split line to be kept, got %v", err)
+	}
+	if _, err := categoryService.MergeCategory(1, "2", 1); err != nil {
+		t.Fatalf("Failed to merge category: %v", err)
+	}
+	expected := []model.Split{{Category: "Rent", CategoryID: 3, Amount: 90_00}, {Category: "Food", CategoryID: 1, Amount: 10_00}}
+	if transaction, _ := services.storages.finance.Get(1); !slices.Equal(transaction.Splits, expected) {
+		t.Errorf("Expected the Groceries line to move to Food, got %+v", transaction.Splits)
+	}
+}
+
+func TestTransactionCategoryValidation(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert, categoryCatalog()...)
+	finance := services.finance
+	date := mustParseDate(t, "2023-06-10")
+
+	tests := []struct {
+		name         string
+		transaction  model.Transaction
+		wantCategory string
+		wantID       int
+		wantErr      error
+	}{
+		{"name ignoring case and spaces", model.Transaction{UserID: 1, Type: "expense", Category: " groceries "}, "Groceries", 2, nil},
+		{"by ID", model.Transaction{UserID: 1, Type: "income", CategoryID: 4}, "Salary", 4, nil},
+		{"uncategorized", model.Transaction{UserID: 1, Type: "expense"}, "", 0, nil},
+		{"unknown name", model.Transaction{UserID: 1, Type: "expense", Category: "Travel"}, "", 0, ErrUnknownCategory},
+		{"another user's ID", model.Transaction{UserID: 1, Type: "expense", CategoryID: 5}, "", 0, ErrUnknownCategory},
+		{"type mismatch", model.Transaction{UserID: 1, Type: "income", Category: "Food"}, "", 0, ErrCategoryTypeMismatch},
+		{"free text without a catalog", model.Transaction{UserID: 3, Type: "expense", Category: " Travel "}, "Travel", 0, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.transaction.Amount, test.transaction.Date = 10_00, date
+			saved, err := finance.AddTransaction(test.transaction)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Expected error %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if saved.Category != test.wantCategory || saved.CategoryID != test.wantID {
+				t.Errorf("Expected category %q (%d), got %q (%d)", test.wantCategory, test.wantID, saved.Category, saved.CategoryID)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"io"
+	"strings"
+)
+
+// ErrNoExchangeRate is returned when an amount has to be converted but no
+// rate for the pair is dated on or before the transaction
+var ErrNoExchangeRate = newError(KindUnprocessable, "no_exchange_rate", "No exchange rate available")
+
+// ErrInvalidRateFile is returned when an exchange rate CSV cannot be imported
+var ErrInvalidRateFile = newError(KindInvalid, "invalid_rate_file", "Invalid exchange rate file")
+
+// ErrConversionOutOfRange is returned when an amount converted to another
+// currency is too large to be stored
+var ErrConversionOutOfRange = newError(KindUnprocessable, "conversion_out_of_range", "The converted amount is too large")
+
+type CurrencyService struct {
+	Rates storage.ExchangeRateStorage
+	Users storage.UserStorage
+}
+
+func NewCurrencyService(rates storage.ExchangeRateStorage, users storage.UserStorage) *CurrencyService {
+	return &CurrencyService{
+		Rates: rates,
+		Users: users,
+	}
+}
+
+// BaseCurrency returns the currency the user's totals are converted to
+func (currencyService *CurrencyService) BaseCurrency(userID int) (string, error) {
+	user, err := currencyService.Users.Get(userID)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && user.BaseCurrency == "") {
+		return model.DefaultCurrency, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.BaseCurrency, nil
+}
+
+// Convert converts amount from one currency to another using the newest rate
+// dated on or before date
+func (currencyService *CurrencyService) Convert(amount model.Money, from, to string, date model.DateOnly) (model.Money, error) {
+	if from == to {
+		return amount, nil
+	}
+	conversion, err := currencyService.conversion(from, to, date)
+	if err != nil {
+		return 0, err
+	}
+	return conversion(amount)
+}
+
+// conversion looks up the rate between two currencies on a date. When only
+// the opposite pair has been imported its rate is applied in reverse.
+func (currencyService *CurrencyService) conversion(from, to string, date model.DateOnly) (func(model.Money) (model.Money, error), error) {
+	rate, err := currencyService.Rates.Latest(from, to, date)
+	if err == nil {
+		return outOfRange(rate.Rate.Convert), nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	rate, err = currencyService.Rates.Latest(to, from, date)
+	if err == nil {
+		return outOfRange(rate.Rate.ConvertBack), nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w from %s to %s on %s", ErrNoExchangeRate, from, to, date)
+}
+
+// outOfRange reports conversions whose result does not fit in a Money as
+// ErrConversionOutOfRange
+func outOfRange(convert func(model.Money) (model.Money, error)) func(model.Money) (model.Money, error) {
+	return func(amount model.Money) (model.Money, error) {
+		converted, err := convert(amount)
+		if errors.Is(err, model.ErrAmountOutOfRange) {
+			return 0, ErrConversionOutOfRange
+		}
+		return converted, err
+	}
+}
+
+// ListRates returns the stored rates of a pair; empty currencies match any
+func (currencyService *CurrencyService) ListRates(from, to string) ([]model.ExchangeRate, error) {
+	return currencyService.Rates.Find(from, to)
+}
+
+// rateColumns are the columns an exchange rate CSV must have, in any order
+var rateColumns = []string{"date", "from", "to", "rate"}
+
+// ImportRatesCSV reads rates from a CSV file with a date,from,to,rate header
+// and stores them, replacing rates already stored for the same date and pair.
+// Nothing is stored unless every line is valid. It returns the number of
+// rates imported.
+func (currencyService *CurrencyService) ImportRatesCSV(reader io.Reader) (int, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if errors.Is(err, io.EOF) {
+		return 0, fmt.Errorf("%w: the file is empty", ErrInvalidRateFile)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidRateFile, err)
+	}
+	columns := map[string]int{}
+	for index, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = index
+	}
+	for _, name := range rateColumns {
+		if _, ok := columns[name]; !ok {
+			return 0, fmt.Errorf("%w: missing column %q", ErrInvalidRateFile, name)
+		}
+	}
+
+	var rates []model.ExchangeRate
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidRateFile, err)
+		}
+		line, _ := csvReader.FieldPos(0)
+		rate, err := parseRateRecord(record, columns)
+		if err != nil {
+			return 0, fmt.Errorf("%w: line %d: %v", ErrInvalidRateFile, line, err)
+		}
+		rates = append(rates, rate)
+	}
+	if len(rates) == 0 {
+		return 0, fmt.Errorf("%w: no rates found", ErrInvalidRateFile)
+	}
+
+	if err := currencyService.Rates.Upsert(rates); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+func parseRateRecord(record []string, columns map[string]int) (model.ExchangeRate, error) {
+	var rate model.ExchangeRate
+	var err error
+	if rate.Date, err = model.ParseDateOnly(strings.TrimSpace(record[columns["date"]])); err != nil {
+		return rate, errors.New("date must be in the format yyyy-mm-dd")
+	}
+	if rate.From, err = model.ParseCurrency(record[columns["from"]]); err != nil {
+		return rate, err
+	}
+	if rate.To, err = model.ParseCurrency(record[columns["to"]]); err != nil {
+		return rate, err
+	}
+	if rate.From == rate.To {
+		return rate, errors.New("from and to must be different currencies")
+	}
+	if rate.Rate, err = model.ParseRate(record[columns["rate"]]); err != nil {
+		return rate, err
+	}
+	return rate, nil
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func mustParseDate(t *testing.T, value string) model.DateOnly {
+	t.Helper()
+	date, err := model.ParseDateOnly(value)
+	if err != nil {
+		t.Fatalf("Failed to parse date %q: %v", value, err)
+	}
+	return date
+}
+
+func TestConvert(t *testing.T) {
+	currencies := newTestServices(t).currencies
+
+	tests := []struct {
+		from, to, date string
+		amount         model.Money
+		expected       model.Money
+	}{
+		{"USD", "BRL", "2023-06-15", 100_00, 500_00},
+		{"USD", "BRL", "2023-07-01", 100_00, 480_00},
+		{"BRL", "USD", "2023-07-10", 480_00, 100_00},
+		{"BRL", "USD", "2023-06-10", 1_00, 20},
+		{"BRL", "BRL", "2020-01-01", 123_45, 123_45},
+	}
+	for _, tt := range tests {
+		converted, err := currencies.Convert(tt.amount, tt.from, tt.to, mustParseDate(t, tt.date))
+		if err != nil {
+			t.Errorf("Convert %s %s to %s on %s: unexpected error %v", tt.amount, tt.from, tt.to, tt.date, err)
+			continue
+		}
+		if converted != tt.expected {
+			t.Errorf("Convert %s %s to %s on %s: expected %s, got %s", tt.amount, tt.from, tt.to, tt.date, tt.expected, converted)
+		}
+	}
+
+	_, err := currencies.Convert(100_00, "USD", "BRL", mustParseDate(t, "2023-05-31"))
+	if !errors.Is(err, ErrNoExchangeRate) {
+		t.Errorf("Expected ErrNoExchangeRate before the first rate, got %v", err)
+	}
+	_, err = currencies.Convert(100_00, "EUR", "BRL", mustParseDate(t, "2023-06-15"))
+	if !errors.Is(err, ErrNoExchangeRate) {
+		t.Errorf("Expected ErrNoExchangeRate for an unknown pair, got %v", err)
+	}
+	_, err = currencies.Convert(math.MaxInt64/2, "USD", "BRL", mustParseDate(t, "2023-06-15"))
+	if !errors.Is(err, ErrConversionOutOfRange) {
+		t.Errorf("Expected ErrConversionOutOfRange for an amount too large to convert, got %v", err)
+	}
+}
+
+func TestImportRatesCSV(t *testing.T) {
+	services := newTestServices(t)
+	rates, currencies := services.storages.rates, services.currencies
+
+	csv := "rate,date,from,to\n5.10,2023-06-01,usd,brl\n5.55,2023-06-01,EUR,BRL\n"
+	imported, err := currencies.ImportRatesCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("Failed to import rates: %v", err)
+	}
+	if stored, _ := rates.Find("EUR", "BRL"); imported != 2 || len(stored) != 1 || stored[0].Rate != "5.55" {
+		t.Fatalf("Expected 2 rates imported, got %d (%+v stored)", imported, stored)
+	}
+
+	// Importing the same date and pair again replaces the rate
+	_, err = currencies.ImportRatesCSV(strings.NewReader("date,from,to,rate\n2023-06-01,USD,BRL,5.20\n"))
+	if err != nil {
+		t.Fatalf("Failed to import rates: %v", err)
+	}
+	if rate, _ := rates.Latest("USD", "BRL", mustParseDate(t, "2023-06-01")); rate.Rate != "5.20" {
+		t.Errorf("Expected the USD/BRL rate to be replaced, got %+v", rate)
+	}
+	if stored, _ := rates.Find("", ""); len(stored) != 3 {
+		t.Errorf("Expected the replaced rate not to be stored twice, got %+v", stored)
+	}
+}
+
+func TestImportRatesCSVRejectsInvalidFiles(t *testing.T) {
+	files := map[string]string{
+		"empty":          "",
+		"header only":    "date,from,to,rate\n",
+		"missing column": "date,from,rate\n2023-06-01,USD,5\n",
+		"bad date":       "date,from,to,rate\n2023-06-01,USD,BRL,5\n01/06/2023,EUR,BRL,5.5\n",
+		"bad currency":   "date,from,to,rate\n2023-06-01,US,BRL,5\n",
+		"same currency":  "date,from,to,rate\n2023-06-01,BRL,BRL,1\n",
+		"negative rate":  "date,from,to,rate\n2023-06-01,USD,BRL,-5\n",
+		"not a number":   "date,from,to,rate\n2023-06-01,USD,BRL,five\n",
+		"short line":     "date,from,to,rate\n2023-06-01,USD,BRL\n",
+	}
+
+	for name, file := range files {
+		services := newTestServices(t)
+		_, err := services.currencies.ImportRatesCSV(strings.NewReader(file))
+		if !errors.Is(err, ErrInvalidRateFile) {
+			t.Errorf("%s: expected ErrInvalidRateFile, got %v", name, err)
+		}
+		if stored, _ := services.storages.rates.Find("", ""); len(stored) != 2 {
+			t.Errorf("%s: expected nothing to be stored, got %+v", name, stored)
+		}
+	}
+}
+
+func TestAddTransactionUsesBaseCurrency(t *testing.T) {
+	financeService := newTestServices(t).finance
+
+	transaction, err := financeService.AddTransaction(model.Transaction{UserID: 2, Type: "income", Amount: 10_00})
+	if err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	if transaction.Currency != "USD" {
+		t.Errorf("Expected the user's base currency USD, got %q", transaction.Currency)
+	}
+
+	transaction, err = financeService.AddTransaction(model.Transaction{UserID: 2, Type: "income", Amount: 10_00, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	if transaction.Currency != "EUR" {
+		t.Errorf("Expected the explicit currency EUR to be kept, got %q", transaction.Currency)
+	}
+}
+
+// multiCurrencyTransactions are John's income and expenses in reais and
+// dollars in June and July 2023
+func multiCurrencyTransactions(t *testing.T) []model.Transaction {
+	return []model.Transaction{
+		{UserID: 1, Type: "income", Amount: 3000_00, Currency: "BRL", Category: "Salary", Date: mustParseDate(t, "2023-06-05")},
+		{UserID: 1, Type: "income", Amount: 100_00, Currency: "USD", Category: "Freelance", Date: mustParseDate(t, "2023-06-10")},
+		{UserID: 1, Type: "expense", Amount: 50_00, Currency: "USD", Category: "Software", Date: mustParseDate(t, "2023-07-02")},
+		{UserID: 1, Type: "expense", Amount: 200_00, Currency: "BRL", Category: "Food", Date: mustParseDate(t, "2023-07-03")},
+	}
+}
+
+func TestGetBalanceConvertsToBaseCurrency(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, multiCurrencyTransactions(t)...)
+
+	balance, err := services.finance.GetBalanceByUserId(1)
+	if err != nil {
+		t.Fatalf("Failed to get balance: %v", err)
+	}
+
+	// 3000 - 200 BRL, plus 100 USD at 5 minus 50 USD at 4.8
+	if balance.Currency != "BRL" || balance.Balance != 3060_00 {
+		t.Errorf("Expected a balance of 3060.00 BRL, got %s %s", balance.Balance, balance.Currency)
+	}
+	expected := []model.CurrencyBalance{{Currency: "BRL", Balance: 2800_00}, {Currency: "USD", Balance: 50_00}}
+	if !slices.Equal(balance.ByCurrency, expected) {
+		t.Errorf("Expected native balances %+v, got %+v", expected, balance.ByCurrency)
+	}
+}
+
+func TestGetBalanceWithoutRate(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert,
+		model.Transaction{UserID: 1, Type: "income", Amount: 100_00, Currency: "JPY", Date: mustParseDate(t, "2023-06-05")})
+
+	if _, err := services.finance.GetBalanceByUserId(1); !errors.Is(err, ErrNoExchangeRate) {
+		t.Errorf("Expected ErrNoExchangeRate, got %v", err)
+	}
+}
+
+func TestSummaryReportByCurrency(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, multiCurrencyTransactions(t)...)
+
+	report, err := services.finance.SummaryReport(1, model.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("Failed to build report: %v", err)
+	}
+
+	if report.Currency != "BRL" {
+		t.Errorf("Expected report in BRL, got %s", report.Currency)
+	}
+	if report.Totals != (model.ReportTotals{Income: 3500_00, Expense: 440_00, Net: 3060_00}) {
+		t.Errorf("Unexpected converted totals: %+v", report.Totals)
+	}
+	expected := []model.CurrencySummary{
+		{Currency: "BRL", ReportTotals: model.ReportTotals{Income: 3000_00, Expense: 200_00, Net: 2800_00}},
+		{Currency: "USD", ReportTotals: model.ReportTotals{Income: 100_00, Expense: 50_00, Net: 50_00}},
+	}
+	if !slices.Equal(report.ByCurrency, expected) {
+		t.Errorf("Expected native totals %+v, got %+v", expected, report.ByCurrency)
+	}
+}
+
+func TestBalanceHistoryInBaseCurrency(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, multiCurrencyTransactions(t)...)
+
+	history, err := services.finance.BalanceHistory(1, model.DateOnly{}, model.DateOnly{}, "month")
+	if err != nil {
+		t.Fatalf("Failed to build balance history: %v", err)
+	}
+	if history.Currency != "BRL" || history.ClosingBalance != 3060_00 {
+		t.Errorf("Expected a closing balance of 3060.00 BRL, got %s %s", history.ClosingBalance, history.Currency)
+	}
+	if len(history.Points) != 2 || history.Points[0].Balance != 3500_00 {
+		t.Errorf("Expected June to close at 3500.00 BRL, got %+v", history.Points)
+	}
+}
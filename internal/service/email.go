@@ -1,9 +1,13 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
 	"gopkg.in/mail.v2"
+	"net"
+	"strconv"
 )
 
 type EmailService struct {
@@ -33,3 +37,29 @@ func (s *EmailService) SendEmail(data model.EmailData) error {
 
 	return d.DialAndSend(m)
 }
+
+// Send delivers a plain text email from the configured SMTP account
+func (s *EmailService) Send(to, subject, body string) error {
+	m := mail.NewMessage()
+	m.SetHeader("From", s.smtpUsername)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/plain", body)
+
+	d := mail.NewDialer(s.smtpHost, s.smtpPort, s.smtpUsername, s.smtpPassword)
+
+	return d.DialAndSend(m)
+}
+
+// Ping reports whether the SMTP server accepts connections, without logging in
+func (s *EmailService) Ping(ctx context.Context) error {
+	if s.smtpHost == "" {
+		return errors.New("no SMTP server is configured")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.smtpHost, strconv.Itoa(s.smtpPort)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,76 @@
+package service
+
+// Kind classifies an Error by what went wrong, which decides the HTTP status
+// it is answered with
+type Kind int
+
+const (
+	// KindInternal is a failure of the API itself
+	KindInternal Kind = iota
+	// KindInvalid is a request that breaks a rule
+	KindInvalid
+	// KindUnauthorized is a request without valid credentials
+	KindUnauthorized
+	// KindForbidden is a request for something the user cannot touch
+	KindForbidden
+	// KindNotFound is a request for a record that does not exist
+	KindNotFound
+	// KindConflict is a request that clashes with the stored records
+	KindConflict
+	// KindTooLarge is a request whose body exceeds a limit
+	KindTooLarge
+	// KindUnprocessable is a valid request that cannot be carried out, such
+	// as a conversion without an exchange rate
+	KindUnprocessable
+)
+
+// Error is a failure the client is told about. Code is a stable identifier
+// for programs to match on, Message a sentence for people and Details
+// optional data about what failed.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Details any
+}
+
+func newError(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches errors with the same code, so the copies made by WithMessage
+// and WithDetails still match their sentinel with errors.Is
+func (e *Error) Is(target error) bool {
+	other, ok := target.(*Error)
+	return ok && other.Code == e.Code
+}
+
+// WithMessage returns a copy of the error with a more specific message
+func (e *Error) WithMessage(message string) *Error {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
+// WithDetails returns a copy of the error carrying details
+func (e *Error) WithDetails(details any) *Error {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// Errors that are not specific to a service
+var (
+	ErrInvalidInput  = newError(KindInvalid, "invalid_input", "Invalid request data")
+	ErrInvalidJSON   = newError(KindInvalid, "invalid_json", "Invalid JSON syntax")
+	ErrMissingToken  = newError(KindUnauthorized, "missing_token", "Missing bearer token")
+	ErrInvalidToken  = newError(KindUnauthorized, "invalid_token", "Invalid or expired token")
+	ErrForbidden     = newError(KindForbidden, "forbidden", "You are not allowed to do this")
+	ErrRouteNotFound = newError(KindNotFound, "route_not_found", "Route not found")
+	ErrFileTooLarge  = newError(KindTooLarge, "file_too_large", "The file is too large")
+	ErrInternal      = newError(KindInternal, "internal_error", "Internal server error")
+)
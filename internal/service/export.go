@@ -0,0 +1,319 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportFlushRows is how many rows are written between flushes, so large
+// exports reach the client while they are still being written
+const exportFlushRows = 500
+
+// exportPageSize is how many transactions an export reads from storage at a time
+const exportPageSize = 500
+
+// exportHeader names the columns of CSV and XLSX exports
+var exportHeader = []string{
+	"id", "date", "type", "category", "description", "amount", "currency",
+	"account_id", "transfer_role", "tags", "balance", "balance_currency",
+}
+
+// ExportStream is an export of transactions being read from storage. Its rows
+// are read a page at a time while Each visits them, so only one page is held
+// in memory however many transactions are exported.
+type ExportStream struct {
+	// Currency is the user's base currency, which balances are converted to
+	Currency string
+	// OpeningBalance sums up the matching transactions before the period
+	OpeningBalance model.Money
+	// ClosingBalance is the balance after the last row, known once Each has
+	// visited every row
+	ClosingBalance model.Money
+
+	storage storage.FinanceStorage
+	userID  int
+	filter  model.TransactionFilter
+	convert func(model.Transaction) (model.Transaction, error)
+	// openings are the account opening balances not read yet, in date order
+	openings []model.Transaction
+	// page holds the lines of the current page not read yet
+	page []model.Transaction
+	// last is the last transaction read from storage, where the next page starts
+	last    model.Transaction
+	done    bool
+	balance model.Money
+	// first is the first row of the period, read while looking for it
+	first    model.ExportRow
+	hasFirst bool
+}
+
+// ExportTransactions starts an export of a user's transactions matching the
+// filter in date order, each followed by the running balance in the base
+// currency. Split transactions are exported one line per category, and with a
+// category filter only the lines in that category are kept, as in the
+// reports. The balance starts from the matching transactions before the
+// period; account opening balances are counted too unless the export is
+// narrowed down to a type, category or tags. The transactions before the
+// period are read here, so errors finding them or converting them are
+// reported before anything is written.
+func (financeService *FinanceService) ExportTransactions(userID int, filter model.TransactionFilter) (*ExportStream, error) {
+	baseCurrency, convert, err := financeService.baseCurrencyConverter(userID)
+	if err != nil {
+		return nil, err
+	}
+	earlier := filter
+	earlier.From = model.DateOnly{}
+	stream := &ExportStream{
+		Currency: baseCurrency,
+		storage:  financeService.Storage,
+		userID:   userID,
+		filter:   earlier,
+		convert:  convert,
+	}
+
+	if filter.Type == "" && filter.Category == "" && len(filter.Tags) == 0 {
+		accounts, err := financeService.Accounts.FindByUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, opening := range openingTransactions(accounts) {
+			if earlier.Matches(opening) {
+				stream.openings = append(stream.openings, opening)
+			}
+		}
+		slices.SortStableFunc(stream.openings, func(a, b model.Transaction) int {
+			return time.Time(a.Date).Compare(time.Time(b.Date))
+		})
+	}
+
+	for {
+		row, ok, err := stream.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if filter.From.IsZero() || !time.Time(row.Date).Before(time.Time(filter.From)) {
+			stream.first, stream.hasFirst = row, true
+			break
+		}
+		stream.OpeningBalance = row.Balance
+	}
+	stream.ClosingBalance = stream.OpeningBalance
+	return stream, nil
+}
+
+// Each calls visit with every row of the period in order, stopping at the
+// first error, and sets ClosingBalance at the end. A stream can only be
+// visited once.
+func (stream *ExportStream) Each(visit func(row model.ExportRow) error) error {
+	row, ok := stream.first, stream.hasFirst
+	stream.hasFirst = false
+	for ok {
+		if err := visit(row); err != nil {
+			return err
+		}
+		var err error
+		if row, ok, err = stream.next(); err != nil {
+			return err
+		}
+	}
+	stream.ClosingBalance = stream.balance
+	return nil
+}
+
+// next reads the next row, or reports false when there are none left. Account
+// opening balances come before the transactions of the same day.
+func (stream *ExportStream) next() (model.ExportRow, bool, error) {
+	for len(stream.page) == 0 && !stream.done {
+		candidates, err := stream.storage.FindPageByUser(stream.userID, stream.filter, stream.last, exportPageSize)
+		if err != nil {
+			return model.ExportRow{}, false, err
+		}
+		if len(candidates) < exportPageSize {
+			stream.done = true
+		}
+		if len(candidates) > 0 {
+			stream.last = candidates[len(candidates)-1]
+		}
+		stream.page = reportPortions(candidates, stream.filter)
+	}
+
+	var transaction model.Transaction
+	switch {
+	case len(stream.openings) > 0 && (len(stream.page) == 0 || !time.Time(stream.page[0].Date).Before(time.Time(stream.openings[0].Date))):
+		transaction, stream.openings = stream.openings[0], stream.openings[1:]
+	case len(stream.page) > 0:
+		transaction, stream.page = stream.page[0], stream.page[1:]
+	default:
+		return model.ExportRow{}, false, nil
+	}
+	converted, err := stream.convert(transaction)
+	if err != nil {
+		return model.ExportRow{}, false, err
+	}
+	stream.balance += balanceChange(converted)
+	return model.ExportRow{Transaction: transaction, Balance: stream.balance}, true, nil
+}
+
+// WriteExport writes an export to output in one of model.ExportFormats as
+// its rows are read. The output is flushed every few hundred rows when it is
+// an HTTP response.
+func WriteExport(output io.Writer, format string, export *ExportStream) error {
+	switch format {
+	case "csv":
+		return writeExportCSV(output, export)
+	case "json":
+		return writeExportJSON(output, export)
+	case "xlsx":
+		return writeExportXLSX(output, export)
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+// flushOutput sends what was written so far to the client
+func flushOutput(output io.Writer) {
+	if flusher, ok := output.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// exportID formats an ID, leaving it blank when unset as for opening balances
+// and transactions without an account
+func exportID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
+
+// csvText keeps text typed by the user from being run as a formula when the
+// file is opened in a spreadsheet, by quoting values starting like one
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func writeExportCSV(output io.Writer, export *ExportStream) error {
+	writer := csv.NewWriter(output)
+	if err := writer.Write(exportHeader); err != nil {
+		return err
+	}
+	rows := 0
+	err := export.Each(func(row model.ExportRow) error {
+		record := []string{
+			exportID(row.ID), row.Date.String(), row.Type, csvText(row.Category), csvText(row.Description), row.Amount.String(),
+			row.CurrencyOrDefault(), exportID(row.AccountID), row.TransferRole, csvText(strings.Join(row.Tags, ",")),
+			row.Balance.String(), export.Currency,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return err
+			}
+			flushOutput(output)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeExportJSON writes the export as a model.TransactionExport, encoding
+// one transaction at a time. The closing balance comes after the
+// transactions, once it is known.
+func writeExportJSON(output io.Writer, export *ExportStream) error {
+	buffered := bufio.NewWriter(output)
+	currency, err := json.Marshal(export.Currency)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buffered, `{"currency":%s,"opening_balance":%s,"transactions":[`, currency, export.OpeningBalance)
+	rows := 0
+	err = export.Each(func(row model.ExportRow) error {
+		if rows > 0 {
+			buffered.WriteByte(',')
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if _, err := buffered.Write(data); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := buffered.Flush(); err != nil {
+				return err
+			}
+			flushOutput(output)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buffered, `],"closing_balance":%s}`+"\n", export.ClosingBalance)
+	return buffered.Flush()
+}
+
+func writeExportXLSX(output io.Writer, export *ExportStream) error {
+	writer, err := newXLSXWriter(output)
+	if err != nil {
+		return err
+	}
+	header := make([]any, len(exportHeader))
+	for index, name := range exportHeader {
+		header[index] = xlsxHeading(name)
+	}
+	if err := writer.writeRow(header...); err != nil {
+		return err
+	}
+	rows := 0
+	err = export.Each(func(row model.ExportRow) error {
+		// IDs stay numbers so the sheet can be sorted by them
+		var id, accountID any = "", ""
+		if row.ID != 0 {
+			id = row.ID
+		}
+		if row.AccountID != 0 {
+			accountID = row.AccountID
+		}
+		err := writer.writeRow(
+			id, row.Date, row.Type, row.Category, row.Description, row.Amount, row.CurrencyOrDefault(),
+			accountID, row.TransferRole, strings.Join(row.Tags, ","), row.Balance, export.Currency,
+		)
+		if err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := writer.flush(); err != nil {
+				return err
+			}
+			flushOutput(output)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.close()
+}
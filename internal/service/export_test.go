@@ -0,0 +1,290 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// seedExport adds to seedAccounts a transfer from checking to savings (2 and
+// 3), a split supermarket expense (4) and a purchase in dollars (5)
+func seedExport(t *testing.T, services *testServices) {
+	t.Helper()
+	seedAccounts(t, services.storages)
+	if _, err := services.finance.AddTransaction(model.Transaction{UserID: 1, Type: "transfer", Amount: 500_00,
+		AccountID: 1, ToAccountID: 2, Date: mustParseDate(t, "2023-06-10"), Description: "Savings"}); err != nil {
+		t.Fatalf("Failed to add transfer: %v", err)
+	}
+	seed(t, services.storages.finance.Insert,
+		model.Transaction{UserID: 1, AccountID: 1, Type: "expense", Amount: 100_00, Currency: "BRL",
+			Date: mustParseDate(t, "2023-06-12"), Description: "Supermarket", Tags: []string{"home", "monthly"},
+			Splits: []model.Split{{Category: "Food", Amount: 60_00}, {Category: "Household", Amount: 40_00}}},
+		model.Transaction{UserID: 1, AccountID: 3, Type: "expense", Amount: 20_00, Currency: "USD",
+			Category: "Books", Date: mustParseDate(t, "2023-06-20"), Description: "Bookstore"},
+	)
+}
+
+// describeTransaction replaces the description of a stored transaction
+func describeTransaction(t *testing.T, storages *testStorages, id int, description string) {
+	t.Helper()
+	transaction, err := storages.finance.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to get transaction: %v", err)
+	}
+	transaction.Description = description
+	if err := storages.finance.Update(transaction); err != nil {
+		t.Fatalf("Failed to update transaction: %v", err)
+	}
+}
+
+// collectExport reads every row of an export
+func collectExport(t *testing.T, financeService *FinanceService, filter model.TransactionFilter) model.TransactionExport {
+	t.Helper()
+	stream, err := financeService.ExportTransactions(1, filter)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	export := model.TransactionExport{Currency: stream.Currency, OpeningBalance: stream.OpeningBalance, Rows: []model.ExportRow{}}
+	err = stream.Each(func(row model.ExportRow) error {
+		export.Rows = append(export.Rows, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	export.ClosingBalance = stream.ClosingBalance
+	return export
+}
+
+type exportLine struct {
+	id       int
+	category string
+	balance  model.Money
+}
+
+func exportLines(export model.TransactionExport) []exportLine {
+	lines := make([]exportLine, len(export.Rows))
+	for index, row := range export.Rows {
+		lines[index] = exportLine{row.ID, row.Category, row.Balance}
+	}
+	return lines
+}
+
+func TestExportTransactionsRunningBalance(t *testing.T) {
+	services := newTestServices(t)
+	seedExport(t, services)
+	financeService := services.finance
+
+	export := collectExport(t, financeService, model.TransactionFilter{From: mustParseDate(t, "2023-06-06")})
+	// The checking account's opening balance and the salary come before the period
+	if export.Currency != "BRL" || export.OpeningBalance != 4000_00 || export.ClosingBalance != 3800_00 {
+		t.Errorf("Expected balances from 4000.00 to 3800.00 BRL, got %+v", export)
+	}
+	// Both halves of the transfer are listed, the split is broken into its
+	// lines and the dollars are converted at 5 BRL
+	expected := []exportLine{{2, "", 3500_00}, {3, "", 4000_00}, {4, "Food", 3940_00}, {4, "Household", 3900_00}, {5, "Books", 3800_00}}
+	if lines := exportLines(export); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected rows %+v, got %+v", expected, lines)
+	}
+	if row := export.Rows[2]; row.Amount != 60_00 || row.Splits != nil {
+		t.Errorf("Expected the food line on its own, got %+v", row)
+	}
+}
+
+func TestExportTransactionsFilters(t *testing.T) {
+	services := newTestServices(t)
+	seedExport(t, services)
+	financeService := services.finance
+
+	export := collectExport(t, financeService, model.TransactionFilter{AccountID: 1})
+	expected := []exportLine{{0, "", 1000_00}, {1, "", 4000_00}, {2, "", 3500_00}, {4, "Food", 3440_00}, {4, "Household", 3400_00}}
+	if lines := exportLines(export); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected the account's statement %+v, got %+v", expected, lines)
+	}
+	if opening := export.Rows[0]; opening.Description != "Opening balance" || opening.Date.String() != "2023-01-01" {
+		t.Errorf("Expected the opening balance first, got %+v", opening)
+	}
+
+	// Narrowed down to a category, the balance only adds up that category
+	export = collectExport(t, financeService, model.TransactionFilter{Category: "household"})
+	expected = []exportLine{{4, "Household", -40_00}}
+	if lines := exportLines(export); !reflect.DeepEqual(lines, expected) || export.ClosingBalance != -40_00 {
+		t.Errorf("Expected only the household line %+v, got %+v", expected, export)
+	}
+
+	export = collectExport(t, financeService, model.TransactionFilter{To: mustParseDate(t, "2022-12-31")})
+	if len(export.Rows) != 0 || export.ClosingBalance != 0 {
+		t.Errorf("Expected an empty export, got %+v", export)
+	}
+}
+
+func TestExportTransactionsReadsPages(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.accounts.Insert, model.Account{UserID: 1, Name: "Checking", Type: "checking", Currency: "BRL",
+		OpeningBalance: 1000_00, OpeningDate: mustParseDate(t, "2023-01-01")})
+	financeService := services.finance
+	day := func(days int) model.DateOnly {
+		return model.DateOnly(time.Time(mustParseDate(t, "2023-01-01")).AddDate(0, 0, days))
+	}
+	// Three and a half pages of transactions, added out of date order
+	count := exportPageSize*3 + exportPageSize/2
+	transactions := make([]model.Transaction, count)
+	for index := range transactions {
+		transactions[index] = model.Transaction{UserID: 1, Type: "income", Amount: 1_00, Currency: "BRL", Date: day(count - 1 - index)}
+	}
+	if _, err := services.storages.finance.InsertMany(transactions); err != nil {
+		t.Fatalf("Failed to add transactions: %v", err)
+	}
+
+	from := day(exportPageSize + 10)
+	export := collectExport(t, financeService, model.TransactionFilter{From: from})
+	// The checking account's opening balance comes first, on 2023-01-01
+	opening := model.Money(1000_00 + (exportPageSize+10)*1_00)
+	if export.OpeningBalance != opening || export.ClosingBalance != model.Money(1000_00+count*1_00) {
+		t.Errorf("Expected balances from %s to %s, got %+v and %+v", opening, model.Money(1000_00+count*1_00),
+			export.OpeningBalance, export.ClosingBalance)
+	}
+	if len(export.Rows) != count-exportPageSize-10 {
+		t.Fatalf("Expected %d rows, got %d", count-exportPageSize-10, len(export.Rows))
+	}
+	for index, row := range export.Rows {
+		if row.Date != day(exportPageSize+10+index) || row.Balance != opening+model.Money(index+1)*1_00 {
+			t.Fatalf("Expected row %d on %s, got %+v", index, day(exportPageSize+10+index), row)
+		}
+	}
+}
+
+func TestWriteExportCSV(t *testing.T) {
+	services := newTestServices(t)
+	seedExport(t, services)
+	financeService := services.finance
+	// Text that a spreadsheet would run as a formula is quoted
+	describeTransaction(t, services.storages, 5, "=HYPERLINK(\"http://example.com\")")
+	export, err := financeService.ExportTransactions(1, model.TransactionFilter{From: mustParseDate(t, "2023-06-11")})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var output bytes.Buffer
+	if err := WriteExport(&output, "csv", export); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := "id,date,type,category,description,amount,currency,account_id,transfer_role,tags,balance,balance_currency\n" +
+		"4,2023-06-12,expense,Food,Supermarket,60.00,BRL,1,,\"home,monthly\",3940.00,BRL\n" +
+		"4,2023-06-12,expense,Household,Supermarket,40.00,BRL,1,,\"home,monthly\",3900.00,BRL\n" +
+		"5,2023-06-20,expense,Books,\"'=HYPERLINK(\"\"http://example.com\"\")\",20.00,USD,3,,,3800.00,BRL\n"
+	if output.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, output.String())
+	}
+}
+
+func TestWriteExportJSON(t *testing.T) {
+	services := newTestServices(t)
+	seedExport(t, services)
+	financeService := services.finance
+	export := collectExport(t, financeService, model.TransactionFilter{})
+	stream, err := financeService.ExportTransactions(1, model.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var output bytes.Buffer
+	if err := WriteExport(&output, "json", stream); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var decoded model.TransactionExport
+	if err := json.Unmarshal(output.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v in %s", err, output.String())
+	}
+	if !reflect.DeepEqual(decoded, export) {
+		t.Errorf("Expected %+v, got %+v", export, decoded)
+	}
+}
+
+func TestWriteExportXLSX(t *testing.T) {
+	services := newTestServices(t)
+	seedExport(t, services)
+	financeService := services.finance
+	describeTransaction(t, services.storages, 5, "Books & <magazines>")
+	export, err := financeService.ExportTransactions(1, model.TransactionFilter{Category: "books"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var output bytes.Buffer
+	if err := WriteExport(&output, "xlsx", export); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatalf("Expected a zip archive, got %v", err)
+	}
+	parts := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file.Name, err)
+		}
+		// Every part must be well-formed XML
+		decoder := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := decoder.Token(); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				t.Fatalf("Expected %s to be well-formed, got %v", file.Name, err)
+			}
+		}
+		parts[file.Name] = string(content)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("Expected %s in the workbook", name)
+		}
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{
+		`<c r="A1" s="3" t="inlineStr"><is><t>id</t></is></c>`,
+		`<c r="A2"><v>5</v></c>`,
+		// 2023-06-20 is day 45097 of spreadsheets
+		`<c r="B2" s="1"><v>45097</v></c>`,
+		`<c r="E2" t="inlineStr"><is><t xml:space="preserve">Books &amp; &lt;magazines&gt;</t></is></c>`,
+		`<c r="F2" s="2"><v>20.00</v></c>`,
+		`<c r="K2" s="2"><v>-100.00</v></c>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("Expected the sheet to contain %s, got %s", cell, sheet)
+		}
+	}
+	if strings.Contains(sheet, `r="I2"`) || strings.Contains(sheet, `r="J2"`) {
+		t.Errorf("Expected blank cells to be left out, got %s", sheet)
+	}
+}
+
+func TestWriteExportRejectsUnknownFormat(t *testing.T) {
+	if err := WriteExport(io.Discard, "pdf", &ExportStream{}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for index, expected := range map[int]string{0: "A", 11: "L", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if column := xlsxColumn(index); column != expected {
+			t.Errorf("Expected column %d to be %s, got %s", index, expected, column)
+		}
+	}
+}
@@ -1,138 +1,492 @@
 package service
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
-	"log"
+	"slices"
 	"strconv"
-	"sync"
+	"strings"
+	"time"
 )
 
+// Errors returned when transactions are looked up or saved
+var (
+	ErrTransactionNotFound  = newError(KindNotFound, "transaction_not_found", "Transaction not found")
+	ErrUnknownAccount       = newError(KindInvalid, "unknown_account", "Account not found")
+	ErrCurrencyMismatch     = newError(KindInvalid, "currency_mismatch", "Currency must match the account currency")
+	ErrTransferAccounts     = newError(KindInvalid, "invalid_transfer_accounts", "A transfer needs an account_id and a different to_account_id")
+	ErrTransferAmount       = newError(KindInvalid, "invalid_transfer_amount", "Transfer amount must be positive")
+	ErrTransferTypeLocked   = newError(KindInvalid, "transfer_type_locked", "A transfer cannot become income or expense, or the reverse")
+	ErrUnknownCategory      = newError(KindInvalid, "unknown_category", "Category not found in your catalog")
+	ErrCategoryTypeMismatch = newError(KindInvalid, "category_type_mismatch", "The category's type must match the transaction type")
+	ErrSplitAmount          = newError(KindInvalid, "invalid_split_amount", "Split amounts must be positive")
+	ErrSplitTotal           = newError(KindInvalid, "split_total_mismatch", "Split amounts must add up to the transaction amount")
+	ErrSplitTransfer        = newError(KindInvalid, "split_transfer", "Transfers cannot be split across categories")
+)
+
+// TransactionObserver is told about every transaction added or updated
+// through FinanceService, after it has been saved
+type TransactionObserver interface {
+	TransactionSaved(transaction model.Transaction)
+}
+
 type FinanceService struct {
-	Transaction []model.Transaction
-	NextID      int
-	Storage     storage.FinanceStorage
-	mu          sync.Mutex
+	Storage    storage.FinanceStorage
+	Accounts   storage.AccountStorage
+	Categories storage.CategoryStorage
+	Currencies *CurrencyService
+	Observers  []TransactionObserver
+}
+
+func NewFinanceService(storage storage.FinanceStorage, accounts storage.AccountStorage, categories storage.CategoryStorage, currencies *CurrencyService) *FinanceService {
+	return &FinanceService{
+		Storage:    storage,
+		Accounts:   accounts,
+		Categories: categories,
+		Currencies: currencies,
+	}
 }
 
-func NewFinanceService(storage storage.FinanceStorage) *FinanceService {
-	transactions, err := storage.Load()
+// applyAccount checks that the transaction's account belongs to its user and
+// records the transaction in the account's currency
+func (financeService *FinanceService) applyAccount(transaction *model.Transaction) error {
+	if transaction.AccountID == 0 {
+		return nil
+	}
+	account, err := financeService.ownedAccount(transaction.UserID, transaction.AccountID)
 	if err != nil {
-		log.Printf("Error loading transactions: %v\n", err)
-		transactions = []model.Transaction{}
+		return err
+	}
+	if transaction.Currency == "" {
+		transaction.Currency = account.Currency
 	}
+	if transaction.Currency != account.Currency {
+		return ErrCurrencyMismatch
+	}
+	return nil
+}
 
-	return &FinanceService{
-		Transaction: transactions,
-		NextID:      getMaxIDTransactions(transactions) + 1,
-		Storage:     storage,
+// applyCategory resolves the transaction's category against the user's
+// catalog, by name when one is given and by CategoryID otherwise, and records
+// the catalog's spelling and ID. Users who have not created any category yet
+// keep free-text categories; transactions without a category are left alone.
+func (financeService *FinanceService) applyCategory(transaction *model.Transaction) error {
+	transaction.Category = strings.TrimSpace(transaction.Category)
+	if transaction.Category == "" && transaction.CategoryID == 0 {
+		return nil
+	}
+	categories, err := financeService.Categories.FindByUser(transaction.UserID)
+	if err != nil {
+		return err
 	}
+	if len(categories) == 0 && transaction.Category != "" {
+		transaction.CategoryID = 0
+		return nil
+	}
+	category, found := findCategory(categories, transaction.Category, transaction.CategoryID)
+	if !found {
+		return ErrUnknownCategory
+	}
+	if category.Type != transaction.Type {
+		return ErrCategoryTypeMismatch
+	}
+	transaction.Category, transaction.CategoryID = category.Name, category.ID
+	return nil
 }
 
-func getMaxIDTransactions(transactions []model.Transaction) int {
-	maxID := 0
-	for _, transactionModel := range transactions {
-		if transactionModel.ID > maxID {
-			maxID = transactionModel.ID
+// applySplits checks the split lines of an income or expense: every amount
+// is positive, they add up to the transaction's amount and their categories
+// are resolved like the transaction's own category
+func (financeService *FinanceService) applySplits(transaction *model.Transaction) error {
+	if len(transaction.Splits) == 0 {
+		transaction.Splits = nil
+		return nil
+	}
+	var total model.Money
+	for index := range transaction.Splits {
+		split := &transaction.Splits[index]
+		if split.Amount <= 0 {
+			return ErrSplitAmount
+		}
+		total += split.Amount
+		line := model.Transaction{UserID: transaction.UserID, Type: transaction.Type, Category: split.Category, CategoryID: split.CategoryID}
+		if err := financeService.applyCategory(&line); err != nil {
+			return err
 		}
+		split.Category, split.CategoryID = line.Category, line.CategoryID
+		split.Note = strings.TrimSpace(split.Note)
 	}
-	return maxID
+	if total != transaction.Amount {
+		return ErrSplitTotal
+	}
+	return nil
 }
 
-func (financeService *FinanceService) loadTransactions() {
-	financeService.mu.Lock()
-	defer financeService.mu.Unlock()
+// notify tells the observers about a saved transaction
+func (financeService *FinanceService) notify(transaction model.Transaction) {
+	for _, observer := range financeService.Observers {
+		observer.TransactionSaved(transaction)
+	}
+}
 
-	transactions, err := financeService.Storage.Load()
+// AddTransaction stores a transaction. Transactions in an account use the
+// account's currency; others without a currency are recorded in the user's
+// base currency. Transfers are stored as a linked pair and the source half is
+// returned.
+func (financeService *FinanceService) AddTransaction(transaction model.Transaction) (model.Transaction, error) {
+	transaction, err := financeService.addTransaction(transaction)
 	if err != nil {
-		log.Printf("Error loading finance transactions: %v\n", err)
-		financeService.Transaction = []model.Transaction{}
-		financeService.NextID = 1
-		return
+		return model.Transaction{}, err
 	}
-	financeService.Transaction = transactions
-	financeService.NextID = getMaxIDTransactions(transactions) + 1
+	financeService.notify(transaction)
+	return transaction, nil
 }
 
-func (financeService *FinanceService) saveTransactions() error {
-	err := financeService.Storage.Save(financeService.Transaction)
+func (financeService *FinanceService) addTransaction(transaction model.Transaction) (model.Transaction, error) {
+	transaction.Tags = model.NormalizeTags(transaction.Tags)
+	if transaction.Type == "transfer" {
+		if len(transaction.Splits) > 0 {
+			return model.Transaction{}, ErrSplitTransfer
+		}
+		return financeService.addTransfer(transaction)
+	}
+	transaction, err := financeService.prepareTransaction(transaction)
 	if err != nil {
-		return fmt.Errorf("Error saving finance transactions: %v\n", err)
+		return model.Transaction{}, err
 	}
-	return nil
+	transaction, err = financeService.Storage.Insert(transaction)
+	if err != nil {
+		return model.Transaction{}, fmt.Errorf("Error saving finance transaction: %w", err)
+	}
+	return transaction, nil
 }
 
-func (financeService *FinanceService) AddTransaction(transaction model.Transaction) (model.Transaction, error) {
-	financeService.mu.Lock()
-	defer financeService.mu.Unlock()
-
-	transaction.ID = financeService.NextID
-	financeService.NextID++
-	financeService.Transaction = append(financeService.Transaction, transaction)
-	err := financeService.saveTransactions()
-	if err != nil {
+// prepareTransaction validates a new income or expense and fills in its
+// account currency, catalog category and split lines, ready to be stored
+func (financeService *FinanceService) prepareTransaction(transaction model.Transaction) (model.Transaction, error) {
+	transaction.ToAccountID, transaction.LinkedID, transaction.TransferRole = 0, 0, ""
+	if err := financeService.applyAccount(&transaction); err != nil {
 		return model.Transaction{}, err
 	}
+	if err := financeService.applyCategory(&transaction); err != nil {
+		return model.Transaction{}, err
+	}
+	if err := financeService.applySplits(&transaction); err != nil {
+		return model.Transaction{}, err
+	}
+	if transaction.Currency == "" {
+		currency, err := financeService.Currencies.BaseCurrency(transaction.UserID)
+		if err != nil {
+			return model.Transaction{}, err
+		}
+		transaction.Currency = currency
+	}
 	return transaction, nil
 }
 
-func (financeService *FinanceService) GetTransactionByUserId(userID int) []model.Transaction {
-	financeService.mu.Lock()
-	defer financeService.mu.Unlock()
+func (financeService *FinanceService) GetTransactionByUserId(userID int) ([]model.Transaction, error) {
+	return financeService.Storage.FindByUser(userID, model.TransactionFilter{})
+}
+
+// GetBalanceByUserId returns the user's balance converted to their base
+// currency, along with the unconverted balance of each currency and account.
+// Account opening balances are included, and transfers only move money
+// between accounts.
+func (financeService *FinanceService) GetBalanceByUserId(userID int) (model.Balance, error) {
+	transactions, err := financeService.Storage.FindByUser(userID, model.TransactionFilter{})
+	if err != nil {
+		return model.Balance{}, err
+	}
+	accounts, err := financeService.Accounts.FindByUser(userID)
+	if err != nil {
+		return model.Balance{}, err
+	}
+	transactions = append(transactions, openingTransactions(accounts)...)
+
+	baseCurrency, converted, err := financeService.inBaseCurrency(userID, transactions)
+	if err != nil {
+		return model.Balance{}, err
+	}
+
+	byCurrency := map[string]model.Money{}
+	byAccount := map[int]model.Money{}
+	for _, transaction := range transactions {
+		byCurrency[transaction.CurrencyOrDefault()] += balanceChange(transaction)
+		byAccount[transaction.AccountID] += balanceChange(transaction)
+	}
+	var total model.Money
+	for _, transaction := range converted {
+		total += balanceChange(transaction)
+	}
+
+	balance := model.Balance{
+		Currency:   baseCurrency,
+		Balance:    total,
+		ByCurrency: make([]model.CurrencyBalance, 0, len(byCurrency)),
+		ByAccount:  make([]model.AccountBalance, 0, len(accounts)),
+	}
+	for currency, amount := range byCurrency {
+		balance.ByCurrency = append(balance.ByCurrency, model.CurrencyBalance{Currency: currency, Balance: amount})
+	}
+	slices.SortFunc(balance.ByCurrency, func(a, b model.CurrencyBalance) int { return cmp.Compare(a.Currency, b.Currency) })
+	for _, account := range accounts {
+		balance.ByAccount = append(balance.ByAccount, model.AccountBalance{
+			AccountID: account.ID,
+			Name:      account.Name,
+			Currency:  account.Currency,
+			Balance:   byAccount[account.ID],
+		})
+	}
+	return balance, nil
+}
+
+// openingTransactions turns account opening balances into transactions dated
+// on the opening date, so they add to balances like any other entry
+func openingTransactions(accounts []model.Account) []model.Transaction {
+	var transactions []model.Transaction
+	for _, account := range accounts {
+		if account.OpeningBalance == 0 {
+			continue
+		}
+		transaction := model.Transaction{
+			Type:        "income",
+			Amount:      account.OpeningBalance,
+			Currency:    account.Currency,
+			Date:        account.OpeningDate,
+			Description: "Opening balance",
+			UserID:      account.UserID,
+			AccountID:   account.ID,
+		}
+		if account.OpeningBalance < 0 {
+			transaction.Type = "expense"
+			transaction.Amount = -account.OpeningBalance
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions
+}
 
-	var result []model.Transaction
-	for _, transaction := range financeService.Transaction {
-		if transaction.UserID == userID {
-			result = append(result, transaction)
+// inBaseCurrency returns copies of the transactions with their amounts
+// converted to the user's base currency at the rate of each transaction's date
+func (financeService *FinanceService) inBaseCurrency(userID int, transactions []model.Transaction) (string, []model.Transaction, error) {
+	baseCurrency, convert, err := financeService.baseCurrencyConverter(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	converted := make([]model.Transaction, len(transactions))
+	for index, transaction := range transactions {
+		if converted[index], err = convert(transaction); err != nil {
+			return "", nil, err
 		}
 	}
-	return result
+	return baseCurrency, converted, nil
 }
 
-func (financeService *FinanceService) GetBalanceByUserId(userID int) float64 {
-	financeService.mu.Lock()
-	defer financeService.mu.Unlock()
+// baseCurrencyConverter returns the user's base currency and a function
+// converting transactions to it, which remembers the rates it has looked up
+func (financeService *FinanceService) baseCurrencyConverter(userID int) (string, func(model.Transaction) (model.Transaction, error), error) {
+	baseCurrency, err := financeService.Currencies.BaseCurrency(userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Reports usually hold many transactions in the same currency and day
+	type rateKey struct {
+		currency string
+		date     model.DateOnly
+	}
+	conversions := map[rateKey]func(model.Money) (model.Money, error){}
 
-	var balance float64
-	for _, transaction := range financeService.Transaction {
-		if transaction.UserID == userID {
-			if transaction.Type == "income" {
-				balance += transaction.Amount
-			} else if transaction.Type == "expense" {
-				balance -= transaction.Amount
+	convert := func(transaction model.Transaction) (model.Transaction, error) {
+		converted := transaction
+		converted.Currency = baseCurrency
+		currency := transaction.CurrencyOrDefault()
+		if currency == baseCurrency {
+			return converted, nil
+		}
+		key := rateKey{currency, transaction.Date}
+		conversion, ok := conversions[key]
+		if !ok {
+			var err error
+			if conversion, err = financeService.Currencies.conversion(currency, baseCurrency, transaction.Date); err != nil {
+				return model.Transaction{}, err
 			}
+			conversions[key] = conversion
 		}
+		var err error
+		if converted.Amount, err = conversion(transaction.Amount); err != nil {
+			return model.Transaction{}, err
+		}
+		return converted, nil
 	}
-	return balance
+	return baseCurrency, convert, nil
 }
 
-func (financeService *FinanceService) DeleteTransaction(idString string) error {
-	financeService.mu.Lock()
-	defer financeService.mu.Unlock()
-
+// getOwnedTransaction loads a transaction and makes sure it belongs to the
+// user. Transactions of other users are reported as not found.
+func (financeService *FinanceService) getOwnedTransaction(userID int, idString string) (model.Transaction, error) {
 	id, _ := strconv.Atoi(idString)
-	for index, transactionModel := range financeService.Transaction {
-		if transactionModel.ID == id {
-			financeService.Transaction = append(financeService.Transaction[:index], financeService.Transaction[index+1:]...)
-			return financeService.Storage.Save(financeService.Transaction)
-		}
+	transaction, err := financeService.Storage.Get(id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && transaction.UserID != userID) {
+		return model.Transaction{}, ErrTransactionNotFound
 	}
-	return errors.New("transaction not found")
+	return transaction, err
 }
 
-func (financeService *FinanceService) UpdateTransaction(idString string, updated model.Transaction) error {
-	financeService.mu.Lock()
-	defer financeService.mu.Unlock()
+// DeleteTransaction removes a transaction; deleting either half of a transfer
+// removes both
+func (financeService *FinanceService) DeleteTransaction(userID int, idString string) error {
+	transaction, err := financeService.getOwnedTransaction(userID, idString)
+	if err != nil {
+		return err
+	}
+	if transaction.LinkedID != 0 {
+		return financeService.Storage.DeleteMany([]int{transaction.ID, transaction.LinkedID})
+	}
+	return financeService.Storage.Delete(transaction.ID)
+}
 
-	id, _ := strconv.Atoi(idString)
-	for index, transactionModel := range financeService.Transaction {
-		if transactionModel.ID == id {
-			updated.ID = id
-			financeService.Transaction[index] = updated
-			return financeService.Storage.Save(financeService.Transaction)
+// UpdateTransaction replaces a transaction. Updating either half of a transfer
+// rewrites both; a transfer cannot become income or expense or the reverse.
+func (financeService *FinanceService) UpdateTransaction(userID int, idString string, updated model.Transaction) error {
+	transaction, err := financeService.getOwnedTransaction(userID, idString)
+	if err != nil {
+		return err
+	}
+	if (transaction.Type == "transfer") != (updated.Type == "transfer") {
+		return ErrTransferTypeLocked
+	}
+	if updated.Type == "transfer" && len(updated.Splits) > 0 {
+		return ErrSplitTransfer
+	}
+	updated.Tags = model.NormalizeTags(updated.Tags)
+	// The rule that created the transaction and the bank's identifier cannot
+	// be changed
+	updated.RecurringID, updated.ExternalID = transaction.RecurringID, transaction.ExternalID
+	var saved model.Transaction
+	if transaction.Type == "transfer" {
+		saved, err = financeService.updateTransfer(transaction, updated)
+	} else {
+		saved, err = financeService.updateTransaction(transaction, updated)
+	}
+	if err != nil {
+		return err
+	}
+	financeService.notify(saved)
+	return nil
+}
+
+// updateTransaction replaces an income or expense and returns the saved version
+func (financeService *FinanceService) updateTransaction(transaction, updated model.Transaction) (model.Transaction, error) {
+	updated.ToAccountID, updated.LinkedID, updated.TransferRole = 0, 0, ""
+	updated.ID = transaction.ID
+	updated.UserID = transaction.UserID
+	if updated.AccountID == 0 {
+		updated.AccountID = transaction.AccountID
+	}
+	if updated.Currency == "" && updated.AccountID == transaction.AccountID {
+		updated.Currency = transaction.Currency
+	}
+	if err := financeService.applyAccount(&updated); err != nil {
+		return model.Transaction{}, err
+	}
+	if err := financeService.applyCategory(&updated); err != nil {
+		return model.Transaction{}, err
+	}
+	if err := financeService.applySplits(&updated); err != nil {
+		return model.Transaction{}, err
+	}
+	if err := financeService.Storage.Update(updated); err != nil {
+		return model.Transaction{}, err
+	}
+	return updated, nil
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// transactionSorters maps the accepted sort fields to comparison functions
+var transactionSorters = map[string]func(a, b model.Transaction) int{
+	"date": func(a, b model.Transaction) int {
+		return time.Time(a.Date).Compare(time.Time(b.Date))
+	},
+	"amount": func(a, b model.Transaction) int {
+		return cmp.Compare(a.Amount, b.Amount)
+	},
+	"category": func(a, b model.Transaction) int {
+		return strings.Compare(strings.ToLower(a.Category), strings.ToLower(b.Category))
+	},
+	"type": func(a, b model.Transaction) int {
+		return strings.Compare(a.Type, b.Type)
+	},
+	"description": func(a, b model.Transaction) int {
+		return strings.Compare(strings.ToLower(a.Description), strings.ToLower(b.Description))
+	},
+	"id": func(a, b model.Transaction) int {
+		return cmp.Compare(a.ID, b.ID)
+	},
+}
+
+// IsValidSortField reports whether transactions can be sorted by field
+func IsValidSortField(field string) bool {
+	_, ok := transactionSorters[field]
+	return ok
+}
+
+// ListTransactions filters, sorts and paginates a user's transactions. The
+// filters are applied here rather than trusted to the storage backend, so
+// every backend returns the same results.
+func (financeService *FinanceService) ListTransactions(userID int, query model.TransactionQuery) (model.TransactionPage, error) {
+	candidates, err := financeService.Storage.FindByUser(userID, query.TransactionFilter)
+	if err != nil {
+		return model.TransactionPage{}, err
+	}
+
+	transactions := make([]model.Transaction, 0, len(candidates))
+	for _, transaction := range candidates {
+		if query.Matches(transaction) {
+			transactions = append(transactions, transaction)
 		}
 	}
-	return errors.New("transaction not found")
+
+	sortBy, ok := transactionSorters[query.SortBy]
+	if !ok {
+		sortBy = transactionSorters["date"]
+	}
+	// Dates default to newest first, every other field to ascending
+	descending := query.SortOrder == "desc" ||
+		(query.SortOrder == "" && (query.SortBy == "" || query.SortBy == "date"))
+	slices.SortStableFunc(transactions, func(a, b model.Transaction) int {
+		result := sortBy(a, b)
+		if result == 0 {
+			result = cmp.Compare(a.ID, b.ID)
+		}
+		if descending {
+			return -result
+		}
+		return result
+	})
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	offset := max(query.Offset, 0)
+
+	start := min(offset, len(transactions))
+	end := min(start+limit, len(transactions))
+	return model.TransactionPage{
+		Items:  transactions[start:end],
+		Total:  len(transactions),
+		Limit:  limit,
+		Offset: offset,
+	}, nil
 }
@@ -1,44 +1,163 @@
 package service
 
 import (
+	"cmp"
 	"errors"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
 )
 
 type MockStorage struct {
 	transactions []model.Transaction
-	saveCalled   bool
-	loadCalled   bool
-	failSave     bool
-	failLoad     bool
+	insertCalled bool
+	updateCalled bool
+	deleteCalled bool
+	failWrite    bool
+	failRead     bool
 }
 
-func (m *MockStorage) Save(transactions []model.Transaction) error {
-	m.saveCalled = true
-	if m.failSave {
-		return errors.New("failed to save")
+func (m *MockStorage) Insert(transaction model.Transaction) (model.Transaction, error) {
+	m.insertCalled = true
+	if m.failWrite {
+		return model.Transaction{}, errors.New("failed to save")
+	}
+	transaction.ID = 1
+	for _, existing := range m.transactions {
+		if existing.ID >= transaction.ID {
+			transaction.ID = existing.ID + 1
+		}
+	}
+	m.transactions = append(m.transactions, transaction)
+	return transaction, nil
+}
+
+func (m *MockStorage) InsertPair(first, second model.Transaction) (model.Transaction, model.Transaction, error) {
+	if m.failWrite {
+		return model.Transaction{}, model.Transaction{}, errors.New("failed to save")
+	}
+	first, _ = m.Insert(first)
+	second.LinkedID = first.ID
+	second, _ = m.Insert(second)
+	first.LinkedID = second.ID
+	m.transactions[len(m.transactions)-2] = first
+	return first, second, nil
+}
+
+func (m *MockStorage) InsertMany(transactions []model.Transaction) ([]model.Transaction, error) {
+	if m.failWrite {
+		return nil, errors.New("failed to save")
+	}
+	inserted := make([]model.Transaction, len(transactions))
+	for index, transaction := range transactions {
+		inserted[index], _ = m.Insert(transaction)
+	}
+	return inserted, nil
+}
+
+func (m *MockStorage) UpdateMany(transactions []model.Transaction) error {
+	for _, transaction := range transactions {
+		if err := m.Update(transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) DeleteMany(ids []int) error {
+	for _, id := range ids {
+		if err := m.Delete(id); err != nil {
+			return err
+		}
 	}
-	m.transactions = transactions
 	return nil
 }
 
-func (m *MockStorage) Load() ([]model.Transaction, error) {
-	m.loadCalled = true
-	if m.failLoad {
+func (m *MockStorage) Update(transaction model.Transaction) error {
+	m.updateCalled = true
+	if m.failWrite {
+		return errors.New("failed to save")
+	}
+	for index, existing := range m.transactions {
+		if existing.ID == transaction.ID {
+			m.transactions[index] = transaction
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (m *MockStorage) Delete(id int) error {
+	m.deleteCalled = true
+	if m.failWrite {
+		return errors.New("failed to save")
+	}
+	for index, existing := range m.transactions {
+		if existing.ID == id {
+			m.transactions = append(m.transactions[:index], m.transactions[index+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (m *MockStorage) Get(id int) (model.Transaction, error) {
+	if m.failRead {
+		return model.Transaction{}, errors.New("failed to load")
+	}
+	for _, existing := range m.transactions {
+		if existing.ID == id {
+			return existing, nil
+		}
+	}
+	return model.Transaction{}, storage.ErrNotFound
+}
+
+func (m *MockStorage) Count() (int, error) {
+	if m.failRead {
+		return 0, errors.New("failed to load")
+	}
+	return len(m.transactions), nil
+}
+
+func (m *MockStorage) FindByUser(userID int, filter model.TransactionFilter) ([]model.Transaction, error) {
+	if m.failRead {
 		return nil, errors.New("failed to load")
 	}
-	return m.transactions, nil
+	var result []model.Transaction
+	for _, existing := range m.transactions {
+		if existing.UserID == userID && filter.Matches(existing) {
+			result = append(result, existing)
+		}
+	}
+	return result, nil
 }
 
-func TestAddTransaction(t *testing.T) {
-	mockStorage := &MockStorage{transactions: []model.Transaction{}}
-	financeService := NewFinanceService(mockStorage)
+func (m *MockStorage) FindPageByUser(userID int, filter model.TransactionFilter, after model.Transaction, limit int) ([]model.Transaction, error) {
+	transactions, err := m.FindByUser(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	byDate := func(a, b model.Transaction) int {
+		return cmp.Or(time.Time(a.Date).Compare(time.Time(b.Date)), cmp.Compare(a.ID, b.ID))
+	}
+	transactions = slices.DeleteFunc(transactions, func(transaction model.Transaction) bool {
+		return byDate(transaction, after) <= 0
+	})
+	slices.SortFunc(transactions, byDate)
+	return transactions[:min(limit, len(transactions))], nil
+}
 
-	initialNextID := financeService.NextID
-	initialTransactionCount := len(financeService.Transaction)
+func TestAddTransaction(t *testing.T) {
+	mockStorage := &MockStorage{transactions: []model.Transaction{
+		{ID: 7, UserID: 1, Description: "Existing", Amount: 10_00, Type: "income"},
+	}}
+	financeService := newFinanceServiceWith(t, mockStorage)
 
 	dateStr := "2023-06-15"
 	var date model.DateOnly
@@ -49,10 +168,11 @@ func TestAddTransaction(t *testing.T) {
 
 	newTransaction := model.Transaction{
 		Description: "Test transaction",
-		Amount:      100.0,
+		Amount:      100_00,
 		Type:        "income",
 		Category:    "Test",
 		Date:        date,
+		UserID:      1,
 	}
 
 	result, err := financeService.AddTransaction(newTransaction)
@@ -61,93 +181,138 @@ func TestAddTransaction(t *testing.T) {
 		t.Fatalf("Failed to add transaction: %v", err)
 	}
 
-	if result.ID != initialNextID {
-		t.Errorf("Expected new transaction ID to be %d, got %d", initialNextID, result.ID)
+	if result.ID != 8 {
+		t.Errorf("Expected new transaction ID to be 8, got %d", result.ID)
 	}
 
-	if financeService.NextID != initialNextID+1 {
-		t.Errorf("Expected NextID to increment to %d, got %d", initialNextID+1, financeService.NextID)
+	if !mockStorage.insertCalled {
+		t.Error("Expected Insert() to be called")
 	}
 
-	if len(financeService.Transaction) != initialTransactionCount+1 {
-		t.Errorf("Expected transaction count to increase by 1, got %d", len(financeService.Transaction))
-	}
-
-	if !mockStorage.saveCalled {
-		t.Error("Expected saveTransactions to be called")
+	if len(mockStorage.transactions) != 2 {
+		t.Errorf("Expected 2 transactions in storage, got %d", len(mockStorage.transactions))
 	}
 
-	lastTransaction := financeService.Transaction[len(financeService.Transaction)-1]
+	lastTransaction := mockStorage.transactions[len(mockStorage.transactions)-1]
 	if lastTransaction.Description != newTransaction.Description ||
 		lastTransaction.Amount != newTransaction.Amount ||
 		lastTransaction.Type != newTransaction.Type ||
 		lastTransaction.Category != newTransaction.Category ||
-		lastTransaction.Date != newTransaction.Date {
+		lastTransaction.Date != newTransaction.Date ||
+		lastTransaction.UserID != newTransaction.UserID {
 		t.Error("Added transaction does not match the input")
 	}
 }
 
-func TestLoadTransactions(t *testing.T) {
-	mockTransactions := []model.Transaction{
-		{ID: 1, Description: "Test 1", Amount: 100, Type: "income"},
-		{ID: 2, Description: "Test 2", Amount: 200, Type: "expense"},
-	}
-
-	mockStorage := &MockStorage{transactions: mockTransactions}
+func TestAddTransactionNormalizesTags(t *testing.T) {
+	mockStorage := &MockStorage{}
+	financeService := newFinanceServiceWith(t, mockStorage)
 
-	financeService := NewFinanceService(mockStorage)
-
-	financeService.loadTransactions()
+	added, err := financeService.AddTransaction(model.Transaction{
+		UserID: 1, Type: "expense", Amount: 45_00, Date: mustParseDate(t, "2023-06-15"),
+		Tags: []string{" Trip-2023 ", "reimbursable", "", "TRIP-2023"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	if !slices.Equal(added.Tags, []string{"trip-2023", "reimbursable"}) {
+		t.Errorf("Expected trimmed, lowercased and deduplicated tags, got %q", added.Tags)
+	}
 
-	if len(financeService.Transaction) != 2 {
-		t.Errorf("Expected 2 transactions, got %d", len(financeService.Transaction))
+	if err := financeService.UpdateTransaction(1, strconv.Itoa(added.ID), model.Transaction{
+		Type: "expense", Amount: 45_00, Date: added.Date, Tags: []string{"  "},
+	}); err != nil {
+		t.Fatalf("Failed to update transaction: %v", err)
 	}
+	if tags := mockStorage.transactions[0].Tags; tags != nil {
+		t.Errorf("Expected blank tags to be dropped, got %q", tags)
+	}
+}
 
-	expectedNextID := 3
-	if financeService.NextID != expectedNextID {
-		t.Errorf("Expected NextID to be %d, got %d", expectedNextID, financeService.NextID)
+func TestAddTransactionSplits(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.categories.Insert,
+		model.Category{UserID: 1, Name: "Groceries", Type: "expense"},
+		model.Category{UserID: 1, Name: "Household", Type: "expense"},
+		model.Category{UserID: 1, Name: "Salary", Type: "income"},
+	)
+	financeService := services.finance
+	date := mustParseDate(t, "2023-06-15")
+
+	tests := []struct {
+		name    string
+		splits  []model.Split
+		wantErr error
+	}{
+		{"lines adding up", []model.Split{{Category: "groceries", Amount: 70_00}, {CategoryID: 2, Amount: 30_00, Note: " Detergent "}}, nil},
+		{"lines not adding up", []model.Split{{Category: "Groceries", Amount: 70_00}, {Category: "Household", Amount: 20_00}}, ErrSplitTotal},
+		{"non-positive line", []model.Split{{Category: "Groceries", Amount: 110_00}, {Category: "Household", Amount: -10_00}}, ErrSplitAmount},
+		{"unknown category", []model.Split{{Category: "Groceries", Amount: 70_00}, {Category: "Toys", Amount: 30_00}}, ErrUnknownCategory},
+		{"category of another type", []model.Split{{Category: "Groceries", Amount: 70_00}, {Category: "Salary", Amount: 30_00}}, ErrCategoryTypeMismatch},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			added, err := financeService.AddTransaction(model.Transaction{UserID: 1, Type: "expense", Amount: 100_00, Date: date, Splits: test.splits})
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Expected error %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			expected := []model.Split{
+				{Category: "Groceries", CategoryID: 1, Amount: 70_00},
+				{Category: "Household", CategoryID: 2, Amount: 30_00, Note: "Detergent"},
+			}
+			if !slices.Equal(added.Splits, expected) {
+				t.Errorf("Expected splits resolved against the catalog %+v, got %+v", expected, added.Splits)
+			}
+		})
 	}
 
-	if !mockStorage.loadCalled {
-		t.Error("Expected Load() to be called")
+	_, err := financeService.AddTransaction(model.Transaction{UserID: 1, Type: "transfer", Amount: 100_00, Date: date,
+		AccountID: 1, ToAccountID: 2, Splits: []model.Split{{Amount: 100_00}}})
+	if !errors.Is(err, ErrSplitTransfer) {
+		t.Errorf("Expected transfers to be rejected, got %v", err)
 	}
 }
 
-func TestLoadTransactionsWithFailure(t *testing.T) {
-	mockStorage := &MockStorage{failLoad: true}
-	financeService := &FinanceService{
-		Transaction: []model.Transaction{{ID: 1}},
-		NextID:      5,
-		Storage:     mockStorage,
-	}
+func TestAddTransactionWithFailure(t *testing.T) {
+	mockStorage := &MockStorage{failWrite: true}
+	financeService := newFinanceServiceWith(t, mockStorage)
 
-	financeService.loadTransactions()
+	_, err := financeService.AddTransaction(model.Transaction{Description: "Test", Amount: 100_00, Type: "income"})
 
-	if len(financeService.Transaction) != 0 {
-		t.Errorf("Expected empty transactions, got %d", len(financeService.Transaction))
+	if err == nil {
+		t.Error("Expected an error, but got nil")
 	}
 
-	if financeService.NextID != 1 {
-		t.Errorf("Expected NextID to be reset to 1, got %d", financeService.NextID)
+	if err != nil && !strings.Contains(err.Error(), "Error saving finance transaction") {
+		t.Errorf("Expected error message to contain 'Error saving finance transaction', got: %v", err)
 	}
 
-	if !mockStorage.loadCalled {
-		t.Error("Load method should have been called")
+	if !mockStorage.insertCalled {
+		t.Error("Expected Insert method to be called")
 	}
 }
 
 func TestGetTransactionByUserId(t *testing.T) {
 	mockTransactions := []model.Transaction{
-		{ID: 1, UserID: 1, Description: "Salary", Amount: 3000, Type: "income"},
-		{ID: 2, UserID: 1, Description: "Rent", Amount: 1000, Type: "expense"},
-		{ID: 3, UserID: 1, Description: "Groceries", Amount: 200, Type: "expense"},
-		{ID: 4, UserID: 2, Description: "Bonus", Amount: 500, Type: "income"},
+		{ID: 1, UserID: 1, Description: "Salary", Amount: 3000_00, Type: "income"},
+		{ID: 2, UserID: 1, Description: "Rent", Amount: 1000_00, Type: "expense"},
+		{ID: 3, UserID: 1, Description: "Groceries", Amount: 200_00, Type: "expense"},
+		{ID: 4, UserID: 2, Description: "Bonus", Amount: 500_00, Type: "income"},
 	}
 
 	mockStorage := &MockStorage{transactions: mockTransactions}
-	financeService := NewFinanceService(mockStorage)
+	financeService := newFinanceServiceWith(t, mockStorage)
 
-	result := financeService.GetTransactionByUserId(1)
+	result, err := financeService.GetTransactionByUserId(1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
 
 	if len(result) != 3 {
 		t.Errorf("Expected 3 transactions for user ID 1, got %d", len(result))
@@ -177,97 +342,107 @@ func TestGetTransactionByUserId(t *testing.T) {
 	}
 }
 
+func TestGetTransactionByUserIdWithFailure(t *testing.T) {
+	financeService := newFinanceServiceWith(t, &MockStorage{failRead: true})
+
+	if _, err := financeService.GetTransactionByUserId(1); err == nil {
+		t.Error("Expected an error when storage fails, got nil")
+	}
+}
+
 func TestGetBalance(t *testing.T) {
 	mockTransactions := []model.Transaction{
-		{ID: 1, Description: "Salary", Amount: 3000, Type: "income"},
-		{ID: 1, Description: "Rent", Amount: 1000, Type: "expense"},
-		{ID: 1, Description: "Freelance", Amount: 500, Type: "income"},
-		{ID: 1, Description: "Groceries", Amount: 200, Type: "expense"},
-		{ID: 2, Description: "Bonus", Amount: 1000, Type: "income"},
+		{ID: 1, UserID: 1, Description: "Salary", Amount: 3000_00, Type: "income"},
+		{ID: 2, UserID: 1, Description: "Rent", Amount: 1000_00, Type: "expense"},
+		{ID: 3, UserID: 1, Description: "Freelance", Amount: 500_00, Type: "income"},
+		{ID: 4, UserID: 1, Description: "Groceries", Amount: 200_00, Type: "expense"},
+		{ID: 5, UserID: 2, Description: "Bonus", Amount: 1000_00, Type: "income", Currency: "USD"},
 	}
 
 	mockStorage := &MockStorage{transactions: mockTransactions}
-	financeService := NewFinanceService(mockStorage)
+	financeService := newFinanceServiceWith(t, mockStorage)
 
-	balance := financeService.GetBalanceByUserId(1)
-	expectedBalance := 2300.0
+	balance, err := financeService.GetBalanceByUserId(1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectedBalance := model.Money(2300_00)
 
-	if balance != expectedBalance {
-		t.Errorf("Expected balance for user ID 1 to be %.2f, got %.2f", expectedBalance, balance)
+	if balance.Balance != expectedBalance {
+		t.Errorf("Expected balance for user ID 1 to be %s, got %s", expectedBalance, balance.Balance)
 	}
 
-	balance2 := financeService.GetBalanceByUserId(2)
-	expectedBalance2 := 1000.0
+	balance2, err := financeService.GetBalanceByUserId(2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectedBalance2 := model.Money(1000_00)
 
-	if balance2 != expectedBalance2 {
-		t.Errorf("Expected balance for user ID 2 to be %.2f, got %.2f", expectedBalance2, balance2)
+	if balance2.Balance != expectedBalance2 {
+		t.Errorf("Expected balance for user ID 2 to be %s, got %s", expectedBalance2, balance2.Balance)
 	}
 }
 
 func TestDeleteTransaction(t *testing.T) {
 	mockTransactions := []model.Transaction{
-		{ID: 1, Description: "Test 1", Amount: 100, Type: "income"},
-		{ID: 2, Description: "Test 2", Amount: 200, Type: "expense"},
-		{ID: 3, Description: "Test 3", Amount: 300, Type: "income"},
+		{ID: 1, UserID: 1, Description: "Test 1", Amount: 100_00, Type: "income"},
+		{ID: 2, UserID: 1, Description: "Test 2", Amount: 200_00, Type: "expense"},
+		{ID: 3, UserID: 1, Description: "Test 3", Amount: 300_00, Type: "income"},
 	}
 
 	mockStorage := &MockStorage{transactions: mockTransactions}
 
-	financeService := NewFinanceService(mockStorage)
+	financeService := newFinanceServiceWith(t, mockStorage)
 
-	err := financeService.DeleteTransaction("2")
+	err := financeService.DeleteTransaction(1, "2")
 
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	if len(financeService.Transaction) != 2 {
-		t.Errorf("Expected 2 transactions after deletion, got %d", len(financeService.Transaction))
+	if !mockStorage.deleteCalled {
+		t.Error("Expected Delete() to be called")
+	}
+
+	if len(mockStorage.transactions) != 2 {
+		t.Errorf("Expected 2 transactions in storage after deletion, got %d", len(mockStorage.transactions))
 	}
 
-	for _, tx := range financeService.Transaction {
+	for _, tx := range mockStorage.transactions {
 		if tx.ID == 2 {
 			t.Error("Transaction with ID 2 should have been deleted")
 		}
 	}
-
-	if !mockStorage.saveCalled {
-		t.Error("Expected Save() to be called")
-	}
-
-	if len(mockStorage.transactions) != 2 {
-		t.Errorf("Expected 2 transactions in storage after deletion, got %d", len(mockStorage.transactions))
-	}
 }
 
 func TestDeleteNonExistentTransaction(t *testing.T) {
 	mockStorage := &MockStorage{transactions: []model.Transaction{
-		{ID: 1, Description: "Test 1", Amount: 100, Type: "income"},
-		{ID: 2, Description: "Test 2", Amount: 200, Type: "expense"},
+		{ID: 1, UserID: 1, Description: "Test 1", Amount: 100_00, Type: "income"},
+		{ID: 2, UserID: 1, Description: "Test 2", Amount: 200_00, Type: "expense"},
 	}}
-	financeService := NewFinanceService(mockStorage)
+	financeService := newFinanceServiceWith(t, mockStorage)
 
-	err := financeService.DeleteTransaction("3")
+	err := financeService.DeleteTransaction(1, "3")
 
 	if err == nil {
 		t.Error("Expected an error when deleting a non-existent transaction, got nil")
-	} else if err.Error() != "transaction not found" {
+	} else if !errors.Is(err, ErrTransactionNotFound) {
 		t.Errorf("Expected error message 'transaction not found', got '%s'", err.Error())
 	}
 
-	if len(financeService.Transaction) != 2 {
-		t.Errorf("Expected 2 transactions after failed deletion, got %d", len(financeService.Transaction))
+	if len(mockStorage.transactions) != 2 {
+		t.Errorf("Expected 2 transactions after failed deletion, got %d", len(mockStorage.transactions))
 	}
 
-	if mockStorage.saveCalled {
-		t.Error("Save method should not have been called for non-existent transaction")
+	if mockStorage.deleteCalled {
+		t.Error("Delete method should not have been called for non-existent transaction")
 	}
 }
 
 func TestUpdateTransaction(t *testing.T) {
 	mockTransactions := []model.Transaction{
-		{ID: 1, Description: "Test 1", Amount: 100, Type: "income"},
-		{ID: 2, Description: "Test 2", Amount: 200, Type: "expense"},
+		{ID: 1, UserID: 1, Description: "Test 1", Amount: 100_00, Type: "income"},
+		{ID: 2, UserID: 1, Description: "Test 2", Amount: 200_00, Type: "expense"},
 	}
 
 	dateStr := "2023-06-15"
@@ -279,34 +454,35 @@ func TestUpdateTransaction(t *testing.T) {
 
 	mockStorage := &MockStorage{transactions: mockTransactions}
 
-	financeService := NewFinanceService(mockStorage)
+	financeService := newFinanceServiceWith(t, mockStorage)
 
 	updatedTransaction := model.Transaction{
 		Description: "Updated Test 2",
-		Amount:      250,
+		Amount:      250_00,
 		Type:        "expense",
 		Category:    "Updated Category",
 		Date:        date,
 	}
 
-	err = financeService.UpdateTransaction("2", updatedTransaction)
+	err = financeService.UpdateTransaction(1, "2", updatedTransaction)
 
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	updatedTx := financeService.Transaction[1]
+	updatedTx := mockStorage.transactions[1]
 	if updatedTx.ID != 2 ||
+		updatedTx.UserID != 1 ||
 		updatedTx.Description != "Updated Test 2" ||
-		updatedTx.Amount != 250 ||
+		updatedTx.Amount != 250_00 ||
 		updatedTx.Type != "expense" ||
 		updatedTx.Category != "Updated Category" ||
 		updatedTx.Date != date {
 		t.Error("Transaction was not updated correctly")
 	}
 
-	if !mockStorage.saveCalled {
-		t.Error("Expected Save() to be called")
+	if !mockStorage.updateCalled {
+		t.Error("Expected Update() to be called")
 	}
 
 	if len(mockStorage.transactions) != 2 {
@@ -316,10 +492,10 @@ func TestUpdateTransaction(t *testing.T) {
 
 func TestUpdateNonExistentTransaction(t *testing.T) {
 	mockStorage := &MockStorage{transactions: []model.Transaction{
-		{ID: 1, Description: "Test 1", Amount: 100, Type: "income"},
-		{ID: 2, Description: "Test 2", Amount: 200, Type: "expense"},
+		{ID: 1, UserID: 1, Description: "Test 1", Amount: 100_00, Type: "income"},
+		{ID: 2, UserID: 1, Description: "Test 2", Amount: 200_00, Type: "expense"},
 	}}
-	financeService := NewFinanceService(mockStorage)
+	financeService := newFinanceServiceWith(t, mockStorage)
 
 	dateStr := "2023-06-15"
 	var date model.DateOnly
@@ -330,49 +506,205 @@ func TestUpdateNonExistentTransaction(t *testing.T) {
 
 	updatedTransaction := model.Transaction{
 		Description: "Updated Non-existent",
-		Amount:      300,
+		Amount:      300_00,
 		Type:        "income",
 		Category:    "Test",
 		Date:        date,
 	}
 
-	err = financeService.UpdateTransaction("3", updatedTransaction)
+	err = financeService.UpdateTransaction(1, "3", updatedTransaction)
 
 	if err == nil {
 		t.Error("Expected an error when updating a non-existent transaction, got nil")
-	} else if err.Error() != "transaction not found" {
+	} else if !errors.Is(err, ErrTransactionNotFound) {
 		t.Errorf("Expected error message 'transaction not found', got '%s'", err.Error())
 	}
 
-	if len(financeService.Transaction) != 2 {
-		t.Errorf("Expected 2 transactions after failed update, got %d", len(financeService.Transaction))
+	if len(mockStorage.transactions) != 2 {
+		t.Errorf("Expected 2 transactions after failed update, got %d", len(mockStorage.transactions))
 	}
 
-	if mockStorage.saveCalled {
-		t.Error("Save method should not have been called for non-existent transaction")
+	if mockStorage.updateCalled {
+		t.Error("Update method should not have been called for non-existent transaction")
 	}
 }
 
-func TestSaveTransactionsWithFailure(t *testing.T) {
-	mockStorage := &MockStorage{failSave: true}
-	financeService := &FinanceService{
-		Transaction: []model.Transaction{
-			{ID: 1, Description: "Test", Amount: 100, Type: "income"},
-		},
-		Storage: mockStorage,
+func TestDeleteTransactionOwnedByAnotherUser(t *testing.T) {
+	mockStorage := &MockStorage{transactions: []model.Transaction{
+		{ID: 1, UserID: 1, Description: "Test 1", Amount: 100_00, Type: "income"},
+		{ID: 2, UserID: 2, Description: "Test 2", Amount: 200_00, Type: "expense"},
+	}}
+	financeService := newFinanceServiceWith(t, mockStorage)
+
+	err := financeService.DeleteTransaction(1, "2")
+
+	if !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("Expected 'transaction not found' when deleting another user's transaction, got %v", err)
 	}
 
-	err := financeService.saveTransactions()
+	if len(mockStorage.transactions) != 2 {
+		t.Errorf("Expected 2 transactions after rejected deletion, got %d", len(mockStorage.transactions))
+	}
 
-	if err == nil {
-		t.Error("Expected an error, but got nil")
+	if mockStorage.deleteCalled {
+		t.Error("Delete method should not have been called for another user's transaction")
+	}
+}
+
+func TestUpdateTransactionOwnedByAnotherUser(t *testing.T) {
+	mockStorage := &MockStorage{transactions: []model.Transaction{
+		{ID: 1, UserID: 2, Description: "Test 1", Amount: 100_00, Type: "income"},
+	}}
+	financeService := newFinanceServiceWith(t, mockStorage)
+
+	err := financeService.UpdateTransaction(1, "1", model.Transaction{Description: "Hijacked", Amount: 1_00, Type: "income"})
+
+	if !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("Expected 'transaction not found' when updating another user's transaction, got %v", err)
+	}
+
+	if mockStorage.transactions[0].Description != "Test 1" || mockStorage.transactions[0].UserID != 2 {
+		t.Error("Another user's transaction should not have been modified")
+	}
+
+	if mockStorage.updateCalled {
+		t.Error("Update method should not have been called for another user's transaction")
 	}
+}
+
+// listTransactions are five transactions of John's from May to July 2023
+// and one of Jane's
+func listTransactions(t *testing.T) []model.Transaction {
+	return []model.Transaction{
+		{UserID: 1, Description: "May salary", Amount: 3000_00, Type: "income", Category: "Salary", Date: mustParseDate(t, "2023-05-31")},
+		{UserID: 1, Description: "June rent", Amount: 1000_00, Type: "expense", Category: "Rent", Date: mustParseDate(t, "2023-06-01")},
+		{UserID: 1, Description: "Supermarket", Amount: 200_00, Type: "expense", Category: "Food", Date: mustParseDate(t, "2023-06-15")},
+		{UserID: 1, Description: "Restaurant", Amount: 80_00, Type: "expense", Category: "food", Date: mustParseDate(t, "2023-06-15")},
+		{UserID: 1, Description: "July salary", Amount: 3000_00, Type: "income", Category: "Salary", Date: mustParseDate(t, "2023-07-01")},
+		{UserID: 2, Description: "Other user", Amount: 50_00, Type: "expense", Category: "Food", Date: mustParseDate(t, "2023-06-10")},
+	}
+}
+
+func transactionIDs(transactions []model.Transaction) []int {
+	ids := make([]int, len(transactions))
+	for i, transaction := range transactions {
+		ids[i] = transaction.ID
+	}
+	return ids
+}
+
+func TestListTransactionsDefaultsToNewestFirst(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, listTransactions(t)...)
+	financeService := services.finance
 
-	if err != nil && !strings.Contains(err.Error(), "Error saving finance transactions") {
-		t.Errorf("Expected error message to contain 'Error saving finance transactions', got: %v", err)
+	page, err := financeService.ListTransactions(1, model.TransactionQuery{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
 	}
 
-	if !mockStorage.saveCalled {
-		t.Error("Expected Save method to be called")
+	expected := []int{5, 4, 3, 2, 1}
+	if !slices.Equal(transactionIDs(page.Items), expected) {
+		t.Errorf("Expected IDs %v, got %v", expected, transactionIDs(page.Items))
+	}
+
+	if page.Total != 5 || page.Limit != 50 || page.Offset != 0 {
+		t.Errorf("Unexpected page metadata: total %d, limit %d, offset %d", page.Total, page.Limit, page.Offset)
+	}
+}
+
+func TestListTransactionsFilters(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, listTransactions(t)...)
+	financeService := services.finance
+	from, _ := model.ParseDateOnly("2023-06-01")
+	to, _ := model.ParseDateOnly("2023-06-30")
+	minAmount, maxAmount := model.Money(100_00), model.Money(1000_00)
+
+	tests := []struct {
+		name     string
+		query    model.TransactionQuery
+		expected []int
+	}{
+		{"date range", model.TransactionQuery{TransactionFilter: model.TransactionFilter{From: from, To: to}}, []int{4, 3, 2}},
+		{"type", model.TransactionQuery{TransactionFilter: model.TransactionFilter{Type: "income"}}, []int{5, 1}},
+		{"category", model.TransactionQuery{TransactionFilter: model.TransactionFilter{Category: "FOOD"}}, []int{4, 3}},
+		{"amount range", model.TransactionQuery{MinAmount: &minAmount, MaxAmount: &maxAmount}, []int{3, 2}},
+		{"description search", model.TransactionQuery{Search: "SALARY"}, []int{5, 1}},
+		{"combined", model.TransactionQuery{TransactionFilter: model.TransactionFilter{Type: "expense", From: from}, Search: "rest"}, []int{4}},
+	}
+
+	for _, tt := range tests {
+		page, err := financeService.ListTransactions(1, tt.query)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.name, err)
+		}
+		if !slices.Equal(transactionIDs(page.Items), tt.expected) {
+			t.Errorf("%s: expected IDs %v, got %v", tt.name, tt.expected, transactionIDs(page.Items))
+		}
+		if page.Total != len(tt.expected) {
+			t.Errorf("%s: expected total %d, got %d", tt.name, len(tt.expected), page.Total)
+		}
+	}
+}
+
+func TestListTransactionsSorting(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, listTransactions(t)...)
+	financeService := services.finance
+
+	tests := []struct {
+		sortBy   string
+		order    string
+		expected []int
+	}{
+		{"amount", "", []int{4, 3, 2, 1, 5}},
+		{"amount", "desc", []int{5, 1, 2, 3, 4}},
+		{"date", "asc", []int{1, 2, 3, 4, 5}},
+		{"category", "asc", []int{3, 4, 2, 1, 5}},
+		{"description", "", []int{5, 2, 1, 4, 3}},
+	}
+
+	for _, tt := range tests {
+		page, err := financeService.ListTransactions(1, model.TransactionQuery{SortBy: tt.sortBy, SortOrder: tt.order})
+		if err != nil {
+			t.Fatalf("%s %s: expected no error, got %v", tt.sortBy, tt.order, err)
+		}
+		if !slices.Equal(transactionIDs(page.Items), tt.expected) {
+			t.Errorf("%s %s: expected IDs %v, got %v", tt.sortBy, tt.order, tt.expected, transactionIDs(page.Items))
+		}
+	}
+}
+
+func TestListTransactionsPagination(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, listTransactions(t)...)
+	financeService := services.finance
+
+	page, err := financeService.ListTransactions(1, model.TransactionQuery{SortBy: "id", Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !slices.Equal(transactionIDs(page.Items), []int{3, 4}) {
+		t.Errorf("Expected IDs [3 4], got %v", transactionIDs(page.Items))
+	}
+	if page.Total != 5 || page.Limit != 2 || page.Offset != 2 {
+		t.Errorf("Unexpected page metadata: total %d, limit %d, offset %d", page.Total, page.Limit, page.Offset)
+	}
+
+	page, err = financeService.ListTransactions(1, model.TransactionQuery{Offset: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(page.Items) != 0 || page.Total != 5 {
+		t.Errorf("Expected an empty page past the end with total 5, got %d items and total %d", len(page.Items), page.Total)
+	}
+
+	page, err = financeService.ListTransactions(1, model.TransactionQuery{Limit: 10000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if page.Limit != maxPageSize {
+		t.Errorf("Expected limit to be capped at %d, got %d", maxPageSize, page.Limit)
 	}
 }
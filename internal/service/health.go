@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"sync"
+	"time"
+)
+
+// readyTimeout bounds how long the readiness checks can take together
+const readyTimeout = 5 * time.Second
+
+// HealthCheck reports whether a dependency of the API can be used
+type HealthCheck func(ctx context.Context) error
+
+// HealthService runs the checks behind the readiness probe
+type HealthService struct {
+	checks map[string]HealthCheck
+}
+
+func NewHealthService() *HealthService {
+	return &HealthService{checks: map[string]HealthCheck{}}
+}
+
+// AddCheck registers a check reported under name
+func (healthService *HealthService) AddCheck(name string, check HealthCheck) {
+	healthService.checks[name] = check
+}
+
+// Ready runs every check at once and reports the API as ready only when all
+// of them pass within readyTimeout
+func (healthService *HealthService) Ready(ctx context.Context) model.Readiness {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	readiness := model.Readiness{Status: "ready", Checks: map[string]string{}}
+	var mu sync.Mutex
+	var running sync.WaitGroup
+	for name, check := range healthService.checks {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			err := check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				readiness.Status = "unavailable"
+				readiness.Checks[name] = err.Error()
+				return
+			}
+			readiness.Checks[name] = "ok"
+		}()
+	}
+	running.Wait()
+	return readiness
+}
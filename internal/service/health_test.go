@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHealthServiceReady(t *testing.T) {
+	healthService := NewHealthService()
+	healthService.AddCheck("storage", func(context.Context) error { return nil })
+
+	readiness := healthService.Ready(context.Background())
+	if readiness.Status != "ready" || !reflect.DeepEqual(readiness.Checks, map[string]string{"storage": "ok"}) {
+		t.Errorf("Expected the API to be ready, got %+v", readiness)
+	}
+
+	// A check that never returns on its own is cut short by the context
+	healthService.AddCheck("smtp", func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("dial tcp: i/o timeout")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	readiness = healthService.Ready(ctx)
+	expected := map[string]string{"storage": "ok", "smtp": "dial tcp: i/o timeout"}
+	if readiness.Status != "unavailable" || !reflect.DeepEqual(readiness.Checks, expected) {
+		t.Errorf("Expected %v and the API unavailable, got %+v", expected, readiness)
+	}
+}
+
+func TestEmailServicePingWithoutServer(t *testing.T) {
+	if err := NewEmailService("", 0, "", "").Ping(context.Background()); err == nil {
+		t.Error("Expected an error without an SMTP server")
+	}
+}
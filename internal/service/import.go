@@ -0,0 +1,488 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidImportFile is returned when a bank statement cannot be read at
+// all; problems with single lines are reported on their rows instead
+var ErrInvalidImportFile = newError(KindInvalid, "invalid_import_file", "Invalid import file")
+
+// Errors returned when import profiles are looked up or saved, and when a
+// commit is refused
+var (
+	ErrImportProfileNotFound   = newError(KindNotFound, "import_profile_not_found", "Import profile not found")
+	ErrImportProfileExists     = newError(KindConflict, "import_profile_exists", "An import profile with this name already exists")
+	ErrInvalidDelimiter        = newError(KindInvalid, "invalid_delimiter", "Delimiter must be a single character other than a quote or line break")
+	ErrNegativeSkipRows        = newError(KindInvalid, "negative_skip_rows", "skip_rows cannot be negative")
+	ErrInvalidDateFormat       = newError(KindInvalid, "invalid_date_format", "Date format must contain dd, mm and yyyy once each, e.g. dd/mm/yyyy")
+	ErrInvalidDecimalSeparator = newError(KindInvalid, "invalid_decimal_separator", "Decimal separator must be '.' or ','")
+	ErrInvalidSignConvention   = newError(KindInvalid, "invalid_sign_convention", "Sign convention must be 'negative_expense' or 'positive_expense'")
+	ErrInvalidImportRows       = newError(KindUnprocessable, "invalid_import_rows", "Nothing was imported because some rows are invalid")
+)
+
+// maxImportRows bounds the number of lines imported from one file
+const maxImportRows = 5000
+
+type ImportService struct {
+	Profiles storage.ImportProfileStorage
+	Finance  *FinanceService
+}
+
+func NewImportService(profiles storage.ImportProfileStorage, finance *FinanceService) *ImportService {
+	return &ImportService{
+		Profiles: profiles,
+		Finance:  finance,
+	}
+}
+
+// dateLayout turns a date format spelled with dd, mm and yyyy into a time
+// layout. It reports false unless each part appears exactly once and only
+// separators are left around them.
+func dateLayout(format string) (string, bool) {
+	format = strings.ToLower(format)
+	if strings.ContainsAny(format, "0123456789") {
+		return "", false
+	}
+	layout := strings.NewReplacer("yyyy", "2006", "mm", "01", "dd", "02").Replace(format)
+	for _, part := range []string{"2006", "01", "02"} {
+		if strings.Count(layout, part) != 1 {
+			return "", false
+		}
+	}
+	return layout, strings.IndexFunc(layout, unicode.IsLetter) < 0
+}
+
+// validateProfile fills in the defaults of a profile, checks its format
+// settings and account, and makes sure its name is unique for the user
+func (importService *ImportService) validateProfile(profile *model.ImportProfile) error {
+	profile.Name = strings.TrimSpace(profile.Name)
+	profile.DateColumn = strings.TrimSpace(profile.DateColumn)
+	profile.AmountColumn = strings.TrimSpace(profile.AmountColumn)
+	profile.DescriptionColumn = strings.TrimSpace(profile.DescriptionColumn)
+	profile.CategoryColumn = strings.TrimSpace(profile.CategoryColumn)
+	if profile.Delimiter == "" {
+		profile.Delimiter = ","
+	}
+	if profile.DateFormat == "" {
+		profile.DateFormat = "yyyy-mm-dd"
+	}
+	if profile.DecimalSeparator == "" {
+		profile.DecimalSeparator = "."
+	}
+	if profile.SignConvention == "" {
+		profile.SignConvention = "negative_expense"
+	}
+
+	delimiter, size := utf8.DecodeRuneInString(profile.Delimiter)
+	if size != len(profile.Delimiter) || delimiter == utf8.RuneError || strings.ContainsRune("\"\r\n", delimiter) {
+		return ErrInvalidDelimiter
+	}
+	if profile.SkipRows < 0 {
+		return ErrNegativeSkipRows
+	}
+	if _, ok := dateLayout(profile.DateFormat); !ok {
+		return ErrInvalidDateFormat
+	}
+	if !slices.Contains(model.DecimalSeparators, profile.DecimalSeparator) {
+		return ErrInvalidDecimalSeparator
+	}
+	if !slices.Contains(model.SignConventions, profile.SignConvention) {
+		return ErrInvalidSignConvention
+	}
+	if profile.AccountID != 0 {
+		if _, err := importService.Finance.ownedAccount(profile.UserID, profile.AccountID); err != nil {
+			return err
+		}
+	}
+
+	profiles, err := importService.Profiles.FindByUser(profile.UserID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range profiles {
+		if existing.ID != profile.ID && strings.EqualFold(existing.Name, profile.Name) {
+			return ErrImportProfileExists
+		}
+	}
+	return nil
+}
+
+func (importService *ImportService) AddProfile(profile model.ImportProfile) (model.ImportProfile, error) {
+	profile.ID = 0
+	if err := importService.validateProfile(&profile); err != nil {
+		return model.ImportProfile{}, err
+	}
+	return importService.Profiles.Insert(profile)
+}
+
+func (importService *ImportService) ListProfiles(userID int) ([]model.ImportProfile, error) {
+	return importService.Profiles.FindByUser(userID)
+}
+
+// GetProfile loads an import profile owned by the user. Profiles of other
+// users are reported as not found.
+func (importService *ImportService) GetProfile(userID int, idString string) (model.ImportProfile, error) {
+	id, _ := strconv.Atoi(idString)
+	profile, err := importService.Profiles.Get(id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && profile.UserID != userID) {
+		return model.ImportProfile{}, ErrImportProfileNotFound
+	}
+	return profile, err
+}
+
+func (importService *ImportService) UpdateProfile(userID int, idString string, updated model.ImportProfile) (model.ImportProfile, error) {
+	profile, err := importService.GetProfile(userID, idString)
+	if err != nil {
+		return model.ImportProfile{}, err
+	}
+	updated.ID = profile.ID
+	updated.UserID = userID
+	if err := importService.validateProfile(&updated); err != nil {
+		return model.ImportProfile{}, err
+	}
+	if err := importService.Profiles.Update(updated); err != nil {
+		return model.ImportProfile{}, err
+	}
+	return updated, nil
+}
+
+func (importService *ImportService) DeleteProfile(userID int, idString string) error {
+	profile, err := importService.GetProfile(userID, idString)
+	if err != nil {
+		return err
+	}
+	return importService.Profiles.Delete(profile.ID)
+}
+
+// ImportCSV reads a bank statement laid out as one of the user's profiles
+// describes. Without commit the parsed rows are only returned for review.
+// With commit they are saved in a single batch, and only if every row could
+// be read; rows that probably repeat an existing transaction are left out
+// unless includeDuplicates is set.
+func (importService *ImportService) ImportCSV(userID int, profileID string, input io.Reader, commit, includeDuplicates bool) (model.ImportResult, error) {
+	profile, err := importService.GetProfile(userID, profileID)
+	if err != nil {
+		return model.ImportResult{}, err
+	}
+	rows, err := importService.parseStatement(profile, input)
+	if err != nil {
+		return model.ImportResult{}, err
+	}
+	return importService.importRows(userID, rows, commit, includeDuplicates)
+}
+
+// importRows flags the duplicates among rows read from a statement and, with
+// commit, saves the rest in a single batch as long as no row has an error.
+// Duplicates, those the bank identifies as already imported included, are
+// left out unless includeDuplicates is set.
+func (importService *ImportService) importRows(userID int, rows []model.ImportRow, commit, includeDuplicates bool) (model.ImportResult, error) {
+	if err := importService.markDuplicates(userID, rows); err != nil {
+		return model.ImportResult{}, err
+	}
+
+	result := model.ImportResult{Rows: rows}
+	var batch []model.Transaction
+	var positions []int
+	for index, row := range rows {
+		if row.Error != "" {
+			result.Invalid++
+			continue
+		}
+		result.Valid++
+		if row.DuplicateOf != 0 {
+			result.Duplicates++
+			if !includeDuplicates {
+				continue
+			}
+		}
+		batch = append(batch, *row.Transaction)
+		positions = append(positions, index)
+	}
+	if !commit {
+		return result, nil
+	}
+	if result.Invalid > 0 {
+		return result, ErrInvalidImportRows.WithDetails(result)
+	}
+
+	if len(batch) > 0 {
+		inserted, err := importService.Finance.Storage.InsertMany(batch)
+		if err != nil {
+			return model.ImportResult{}, fmt.Errorf("Error saving imported transactions: %w", err)
+		}
+		for index := range inserted {
+			rows[positions[index]].Transaction = &inserted[index]
+			importService.Finance.notify(inserted[index])
+		}
+		result.Imported = len(inserted)
+	}
+	result.Committed = true
+	return result, nil
+}
+
+// statementColumns holds the positions of a profile's columns in the header
+// of a file; optional columns that are not mapped are -1
+type statementColumns struct {
+	date, amount, description, category int
+}
+
+// findColumns locates the profile's columns in header, ignoring case
+func findColumns(profile model.ImportProfile, header []string) (statementColumns, error) {
+	positions := map[string]int{}
+	for index, name := range header {
+		positions[strings.ToLower(strings.TrimSpace(name))] = index
+	}
+	find := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		index, ok := positions[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("%w: missing column %q", ErrInvalidImportFile, name)
+		}
+		return index, nil
+	}
+
+	var columns statementColumns
+	var err error
+	if columns.date, err = find(profile.DateColumn); err != nil {
+		return columns, err
+	}
+	if columns.amount, err = find(profile.AmountColumn); err != nil {
+		return columns, err
+	}
+	if columns.description, err = find(profile.DescriptionColumn); err != nil {
+		return columns, err
+	}
+	if columns.category, err = find(profile.CategoryColumn); err != nil {
+		return columns, err
+	}
+	return columns, nil
+}
+
+// parseStatement reads the rows of a CSV statement. Every line after the
+// header becomes a row, carrying the error that kept it from becoming a
+// transaction if there is one.
+func (importService *ImportService) parseStatement(profile model.ImportProfile, input io.Reader) ([]model.ImportRow, error) {
+	buffered := bufio.NewReader(input)
+	// Spreadsheets often start UTF-8 files with a byte order mark
+	if bom, _ := buffered.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		buffered.Discard(3)
+	}
+	csvReader := csv.NewReader(buffered)
+	csvReader.Comma, _ = utf8.DecodeRuneInString(profile.Delimiter)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	var header []string
+	var err error
+	for skipped := 0; skipped <= profile.SkipRows; skipped++ {
+		if header, err = csvReader.Read(); err != nil {
+			break
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: the file has no header", ErrInvalidImportFile)
+	}
+	if err != nil {
+		return nil, statementError(err)
+	}
+	columns, err := findColumns(profile, header)
+	if err != nil {
+		return nil, err
+	}
+	layout, _ := dateLayout(profile.DateFormat)
+
+	var rows []model.ImportRow
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, statementError(err)
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("%w: more than %d lines", ErrInvalidImportFile, maxImportRows)
+		}
+		line, _ := csvReader.FieldPos(0)
+		row := model.ImportRow{Line: line}
+		transaction, err := importService.parseRecord(profile, layout, columns, record)
+		if err != nil {
+			row.Error = err.Error()
+		} else {
+			row.Transaction = &transaction
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no rows found", ErrInvalidImportFile)
+	}
+	return rows, nil
+}
+
+// statementError reports malformed CSV as an invalid file and passes errors
+// reading the input through unchanged
+func statementError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+	return err
+}
+
+// parseRecord turns a line of a statement into a transaction validated like
+// one added by hand. The sign of the amount decides between income and
+// expense according to the profile's convention.
+func (importService *ImportService) parseRecord(profile model.ImportProfile, layout string, columns statementColumns, record []string) (model.Transaction, error) {
+	if len(record) <= max(columns.date, columns.amount, columns.description, columns.category) {
+		return model.Transaction{}, errors.New("line has fewer columns than the header")
+	}
+	field := func(index int) string {
+		if index < 0 {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	date, err := time.Parse(layout, field(columns.date))
+	if err != nil {
+		return model.Transaction{}, fmt.Errorf("date must be in the format %s", profile.DateFormat)
+	}
+	amount, err := parseStatementAmount(field(columns.amount), profile.DecimalSeparator)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	if amount == 0 {
+		return model.Transaction{}, errors.New("amount is zero")
+	}
+
+	transaction := model.Transaction{
+		UserID:      profile.UserID,
+		AccountID:   profile.AccountID,
+		Type:        "income",
+		Amount:      amount,
+		Date:        model.DateOnly(date),
+		Description: field(columns.description),
+		Category:    field(columns.category),
+	}
+	if (amount < 0) == (profile.SignConvention == "negative_expense") {
+		transaction.Type = "expense"
+	}
+	if amount < 0 {
+		transaction.Amount = -amount
+	}
+	return importService.Finance.prepareTransaction(transaction)
+}
+
+// parseStatementAmount reads an amount written with the given decimal
+// separator. The other separator groups thousands and is dropped; amounts in
+// parentheses are negative, as accountants write them.
+func parseStatementAmount(value, decimalSeparator string) (model.Money, error) {
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	if negative {
+		value = value[1 : len(value)-1]
+	}
+	thousands := ","
+	if decimalSeparator == "," {
+		thousands = "."
+	}
+	value = strings.ReplaceAll(strings.ReplaceAll(value, thousands, ""), " ", "")
+	amount, err := model.ParseMoney(strings.Replace(value, decimalSeparator, ".", 1))
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// markDuplicates points rows at the existing transactions of the user they
+// probably repeat. A row carrying the bank's identifier matches the
+// transaction imported with it into the same account; identifiers are only
+// unique within an account, so rows outside any account are never matched
+// this way. Otherwise a row matches a transaction with the same date, type,
+// amount and currency, unless both came with different identifiers of the
+// bank. Either way the account must be the same when both have one, and each
+// existing transaction is claimed by one row at most, so a statement with two
+// identical purchases flags only one of them when a single one was recorded.
+func (importService *ImportService) markDuplicates(userID int, rows []model.ImportRow) error {
+	var from, to model.DateOnly
+	for _, row := range rows {
+		if row.Transaction == nil {
+			continue
+		}
+		date := row.Transaction.Date
+		if from.IsZero() || time.Time(date).Before(time.Time(from)) {
+			from = date
+		}
+		if time.Time(date).After(time.Time(to)) {
+			to = date
+		}
+	}
+	if from.IsZero() {
+		return nil
+	}
+	existing, err := importService.Finance.Storage.FindByUser(userID, model.TransactionFilter{From: from, To: to})
+	if err != nil {
+		return err
+	}
+
+	claimed := map[int]bool{}
+	claim := func(row *model.ImportRow, matches func(transaction, imported model.Transaction) bool) bool {
+		if row.Transaction == nil || row.DuplicateOf != 0 {
+			return false
+		}
+		for _, transaction := range existing {
+			if !claimed[transaction.ID] && sameAccount(transaction, *row.Transaction) && matches(transaction, *row.Transaction) {
+				claimed[transaction.ID] = true
+				row.DuplicateOf = transaction.ID
+				return true
+			}
+		}
+		return false
+	}
+	// Identifiers are certain, so they are matched before any guess is made
+	for index := range rows {
+		rows[index].AlreadyImported = claim(&rows[index], func(transaction, imported model.Transaction) bool {
+			return imported.ExternalID != "" && imported.AccountID != 0 &&
+				transaction.AccountID == imported.AccountID && transaction.ExternalID == imported.ExternalID
+		})
+	}
+	for index := range rows {
+		claim(&rows[index], func(transaction, imported model.Transaction) bool {
+			return (transaction.ExternalID == "" || imported.ExternalID == "" || transaction.ExternalID == imported.ExternalID) &&
+				sameMovement(transaction, imported)
+		})
+	}
+	return nil
+}
+
+// sameAccount reports whether two transactions may belong to the same
+// account; transactions outside any account match every account
+func sameAccount(a, b model.Transaction) bool {
+	return a.AccountID == 0 || b.AccountID == 0 || a.AccountID == b.AccountID
+}
+
+// sameMovement reports whether two transactions probably record the same
+// movement of money. Descriptions are ignored: banks rarely word them the
+// way they were typed in by hand.
+func sameMovement(a, b model.Transaction) bool {
+	return a.Type == b.Type && a.Amount == b.Amount && a.Currency == b.Currency &&
+		time.Time(a.Date).Equal(time.Time(b.Date))
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// seedBankStatement gives John a checking account (1) with the rent already
+// recorded (1) and a profile for a Brazilian bank statement (1); Jane has an
+// account of her own (2)
+func seedBankStatement(t *testing.T, storages *testStorages) {
+	t.Helper()
+	seed(t, storages.accounts.Insert,
+		model.Account{UserID: 1, Name: "Checking", Type: "checking", Currency: "BRL"},
+		model.Account{UserID: 2, Name: "Other", Type: "cash", Currency: "BRL"},
+	)
+	seed(t, storages.finance.Insert, model.Transaction{UserID: 1, AccountID: 1, Type: "expense", Amount: 1500_00, Currency: "BRL",
+		Category: "Rent", Date: mustParseDate(t, "2023-06-05")})
+	seed(t, storages.imports.Insert, model.ImportProfile{UserID: 1, Name: "Bank", Delimiter: ";", SkipRows: 1,
+		DateColumn: "Data", AmountColumn: "Valor", DescriptionColumn: "Histórico", DateFormat: "dd/mm/yyyy",
+		DecimalSeparator: ",", SignConvention: "negative_expense", AccountID: 1})
+}
+
+const bankStatement = "\xef\xbb\xbfExtrato conta corrente\n" +
+	"Data;Histórico;Valor\n" +
+	"01/06/2023;Salário;3.000,00\n" +
+	"05/06/2023;Aluguel;-1.500,00\n" +
+	"10/06/2023;Padaria;-12,50\n"
+
+func TestDateLayout(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+		ok       bool
+	}{
+		{"dd/mm/yyyy", "02/01/2006", true},
+		{"YYYY-MM-DD", "2006-01-02", true},
+		{"mm.dd.yyyy", "01.02.2006", true},
+		{"dd/mm/yy", "", false},
+		{"d/m/yyyy", "", false},
+		{"dd/mm/yyyy dd", "", false},
+		{"02/01/2006", "", false},
+	}
+	for _, tt := range tests {
+		layout, ok := dateLayout(tt.format)
+		if ok != tt.ok || (ok && layout != tt.expected) {
+			t.Errorf("%q: expected %q (%v), got %q (%v)", tt.format, tt.expected, tt.ok, layout, ok)
+		}
+	}
+}
+
+func TestParseStatementAmount(t *testing.T) {
+	tests := []struct {
+		value     string
+		separator string
+		expected  model.Money
+	}{
+		{"1.234,56", ",", 1234_56},
+		{"-12,30", ",", -12_30},
+		{"1,234.56", ".", 1234_56},
+		{"(45.00)", ".", -45_00},
+		{"+ 7", ".", 7_00},
+	}
+	for _, tt := range tests {
+		amount, err := parseStatementAmount(tt.value, tt.separator)
+		if err != nil || amount != tt.expected {
+			t.Errorf("%q: expected %s, got %s (%v)", tt.value, tt.expected, amount, err)
+		}
+	}
+	if _, err := parseStatementAmount("12,3,4", ","); err == nil {
+		t.Error("Expected an amount with two decimal separators to be rejected")
+	}
+}
+
+func TestAddProfileValidation(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+
+	tests := []struct {
+		name     string
+		change   func(profile *model.ImportProfile)
+		expected error
+	}{
+		{"long delimiter", func(profile *model.ImportProfile) { profile.Delimiter = ";;" }, ErrInvalidDelimiter},
+		{"quote delimiter", func(profile *model.ImportProfile) { profile.Delimiter = `"` }, ErrInvalidDelimiter},
+		{"date format without year", func(profile *model.ImportProfile) { profile.DateFormat = "dd/mm" }, ErrInvalidDateFormat},
+		{"decimal separator", func(profile *model.ImportProfile) { profile.DecimalSeparator = "'" }, ErrInvalidDecimalSeparator},
+		{"sign convention", func(profile *model.ImportProfile) { profile.SignConvention = "always_expense" }, ErrInvalidSignConvention},
+		{"account of another user", func(profile *model.ImportProfile) { profile.AccountID = 2 }, ErrUnknownAccount},
+		{"name in use", func(profile *model.ImportProfile) { profile.Name = "BANK" }, ErrImportProfileExists},
+	}
+	for _, tt := range tests {
+		profile := model.ImportProfile{UserID: 1, Name: "Card", DateColumn: "date", AmountColumn: "amount"}
+		tt.change(&profile)
+		if _, err := importService.AddProfile(profile); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, err)
+		}
+	}
+
+	profile, err := importService.AddProfile(model.ImportProfile{UserID: 1, Name: "Card", DateColumn: "date", AmountColumn: "amount"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if profile.Delimiter != "," || profile.DateFormat != "yyyy-mm-dd" || profile.DecimalSeparator != "." || profile.SignConvention != "negative_expense" {
+		t.Errorf("Expected the defaults to be filled in, got %+v", profile)
+	}
+	if _, err := importService.GetProfile(2, "1"); !errors.Is(err, ErrImportProfileNotFound) {
+		t.Errorf("Expected profiles of other users to be hidden, got %v", err)
+	}
+}
+
+func TestImportCSVPreview(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+
+	result, err := importService.ImportCSV(1, "1", strings.NewReader(bankStatement), false, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if count := transactionCount(t, services.storages); result.Committed || count != 1 {
+		t.Fatalf("Expected a preview to save nothing, got %d transactions", count)
+	}
+	if len(result.Rows) != 3 || result.Valid != 3 || result.Invalid != 0 || result.Duplicates != 1 {
+		t.Fatalf("Expected 3 valid rows and 1 duplicate, got %+v", result)
+	}
+
+	salary := result.Rows[0]
+	if salary.Line != 3 || salary.Transaction.Type != "income" || salary.Transaction.Amount != 3000_00 ||
+		salary.Transaction.Date.String() != "2023-06-01" || salary.Transaction.Description != "Salário" ||
+		salary.Transaction.AccountID != 1 || salary.Transaction.Currency != "BRL" {
+		t.Errorf("Expected the salary on line 3 as income, got %+v (%+v)", salary, salary.Transaction)
+	}
+	if rent := result.Rows[1]; rent.Transaction.Type != "expense" || rent.Transaction.Amount != 1500_00 || rent.DuplicateOf != 1 {
+		t.Errorf("Expected the rent as an expense duplicating transaction 1, got %+v (%+v)", rent, rent.Transaction)
+	}
+}
+
+func TestImportCSVRowErrors(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+	statement := "Extrato\nData;Histórico;Valor\n" +
+		"31/06/2023;Data inválida;-10,00\n" +
+		"01/06/2023;Valor inválido;abc\n" +
+		"02/06/2023;Zero;0,00\n" +
+		"03/06/2023;Curta\n" +
+		"04/06/2023;Padaria;-12,50\n"
+
+	result, err := importService.ImportCSV(1, "1", strings.NewReader(statement), true, false)
+	if !errors.Is(err, ErrInvalidImportRows) {
+		t.Fatalf("Expected the commit to be refused, got %v", err)
+	}
+	if result.Invalid != 4 || result.Valid != 1 || result.Committed {
+		t.Errorf("Expected 4 invalid rows and nothing committed, got %+v", result)
+	}
+	if result.Rows[0].Error != "date must be in the format dd/mm/yyyy" || result.Rows[3].Error != "line has fewer columns than the header" {
+		t.Errorf("Expected errors explaining each line, got %+v", result.Rows)
+	}
+	if count := transactionCount(t, services.storages); count != 1 {
+		t.Errorf("Expected nothing saved, got %d transactions", count)
+	}
+
+	if _, err := importService.ImportCSV(1, "1", strings.NewReader("Extrato\nData;Valor\n"), false, false); err == nil ||
+		!strings.Contains(err.Error(), `missing column "Histórico"`) {
+		t.Errorf("Expected the missing column to be reported, got %v", err)
+	}
+}
+
+func TestImportCSVCommit(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+
+	result, err := importService.ImportCSV(1, "1", strings.NewReader(bankStatement), true, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !result.Committed || result.Imported != 2 || transactionCount(t, services.storages) != 3 {
+		t.Fatalf("Expected the two new rows to be saved, got %+v", result)
+	}
+	if result.Rows[0].Transaction.ID == 0 || result.Rows[1].Transaction.ID != 0 {
+		t.Errorf("Expected saved rows to carry their IDs and the duplicate to be left out, got %+v", result.Rows)
+	}
+
+	// Importing the same file again only finds duplicates
+	result, err = importService.ImportCSV(1, "1", strings.NewReader(bankStatement), true, false)
+	if err != nil || result.Imported != 0 || result.Duplicates != 3 {
+		t.Errorf("Expected every row to be a duplicate, got %+v (%v)", result, err)
+	}
+
+	result, err = importService.ImportCSV(1, "1", strings.NewReader(bankStatement), true, true)
+	if err != nil || result.Imported != 3 || transactionCount(t, services.storages) != 6 {
+		t.Errorf("Expected duplicates to be imported on request, got %+v (%v)", result, err)
+	}
+}
+
+func TestImportCSVDuplicatesAreClaimedOnce(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+	statement := "Extrato\nData;Histórico;Valor\n" +
+		"05/06/2023;Aluguel;-1.500,00\n" +
+		"05/06/2023;Aluguel;-1.500,00\n"
+
+	result, err := importService.ImportCSV(1, "1", strings.NewReader(statement), false, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Duplicates != 1 || result.Rows[0].DuplicateOf != 1 || result.Rows[1].DuplicateOf != 0 {
+		t.Errorf("Expected only the first row to match the recorded rent, got %+v", result.Rows)
+	}
+}
+
+func TestImportCSVPositiveExpenses(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+	profile, err := importService.AddProfile(model.ImportProfile{UserID: 1, Name: "Card", DateColumn: "date",
+		AmountColumn: "amount", CategoryColumn: "category", DateFormat: "mm/dd/yyyy", SignConvention: "positive_expense"})
+	if err != nil {
+		t.Fatalf("Failed to add profile: %v", err)
+	}
+	statement := "date,amount,category\n06/15/2023,\"1,200.00\",Travel\n06/20/2023,-50.00,Refunds\n"
+
+	result, err := importService.ImportCSV(1, "2", strings.NewReader(statement), false, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	charge, refund := result.Rows[0].Transaction, result.Rows[1].Transaction
+	if charge.Type != "expense" || charge.Amount != 1200_00 || charge.Category != "Travel" || charge.AccountID != profile.AccountID {
+		t.Errorf("Expected the charge as an expense, got %+v", charge)
+	}
+	if refund.Type != "income" || refund.Amount != 50_00 || refund.Date.String() != "2023-06-20" {
+		t.Errorf("Expected the refund as income, got %+v", refund)
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"html"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ofxEntry is a STMTTRN aggregate of an OFX statement: its leaf elements by
+// tag name, the line it starts on and the currency of its statement
+type ofxEntry struct {
+	line     int
+	fields   map[string]string
+	currency string
+}
+
+// parseOFX collects the transactions of an OFX or QFX file. Both the SGML
+// syntax of OFX 1.x, where leaf elements are not closed, and the XML syntax
+// of OFX 2.x are read by looking at the tags alone.
+func parseOFX(content string) ([]ofxEntry, error) {
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, fmt.Errorf("%w: no OFX element found", ErrInvalidImportFile)
+	}
+	line := 1 + strings.Count(content[:start], "\n")
+	rest := content[start:]
+
+	var entries []ofxEntry
+	var current *ofxEntry
+	var currency string
+	for {
+		open := strings.IndexByte(rest, '<')
+		if open < 0 {
+			break
+		}
+		line += strings.Count(rest[:open], "\n")
+		length := strings.IndexByte(rest[open:], '>')
+		if length < 0 {
+			return nil, fmt.Errorf("%w: unterminated tag on line %d", ErrInvalidImportFile, line)
+		}
+		tag := strings.ToUpper(strings.TrimSpace(rest[open+1 : open+length]))
+		rest = rest[open+length+1:]
+		end := strings.IndexByte(rest, '<')
+		if end < 0 {
+			end = len(rest)
+		}
+		value := strings.TrimSpace(html.UnescapeString(rest[:end]))
+
+		switch {
+		case tag == "STMTTRN":
+			current = &ofxEntry{line: line, fields: map[string]string{}, currency: currency}
+		case tag == "/STMTTRN" && current != nil:
+			entries = append(entries, *current)
+			current = nil
+		case tag == "CURDEF":
+			currency = value
+		case current != nil && !strings.HasPrefix(tag, "/"):
+			current.fields[tag] = value
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: no transactions found", ErrInvalidImportFile)
+	}
+	return entries, nil
+}
+
+// ofxTransaction turns a STMTTRN entry into an income or expense of the
+// user: negative amounts are debits, and FITID becomes the ExternalID
+func ofxTransaction(userID, accountID int, entry ofxEntry) (model.Transaction, error) {
+	posted := entry.fields["DTPOSTED"]
+	if len(posted) < 8 {
+		return model.Transaction{}, errors.New("invalid posting date")
+	}
+	date, err := time.Parse("20060102", posted[:8])
+	if err != nil {
+		return model.Transaction{}, errors.New("invalid posting date")
+	}
+	// The OFX specification allows a comma as the decimal separator
+	amount, err := model.ParseMoney(strings.Replace(entry.fields["TRNAMT"], ",", ".", 1))
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	if amount == 0 {
+		return model.Transaction{}, errors.New("amount is zero")
+	}
+
+	transaction := model.Transaction{
+		UserID:      userID,
+		AccountID:   accountID,
+		Type:        "income",
+		Amount:      amount,
+		Date:        model.DateOnly(date),
+		Description: entry.fields["NAME"],
+		ExternalID:  entry.fields["FITID"],
+	}
+	switch memo := entry.fields["MEMO"]; {
+	case transaction.Description == "":
+		transaction.Description = memo
+	case memo != "" && !strings.EqualFold(memo, transaction.Description):
+		transaction.Description += " - " + memo
+	}
+	if amount < 0 {
+		transaction.Type, transaction.Amount = "expense", -amount
+	}
+	if entry.currency != "" {
+		if transaction.Currency, err = model.ParseCurrency(entry.currency); err != nil {
+			return model.Transaction{}, err
+		}
+	}
+	return transaction, nil
+}
+
+// ImportOFX reads an OFX or QFX statement into the given account, or outside
+// any account when accountID is 0, and previews or saves its transactions
+// like ImportCSV. Transactions already imported into the account are
+// recognised by their FITID, so overlapping statements can be imported
+// without recording the same transaction twice.
+func (importService *ImportService) ImportOFX(userID, accountID int, input io.Reader, commit, includeDuplicates bool) (model.ImportResult, error) {
+	if accountID != 0 {
+		if _, err := importService.Finance.ownedAccount(userID, accountID); err != nil {
+			return model.ImportResult{}, err
+		}
+	}
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return model.ImportResult{}, err
+	}
+	// OFX 1.x files are usually encoded in Latin-1 rather than UTF-8
+	if !utf8.Valid(data) {
+		runes := make([]rune, len(data))
+		for index, b := range data {
+			runes[index] = rune(b)
+		}
+		data = []byte(string(runes))
+	}
+	entries, err := parseOFX(string(data))
+	if err != nil {
+		return model.ImportResult{}, err
+	}
+
+	var rows []model.ImportRow
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		// Statements covering overlapping periods may list a transaction twice
+		if fitID := entry.fields["FITID"]; fitID != "" {
+			if seen[fitID] {
+				continue
+			}
+			seen[fitID] = true
+		}
+		row := model.ImportRow{Line: entry.line}
+		transaction, err := ofxTransaction(userID, accountID, entry)
+		if err == nil {
+			transaction, err = importService.Finance.prepareTransaction(transaction)
+		}
+		if err != nil {
+			row.Error = err.Error()
+		} else {
+			row.Transaction = &transaction
+		}
+		rows = append(rows, row)
+		if len(rows) > maxImportRows {
+			return model.ImportResult{}, fmt.Errorf("%w: more than %d transactions", ErrInvalidImportFile, maxImportRows)
+		}
+	}
+	return importService.importRows(userID, rows, commit, includeDuplicates)
+}
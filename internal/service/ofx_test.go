@@ -0,0 +1,208 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func importOFXFile(t *testing.T, importService *ImportService, name string, accountID int, commit, includeDuplicates bool) (model.ImportResult, error) {
+	t.Helper()
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to open sample statement: %v", err)
+	}
+	defer file.Close()
+	return importService.ImportOFX(1, accountID, file, commit, includeDuplicates)
+}
+
+func TestImportOFXPreview(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+
+	result, err := importOFXFile(t, importService, "checking.ofx", 1, false, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result.Rows) != 3 || result.Valid != 3 || result.Committed || transactionCount(t, services.storages) != 1 {
+		t.Fatalf("Expected 3 rows and nothing saved, got %+v", result)
+	}
+
+	// The statement is in Latin-1, uses a comma in one amount and leaves the
+	// name of the rent out
+	expected := []model.Transaction{
+		{UserID: 1, AccountID: 1, Type: "income", Amount: 3000_00, Currency: "BRL", Date: mustParseDate(t, "2023-06-01"),
+			Description: "SALARIO - EMPRESA LTDA", ExternalID: "20230601001"},
+		{UserID: 1, AccountID: 1, Type: "expense", Amount: 1500_00, Currency: "BRL", Date: mustParseDate(t, "2023-06-05"),
+			Description: "ALUGUEL JUNHO", ExternalID: "20230605001"},
+		{UserID: 1, AccountID: 1, Type: "expense", Amount: 12_50, Currency: "BRL", Date: mustParseDate(t, "2023-06-10"),
+			Description: "PADARIA SÃO JOSÉ", ExternalID: "20230610001"},
+	}
+	for index, row := range result.Rows {
+		if row.Transaction == nil || !reflect.DeepEqual(*row.Transaction, expected[index]) {
+			t.Errorf("Row %d: expected %+v, got %+v (%+v)", index, expected[index], row.Transaction, row)
+		}
+	}
+	if result.Rows[0].Line != 39 || result.Rows[2].Line != 54 {
+		t.Errorf("Expected rows on lines 39 and 54, got %d and %d", result.Rows[0].Line, result.Rows[2].Line)
+	}
+
+	// The rent was typed in by hand before the statement was imported
+	if rent := result.Rows[1]; rent.DuplicateOf != 1 || rent.AlreadyImported {
+		t.Errorf("Expected the rent to be flagged as a likely duplicate, got %+v", rent)
+	}
+}
+
+func TestImportOFXIsIdempotent(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+
+	result, err := importOFXFile(t, importService, "checking.ofx", 1, true, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Imported != 2 || transactionCount(t, services.storages) != 3 {
+		t.Fatalf("Expected the salary and the bakery to be saved, got %+v", result)
+	}
+
+	// Importing the statement again finds the saved rows by FITID and leaves
+	// them out with the other duplicates
+	result, err = importOFXFile(t, importService, "checking.ofx", 1, true, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !result.Rows[0].AlreadyImported || !result.Rows[2].AlreadyImported || result.Rows[1].AlreadyImported {
+		t.Errorf("Expected the saved rows to be recognised by FITID, got %+v", result.Rows)
+	}
+	if result.Imported != 0 || result.Duplicates != 3 || transactionCount(t, services.storages) != 3 {
+		t.Errorf("Expected nothing left to import, got %+v", result)
+	}
+
+	// Duplicates requested explicitly are saved, FITID matches included
+	result, err = importOFXFile(t, importService, "checking.ofx", 1, true, true)
+	if err != nil || result.Imported != 3 || transactionCount(t, services.storages) != 6 {
+		t.Errorf("Expected every row to be imported on request, got %+v (%v)", result, err)
+	}
+}
+
+func TestImportOFXMatchesFITIDWithinAnAccount(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	seed(t, services.storages.accounts.Insert, model.Account{UserID: 1, Name: "Savings", Type: "savings", Currency: "BRL"})
+	importService := services.imports
+	if _, err := importOFXFile(t, importService, "checking.ofx", 1, true, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Another bank may use the same FITIDs for another account
+	result, err := importOFXFile(t, importService, "checking.ofx", 3, false, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Duplicates != 0 {
+		t.Errorf("Expected no duplicates in another account, got %+v", result.Rows)
+	}
+
+	// Outside any account the FITID is not enough, but the same movement is
+	// still a likely duplicate
+	result, err = importOFXFile(t, importService, "checking.ofx", 0, false, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for index, row := range result.Rows {
+		if row.DuplicateOf == 0 || row.AlreadyImported {
+			t.Errorf("Row %d: expected a likely duplicate, got %+v", index, row)
+		}
+	}
+}
+
+func TestImportQFXCreditCard(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+
+	result, err := importOFXFile(t, importService, "creditcard.qfx", 0, false, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The purchase listed twice is only read once
+	if len(result.Rows) != 3 || result.Valid != 2 || result.Invalid != 1 {
+		t.Fatalf("Expected 2 valid rows and 1 invalid, got %+v", result)
+	}
+	purchase, refund := result.Rows[0].Transaction, result.Rows[1].Transaction
+	if purchase.Type != "expense" || purchase.Amount != 45_90 || purchase.Currency != "USD" ||
+		purchase.Description != "BARNES & NOBLE #2231" || purchase.Date.String() != "2023-06-15" {
+		t.Errorf("Expected the purchase as a USD expense, got %+v", purchase)
+	}
+	if refund.Type != "income" || refund.Amount != 20_00 || refund.Description != "REFUND - Returned item" {
+		t.Errorf("Expected the refund as income, got %+v", refund)
+	}
+	if payment := result.Rows[2]; payment.Error != "invalid posting date" || payment.Line != 44 {
+		t.Errorf("Expected the payment's date to be rejected, got %+v", payment)
+	}
+
+	if _, err := importOFXFile(t, importService, "creditcard.qfx", 0, true, false); !errors.Is(err, ErrInvalidImportRows) {
+		t.Errorf("Expected the commit to be refused, got %v", err)
+	}
+	if count := transactionCount(t, services.storages); count != 1 {
+		t.Errorf("Expected nothing saved, got %d transactions", count)
+	}
+}
+
+func TestImportOFXRejects(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	importService := services.imports
+
+	if _, err := importOFXFile(t, importService, "checking.ofx", 2, false, false); !errors.Is(err, ErrUnknownAccount) {
+		t.Errorf("Expected accounts of other users to be rejected, got %v", err)
+	}
+	for _, content := range []string{
+		"date,amount\n2023-06-01,10.00\n",
+		"<OFX><BANKMSGSRSV1><STMTTRNRS></STMTTRNRS></BANKMSGSRSV1></OFX>",
+		"<OFX><STMTTRN><TRNAMT>10.00",
+	} {
+		if _, err := importService.ImportOFX(1, 0, strings.NewReader(content), false, false); !errors.Is(err, ErrInvalidImportFile) {
+			t.Errorf("Expected %q to be an invalid file, got %v", content, err)
+		}
+	}
+}
+
+func TestUpdateTransactionKeepsExternalID(t *testing.T) {
+	services := newTestServices(t)
+	seedBankStatement(t, services.storages)
+	seed(t, services.storages.accounts.Insert, model.Account{UserID: 1, Name: "Savings", Type: "savings", Currency: "BRL"})
+	if _, err := importOFXFile(t, services.imports, "checking.ofx", 1, true, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	transfer, err := services.finance.AddTransaction(model.Transaction{UserID: 1, Type: "transfer", Amount: 100_00,
+		AccountID: 1, ToAccountID: 3, Date: mustParseDate(t, "2023-06-20")})
+	if err != nil {
+		t.Fatalf("Failed to add transfer: %v", err)
+	}
+
+	salary, _ := services.storages.finance.Get(2)
+	salary.ExternalID = ""
+	if err := services.finance.UpdateTransaction(1, "2", salary); err != nil {
+		t.Fatalf("Failed to update the salary: %v", err)
+	}
+	if salary, _ := services.storages.finance.Get(2); salary.ExternalID != "20230601001" {
+		t.Errorf("Expected the salary to keep its FITID, got %+v", salary)
+	}
+
+	transfer.ExternalID = "20230620001"
+	if err := services.finance.UpdateTransaction(1, strconv.Itoa(transfer.ID), transfer); err != nil {
+		t.Fatalf("Failed to update the transfer: %v", err)
+	}
+	if transfer, _ := services.storages.finance.Get(transfer.ID); transfer.ExternalID != "" {
+		t.Errorf("Expected the transfer not to take a FITID, got %+v", transfer)
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPreviewSize = 12
+	maxPreviewSize     = 366
+	// maxOccurrences bounds how many occurrences of a rule are ever walked
+	// through, so no date or rule can keep pendingDates running
+	maxOccurrences = 100_000
+)
+
+// Errors returned when recurring rules and their occurrences are looked up
+// or saved
+var (
+	ErrRecurringRuleNotFound = newError(KindNotFound, "recurring_rule_not_found", "Recurring rule not found")
+	ErrOccurrenceNotFound    = newError(KindNotFound, "occurrence_not_found", "No pending occurrence on that date")
+	ErrEndBeforeStart        = newError(KindInvalid, "end_before_start", "End date must not be before the start date")
+)
+
+type RecurringService struct {
+	Storage storage.RecurringStorage
+	Finance *FinanceService
+	Users   storage.UserStorage
+	// changing serializes the changes to one user's rules, so the scheduler
+	// and the rule handlers never save over each other
+	changing userLocks
+}
+
+func NewRecurringService(storage storage.RecurringStorage, finance *FinanceService, users storage.UserStorage) *RecurringService {
+	return &RecurringService{
+		Storage: storage,
+		Finance: finance,
+		Users:   users,
+	}
+}
+
+// IsValidFrequency reports whether frequency is one of model.RecurringFrequencies
+func IsValidFrequency(frequency string) bool {
+	return slices.Contains(model.RecurringFrequencies, frequency)
+}
+
+// validateRule checks the parts of a rule that depend on stored data: the
+// schedule bounds and the accounts it records transactions in
+func (recurringService *RecurringService) validateRule(rule *model.RecurringRule) error {
+	rule.Interval = max(rule.Interval, 1)
+	if rule.EndDate != nil && time.Time(*rule.EndDate).Before(time.Time(rule.StartDate)) {
+		return ErrEndBeforeStart
+	}
+	if rule.Type == "transfer" {
+		if rule.AccountID == 0 || rule.ToAccountID == 0 || rule.AccountID == rule.ToAccountID {
+			return ErrTransferAccounts
+		}
+		if _, err := recurringService.Finance.ownedAccount(rule.UserID, rule.ToAccountID); err != nil {
+			return err
+		}
+	} else {
+		rule.ToAccountID = 0
+		// Occurrences are resolved against the catalog when they are
+		// created; checking now keeps a bad category from failing them later
+		probe := model.Transaction{UserID: rule.UserID, Type: rule.Type, Category: rule.Category}
+		if err := recurringService.Finance.applyCategory(&probe); err != nil {
+			return err
+		}
+		rule.Category = probe.Category
+	}
+	if rule.AccountID != 0 {
+		account, err := recurringService.Finance.ownedAccount(rule.UserID, rule.AccountID)
+		if err != nil {
+			return err
+		}
+		if rule.Currency != "" && rule.Currency != account.Currency {
+			return ErrCurrencyMismatch
+		}
+	}
+	return nil
+}
+
+// AddRule stores a new recurring rule. Its first occurrence is on StartDate.
+func (recurringService *RecurringService) AddRule(rule model.RecurringRule) (model.RecurringRule, error) {
+	rule.LastDate, rule.Overrides = nil, nil
+	if err := recurringService.validateRule(&rule); err != nil {
+		return model.RecurringRule{}, err
+	}
+	return recurringService.Storage.Insert(rule)
+}
+
+func (recurringService *RecurringService) ListRules(userID int) ([]model.RecurringRule, error) {
+	return recurringService.Storage.FindByUser(userID)
+}
+
+// GetRule loads a rule owned by the user. Rules of other users are reported
+// as not found.
+func (recurringService *RecurringService) GetRule(userID int, idString string) (model.RecurringRule, error) {
+	id, _ := strconv.Atoi(idString)
+	rule, err := recurringService.Storage.Get(id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && rule.UserID != userID) {
+		return model.RecurringRule{}, ErrRecurringRuleNotFound
+	}
+	return rule, err
+}
+
+// UpdateRule replaces a rule's schedule and transaction. Occurrences already
+// turned into transactions are left alone, and overrides are kept for the
+// dates that are still pending occurrences of the new schedule.
+func (recurringService *RecurringService) UpdateRule(userID int, idString string, updated model.RecurringRule) (model.RecurringRule, error) {
+	defer recurringService.changing.lock(userID)()
+	rule, err := recurringService.GetRule(userID, idString)
+	if err != nil {
+		return model.RecurringRule{}, err
+	}
+	updated.ID = rule.ID
+	updated.UserID = userID
+	updated.LastDate = rule.LastDate
+	if err := recurringService.validateRule(&updated); err != nil {
+		return model.RecurringRule{}, err
+	}
+	updated.Overrides = nil
+	for _, override := range rule.Overrides {
+		if isPending(updated, override.Date) {
+			updated.Overrides = append(updated.Overrides, override)
+		}
+	}
+	if err := recurringService.Storage.Update(updated); err != nil {
+		return model.RecurringRule{}, err
+	}
+	return updated, nil
+}
+
+// DeleteRule removes a rule; transactions it already created are kept
+func (recurringService *RecurringService) DeleteRule(userID int, idString string) error {
+	defer recurringService.changing.lock(userID)()
+	rule, err := recurringService.GetRule(userID, idString)
+	if err != nil {
+		return err
+	}
+	return recurringService.Storage.Delete(rule.ID)
+}
+
+// pendingDates returns the dates of the occurrences after rule.LastDate, up
+// to limit dates and up to until. Unbounded calls stop after the first
+// maxOccurrences occurrences of the rule.
+func pendingDates(rule model.RecurringRule, until model.DateOnly, limit int) []model.DateOnly {
+	var dates []model.DateOnly
+	for index := 0; index < maxOccurrences && (limit <= 0 || len(dates) < limit); index++ {
+		date := rule.OccurrenceDate(index)
+		if rule.Ended(index, date) || (!until.IsZero() && time.Time(date).After(time.Time(until))) {
+			break
+		}
+		if rule.LastDate != nil && !time.Time(date).After(time.Time(*rule.LastDate)) {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+// isPending reports whether date is an occurrence of the rule that has not
+// been turned into a transaction yet
+func isPending(rule model.RecurringRule, date model.DateOnly) bool {
+	// A zero date would leave pendingDates without a bound
+	if date.IsZero() || time.Time(date).Before(time.Time(rule.StartDate)) {
+		return false
+	}
+	return slices.Contains(pendingDates(rule, date, 0), date)
+}
+
+// Preview lists the next pending occurrences of a rule with their overrides
+// applied; skipped occurrences are included and flagged
+func (recurringService *RecurringService) Preview(userID int, idString string, limit int) ([]model.Occurrence, error) {
+	rule, err := recurringService.GetRule(userID, idString)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultPreviewSize
+	}
+	limit = min(limit, maxPreviewSize)
+	occurrences := []model.Occurrence{}
+	for _, date := range pendingDates(rule, model.DateOnly{}, limit) {
+		occurrences = append(occurrences, rule.Occurrence(date))
+	}
+	return occurrences, nil
+}
+
+// SetOverride changes or skips the pending occurrence on override.Date,
+// replacing any earlier override of that occurrence
+func (recurringService *RecurringService) SetOverride(userID int, idString string, override model.OccurrenceOverride) (model.RecurringRule, error) {
+	defer recurringService.changing.lock(userID)()
+	rule, err := recurringService.GetRule(userID, idString)
+	if err != nil {
+		return model.RecurringRule{}, err
+	}
+	if !isPending(rule, override.Date) {
+		return model.RecurringRule{}, ErrOccurrenceNotFound
+	}
+	if override.Category != nil && rule.Type != "transfer" {
+		probe := model.Transaction{UserID: userID, Type: rule.Type, Category: *override.Category}
+		if err := recurringService.Finance.applyCategory(&probe); err != nil {
+			return model.RecurringRule{}, err
+		}
+		override.Category = &probe.Category
+	}
+	// The storage may hand out the stored rule's own slice
+	rule.Overrides = slices.DeleteFunc(slices.Clone(rule.Overrides), func(existing model.OccurrenceOverride) bool {
+		return existing.Date == override.Date
+	})
+	rule.Overrides = append(rule.Overrides, override)
+	slices.SortFunc(rule.Overrides, func(a, b model.OccurrenceOverride) int {
+		return time.Time(a.Date).Compare(time.Time(b.Date))
+	})
+	if err := recurringService.Storage.Update(rule); err != nil {
+		return model.RecurringRule{}, err
+	}
+	return rule, nil
+}
+
+// DeleteOverride restores the pending occurrence on date to the rule's
+// transaction, undoing a skip or an edit
+func (recurringService *RecurringService) DeleteOverride(userID int, idString string, date model.DateOnly) (model.RecurringRule, error) {
+	defer recurringService.changing.lock(userID)()
+	rule, err := recurringService.GetRule(userID, idString)
+	if err != nil {
+		return model.RecurringRule{}, err
+	}
+	if _, ok := rule.Override(date); !ok || !isPending(rule, date) {
+		return model.RecurringRule{}, ErrOccurrenceNotFound
+	}
+	rule.Overrides = slices.DeleteFunc(slices.Clone(rule.Overrides), func(existing model.OccurrenceOverride) bool {
+		return existing.Date == date
+	})
+	if err := recurringService.Storage.Update(rule); err != nil {
+		return model.RecurringRule{}, err
+	}
+	return rule, nil
+}
+
+// ProcessDue turns every pending occurrence dated up to today into a
+// transaction and returns how many were created. A rule that fails stops at
+// the failing occurrence and is retried on the next run; the other rules are
+// still processed. Rules of inactive users are left pending.
+func (recurringService *RecurringService) ProcessDue(today model.DateOnly) (int, error) {
+	rules, err := recurringService.Storage.List()
+	if err != nil {
+		return 0, err
+	}
+	created := 0
+	var errs []error
+	for _, rule := range rules {
+		user, err := recurringService.Users.Get(rule.UserID)
+		if err != nil || !user.Status {
+			continue
+		}
+		count, err := recurringService.processRule(rule.UserID, rule.ID, today)
+		created += count
+		if err != nil {
+			errs = append(errs, fmt.Errorf("recurring rule %d: %w", rule.ID, err))
+		}
+	}
+	return created, errors.Join(errs...)
+}
+
+// processRule materializes the due occurrences of a single rule, saving the
+// rule after each one so a failure never repeats an occurrence. The rule is
+// read again under the user's lock, so a change made since ProcessDue listed
+// it is neither missed nor overwritten.
+func (recurringService *RecurringService) processRule(userID, id int, today model.DateOnly) (int, error) {
+	defer recurringService.changing.lock(userID)()
+	rule, err := recurringService.Storage.Get(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	created := 0
+	for _, date := range pendingDates(rule, today, 0) {
+		occurrence := rule.Occurrence(date)
+		if !occurrence.Skipped {
+			exists, err := recurringService.materialized(rule, date)
+			if err != nil {
+				return created, err
+			}
+			if !exists {
+				_, err := recurringService.Finance.AddTransaction(model.Transaction{
+					Type:        occurrence.Type,
+					Amount:      occurrence.Amount,
+					Currency:    occurrence.Currency,
+					Category:    occurrence.Category,
+					Date:        date,
+					Description: occurrence.Description,
+					UserID:      rule.UserID,
+					AccountID:   rule.AccountID,
+					ToAccountID: rule.ToAccountID,
+					RecurringID: rule.ID,
+				})
+				if err != nil {
+					return created, err
+				}
+				created++
+			}
+		}
+		rule.LastDate = &date
+		rule.Overrides = slices.DeleteFunc(slices.Clone(rule.Overrides), func(override model.OccurrenceOverride) bool {
+			return override.Date == date
+		})
+		if err := recurringService.Storage.Update(rule); err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// materialized reports whether the occurrence on date already has its
+// transaction, which happens when saving the rule failed after adding it
+func (recurringService *RecurringService) materialized(rule model.RecurringRule, date model.DateOnly) (bool, error) {
+	transactions, err := recurringService.Finance.Storage.FindByUser(rule.UserID, model.TransactionFilter{From: date, To: date})
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(transactions, func(transaction model.Transaction) bool {
+		return transaction.RecurringID == rule.ID
+	}), nil
+}
+
+// Run processes due occurrences right away and then every interval until ctx
+// is cancelled
+func (recurringService *RecurringService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		created, err := recurringService.ProcessDue(today())
+		if err != nil {
+			slog.Error("Error processing recurring transactions", "error", err)
+		}
+		if created > 0 {
+			slog.Info("Created recurring transactions", "count", created)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"cmp"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+)
+
+// failingRecurringStorage fails to update rules while fail is set
+type failingRecurringStorage struct {
+	storage.RecurringStorage
+	fail bool
+}
+
+func (s *failingRecurringStorage) Update(rule model.RecurringRule) error {
+	if s.fail {
+		return errors.New("failed to save")
+	}
+	return s.RecurringStorage.Update(rule)
+}
+
+// staleRecurringStorage lists the rules as they were when listed was taken
+type staleRecurringStorage struct {
+	storage.RecurringStorage
+	listed []model.RecurringRule
+}
+
+func (s *staleRecurringStorage) List() ([]model.RecurringRule, error) {
+	return s.listed, nil
+}
+
+// seedRecurringAccounts gives John a checking (1) and a savings account (2)
+// and Jane an account of her own (3)
+func seedRecurringAccounts(t *testing.T, storages *testStorages) {
+	t.Helper()
+	seed(t, storages.accounts.Insert,
+		model.Account{UserID: 1, Name: "Checking", Type: "checking", Currency: "BRL"},
+		model.Account{UserID: 1, Name: "Savings", Type: "savings", Currency: "BRL"},
+		model.Account{UserID: 2, Name: "Other", Type: "cash", Currency: "BRL"},
+	)
+}
+
+// johnsTransactions reads every transaction John has, in the order they were
+// stored
+func johnsTransactions(t *testing.T, storages *testStorages) []model.Transaction {
+	t.Helper()
+	transactions, err := storages.finance.FindByUser(1, model.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("Failed to find transactions: %v", err)
+	}
+	slices.SortFunc(transactions, func(a, b model.Transaction) int { return cmp.Compare(a.ID, b.ID) })
+	return transactions
+}
+
+func rentRule(t *testing.T) model.RecurringRule {
+	return model.RecurringRule{UserID: 1, Frequency: "monthly", StartDate: mustParseDate(t, "2023-01-31"),
+		Type: "expense", Amount: 1500_00, Category: "Rent", Description: "Rent", AccountID: 1}
+}
+
+func TestAddRuleValidation(t *testing.T) {
+	services := newTestServices(t)
+	seedRecurringAccounts(t, services.storages)
+	recurringService := services.recurring
+	before := mustParseDate(t, "2022-12-31")
+
+	tests := []struct {
+		name     string
+		change   func(rule *model.RecurringRule)
+		expected error
+	}{
+		{"end before start", func(rule *model.RecurringRule) { rule.EndDate = &before }, ErrEndBeforeStart},
+		{"account of another user", func(rule *model.RecurringRule) { rule.AccountID = 3 }, ErrUnknownAccount},
+		{"transfer to the same account", func(rule *model.RecurringRule) { rule.Type, rule.ToAccountID = "transfer", 1 }, ErrTransferAccounts},
+		{"currency of another account", func(rule *model.RecurringRule) { rule.Currency = "USD" }, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		rule := rentRule(t)
+		tt.change(&rule)
+		if _, err := recurringService.AddRule(rule); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, err)
+		}
+	}
+
+	rule, err := recurringService.AddRule(rentRule(t))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rule.ID == 0 || rule.Interval != 1 {
+		t.Errorf("Expected a stored rule with an interval of 1, got %+v", rule)
+	}
+}
+
+func TestProcessDueMaterializesOccurrences(t *testing.T) {
+	services := newTestServices(t)
+	seedRecurringAccounts(t, services.storages)
+	recurringService := services.recurring
+	rule := rentRule(t)
+	rule.Count = 4
+	rule, _ = recurringService.AddRule(rule)
+
+	created, err := recurringService.ProcessDue(mustParseDate(t, "2023-03-15"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	transactions := johnsTransactions(t, services.storages)
+	if created != 2 || len(transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(transactions))
+	}
+	second := transactions[1]
+	if second.Date.String() != "2023-02-28" || second.RecurringID != rule.ID || second.Currency != "BRL" || second.AccountID != 1 {
+		t.Errorf("Expected the February rent in the checking account, got %+v", second)
+	}
+
+	// Running again the same day creates nothing new
+	if created, _ := recurringService.ProcessDue(mustParseDate(t, "2023-03-15")); created != 0 {
+		t.Errorf("Expected no new transactions, got %d", created)
+	}
+
+	// The count stops the rule after four occurrences
+	created, _ = recurringService.ProcessDue(mustParseDate(t, "2024-01-01"))
+	transactions = johnsTransactions(t, services.storages)
+	if created != 2 || len(transactions) != 4 || transactions[3].Date.String() != "2023-04-30" {
+		t.Errorf("Expected the March and April rent only, got %d: %+v", created, transactions)
+	}
+}
+
+func TestProcessDueAppliesOverrides(t *testing.T) {
+	services := newTestServices(t)
+	seedRecurringAccounts(t, services.storages)
+	recurringService := services.recurring
+	rule, _ := recurringService.AddRule(rentRule(t))
+	id := "1"
+	amount := model.Money(1600_00)
+
+	if _, err := recurringService.SetOverride(1, id, model.OccurrenceOverride{Date: mustParseDate(t, "2023-02-28"), Skip: true}); err != nil {
+		t.Fatalf("Expected no error skipping, got %v", err)
+	}
+	if _, err := recurringService.SetOverride(1, id, model.OccurrenceOverride{Date: mustParseDate(t, "2023-03-31"), Amount: &amount}); err != nil {
+		t.Fatalf("Expected no error editing, got %v", err)
+	}
+	if _, err := recurringService.SetOverride(1, id, model.OccurrenceOverride{Date: mustParseDate(t, "2023-03-30"), Skip: true}); !errors.Is(err, ErrOccurrenceNotFound) {
+		t.Errorf("Expected a date off the schedule to be rejected, got %v", err)
+	}
+
+	preview, err := recurringService.Preview(1, id, 3)
+	if err != nil || len(preview) != 3 {
+		t.Fatalf("Expected 3 occurrences, got %+v (%v)", preview, err)
+	}
+	if !preview[1].Skipped || preview[2].Amount != 1600_00 {
+		t.Errorf("Expected February skipped and March edited, got %+v", preview)
+	}
+
+	if _, err := recurringService.ProcessDue(mustParseDate(t, "2023-03-31")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if transactions := johnsTransactions(t, services.storages); len(transactions) != 2 || transactions[1].Amount != 1600_00 {
+		t.Errorf("Expected January and the edited March rent, got %+v", transactions)
+	}
+
+	rule, _ = recurringService.GetRule(1, id)
+	if rule.LastDate.String() != "2023-03-31" || len(rule.Overrides) != 0 {
+		t.Errorf("Expected the rule to move past March and drop used overrides, got %+v", rule)
+	}
+	if _, err := recurringService.SetOverride(1, id, model.OccurrenceOverride{Date: mustParseDate(t, "2023-01-31"), Skip: true}); err == nil {
+		t.Error("Expected materialized occurrences to be rejected")
+	}
+}
+
+func TestSetOverrideRejectsDatesOutsideTheSchedule(t *testing.T) {
+	services := newTestServices(t)
+	seedRecurringAccounts(t, services.storages)
+	recurringService := services.recurring
+	rule := rentRule(t)
+	rule.Frequency = "daily"
+	if _, err := recurringService.AddRule(rule); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The rule has no end, so the zero date must not be searched for
+	for _, date := range []model.DateOnly{{}, mustParseDate(t, "2023-01-30")} {
+		done := make(chan error, 1)
+		go func() {
+			_, err := recurringService.SetOverride(1, "1", model.OccurrenceOverride{Date: date, Skip: true})
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrOccurrenceNotFound) {
+				t.Errorf("%s: expected %v, got %v", date, ErrOccurrenceNotFound, err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: expected the override to be rejected right away", date)
+		}
+	}
+
+	if dates := pendingDates(rule, model.DateOnly{}, 0); len(dates) != maxOccurrences {
+		t.Errorf("Expected an unbounded search to stop after %d occurrences, got %d", maxOccurrences, len(dates))
+	}
+}
+
+func TestProcessDueSkipsInactiveUsersAndRetriesFailures(t *testing.T) {
+	storages := newTestStorages(t)
+	seedRecurringAccounts(t, storages)
+	transactions := &MockStorage{}
+	storages.finance = transactions
+	recurringService := storages.services().recurring
+	inactive := rentRule(t)
+	inactive.UserID, inactive.AccountID = 2, 3
+	seed(t, storages.recurring.Insert, inactive)
+	jane, _ := storages.users.Get(2)
+	jane.Status = false
+	storages.users.Update(jane)
+	recurringService.AddRule(rentRule(t))
+
+	transactions.failWrite = true
+	created, err := recurringService.ProcessDue(mustParseDate(t, "2023-02-28"))
+	if err == nil || created != 0 {
+		t.Fatalf("Expected the failed insert to be reported, got %d (%v)", created, err)
+	}
+	transactions.failWrite = false
+
+	created, err = recurringService.ProcessDue(mustParseDate(t, "2023-02-28"))
+	if err != nil || created != 2 {
+		t.Fatalf("Expected both occurrences on the retry, got %d (%v)", created, err)
+	}
+	for _, transaction := range transactions.transactions {
+		if transaction.UserID != 1 {
+			t.Errorf("Expected no transactions for the inactive user, got %+v", transaction)
+		}
+	}
+}
+
+func TestProcessDueDoesNotRepeatAfterSaveFailure(t *testing.T) {
+	storages := newTestStorages(t)
+	seedRecurringAccounts(t, storages)
+	rules := &failingRecurringStorage{RecurringStorage: storages.recurring}
+	storages.recurring = rules
+	recurringService := storages.services().recurring
+	recurringService.AddRule(rentRule(t))
+
+	rules.fail = true
+	if _, err := recurringService.ProcessDue(mustParseDate(t, "2023-01-31")); err == nil {
+		t.Fatal("Expected the failed rule update to be reported")
+	}
+	rules.fail = false
+
+	if _, err := recurringService.ProcessDue(mustParseDate(t, "2023-01-31")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if transactions := johnsTransactions(t, storages); len(transactions) != 1 {
+		t.Errorf("Expected a single January rent, got %+v", transactions)
+	}
+}
+
+func TestProcessDueRereadsRulesChangedSinceListing(t *testing.T) {
+	storages := newTestStorages(t)
+	seedRecurringAccounts(t, storages)
+	rules := &staleRecurringStorage{RecurringStorage: storages.recurring}
+	storages.recurring = rules
+	recurringService := storages.services().recurring
+	recurringService.AddRule(rentRule(t))
+	rules.listed, _ = storages.recurring.FindByUser(1)
+
+	if _, err := recurringService.SetOverride(1, "1", model.OccurrenceOverride{Date: mustParseDate(t, "2023-01-31"), Skip: true}); err != nil {
+		t.Fatalf("Expected no error skipping, got %v", err)
+	}
+	created, err := recurringService.ProcessDue(mustParseDate(t, "2023-01-31"))
+	if err != nil || created != 0 {
+		t.Fatalf("Expected the skipped occurrence not to be created, got %d (%v)", created, err)
+	}
+	rule, _ := recurringService.GetRule(1, "1")
+	if rule.LastDate.String() != "2023-01-31" || len(rule.Overrides) != 0 {
+		t.Errorf("Expected the rule to move past January and drop the skip, got %+v", rule)
+	}
+}
+
+func TestDeleteOverrideKeepsStoredRuleOnSaveFailure(t *testing.T) {
+	storages := newTestStorages(t)
+	seedRecurringAccounts(t, storages)
+	// The file backend hands out the stored rule's own overrides
+	file, err := storage.NewFileRecurringStorage(filepath.Join(t.TempDir(), "recurring.json"))
+	if err != nil {
+		t.Fatalf("Failed to open the rule file: %v", err)
+	}
+	rules := &failingRecurringStorage{RecurringStorage: file}
+	storages.recurring = rules
+	recurringService := storages.services().recurring
+	recurringService.AddRule(rentRule(t))
+	for _, date := range []string{"2023-02-28", "2023-03-31"} {
+		if _, err := recurringService.SetOverride(1, "1", model.OccurrenceOverride{Date: mustParseDate(t, date), Skip: true}); err != nil {
+			t.Fatalf("Expected no error skipping %s, got %v", date, err)
+		}
+	}
+
+	rules.fail = true
+	if _, err := recurringService.DeleteOverride(1, "1", mustParseDate(t, "2023-02-28")); err == nil {
+		t.Fatal("Expected the failed rule update to be reported")
+	}
+	rule, _ := file.Get(1)
+	if len(rule.Overrides) != 2 || rule.Overrides[0].Date.String() != "2023-02-28" || rule.Overrides[1].Date.String() != "2023-03-31" {
+		t.Errorf("Expected both overrides to be kept, got %+v", rule.Overrides)
+	}
+}
+
+func TestRecurringTransferRule(t *testing.T) {
+	services := newTestServices(t)
+	seedRecurringAccounts(t, services.storages)
+	recurringService := services.recurring
+	_, err := recurringService.AddRule(model.RecurringRule{UserID: 1, Frequency: "weekly", StartDate: mustParseDate(t, "2023-01-02"),
+		Type: "transfer", Amount: 100_00, AccountID: 1, ToAccountID: 2, Count: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if created, err := recurringService.ProcessDue(mustParseDate(t, "2023-02-01")); err != nil || created != 1 {
+		t.Fatalf("Expected one transfer, got %d (%v)", created, err)
+	}
+	if transactions := johnsTransactions(t, services.storages); len(transactions) != 2 || transactions[1].TransferRole != "destination" {
+		t.Errorf("Expected both halves of the transfer, got %+v", transactions)
+	}
+}
+
+func TestUpdateTransactionKeepsRecurringRule(t *testing.T) {
+	services := newTestServices(t)
+	seedRecurringAccounts(t, services.storages)
+	services.recurring.AddRule(rentRule(t))
+	if _, err := services.recurring.ProcessDue(mustParseDate(t, "2023-01-31")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	rent := johnsTransactions(t, services.storages)[0]
+	rent.Amount, rent.RecurringID = 1600_00, 0
+	if err := services.finance.UpdateTransaction(1, "1", rent); err != nil {
+		t.Fatalf("Failed to update the rent: %v", err)
+	}
+	if rent := johnsTransactions(t, services.storages)[0]; rent.Amount != 1600_00 || rent.RecurringID != 1 {
+		t.Errorf("Expected the rent to stay linked to its rule, got %+v", rent)
+	}
+}
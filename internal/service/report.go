@@ -0,0 +1,211 @@
+package service
+
+import (
+	"cmp"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+// totalsFor returns the totals stored under key, creating them if needed
+func totalsFor[K comparable](totals map[K]*model.ReportTotals, key K) *model.ReportTotals {
+	if totals[key] == nil {
+		totals[key] = &model.ReportTotals{}
+	}
+	return totals[key]
+}
+
+// addToTotals accumulates a transaction into the totals. Transfers are
+// neither income nor expense and are left out.
+func addToTotals(totals *model.ReportTotals, transaction model.Transaction) {
+	switch transaction.Type {
+	case "income":
+		totals.Income += transaction.Amount
+		totals.Net += transaction.Amount
+	case "expense":
+		totals.Expense += transaction.Amount
+		totals.Net -= transaction.Amount
+	}
+}
+
+// reportPortions keeps the candidates matching filter and breaks split
+// transactions into their lines, so amounts are counted under the lines'
+// categories. With a category filter, only the lines in that category are
+// kept.
+func reportPortions(candidates []model.Transaction, filter model.TransactionFilter) []model.Transaction {
+	portions := make([]model.Transaction, 0, len(candidates))
+	for _, transaction := range candidates {
+		if !filter.Matches(transaction) {
+			continue
+		}
+		for _, portion := range transaction.Portions() {
+			if filter.Category == "" || strings.EqualFold(portion.Category, filter.Category) {
+				portions = append(portions, portion)
+			}
+		}
+	}
+	return portions
+}
+
+// SummaryReport aggregates income, expense and net totals of the transactions
+// matching filter, grouped by month, by category and by month and category.
+// Split transactions count under the categories of their lines.
+// Amounts are converted to the user's base currency; the unconverted totals
+// of each currency are reported separately.
+func (financeService *FinanceService) SummaryReport(userID int, filter model.TransactionFilter) (model.SummaryReport, error) {
+	candidates, err := financeService.Storage.FindByUser(userID, filter)
+	if err != nil {
+		return model.SummaryReport{}, err
+	}
+
+	var report model.SummaryReport
+	byCurrency := map[string]*model.ReportTotals{}
+	matching := reportPortions(candidates, filter)
+	for _, transaction := range matching {
+		addToTotals(totalsFor(byCurrency, transaction.CurrencyOrDefault()), transaction)
+	}
+	report.Currency, matching, err = financeService.inBaseCurrency(userID, matching)
+	if err != nil {
+		return model.SummaryReport{}, err
+	}
+
+	type monthCategory struct{ month, category string }
+	byMonth := map[string]*model.ReportTotals{}
+	byCategory := map[string]*model.ReportTotals{}
+	byMonthCategory := map[monthCategory]*model.ReportTotals{}
+
+	for _, transaction := range matching {
+		month := time.Time(transaction.Date).Format(monthLayout)
+
+		addToTotals(&report.Totals, transaction)
+		addToTotals(totalsFor(byMonth, month), transaction)
+		addToTotals(totalsFor(byCategory, transaction.Category), transaction)
+		addToTotals(totalsFor(byMonthCategory, monthCategory{month, transaction.Category}), transaction)
+	}
+
+	report.ByCurrency = make([]model.CurrencySummary, 0, len(byCurrency))
+	for currency, totals := range byCurrency {
+		report.ByCurrency = append(report.ByCurrency, model.CurrencySummary{Currency: currency, ReportTotals: *totals})
+	}
+	slices.SortFunc(report.ByCurrency, func(a, b model.CurrencySummary) int { return cmp.Compare(a.Currency, b.Currency) })
+
+	report.ByMonth = make([]model.MonthSummary, 0, len(byMonth))
+	for month, totals := range byMonth {
+		report.ByMonth = append(report.ByMonth, model.MonthSummary{Month: month, ReportTotals: *totals})
+	}
+	slices.SortFunc(report.ByMonth, func(a, b model.MonthSummary) int { return cmp.Compare(a.Month, b.Month) })
+
+	report.ByCategory = make([]model.CategorySummary, 0, len(byCategory))
+	for category, totals := range byCategory {
+		report.ByCategory = append(report.ByCategory, model.CategorySummary{Category: category, ReportTotals: *totals})
+	}
+	slices.SortFunc(report.ByCategory, func(a, b model.CategorySummary) int { return cmp.Compare(a.Category, b.Category) })
+
+	report.ByMonthCategory = make([]model.MonthCategorySummary, 0, len(byMonthCategory))
+	for key, totals := range byMonthCategory {
+		report.ByMonthCategory = append(report.ByMonthCategory, model.MonthCategorySummary{Month: key.month, Category: key.category, ReportTotals: *totals})
+	}
+	slices.SortFunc(report.ByMonthCategory, func(a, b model.MonthCategorySummary) int {
+		return cmp.Or(cmp.Compare(a.Month, b.Month), cmp.Compare(a.Category, b.Category))
+	})
+
+	return report, nil
+}
+
+// TagReport aggregates income, expense and net totals of the transactions
+// matching filter by tag, in the user's base currency
+func (financeService *FinanceService) TagReport(userID int, filter model.TransactionFilter) (model.TagReport, error) {
+	candidates, err := financeService.Storage.FindByUser(userID, filter)
+	if err != nil {
+		return model.TagReport{}, err
+	}
+	matching := reportPortions(candidates, filter)
+
+	var report model.TagReport
+	report.Currency, matching, err = financeService.inBaseCurrency(userID, matching)
+	if err != nil {
+		return model.TagReport{}, err
+	}
+
+	byTag := map[string]*model.ReportTotals{}
+	for _, transaction := range matching {
+		if len(transaction.Tags) == 0 {
+			addToTotals(&report.Untagged, transaction)
+		}
+		for _, tag := range transaction.Tags {
+			addToTotals(totalsFor(byTag, tag), transaction)
+		}
+	}
+
+	report.Tags = make([]model.TagSummary, 0, len(byTag))
+	for tag, totals := range byTag {
+		report.Tags = append(report.Tags, model.TagSummary{Tag: tag, ReportTotals: *totals})
+	}
+	slices.SortFunc(report.Tags, func(a, b model.TagSummary) int { return cmp.Compare(a.Tag, b.Tag) })
+	return report, nil
+}
+
+// BalanceHistory returns the running balance at the end of every day or month
+// with activity between from and to. Transactions before from make up the
+// opening balance, so the first point continues from the real balance.
+// Account opening balances count from their opening date, and amounts are
+// converted to the user's base currency.
+func (financeService *FinanceService) BalanceHistory(userID int, from, to model.DateOnly, interval string) (model.BalanceHistory, error) {
+	layout := dayLayout
+	if interval == "month" {
+		layout = monthLayout
+	} else {
+		interval = "day"
+	}
+
+	transactions, err := financeService.Storage.FindByUser(userID, model.TransactionFilter{To: to})
+	if err != nil {
+		return model.BalanceHistory{}, err
+	}
+	accounts, err := financeService.Accounts.FindByUser(userID)
+	if err != nil {
+		return model.BalanceHistory{}, err
+	}
+	for _, opening := range openingTransactions(accounts) {
+		if (model.TransactionFilter{To: to}).Matches(opening) {
+			transactions = append(transactions, opening)
+		}
+	}
+	baseCurrency, transactions, err := financeService.inBaseCurrency(userID, transactions)
+	if err != nil {
+		return model.BalanceHistory{}, err
+	}
+	slices.SortStableFunc(transactions, func(a, b model.Transaction) int {
+		return time.Time(a.Date).Compare(time.Time(b.Date))
+	})
+
+	history := model.BalanceHistory{Currency: baseCurrency, Interval: interval, Points: []model.BalancePoint{}}
+	var balance model.Money
+	for _, transaction := range transactions {
+		var totals model.ReportTotals
+		addToTotals(&totals, transaction)
+		balance += balanceChange(transaction)
+
+		if !from.IsZero() && time.Time(transaction.Date).Before(time.Time(from)) {
+			history.OpeningBalance = balance
+			continue
+		}
+
+		period := time.Time(transaction.Date).Format(layout)
+		if len(history.Points) == 0 || history.Points[len(history.Points)-1].Period != period {
+			history.Points = append(history.Points, model.BalancePoint{Period: period})
+		}
+		point := &history.Points[len(history.Points)-1]
+		point.Income += totals.Income
+		point.Expense += totals.Expense
+		point.Balance = balance
+	}
+	history.ClosingBalance = balance
+	return history, nil
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// reportTransactions are John's income and expenses from May to July 2023,
+// with an expense of Jane's that his reports must leave out
+func reportTransactions(t *testing.T) []model.Transaction {
+	return []model.Transaction{
+		{UserID: 1, Amount: 3000_00, Type: "income", Category: "Salary", Date: mustParseDate(t, "2023-05-31")},
+		{UserID: 1, Amount: 1000_00, Type: "expense", Category: "Rent", Date: mustParseDate(t, "2023-06-01")},
+		{UserID: 1, Amount: 200_00, Type: "expense", Category: "Food", Date: mustParseDate(t, "2023-06-15"), Tags: []string{"trip", "reimbursable"}},
+		{UserID: 1, Amount: 80_00, Type: "expense", Category: "Food", Date: mustParseDate(t, "2023-06-15"), Tags: []string{"trip"}},
+		{UserID: 1, Amount: 3000_00, Type: "income", Category: "Salary", Date: mustParseDate(t, "2023-06-30")},
+		{UserID: 1, Amount: 150_00, Type: "expense", Category: "Food", Date: mustParseDate(t, "2023-07-02")},
+		{UserID: 2, Amount: 999_00, Type: "expense", Category: "Food", Date: mustParseDate(t, "2023-06-10")},
+	}
+}
+
+func TestSummaryReport(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, reportTransactions(t)...)
+	financeService := services.finance
+
+	report, err := financeService.SummaryReport(1, model.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expectedTotals := model.ReportTotals{Income: 6000_00, Expense: 1430_00, Net: 4570_00}
+	if report.Totals != expectedTotals {
+		t.Errorf("Expected totals %+v, got %+v", expectedTotals, report.Totals)
+	}
+
+	expectedMonths := []model.MonthSummary{
+		{Month: "2023-05", ReportTotals: model.ReportTotals{Income: 3000_00, Net: 3000_00}},
+		{Month: "2023-06", ReportTotals: model.ReportTotals{Income: 3000_00, Expense: 1280_00, Net: 1720_00}},
+		{Month: "2023-07", ReportTotals: model.ReportTotals{Expense: 150_00, Net: -150_00}},
+	}
+	if len(report.ByMonth) != len(expectedMonths) {
+		t.Fatalf("Expected %d months, got %d", len(expectedMonths), len(report.ByMonth))
+	}
+	for i, expected := range expectedMonths {
+		if report.ByMonth[i] != expected {
+			t.Errorf("Expected month %+v, got %+v", expected, report.ByMonth[i])
+		}
+	}
+
+	expectedCategories := []model.CategorySummary{
+		{Category: "Food", ReportTotals: model.ReportTotals{Expense: 430_00, Net: -430_00}},
+		{Category: "Rent", ReportTotals: model.ReportTotals{Expense: 1000_00, Net: -1000_00}},
+		{Category: "Salary", ReportTotals: model.ReportTotals{Income: 6000_00, Net: 6000_00}},
+	}
+	if len(report.ByCategory) != len(expectedCategories) {
+		t.Fatalf("Expected %d categories, got %d", len(expectedCategories), len(report.ByCategory))
+	}
+	for i, expected := range expectedCategories {
+		if report.ByCategory[i] != expected {
+			t.Errorf("Expected category %+v, got %+v", expected, report.ByCategory[i])
+		}
+	}
+
+	if len(report.ByMonthCategory) != 5 {
+		t.Fatalf("Expected 5 month/category rows, got %d", len(report.ByMonthCategory))
+	}
+	juneFood := report.ByMonthCategory[1]
+	if juneFood.Month != "2023-06" || juneFood.Category != "Food" || juneFood.Expense != 280_00 {
+		t.Errorf("Expected June food expense of 280, got %+v", juneFood)
+	}
+}
+
+func TestSummaryReportWithDateRange(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, reportTransactions(t)...)
+	financeService := services.finance
+	from, _ := model.ParseDateOnly("2023-06-01")
+	to, _ := model.ParseDateOnly("2023-06-30")
+
+	report, err := financeService.SummaryReport(1, model.TransactionFilter{From: from, To: to})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(report.ByMonth) != 1 || report.ByMonth[0].Month != "2023-06" {
+		t.Errorf("Expected only June in the report, got %+v", report.ByMonth)
+	}
+
+	expectedTotals := model.ReportTotals{Income: 3000_00, Expense: 1280_00, Net: 1720_00}
+	if report.Totals != expectedTotals {
+		t.Errorf("Expected totals %+v, got %+v", expectedTotals, report.Totals)
+	}
+}
+
+func TestSummaryReportEmpty(t *testing.T) {
+	financeService := newTestServices(t).finance
+
+	report, err := financeService.SummaryReport(1, model.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if report.ByMonth == nil || report.ByCategory == nil || report.ByMonthCategory == nil {
+		t.Error("Expected empty groupings to be empty slices rather than nil")
+	}
+}
+
+func TestSummaryReportSplits(t *testing.T) {
+	date := mustParseDate(t, "2023-06-15")
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert,
+		model.Transaction{UserID: 1, Amount: 100_00, Type: "expense", Category: "Supermarket", Date: date,
+			Splits: []model.Split{{Category: "Food", Amount: 60_00}, {Category: "Household", Amount: 40_00}}},
+		model.Transaction{UserID: 1, Amount: 20_00, Type: "expense", Category: "Food", Date: date},
+	)
+	financeService := services.finance
+
+	report, err := financeService.SummaryReport(1, model.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectedCategories := []model.CategorySummary{
+		{Category: "Food", ReportTotals: model.ReportTotals{Expense: 80_00, Net: -80_00}},
+		{Category: "Household", ReportTotals: model.ReportTotals{Expense: 40_00, Net: -40_00}},
+	}
+	if !slices.Equal(report.ByCategory, expectedCategories) {
+		t.Errorf("Expected split amounts under their own categories %+v, got %+v", expectedCategories, report.ByCategory)
+	}
+	if report.Totals.Expense != 120_00 {
+		t.Errorf("Expected splits not to change the total, got %+v", report.Totals)
+	}
+
+	report, err = financeService.SummaryReport(1, model.TransactionFilter{Category: "household"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Totals.Expense != 40_00 || len(report.ByCategory) != 1 {
+		t.Errorf("Expected only the household line, got %+v", report)
+	}
+}
+
+func TestTagReport(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, reportTransactions(t)...)
+	financeService := services.finance
+
+	report, err := financeService.TagReport(1, model.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Transaction 3 carries both tags and counts towards each of them
+	expectedTags := []model.TagSummary{
+		{Tag: "reimbursable", ReportTotals: model.ReportTotals{Expense: 200_00, Net: -200_00}},
+		{Tag: "trip", ReportTotals: model.ReportTotals{Expense: 280_00, Net: -280_00}},
+	}
+	if len(report.Tags) != len(expectedTags) {
+		t.Fatalf("Expected %d tags, got %+v", len(expectedTags), report.Tags)
+	}
+	for i, expected := range expectedTags {
+		if report.Tags[i] != expected {
+			t.Errorf("Expected tag %+v, got %+v", expected, report.Tags[i])
+		}
+	}
+	expectedUntagged := model.ReportTotals{Income: 6000_00, Expense: 1150_00, Net: 4850_00}
+	if report.Untagged != expectedUntagged {
+		t.Errorf("Expected untagged totals %+v, got %+v", expectedUntagged, report.Untagged)
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, reportTransactions(t)...)
+	financeService := services.finance
+
+	tests := []struct {
+		name     string
+		filter   model.TransactionFilter
+		expected []int
+	}{
+		{"any tag", model.TransactionFilter{Tags: []string{"reimbursable", "TRIP"}}, []int{3, 4}},
+		{"all tags", model.TransactionFilter{Tags: []string{"reimbursable", "trip"}, AllTags: true}, []int{3}},
+		{"unknown tag", model.TransactionFilter{Tags: []string{"work"}}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := financeService.ListTransactions(1, model.TransactionQuery{TransactionFilter: test.filter, SortBy: "id"})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			var ids []int
+			for _, transaction := range page.Items {
+				ids = append(ids, transaction.ID)
+			}
+			if !slices.Equal(ids, test.expected) {
+				t.Errorf("Expected transactions %v, got %v", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestBalanceHistoryByDay(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, reportTransactions(t)...)
+	financeService := services.finance
+	from, _ := model.ParseDateOnly("2023-06-01")
+	to, _ := model.ParseDateOnly("2023-06-30")
+
+	history, err := financeService.BalanceHistory(1, from, to, "day")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if history.OpeningBalance != 3000_00 {
+		t.Errorf("Expected opening balance 3000.00, got %s", history.OpeningBalance)
+	}
+
+	expected := []model.BalancePoint{
+		{Period: "2023-06-01", Expense: 1000_00, Balance: 2000_00},
+		{Period: "2023-06-15", Expense: 280_00, Balance: 1720_00},
+		{Period: "2023-06-30", Income: 3000_00, Balance: 4720_00},
+	}
+	if len(history.Points) != len(expected) {
+		t.Fatalf("Expected %d points, got %d", len(expected), len(history.Points))
+	}
+	for i, point := range expected {
+		if history.Points[i] != point {
+			t.Errorf("Expected point %+v, got %+v", point, history.Points[i])
+		}
+	}
+
+	if history.ClosingBalance != 4720_00 {
+		t.Errorf("Expected closing balance 4720.00, got %s", history.ClosingBalance)
+	}
+}
+
+func TestBalanceHistoryByMonth(t *testing.T) {
+	services := newTestServices(t)
+	seed(t, services.storages.finance.Insert, reportTransactions(t)...)
+	financeService := services.finance
+
+	history, err := financeService.BalanceHistory(1, model.DateOnly{}, model.DateOnly{}, "month")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []model.BalancePoint{
+		{Period: "2023-05", Income: 3000_00, Balance: 3000_00},
+		{Period: "2023-06", Income: 3000_00, Expense: 1280_00, Balance: 4720_00},
+		{Period: "2023-07", Expense: 150_00, Balance: 4570_00},
+	}
+	if len(history.Points) != len(expected) {
+		t.Fatalf("Expected %d points, got %d", len(expected), len(history.Points))
+	}
+	for i, point := range expected {
+		if history.Points[i] != point {
+			t.Errorf("Expected point %+v, got %+v", point, history.Points[i])
+		}
+	}
+
+	if history.OpeningBalance != 0 || history.ClosingBalance != 4570_00 {
+		t.Errorf("Expected opening 0.00 and closing 4570.00, got %s and %s", history.OpeningBalance, history.ClosingBalance)
+	}
+}
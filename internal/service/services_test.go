@@ -0,0 +1,137 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+)
+
+// testStorages holds one storage of each kind, all in the same SQLite
+// database
+type testStorages struct {
+	finance    storage.FinanceStorage
+	users      storage.UserStorage
+	rates      storage.ExchangeRateStorage
+	accounts   storage.AccountStorage
+	categories storage.CategoryStorage
+	imports    storage.ImportProfileStorage
+	recurring  storage.RecurringStorage
+	budgets    storage.BudgetStorage
+	alerts     storage.AlertStorage
+}
+
+// testServices holds every service, wired over testStorages the way the
+// server wires them
+type testServices struct {
+	storages   *testStorages
+	currencies *CurrencyService
+	accounts   *AccountService
+	finance    *FinanceService
+	recurring  *RecurringService
+	categories *CategoryService
+	imports    *ImportService
+	budgets    *BudgetService
+	alerts     *AlertService
+	users      *UserService
+	userData   *UserDataService
+}
+
+// newTestStorages opens the storages in a temporary directory. John (1) has
+// BRL as his base currency and Jane (2) has USD, and a dollar is worth 5 BRL
+// from June 2023 and 4.8 BRL from July 2023.
+func newTestStorages(t *testing.T) *testStorages {
+	t.Helper()
+	db, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	storages := &testStorages{
+		finance:    storage.NewSQLiteFinanceStorage(db),
+		users:      storage.NewSQLiteUserStorage(db),
+		rates:      storage.NewSQLiteExchangeRateStorage(db),
+		accounts:   storage.NewSQLiteAccountStorage(db),
+		categories: storage.NewSQLiteCategoryStorage(db),
+		imports:    storage.NewSQLiteImportProfileStorage(db),
+		recurring:  storage.NewSQLiteRecurringStorage(db),
+		budgets:    storage.NewSQLiteBudgetStorage(db),
+		alerts:     storage.NewSQLiteAlertStorage(db),
+	}
+
+	seed(t, storages.users.Insert,
+		model.User{Name: "John Doe", Email: "john@example.com", Password: "hash", Status: true, BaseCurrency: "BRL"},
+		model.User{Name: "Jane Doe", Email: "jane@example.com", Password: "hash", Status: true, BaseCurrency: "USD"},
+	)
+	err = storages.rates.Upsert([]model.ExchangeRate{
+		{Date: mustParseDate(t, "2023-06-01"), From: "USD", To: "BRL", Rate: "5"},
+		{Date: mustParseDate(t, "2023-07-01"), From: "USD", To: "BRL", Rate: "4.8"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to store exchange rates: %v", err)
+	}
+	return storages
+}
+
+// newTestServices wires the services over newTestStorages
+func newTestServices(t *testing.T) *testServices {
+	t.Helper()
+	return newTestStorages(t).services()
+}
+
+// services wires the services over the storages, so a test can swap one of
+// them first
+func (storages *testStorages) services() *testServices {
+	currencies := NewCurrencyService(storages.rates, storages.users)
+	finance := NewFinanceService(storages.finance, storages.accounts, storages.categories, currencies)
+	alerts := NewAlertService(storages.alerts, finance, storages.users, nil)
+	finance.Observers = append(finance.Observers, alerts)
+	users := NewUserService(storages.users)
+	return &testServices{
+		storages:   storages,
+		currencies: currencies,
+		accounts:   NewAccountService(storages.accounts, storages.finance, storages.recurring, storages.imports, currencies),
+		finance:    finance,
+		recurring:  NewRecurringService(storages.recurring, finance, storages.users),
+		categories: NewCategoryService(storages.categories, storages.finance, storages.recurring, storages.budgets, storages.alerts),
+		imports:    NewImportService(storages.imports, finance),
+		budgets:    NewBudgetService(storages.budgets, finance),
+		alerts:     alerts,
+		users:      users,
+		userData: NewUserDataService(users, storages.finance, storages.accounts, storages.categories,
+			storages.recurring, storages.budgets, storages.alerts, storages.imports),
+	}
+}
+
+// newFinanceServiceWith wires the finance service over the given transaction
+// storage and the test storages for everything else
+func newFinanceServiceWith(t *testing.T, transactions storage.FinanceStorage) *FinanceService {
+	t.Helper()
+	storages := newTestStorages(t)
+	storages.finance = transactions
+	return storages.services().finance
+}
+
+// transactionCount counts the transactions stored for every user
+func transactionCount(t *testing.T, storages *testStorages) int {
+	t.Helper()
+	count, err := storages.finance.Count()
+	if err != nil {
+		t.Fatalf("Failed to count transactions: %v", err)
+	}
+	return count
+}
+
+// seed inserts records through a storage and returns them with their IDs
+func seed[T any](t *testing.T, insert func(T) (T, error), records ...T) []T {
+	t.Helper()
+	inserted := make([]T, len(records))
+	for i, record := range records {
+		var err error
+		if inserted[i], err = insert(record); err != nil {
+			t.Fatalf("Failed to seed %+v: %v", record, err)
+		}
+	}
+	return inserted
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"strconv"
+	"time"
+)
+
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+	tokenIssuer      = "myfinance"
+)
+
+type tokenClaims struct {
+	TokenType string `json:"token_type"`
+	jwt.RegisteredClaims
+}
+
+type TokenService struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenService(secret string, accessTTL, refreshTTL time.Duration) *TokenService {
+	return &TokenService{
+		secret:     []byte(secret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// IssueTokens signs a new access/refresh token pair for the given user
+func (tokenService *TokenService) IssueTokens(user model.User) (model.TokenPair, error) {
+	accessToken, err := tokenService.sign(user.ID, accessTokenType, tokenService.accessTTL)
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+	refreshToken, err := tokenService.sign(user.ID, refreshTokenType, tokenService.refreshTTL)
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+	return model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(tokenService.accessTTL.Seconds()),
+	}, nil
+}
+
+// ParseAccessToken validates an access token and returns the user ID it was issued for
+func (tokenService *TokenService) ParseAccessToken(token string) (int, error) {
+	return tokenService.parse(token, accessTokenType)
+}
+
+// ParseRefreshToken validates a refresh token and returns the user ID it was issued for
+func (tokenService *TokenService) ParseRefreshToken(token string) (int, error) {
+	return tokenService.parse(token, refreshTokenType)
+}
+
+func (tokenService *TokenService) sign(userID int, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := tokenClaims{
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(tokenService.secret)
+}
+
+func (tokenService *TokenService) parse(token string, tokenType string) (int, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return tokenService.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(tokenIssuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, err
+	}
+	if claims.TokenType != tokenType {
+		return 0, errors.New("invalid token type")
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, errors.New("invalid token subject")
+	}
+	return userID, nil
+}
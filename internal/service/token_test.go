@@ -0,0 +1,85 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestIssueAndParseTokens(t *testing.T) {
+	tokenService := NewTokenService("test-secret", time.Minute, time.Hour)
+
+	tokens, err := tokenService.IssueTokens(model.User{ID: 42})
+	if err != nil {
+		t.Fatalf("Failed to issue tokens: %v", err)
+	}
+
+	if tokens.TokenType != "Bearer" {
+		t.Errorf("Expected token type 'Bearer', got '%s'", tokens.TokenType)
+	}
+
+	if tokens.ExpiresIn != 60 {
+		t.Errorf("Expected ExpiresIn to be 60, got %d", tokens.ExpiresIn)
+	}
+
+	userID, err := tokenService.ParseAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Expected access token to be valid, got %v", err)
+	}
+	if userID != 42 {
+		t.Errorf("Expected access token user ID to be 42, got %d", userID)
+	}
+
+	userID, err = tokenService.ParseRefreshToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Expected refresh token to be valid, got %v", err)
+	}
+	if userID != 42 {
+		t.Errorf("Expected refresh token user ID to be 42, got %d", userID)
+	}
+}
+
+func TestTokensAreNotInterchangeable(t *testing.T) {
+	tokenService := NewTokenService("test-secret", time.Minute, time.Hour)
+
+	tokens, err := tokenService.IssueTokens(model.User{ID: 1})
+	if err != nil {
+		t.Fatalf("Failed to issue tokens: %v", err)
+	}
+
+	if _, err := tokenService.ParseAccessToken(tokens.RefreshToken); err == nil {
+		t.Error("Expected refresh token to be rejected as an access token")
+	}
+
+	if _, err := tokenService.ParseRefreshToken(tokens.AccessToken); err == nil {
+		t.Error("Expected access token to be rejected as a refresh token")
+	}
+}
+
+func TestParseTokenWithWrongSecret(t *testing.T) {
+	issuer := NewTokenService("secret-a", time.Minute, time.Hour)
+	verifier := NewTokenService("secret-b", time.Minute, time.Hour)
+
+	tokens, err := issuer.IssueTokens(model.User{ID: 1})
+	if err != nil {
+		t.Fatalf("Failed to issue tokens: %v", err)
+	}
+
+	if _, err := verifier.ParseAccessToken(tokens.AccessToken); err == nil {
+		t.Error("Expected token signed with another secret to be rejected")
+	}
+}
+
+func TestParseExpiredToken(t *testing.T) {
+	tokenService := NewTokenService("test-secret", -time.Minute, time.Hour)
+
+	tokens, err := tokenService.IssueTokens(model.User{ID: 1})
+	if err != nil {
+		t.Fatalf("Failed to issue tokens: %v", err)
+	}
+
+	if _, err := tokenService.ParseAccessToken(tokens.AccessToken); err == nil {
+		t.Error("Expected expired access token to be rejected")
+	}
+}
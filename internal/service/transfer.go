@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+)
+
+// balanceChange is how much a transaction moves its account's balance.
+// Transfers take money out of the source account and put it into the
+// destination account, so they cancel out in the user's total.
+func balanceChange(transaction model.Transaction) model.Money {
+	switch {
+	case transaction.Type == "income":
+		return transaction.Amount
+	case transaction.Type == "expense":
+		return -transaction.Amount
+	case transaction.Type == "transfer" && transaction.TransferRole == "source":
+		return -transaction.Amount
+	case transaction.Type == "transfer" && transaction.TransferRole == "destination":
+		return transaction.Amount
+	}
+	return 0
+}
+
+// ownedAccount loads an account and makes sure it belongs to the user
+func (financeService *FinanceService) ownedAccount(userID, accountID int) (model.Account, error) {
+	account, err := financeService.Accounts.Get(accountID)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && account.UserID != userID) {
+		return model.Account{}, ErrUnknownAccount
+	}
+	return account, err
+}
+
+// transferHalves builds the two transactions of a transfer from AccountID to
+// ToAccountID. The destination receives the amount converted to its currency
+// at the rate of the transfer date.
+func (financeService *FinanceService) transferHalves(transfer model.Transaction) (model.Transaction, model.Transaction, error) {
+	if transfer.AccountID == 0 || transfer.ToAccountID == 0 || transfer.AccountID == transfer.ToAccountID {
+		return model.Transaction{}, model.Transaction{}, ErrTransferAccounts
+	}
+	if transfer.Amount <= 0 {
+		return model.Transaction{}, model.Transaction{}, ErrTransferAmount
+	}
+	from, err := financeService.ownedAccount(transfer.UserID, transfer.AccountID)
+	if err != nil {
+		return model.Transaction{}, model.Transaction{}, err
+	}
+	to, err := financeService.ownedAccount(transfer.UserID, transfer.ToAccountID)
+	if err != nil {
+		return model.Transaction{}, model.Transaction{}, err
+	}
+	if transfer.Currency != "" && transfer.Currency != from.Currency {
+		return model.Transaction{}, model.Transaction{}, ErrCurrencyMismatch
+	}
+	received, err := financeService.Currencies.Convert(transfer.Amount, from.Currency, to.Currency, transfer.Date)
+	if err != nil {
+		return model.Transaction{}, model.Transaction{}, err
+	}
+
+	source := transfer
+	source.ToAccountID, source.CategoryID = 0, 0
+	source.Currency = from.Currency
+	source.TransferRole = "source"
+
+	destination := source
+	destination.AccountID = to.ID
+	destination.Amount = received
+	destination.Currency = to.Currency
+	destination.TransferRole = "destination"
+	return source, destination, nil
+}
+
+// addTransfer stores both halves of a new transfer and returns the source half
+func (financeService *FinanceService) addTransfer(transfer model.Transaction) (model.Transaction, error) {
+	source, destination, err := financeService.transferHalves(transfer)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	source, _, err = financeService.Storage.InsertPair(source, destination)
+	if err != nil {
+		return model.Transaction{}, fmt.Errorf("Error saving finance transaction: %w", err)
+	}
+	return source, nil
+}
+
+// updateTransfer replaces both halves of the transfer that transaction belongs
+// to and returns the new source half. Accounts left out of updated keep their
+// current value.
+func (financeService *FinanceService) updateTransfer(transaction, updated model.Transaction) (model.Transaction, error) {
+	linked, err := financeService.Storage.Get(transaction.LinkedID)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	source, destination := transaction, linked
+	if transaction.TransferRole == "destination" {
+		source, destination = linked, transaction
+	}
+
+	updated.UserID = source.UserID
+	if updated.AccountID == 0 {
+		updated.AccountID = source.AccountID
+	}
+	if updated.ToAccountID == 0 {
+		updated.ToAccountID = destination.AccountID
+	}
+	newSource, newDestination, err := financeService.transferHalves(updated)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	newSource.ID, newSource.LinkedID = source.ID, destination.ID
+	newDestination.ID, newDestination.LinkedID = destination.ID, source.ID
+	if err := financeService.Storage.UpdateMany([]model.Transaction{newSource, newDestination}); err != nil {
+		return model.Transaction{}, err
+	}
+	return newSource, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// seedAccounts gives John a checking account (1) opened with 1000.00 and
+// paid a salary (1), a savings account (2) and a dollar account (3); Jane has
+// an account of her own (4)
+func seedAccounts(t *testing.T, storages *testStorages) {
+	t.Helper()
+	seed(t, storages.accounts.Insert,
+		model.Account{UserID: 1, Name: "Checking", Type: "checking", Currency: "BRL", OpeningBalance: 1000_00, OpeningDate: mustParseDate(t, "2023-01-01")},
+		model.Account{UserID: 1, Name: "Savings", Type: "savings", Currency: "BRL"},
+		model.Account{UserID: 1, Name: "Dollar account", Type: "checking", Currency: "USD"},
+		model.Account{UserID: 2, Name: "Someone else's", Type: "checking", Currency: "BRL"},
+	)
+	seed(t, storages.finance.Insert,
+		model.Transaction{UserID: 1, AccountID: 1, Type: "income", Amount: 3000_00, Currency: "BRL", Date: mustParseDate(t, "2023-06-05")})
+}
+
+func TestAddTransferCreatesLinkedPair(t *testing.T) {
+	services := newTestServices(t)
+	seedAccounts(t, services.storages)
+	financeService := services.finance
+
+	source, err := financeService.AddTransaction(model.Transaction{UserID: 1, Type: "transfer", Amount: 500_00,
+		AccountID: 1, ToAccountID: 2, Date: mustParseDate(t, "2023-06-10"), Description: "Savings"})
+	if err != nil {
+		t.Fatalf("Failed to add transfer: %v", err)
+	}
+	if count := transactionCount(t, services.storages); count != 3 {
+		t.Fatalf("Expected two new transactions, got %d", count-1)
+	}
+	destination, _ := services.storages.finance.Get(3)
+	if source.TransferRole != "source" || destination.TransferRole != "destination" {
+		t.Errorf("Expected source and destination roles, got %q and %q", source.TransferRole, destination.TransferRole)
+	}
+	if source.LinkedID != destination.ID || destination.LinkedID != source.ID {
+		t.Errorf("Expected the halves to be linked, got %+v and %+v", source, destination)
+	}
+	if destination.AccountID != 2 || destination.Amount != 500_00 || source.ToAccountID != 0 {
+		t.Errorf("Unexpected destination half %+v", destination)
+	}
+
+	balance, err := financeService.GetBalanceByUserId(1)
+	if err != nil {
+		t.Fatalf("Failed to get balance: %v", err)
+	}
+	if balance.Balance != 4000_00 {
+		t.Errorf("Expected the transfer to leave the total at 4000.00, got %s", balance.Balance)
+	}
+	expected := map[int]model.Money{1: 3500_00, 2: 500_00, 3: 0}
+	for _, accountBalance := range balance.ByAccount {
+		if accountBalance.Balance != expected[accountBalance.AccountID] {
+			t.Errorf("Expected account %d to hold %s, got %s", accountBalance.AccountID, expected[accountBalance.AccountID], accountBalance.Balance)
+		}
+	}
+
+	report, err := financeService.SummaryReport(1, model.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("Failed to build report: %v", err)
+	}
+	if report.Totals != (model.ReportTotals{Income: 3000_00, Net: 3000_00}) {
+		t.Errorf("Expected transfers to be left out of income and expense, got %+v", report.Totals)
+	}
+}
+
+func TestAddTransferBetweenCurrencies(t *testing.T) {
+	services := newTestServices(t)
+	seedAccounts(t, services.storages)
+	financeService := services.finance
+
+	_, err := financeService.AddTransaction(model.Transaction{UserID: 1, Type: "transfer", Amount: 100_00,
+		AccountID: 3, ToAccountID: 1, Date: mustParseDate(t, "2023-06-10")})
+	if err != nil {
+		t.Fatalf("Failed to add transfer: %v", err)
+	}
+	source, _ := services.storages.finance.Get(2)
+	destination, _ := services.storages.finance.Get(3)
+	if source.Currency != "USD" || source.Amount != 100_00 {
+		t.Errorf("Expected 100.00 USD to leave the source, got %s %s", source.Amount, source.Currency)
+	}
+	if destination.Currency != "BRL" || destination.Amount != 500_00 {
+		t.Errorf("Expected 500.00 BRL to reach the destination, got %s %s", destination.Amount, destination.Currency)
+	}
+}
+
+func TestAddTransferValidation(t *testing.T) {
+	services := newTestServices(t)
+	seedAccounts(t, services.storages)
+	financeService := services.finance
+	date := mustParseDate(t, "2023-06-10")
+
+	tests := []struct {
+		transfer model.Transaction
+		expected error
+	}{
+		{model.Transaction{UserID: 1, Type: "transfer", Amount: 1_00, AccountID: 1, Date: date}, ErrTransferAccounts},
+		{model.Transaction{UserID: 1, Type: "transfer", Amount: 1_00, AccountID: 1, ToAccountID: 1, Date: date}, ErrTransferAccounts},
+		{model.Transaction{UserID: 1, Type: "transfer", Amount: 0, AccountID: 1, ToAccountID: 2, Date: date}, ErrTransferAmount},
+		{model.Transaction{UserID: 1, Type: "transfer", Amount: 1_00, AccountID: 1, ToAccountID: 4, Date: date}, ErrUnknownAccount},
+		{model.Transaction{UserID: 1, Type: "transfer", Amount: 1_00, AccountID: 1, ToAccountID: 2, Currency: "USD", Date: date}, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		_, err := financeService.AddTransaction(tt.transfer)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("Expected %v for %+v, got %v", tt.expected, tt.transfer, err)
+		}
+	}
+	if count := transactionCount(t, services.storages); count != 1 {
+		t.Errorf("Expected nothing to be stored, got %d transactions", count)
+	}
+}
+
+func TestUpdateAndDeleteTransferAsUnit(t *testing.T) {
+	services := newTestServices(t)
+	seedAccounts(t, services.storages)
+	financeService := services.finance
+	source, err := financeService.AddTransaction(model.Transaction{UserID: 1, Type: "transfer", Amount: 500_00,
+		AccountID: 1, ToAccountID: 2, Date: mustParseDate(t, "2023-06-10")})
+	if err != nil {
+		t.Fatalf("Failed to add transfer: %v", err)
+	}
+
+	// Updating through the destination half rewrites both halves
+	err = financeService.UpdateTransaction(1, "3", model.Transaction{Type: "transfer", Amount: 700_00, Date: mustParseDate(t, "2023-06-11")})
+	if err != nil {
+		t.Fatalf("Failed to update transfer: %v", err)
+	}
+	updatedSource, _ := services.storages.finance.Get(2)
+	destination, _ := services.storages.finance.Get(3)
+	if updatedSource.ID != source.ID || updatedSource.Amount != 700_00 || destination.Amount != 700_00 {
+		t.Errorf("Expected both halves to move 700.00, got %+v and %+v", updatedSource, destination)
+	}
+	if updatedSource.AccountID != 1 || destination.AccountID != 2 || updatedSource.LinkedID != destination.ID {
+		t.Errorf("Expected accounts and links to be kept, got %+v and %+v", updatedSource, destination)
+	}
+
+	err = financeService.UpdateTransaction(1, "2", model.Transaction{Type: "expense", Amount: 700_00})
+	if !errors.Is(err, ErrTransferTypeLocked) {
+		t.Errorf("Expected 'transfer type cannot change', got %v", err)
+	}
+	err = financeService.UpdateTransaction(1, "1", model.Transaction{Type: "transfer", Amount: 700_00})
+	if !errors.Is(err, ErrTransferTypeLocked) {
+		t.Errorf("Expected 'transfer type cannot change', got %v", err)
+	}
+
+	if err := financeService.DeleteTransaction(1, "2"); err != nil {
+		t.Fatalf("Failed to delete transfer: %v", err)
+	}
+	if left, _ := services.storages.finance.FindByUser(1, model.TransactionFilter{}); len(left) != 1 || left[0].ID != 1 {
+		t.Errorf("Expected both halves to be deleted, got %+v", left)
+	}
+}
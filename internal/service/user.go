@@ -1,88 +1,140 @@
 package service
 
 import (
+	"context"
+	"crypto/subtle"
 	"errors"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
-	"log"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"net/mail"
 	"strconv"
+	"strings"
 	"sync"
 )
 
+// Errors returned when users sign up, sign in, are looked up or change their
+// profile
+var (
+	ErrUserNotFound        = newError(KindNotFound, "user_not_found", "User not found")
+	ErrEmailExists         = newError(KindConflict, "email_exists", "A user with this email already exists")
+	ErrInvalidName         = newError(KindInvalid, "invalid_name", "Name cannot be empty")
+	ErrInvalidEmail        = newError(KindInvalid, "invalid_email", "Email must be a valid address such as john@example.com")
+	ErrInvalidPassword     = newError(KindInvalid, "invalid_password", "Password cannot be empty or longer than 72 bytes")
+	ErrInvalidCredentials  = newError(KindUnauthorized, "invalid_credentials", "Invalid credentials")
+	ErrInvalidRefreshToken = newError(KindUnauthorized, "invalid_refresh_token", "Invalid or expired refresh token")
+)
+
+// maxPasswordBytes is the longest password bcrypt accepts
+const maxPasswordBytes = 72
+
 type UserService struct {
-	User    []model.User
-	NextID  int
 	Storage storage.UserStorage
-	mu      sync.Mutex
+	// mu guards email uniqueness across users
+	mu sync.Mutex
+	// changing serializes the changes to one user, including
+	// UserDataService.Purge, so none of them writes back a stale record
+	changing userLocks
 }
 
 func NewUserService(storage storage.UserStorage) *UserService {
-	users, err := storage.Load()
-	if err != nil {
-		log.Printf("Error loading users: %v", err)
-		users = []model.User{}
-	}
-
 	return &UserService{
-		User:    users,
-		NextID:  getMaxIDUsers(users) + 1,
 		Storage: storage,
 	}
 }
 
-func (userService *UserService) emailExists(email string) bool {
-	for _, user := range userService.User {
-		if user.Email == email {
-			return true
-		}
+// findUser loads a user, treating users whose data was purged as missing
+func findUser(users storage.UserStorage, userID int) (model.User, error) {
+	userModel, err := users.Get(userID)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && userModel.Purged) {
+		return model.User{}, ErrUserNotFound
 	}
-	return false
+	return userModel, err
 }
 
-func getMaxIDUsers(users []model.User) int {
-	maxID := 0
-	for _, userModel := range users {
-		if userModel.ID > maxID {
-			maxID = userModel.ID
-		}
+func (userService *UserService) emailExists(email string) (bool, error) {
+	_, err := userService.Storage.FindByEmail(email)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
-	return maxID
+	return true, nil
 }
 
-func (userService *UserService) loadUsers() {
-	users, err := userService.Storage.Load()
+// validEmail reports whether email is a bare address such as john@example.com
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+// hashPassword derives the bcrypt hash stored in place of the plaintext password
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("Error loading users file: %v", err)
-		userService.User = []model.User{}
-		userService.NextID = 1
-		return
+		return "", err
 	}
-	userService.User = users
-	userService.NextID = getMaxIDUsers(users) + 1
+	return string(hash), nil
 }
 
-func (userService *UserService) saveUser() error {
-	err := userService.Storage.Save(userService.User)
-	if err != nil {
-		log.Printf("Error saving users file: %v", err)
-		return err
+// isHashedPassword reports whether a stored password is a bcrypt hash rather
+// than a legacy plaintext value
+func isHashedPassword(stored string) bool {
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
+
+// verifyPassword checks a candidate password against the stored value and
+// reports whether the stored value should be rehashed
+func verifyPassword(stored, password string) (valid bool, needsRehash bool) {
+	if !isHashedPassword(stored) {
+		valid = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return valid, valid
 	}
-	return nil
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, false
+	}
+	cost, _ := bcrypt.Cost([]byte(stored))
+	return true, cost < bcrypt.DefaultCost
 }
 
+// AddUser registers a user with a hashed password. The email is trimmed and
+// must be valid and unused; the password cannot be empty or longer than
+// bcrypt accepts.
 func (userService *UserService) AddUser(user model.User) (model.User, error) {
+	user.Email = strings.TrimSpace(user.Email)
+	if !validEmail(user.Email) {
+		return model.User{}, ErrInvalidEmail
+	}
+	if user.Password == "" || len(user.Password) > maxPasswordBytes {
+		return model.User{}, ErrInvalidPassword
+	}
+
+	// Serialise registrations so two requests cannot claim the same email
 	userService.mu.Lock()
 	defer userService.mu.Unlock()
-	if userService.emailExists(user.Email) {
-		return model.User{}, errors.New("email already exists")
+
+	exists, err := userService.emailExists(user.Email)
+	if err != nil {
+		return model.User{}, err
+	}
+	if exists {
+		return model.User{}, ErrEmailExists
+	}
+	hash, err := hashPassword(user.Password)
+	if err != nil {
+		return model.User{}, err
 	}
-	user.ID = userService.NextID
+	user.Password = hash
 	user.Status = true
-	userService.User = append(userService.User, user)
-	userService.NextID++
-
-	err := userService.saveUser()
+	user.Purged = false
+	if user.BaseCurrency == "" {
+		user.BaseCurrency = model.DefaultCurrency
+	}
 
+	user, err = userService.Storage.Insert(user)
 	if err != nil {
 		return model.User{}, err
 	}
@@ -90,33 +142,224 @@ func (userService *UserService) AddUser(user model.User) (model.User, error) {
 	return user, nil
 }
 
-func (userService *UserService) Authenticate(email, password string) (*model.User, bool) {
-	userService.mu.Lock()
-	defer userService.mu.Unlock()
+func (userService *UserService) Authenticate(ctx context.Context, email, password string) (*model.User, bool) {
+	userAuth, err := userService.Storage.FindByEmail(email)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			slog.ErrorContext(ctx, "Error loading user", "error", err)
+		}
+		return nil, false
+	}
+	if !userAuth.Status {
+		return nil, false
+	}
+
+	valid, needsRehash := verifyPassword(userAuth.Password, password)
+	if !valid {
+		return nil, false
+	}
+	if needsRehash {
+		userService.rehashPassword(ctx, userAuth, password)
+	}
+	return &model.User{
+		ID:           userAuth.ID,
+		Name:         userAuth.Name,
+		Email:        userAuth.Email,
+		BaseCurrency: userAuth.BaseCurrency,
+	}, true
+}
+
+// rehashPassword upgrades a legacy or weak stored password after a successful
+// login. The user is read again under their lock so a profile change made in
+// the meantime is kept, and users deactivated or purged since are left alone.
+// Failures are logged and retried on the next login.
+func (userService *UserService) rehashPassword(ctx context.Context, user model.User, password string) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error rehashing password", "user_id", user.ID, "error", err)
+		return
+	}
+
+	defer userService.changing.lock(user.ID)()
+
+	current, err := userService.Storage.Get(user.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading user to rehash password", "user_id", user.ID, "error", err)
+		return
+	}
+	// Leave a password changed since the login alone
+	if current.Password != user.Password || current.Purged || !current.Status {
+		return
+	}
+	current.Password = hash
+	if err := userService.Storage.Update(current); err != nil {
+		slog.ErrorContext(ctx, "Error saving rehashed password", "user_id", user.ID, "error", err)
+	}
+}
+
+// MigrateLegacyPasswords hashes every password still stored in plaintext and
+// returns how many users were migrated
+func (userService *UserService) MigrateLegacyPasswords() (int, error) {
+	users, err := userService.Storage.List()
+	if err != nil {
+		return 0, err
+	}
 
-	for _, userAuth := range userService.User {
-		if userAuth.Email == email && userAuth.Password == password && userAuth.Status {
-			return &model.User{
-				ID:    userAuth.ID,
-				Name:  userAuth.Name,
-				Email: userAuth.Email,
-			}, true
+	migrated := 0
+	for _, userModel := range users {
+		// Purged users have no password left to hash
+		if userModel.Purged || isHashedPassword(userModel.Password) {
+			continue
+		}
+		hash, err := hashPassword(userModel.Password)
+		if err != nil {
+			return migrated, err
+		}
+		userModel.Password = hash
+		if err := userService.Storage.Update(userModel); err != nil {
+			return migrated, err
 		}
+		migrated++
 	}
-	return nil, false
+	return migrated, nil
 }
 
-func (userService *UserService) DeleteUser(userId string) error {
+func (userService *UserService) GetActiveUser(id int) (*model.User, bool) {
+	userModel, err := userService.Storage.Get(id)
+	if err != nil || !userModel.Status {
+		return nil, false
+	}
+	return &model.User{
+		ID:           userModel.ID,
+		Name:         userModel.Name,
+		Email:        userModel.Email,
+		BaseCurrency: userModel.BaseCurrency,
+	}, true
+}
+
+// IsActive reports whether the user exists and has not been deactivated
+func (userService *UserService) IsActive(userID int) (bool, error) {
+	userModel, err := userService.Storage.Get(userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return userModel.Status, nil
+}
+
+// GetUser returns a user without their password
+func (userService *UserService) GetUser(userID int) (model.User, error) {
+	userModel, err := findUser(userService.Storage, userID)
+	if err != nil {
+		return model.User{}, err
+	}
+	userModel.Password = ""
+	return userModel, nil
+}
+
+// UpdateProfile changes the name and email of a user. Both are trimmed; the
+// email must be valid and not used by another user.
+func (userService *UserService) UpdateProfile(userID int, update model.UserUpdate) (model.User, error) {
+	defer userService.changing.lock(userID)()
+	// Shares the registration lock so an email cannot be claimed twice
 	userService.mu.Lock()
 	defer userService.mu.Unlock()
 
-	id, _ := strconv.Atoi(userId)
-	for index, userModel := range userService.User {
-		if userModel.ID == id {
-			userModel.Status = false
-			userService.User[index] = userModel
-			return userService.Storage.Save(userService.User)
+	userModel, err := findUser(userService.Storage, userID)
+	if err != nil {
+		return model.User{}, err
+	}
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return model.User{}, ErrInvalidName
+		}
+		userModel.Name = name
+	}
+	if update.Email != nil {
+		email := strings.TrimSpace(*update.Email)
+		if !validEmail(email) {
+			return model.User{}, ErrInvalidEmail
+		}
+		if email != userModel.Email {
+			owner, err := userService.Storage.FindByEmail(email)
+			if err == nil && owner.ID != userID {
+				return model.User{}, ErrEmailExists
+			}
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return model.User{}, err
+			}
+		}
+		userModel.Email = email
+	}
+
+	if err := userService.Storage.Update(userModel); err != nil {
+		return model.User{}, err
+	}
+	userModel.Password = ""
+	return userModel, nil
+}
+
+// ReactivateUser lets a deactivated user sign in again. Users whose data was
+// purged cannot be brought back.
+func (userService *UserService) ReactivateUser(userID int) (model.User, error) {
+	defer userService.changing.lock(userID)()
+	userModel, err := findUser(userService.Storage, userID)
+	if err != nil {
+		return model.User{}, err
+	}
+	if !userModel.Status {
+		userModel.Status = true
+		if err := userService.Storage.Update(userModel); err != nil {
+			return model.User{}, err
 		}
 	}
-	return errors.New("user not found")
+	userModel.Password = ""
+	return userModel, nil
+}
+
+// DeleteUser deactivates a user, keeping their data; see
+// UserDataService.Purge to erase it
+func (userService *UserService) DeleteUser(userId string) error {
+	id, _ := strconv.Atoi(userId)
+	defer userService.changing.lock(id)()
+	userModel, err := findUser(userService.Storage, id)
+	if err != nil {
+		return err
+	}
+	userModel.Status = false
+	return userService.Storage.Update(userModel)
+}
+
+// SetBaseCurrency changes the currency the user's balance and reports are
+// converted to
+func (userService *UserService) SetBaseCurrency(userID int, currency string) (model.User, error) {
+	defer userService.changing.lock(userID)()
+	userModel, err := findUser(userService.Storage, userID)
+	if err != nil {
+		return model.User{}, err
+	}
+	userModel.BaseCurrency = currency
+	if err := userService.Storage.Update(userModel); err != nil {
+		return model.User{}, err
+	}
+	userModel.Password = ""
+	return userModel, nil
+}
+
+// SetAlertsOptOut turns all alert emails of the user off or back on
+func (userService *UserService) SetAlertsOptOut(userID int, optOut bool) (model.User, error) {
+	defer userService.changing.lock(userID)()
+	userModel, err := findUser(userService.Storage, userID)
+	if err != nil {
+		return model.User{}, err
+	}
+	userModel.AlertsOptOut = optOut
+	if err := userService.Storage.Update(userModel); err != nil {
+		return model.User{}, err
+	}
+	userModel.Password = ""
+	return userModel, nil
 }
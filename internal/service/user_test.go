@@ -1,35 +1,96 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type MockUserStorage struct {
-	users      []model.User
-	saveCalled bool
-	loadCalled bool
-	failSave   bool
-	failLoad   bool
+	users        []model.User
+	insertCalled bool
+	updateCalled bool
+	failWrite    bool
+	failRead     bool
 }
 
-func (m *MockUserStorage) Save(users []model.User) error {
-	m.saveCalled = true
-	if m.failSave {
+func (m *MockUserStorage) Insert(user model.User) (model.User, error) {
+	m.insertCalled = true
+	if m.failWrite {
+		return model.User{}, errors.New("failed to save")
+	}
+	user.ID = 1
+	for _, existing := range m.users {
+		if existing.ID >= user.ID {
+			user.ID = existing.ID + 1
+		}
+	}
+	m.users = append(m.users, user)
+	return user, nil
+}
+
+func (m *MockUserStorage) Update(user model.User) error {
+	m.updateCalled = true
+	if m.failWrite {
 		return errors.New("failed to save")
 	}
-	m.users = users
-	return nil
+	for index, existing := range m.users {
+		if existing.ID == user.ID {
+			m.users[index] = user
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (m *MockUserStorage) Delete(id int) error {
+	if m.failWrite {
+		return errors.New("failed to save")
+	}
+	for index, existing := range m.users {
+		if existing.ID == id {
+			m.users = append(m.users[:index], m.users[index+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrNotFound
 }
 
-func (m *MockUserStorage) Load() ([]model.User, error) {
-	m.loadCalled = true
-	if m.failLoad {
+func (m *MockUserStorage) Get(id int) (model.User, error) {
+	if m.failRead {
+		return model.User{}, errors.New("failed to load")
+	}
+	for _, existing := range m.users {
+		if existing.ID == id {
+			return existing, nil
+		}
+	}
+	return model.User{}, storage.ErrNotFound
+}
+
+func (m *MockUserStorage) FindByEmail(email string) (model.User, error) {
+	if m.failRead {
+		return model.User{}, errors.New("failed to load")
+	}
+	for _, existing := range m.users {
+		if existing.Email == email {
+			return existing, nil
+		}
+	}
+	return model.User{}, storage.ErrNotFound
+}
+
+func (m *MockUserStorage) List() ([]model.User, error) {
+	if m.failRead {
 		return nil, errors.New("failed to load")
 	}
-	return m.users, nil
+	return append([]model.User(nil), m.users...), nil
 }
 
 func TestAddUser(t *testing.T) {
@@ -64,16 +125,12 @@ func TestAddUser(t *testing.T) {
 		t.Error("Expected user status to be true")
 	}
 
-	if len(userService.User) != 1 {
-		t.Errorf("Expected user count to be 1, got %d", len(userService.User))
-	}
-
-	if userService.NextID != 2 {
-		t.Errorf("Expected NextID to be 2, got %d", userService.NextID)
+	if len(mockStorage.users) != 1 {
+		t.Errorf("Expected user count to be 1, got %d", len(mockStorage.users))
 	}
 
-	if !mockStorage.saveCalled {
-		t.Error("Expected Save() to be called")
+	if !mockStorage.insertCalled {
+		t.Error("Expected Insert() to be called")
 	}
 }
 
@@ -95,16 +152,66 @@ func TestAddUserWithExistingEmail(t *testing.T) {
 		t.Error("Expected an error when adding a user with an existing email, but got nil")
 	}
 
-	if err != nil && err.Error() != "email already exists" {
+	if err != nil && !errors.Is(err, ErrEmailExists) {
 		t.Errorf("Expected error message 'email already exists', got '%s'", err.Error())
 	}
 
-	if len(userService.User) != 1 {
-		t.Errorf("Expected user count to remain 1, got %d", len(userService.User))
+	if len(mockStorage.users) != 1 {
+		t.Errorf("Expected user count to remain 1, got %d", len(mockStorage.users))
+	}
+
+	if mockStorage.insertCalled {
+		t.Error("Insert method should not have been called for existing email")
+	}
+}
+
+func TestAddUserValidatesEmailAndPassword(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{}}
+	userService := NewUserService(mockStorage)
+
+	tests := []struct {
+		name     string
+		user     model.User
+		expected error
+	}{
+		{"empty email", model.User{Name: "John Doe", Password: "password123"}, ErrInvalidEmail},
+		{"malformed email", model.User{Name: "John Doe", Email: "john.example.com", Password: "password123"}, ErrInvalidEmail},
+		{"email with a display name", model.User{Name: "John Doe", Email: "John <john@example.com>", Password: "password123"}, ErrInvalidEmail},
+		{"empty password", model.User{Name: "John Doe", Email: "john@example.com"}, ErrInvalidPassword},
+		{"password longer than bcrypt accepts", model.User{Name: "John Doe", Email: "john@example.com", Password: strings.Repeat("a", 73)}, ErrInvalidPassword},
+	}
+	for _, tt := range tests {
+		_, err := userService.AddUser(tt.user)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, err)
+		}
+		var serviceErr *Error
+		if !errors.As(err, &serviceErr) || serviceErr.Kind != KindInvalid {
+			t.Errorf("%s: expected an invalid input error, got %v", tt.name, err)
+		}
+	}
+	if len(mockStorage.users) != 0 {
+		t.Errorf("Expected no user to be stored, got %+v", mockStorage.users)
 	}
 
-	if mockStorage.saveCalled {
-		t.Error("Save method should not have been called for existing email")
+	user, err := userService.AddUser(model.User{Name: "John Doe", Email: " john@example.com ", Password: strings.Repeat("a", 72)})
+	if err != nil || user.Email != "john@example.com" {
+		t.Errorf("Expected a trimmed email and a 72-byte password to be accepted, got %+v (%v)", user, err)
+	}
+}
+
+func TestAddUserWithStorageFailure(t *testing.T) {
+	mockStorage := &MockUserStorage{failRead: true}
+	userService := NewUserService(mockStorage)
+
+	_, err := userService.AddUser(model.User{Name: "John Doe", Email: "john@example.com", Password: "password123"})
+
+	if err == nil {
+		t.Error("Expected an error when storage cannot be read, got nil")
+	}
+
+	if mockStorage.insertCalled {
+		t.Error("Insert method should not have been called when the email check failed")
 	}
 }
 
@@ -116,7 +223,7 @@ func TestAuthenticateUser(t *testing.T) {
 	userService := NewUserService(mockStorage)
 
 	// Test successful authentication
-	authenticatedUser, isAuthenticated := userService.Authenticate("john@example.com", "password123")
+	authenticatedUser, isAuthenticated := userService.Authenticate(context.Background(), "john@example.com", "password123")
 
 	if !isAuthenticated {
 		t.Error("Expected user to be authenticated, but authentication failed")
@@ -138,7 +245,7 @@ func TestAuthenticateUser(t *testing.T) {
 		t.Errorf("Expected authenticated user email to be 'john@example.com', got '%s'", authenticatedUser.Email)
 	}
 
-	_, isAuthenticated = userService.Authenticate("jane@example.com", "password456")
+	_, isAuthenticated = userService.Authenticate(context.Background(), "jane@example.com", "password456")
 
 	if isAuthenticated {
 		t.Error("Expected authentication to fail for inactive user, but it succeeded")
@@ -152,19 +259,19 @@ func TestAuthenticateWithIncorrectCredentials(t *testing.T) {
 	}}
 	userService := NewUserService(mockStorage)
 
-	_, isAuthenticated := userService.Authenticate("john@example.com", "incorrectpassword")
+	_, isAuthenticated := userService.Authenticate(context.Background(), "john@example.com", "incorrectpassword")
 
 	if isAuthenticated {
 		t.Error("Expected authentication to fail with incorrect password, but it succeeded")
 	}
 
-	_, isAuthenticated = userService.Authenticate("nonexistent@example.com", "anypassword")
+	_, isAuthenticated = userService.Authenticate(context.Background(), "nonexistent@example.com", "anypassword")
 
 	if isAuthenticated {
 		t.Error("Expected authentication to fail with non-existent email, but it succeeded")
 	}
 
-	_, isAuthenticated = userService.Authenticate("jane@example.com", "password456")
+	_, isAuthenticated = userService.Authenticate(context.Background(), "jane@example.com", "password456")
 
 	if isAuthenticated {
 		t.Error("Expected authentication to fail for inactive user, but it succeeded")
@@ -177,7 +284,7 @@ func TestAuthenticateInactiveUser(t *testing.T) {
 	}}
 	userService := NewUserService(mockStorage)
 
-	_, isAuthenticated := userService.Authenticate("john@example.com", "password123")
+	_, isAuthenticated := userService.Authenticate(context.Background(), "john@example.com", "password123")
 
 	if isAuthenticated {
 		t.Error("Expected authentication to fail for inactive user, but it succeeded")
@@ -197,22 +304,62 @@ func TestDeleteUser(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	if len(userService.User) != 2 {
-		t.Errorf("Expected 2 users after deletion, got %d", len(userService.User))
+	if len(mockStorage.users) != 2 {
+		t.Errorf("Expected 2 users after deletion, got %d", len(mockStorage.users))
 	}
 
-	deletedUser := userService.User[0]
+	deletedUser := mockStorage.users[0]
 	if deletedUser.ID != 1 || deletedUser.Status != false {
 		t.Errorf("Expected user with ID 1 to be marked as inactive, got ID: %d, Status: %v", deletedUser.ID, deletedUser.Status)
 	}
 
-	activeUser := userService.User[1]
+	activeUser := mockStorage.users[1]
 	if activeUser.ID != 2 || activeUser.Status != true {
 		t.Errorf("Expected user with ID 2 to remain active, got ID: %d, Status: %v", activeUser.ID, activeUser.Status)
 	}
 
-	if !mockStorage.saveCalled {
-		t.Error("Expected Save() to be called")
+	if !mockStorage.updateCalled {
+		t.Error("Expected Update() to be called")
+	}
+}
+
+// TestUserChangesWaitForTheUsersLock checks every change to a user is made
+// under the user's lock, reading the user only once it holds it
+func TestUserChangesWaitForTheUsersLock(t *testing.T) {
+	changes := map[string]func(userService *UserService) error{
+		"delete": func(userService *UserService) error { return userService.DeleteUser("1") },
+		"base currency": func(userService *UserService) error {
+			_, err := userService.SetBaseCurrency(1, "USD")
+			return err
+		},
+		"alerts opt-out": func(userService *UserService) error {
+			_, err := userService.SetAlertsOptOut(1, true)
+			return err
+		},
+	}
+	for name, change := range changes {
+		mockStorage := &MockUserStorage{users: []model.User{
+			{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "password123", Status: true},
+		}}
+		userService := NewUserService(mockStorage)
+		unlock := userService.changing.lock(1)
+
+		done := make(chan error, 1)
+		go func() { done <- change(userService) }()
+		select {
+		case err := <-done:
+			t.Fatalf("%s: expected the change to wait for the user's lock, got %v", name, err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		// Changed while the lock was held, and kept by the waiting change
+		mockStorage.users[0].Name = "John Smith"
+		unlock()
+		if err := <-done; err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if mockStorage.users[0].Name != "John Smith" {
+			t.Errorf("%s: expected the user to be read again under the lock, got %+v", name, mockStorage.users[0])
+		}
 	}
 }
 
@@ -226,61 +373,41 @@ func TestDeleteNonExistentUser(t *testing.T) {
 
 	if err == nil {
 		t.Error("Expected an error when deleting a non-existent user, got nil")
-	} else if err.Error() != "user not found" {
+	} else if !errors.Is(err, ErrUserNotFound) {
 		t.Errorf("Expected error message 'user not found', got '%s'", err.Error())
 	}
 
-	if len(userService.User) != 1 {
-		t.Errorf("Expected user count to remain 1, got %d", len(userService.User))
+	if len(mockStorage.users) != 1 {
+		t.Errorf("Expected user count to remain 1, got %d", len(mockStorage.users))
 	}
 
-	if mockStorage.saveCalled {
-		t.Error("Save method should not have been called for non-existent user")
+	if mockStorage.updateCalled {
+		t.Error("Update method should not have been called for non-existent user")
 	}
 }
 
-func TestNewUserServiceLoadsUsers(t *testing.T) {
-	mockUsers := []model.User{
+func TestGetActiveUser(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{
 		{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "password123", Status: true},
-		{ID: 2, Name: "Jane Smith", Email: "jane@example.com", Password: "password456", Status: true},
-	}
-
-	mockStorage := &MockUserStorage{users: mockUsers}
+		{ID: 2, Name: "Jane Smith", Email: "jane@example.com", Password: "password456", Status: false},
+	}}
 	userService := NewUserService(mockStorage)
 
-	if len(userService.User) != 2 {
-		t.Errorf("Expected 2 users to be loaded, got %d", len(userService.User))
+	user, active := userService.GetActiveUser(1)
+	if !active || user == nil || user.ID != 1 {
+		t.Errorf("Expected user 1 to be active, got %+v", user)
 	}
 
-	if userService.NextID != 3 {
-		t.Errorf("Expected NextID to be 3, got %d", userService.NextID)
+	if user != nil && user.Password != "" {
+		t.Error("Expected password to be omitted from the returned user")
 	}
 
-	if !mockStorage.loadCalled {
-		t.Error("Expected Load() to be called during service initialization")
+	if _, active := userService.GetActiveUser(2); active {
+		t.Error("Expected inactive user 2 not to be returned")
 	}
 
-	for i, user := range mockUsers {
-		if userService.User[i].ID != user.ID || userService.User[i].Name != user.Name || userService.User[i].Email != user.Email {
-			t.Errorf("User at index %d does not match expected user", i)
-		}
-	}
-}
-
-func TestNewUserServiceHandlesLoadError(t *testing.T) {
-	mockStorage := &MockUserStorage{failLoad: true}
-	userService := NewUserService(mockStorage)
-
-	if len(userService.User) != 0 {
-		t.Errorf("Expected empty user list when loading fails, got %d users", len(userService.User))
-	}
-
-	if userService.NextID != 1 {
-		t.Errorf("Expected NextID to be 1 when loading fails, got %d", userService.NextID)
-	}
-
-	if !mockStorage.loadCalled {
-		t.Error("Expected Load() to be called during service initialization")
+	if _, active := userService.GetActiveUser(3); active {
+		t.Error("Expected non-existent user 3 not to be returned")
 	}
 }
 
@@ -300,8 +427,8 @@ func TestAddUserSavesToStorage(t *testing.T) {
 		t.Fatalf("Failed to add user: %v", err)
 	}
 
-	if !mockStorage.saveCalled {
-		t.Error("Expected Save() to be called after adding a new user")
+	if !mockStorage.insertCalled {
+		t.Error("Expected Insert() to be called after adding a new user")
 	}
 
 	if len(mockStorage.users) != 1 {
@@ -309,10 +436,18 @@ func TestAddUserSavesToStorage(t *testing.T) {
 	}
 
 	savedUser := mockStorage.users[0]
-	if savedUser.Name != newUser.Name || savedUser.Email != newUser.Email || savedUser.Password != newUser.Password {
+	if savedUser.Name != newUser.Name || savedUser.Email != newUser.Email {
 		t.Error("Saved user does not match the added user")
 	}
 
+	if savedUser.Password == newUser.Password {
+		t.Error("Expected password to be stored hashed, got plaintext")
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(savedUser.Password), []byte(newUser.Password)) != nil {
+		t.Error("Stored password hash does not match the original password")
+	}
+
 	if savedUser.ID != 1 {
 		t.Errorf("Expected saved user ID to be 1, got %d", savedUser.ID)
 	}
@@ -322,26 +457,320 @@ func TestAddUserSavesToStorage(t *testing.T) {
 	}
 }
 
-func TestNewUserServiceDeterminesNextID(t *testing.T) {
-	mockUsers := []model.User{
-		{ID: 1, Name: "User 1", Email: "user1@example.com"},
-		{ID: 3, Name: "User 3", Email: "user3@example.com"},
-		{ID: 2, Name: "User 2", Email: "user2@example.com"},
+func TestAuthenticateHashedPassword(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{}}
+	userService := NewUserService(mockStorage)
+
+	_, err := userService.AddUser(model.User{Name: "John Doe", Email: "john@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to add user: %v", err)
+	}
+
+	if _, isAuthenticated := userService.Authenticate(context.Background(), "john@example.com", "password123"); !isAuthenticated {
+		t.Error("Expected user with hashed password to be authenticated")
+	}
+
+	if _, isAuthenticated := userService.Authenticate(context.Background(), "john@example.com", "wrongpassword"); isAuthenticated {
+		t.Error("Expected authentication to fail with incorrect password")
 	}
+}
 
-	mockStorage := &MockUserStorage{users: mockUsers}
+func TestAuthenticateRehashesLegacyPassword(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{
+		{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "password123", Status: true},
+	}}
 	userService := NewUserService(mockStorage)
 
-	expectedNextID := 4
-	if userService.NextID != expectedNextID {
-		t.Errorf("Expected NextID to be %d, got %d", expectedNextID, userService.NextID)
+	if _, isAuthenticated := userService.Authenticate(context.Background(), "john@example.com", "password123"); !isAuthenticated {
+		t.Fatal("Expected legacy plaintext user to be authenticated")
+	}
+
+	if !mockStorage.updateCalled {
+		t.Error("Expected Update() to be called after rehashing a legacy password")
+	}
+
+	stored := mockStorage.users[0].Password
+	if stored == "password123" {
+		t.Fatal("Expected legacy password to be replaced by a hash")
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte("password123")) != nil {
+		t.Error("Rehashed password does not match the original password")
+	}
+
+	mockStorage.updateCalled = false
+	if _, isAuthenticated := userService.Authenticate(context.Background(), "john@example.com", "password123"); !isAuthenticated {
+		t.Error("Expected user to authenticate against the upgraded hash")
+	}
+
+	if mockStorage.updateCalled {
+		t.Error("Update method should not be called once the password is already hashed")
 	}
+}
 
-	if !mockStorage.loadCalled {
-		t.Error("Expected Load() to be called during service initialization")
+func TestRehashPasswordKeepsConcurrentProfileChanges(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{
+		{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "password123", Status: true},
+	}}
+	userService := NewUserService(mockStorage)
+	loggedIn := mockStorage.users[0]
+
+	// The profile changes between the login and the rehash
+	name := "John Smith"
+	if _, err := userService.UpdateProfile(1, model.UserUpdate{Name: &name}); err != nil {
+		t.Fatalf("Failed to update profile: %v", err)
+	}
+	userService.rehashPassword(context.Background(), loggedIn, "password123")
+
+	stored := mockStorage.users[0]
+	if stored.Name != "John Smith" {
+		t.Errorf("Expected the new name to be kept, got %q", stored.Name)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("password123")) != nil {
+		t.Error("Expected the password to be rehashed")
+	}
+}
+
+func TestRehashPasswordSkipsDeactivatedAndPurgedUsers(t *testing.T) {
+	for _, changed := range []model.User{
+		{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "password123"},
+		{ID: 1, Purged: true},
+	} {
+		mockStorage := &MockUserStorage{users: []model.User{
+			{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "password123", Status: true},
+		}}
+		userService := NewUserService(mockStorage)
+		loggedIn := mockStorage.users[0]
+
+		// The user is deactivated or purged between the login and the rehash
+		mockStorage.users[0] = changed
+		userService.rehashPassword(context.Background(), loggedIn, "password123")
+
+		if mockStorage.users[0] != changed {
+			t.Errorf("Expected %+v to be left alone, got %+v", changed, mockStorage.users[0])
+		}
+	}
+}
+
+func TestAuthenticateDoesNotRehashOnFailedLogin(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{
+		{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "password123", Status: true},
+	}}
+	userService := NewUserService(mockStorage)
+
+	if _, isAuthenticated := userService.Authenticate(context.Background(), "john@example.com", "wrongpassword"); isAuthenticated {
+		t.Error("Expected authentication to fail with incorrect password")
+	}
+
+	if mockStorage.updateCalled {
+		t.Error("Update method should not have been called after a failed login")
+	}
+}
+
+func TestMigrateLegacyPasswords(t *testing.T) {
+	hash, err := hashPassword("already-hashed")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	mockStorage := &MockUserStorage{users: []model.User{
+		{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "password123", Status: true},
+		{ID: 2, Name: "Jane Doe", Email: "jane@example.com", Password: hash, Status: true},
+		{ID: 3, Name: "Old User", Email: "old@example.com", Password: "password789", Status: false},
+		{ID: 4, Purged: true},
+	}}
+	userService := NewUserService(mockStorage)
+
+	migrated, err := userService.MigrateLegacyPasswords()
+	if err != nil {
+		t.Fatalf("Failed to migrate passwords: %v", err)
+	}
+
+	if migrated != 2 {
+		t.Errorf("Expected 2 migrated users, got %d", migrated)
+	}
+
+	if mockStorage.users[1].Password != hash {
+		t.Error("Already hashed password should not have been changed")
+	}
+
+	if mockStorage.users[3].Password != "" {
+		t.Error("Purged user should not have been given a password")
+	}
+
+	for _, user := range mockStorage.users[:3] {
+		if !isHashedPassword(user.Password) {
+			t.Errorf("Expected user %d to have a hashed password", user.ID)
+		}
+	}
+
+	mockStorage.updateCalled = false
+	migrated, err = userService.MigrateLegacyPasswords()
+	if err != nil || migrated != 0 {
+		t.Errorf("Expected second migration to be a no-op, got %d migrated and error %v", migrated, err)
+	}
+
+	if mockStorage.updateCalled {
+		t.Error("Update method should not have been called when nothing was migrated")
+	}
+}
+
+func TestSetBaseCurrency(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{{ID: 1, Email: "john@example.com", Password: "hash", Status: true, BaseCurrency: "BRL"}}}
+	userService := NewUserService(mockStorage)
+
+	user, err := userService.SetBaseCurrency(1, "USD")
+	if err != nil {
+		t.Fatalf("Failed to set base currency: %v", err)
+	}
+	if user.BaseCurrency != "USD" || mockStorage.users[0].BaseCurrency != "USD" {
+		t.Errorf("Expected base currency USD to be stored, got %q", mockStorage.users[0].BaseCurrency)
+	}
+	if user.Password != "" {
+		t.Error("Expected the password to be left out of the returned user")
+	}
+
+	if _, err := userService.SetBaseCurrency(2, "USD"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected 'user not found', got %v", err)
+	}
+}
+
+func TestSetAlertsOptOut(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{{ID: 1, Email: "john@example.com", Password: "hash", Status: true}}}
+	userService := NewUserService(mockStorage)
+
+	user, err := userService.SetAlertsOptOut(1, true)
+	if err != nil {
+		t.Fatalf("Failed to opt out of alerts: %v", err)
+	}
+	if !user.AlertsOptOut || !mockStorage.users[0].AlertsOptOut || user.Password != "" {
+		t.Errorf("Expected the opt-out to be stored and the password left out, got %+v", user)
+	}
+	if _, err := userService.SetAlertsOptOut(2, true); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected 'user not found', got %v", err)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{
+		{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "hash", Status: true},
+		{ID: 2, Purged: true},
+	}}
+	userService := NewUserService(mockStorage)
+
+	user, err := userService.GetUser(1)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if user.Name != "John Doe" || user.Password != "" {
+		t.Errorf("Expected John Doe without the password, got %+v", user)
+	}
+	for _, id := range []int{2, 3} {
+		if _, err := userService.GetUser(id); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("User %d: expected 'user not found', got %v", id, err)
+		}
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	text := func(value string) *string { return &value }
+	tests := []struct {
+		name     string
+		update   model.UserUpdate
+		expected model.User
+		wantErr  error
+	}{
+		{
+			name:     "name and email are trimmed",
+			update:   model.UserUpdate{Name: text("  Johnny "), Email: text(" johnny@example.com ")},
+			expected: model.User{ID: 1, Name: "Johnny", Email: "johnny@example.com", Status: true},
+		},
+		{
+			name:     "missing fields are kept",
+			update:   model.UserUpdate{Name: text("Johnny")},
+			expected: model.User{ID: 1, Name: "Johnny", Email: "john@example.com", Status: true},
+		},
+		{
+			name:     "own email",
+			update:   model.UserUpdate{Email: text("john@example.com")},
+			expected: model.User{ID: 1, Name: "John Doe", Email: "john@example.com", Status: true},
+		},
+		{name: "empty name", update: model.UserUpdate{Name: text("  ")}, wantErr: ErrInvalidName},
+		{name: "invalid email", update: model.UserUpdate{Email: text("john")}, wantErr: ErrInvalidEmail},
+		{name: "email with a display name", update: model.UserUpdate{Email: text("John <john@example.com>")}, wantErr: ErrInvalidEmail},
+		{name: "email of another user", update: model.UserUpdate{Email: text("jane@example.com")}, wantErr: ErrEmailExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &MockUserStorage{users: []model.User{
+				{ID: 1, Name: "John Doe", Email: "john@example.com", Password: "hash", Status: true},
+				{ID: 2, Name: "Jane Doe", Email: "jane@example.com", Password: "hash", Status: true},
+			}}
+			userService := NewUserService(mockStorage)
+
+			user, err := userService.UpdateProfile(1, tt.update)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				if mockStorage.updateCalled {
+					t.Error("Expected nothing to be saved")
+				}
+				return
+			}
+			if user != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, user)
+			}
+			stored := mockStorage.users[0]
+			if stored.Name != tt.expected.Name || stored.Email != tt.expected.Email || stored.Password != "hash" {
+				t.Errorf("Expected the profile to be stored with the password kept, got %+v", stored)
+			}
+		})
+	}
+}
+
+func TestReactivateUser(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{
+		{ID: 1, Email: "john@example.com", Password: "hash", Status: false},
+		{ID: 2, Purged: true},
+	}}
+	userService := NewUserService(mockStorage)
+
+	user, err := userService.ReactivateUser(1)
+	if err != nil {
+		t.Fatalf("Failed to reactivate user: %v", err)
+	}
+	if !user.Status || !mockStorage.users[0].Status || user.Password != "" {
+		t.Errorf("Expected the user to be active again without the password, got %+v", user)
+	}
+	if _, active := userService.GetActiveUser(1); !active {
+		t.Error("Expected the reactivated user to be active")
+	}
+
+	if _, err := userService.ReactivateUser(2); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected purged users to stay gone, got %v", err)
+	}
+	if mockStorage.users[1].Status {
+		t.Error("Expected the purged user to stay inactive")
+	}
+}
+
+func TestIsActive(t *testing.T) {
+	mockStorage := &MockUserStorage{users: []model.User{
+		{ID: 1, Status: true},
+		{ID: 2, Status: false},
+	}}
+	userService := NewUserService(mockStorage)
+
+	for id, expected := range map[int]bool{1: true, 2: false, 3: false} {
+		active, err := userService.IsActive(id)
+		if err != nil || active != expected {
+			t.Errorf("User %d: expected %v, got %v (%v)", id, expected, active, err)
+		}
 	}
 
-	if len(userService.User) != len(mockUsers) {
-		t.Errorf("Expected %d users to be loaded, got %d", len(mockUsers), len(userService.User))
+	mockStorage.failRead = true
+	if _, err := userService.IsActive(1); err == nil {
+		t.Error("Expected storage failures to be reported")
 	}
 }
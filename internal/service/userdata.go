@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/storage"
+	"log/slog"
+	"time"
+)
+
+// UserDataService exports and erases everything stored for a user, across
+// every storage
+type UserDataService struct {
+	Users          *UserService
+	Transactions   storage.FinanceStorage
+	Accounts       storage.AccountStorage
+	Categories     storage.CategoryStorage
+	Recurring      storage.RecurringStorage
+	Budgets        storage.BudgetStorage
+	Alerts         storage.AlertStorage
+	ImportProfiles storage.ImportProfileStorage
+}
+
+func NewUserDataService(users *UserService, transactions storage.FinanceStorage, accounts storage.AccountStorage,
+	categories storage.CategoryStorage, recurring storage.RecurringStorage, budgets storage.BudgetStorage,
+	alerts storage.AlertStorage, importProfiles storage.ImportProfileStorage) *UserDataService {
+	return &UserDataService{
+		Users:          users,
+		Transactions:   transactions,
+		Accounts:       accounts,
+		Categories:     categories,
+		Recurring:      recurring,
+		Budgets:        budgets,
+		Alerts:         alerts,
+		ImportProfiles: importProfiles,
+	}
+}
+
+// Export gathers everything stored for a user. Deactivated users can be
+// exported too; purged ones are not found.
+func (userDataService *UserDataService) Export(userID int) (model.UserExport, error) {
+	user, err := findUser(userDataService.Users.Storage, userID)
+	if err != nil {
+		return model.UserExport{}, err
+	}
+	export := model.UserExport{ExportedAt: time.Now().UTC(), User: user.Profile()}
+	if export.Accounts, err = userDataService.Accounts.FindByUser(userID); err != nil {
+		return model.UserExport{}, err
+	}
+	if export.Categories, err = userDataService.Categories.FindByUser(userID); err != nil {
+		return model.UserExport{}, err
+	}
+	if export.Transactions, err = userDataService.Transactions.FindByUser(userID, model.TransactionFilter{}); err != nil {
+		return model.UserExport{}, err
+	}
+	if export.RecurringRules, err = userDataService.Recurring.FindByUser(userID); err != nil {
+		return model.UserExport{}, err
+	}
+	if export.Budgets, err = userDataService.Budgets.FindByUser(userID); err != nil {
+		return model.UserExport{}, err
+	}
+	if export.AlertRules, err = userDataService.Alerts.FindByUser(userID); err != nil {
+		return model.UserExport{}, err
+	}
+	if export.ImportProfiles, err = userDataService.ImportProfiles.FindByUser(userID); err != nil {
+		return model.UserExport{}, err
+	}
+
+	// Empty lists rather than nulls, so the file reads the same for every user
+	export.Accounts = nonNil(export.Accounts)
+	export.Categories = nonNil(export.Categories)
+	export.Transactions = nonNil(export.Transactions)
+	export.RecurringRules = nonNil(export.RecurringRules)
+	export.Budgets = nonNil(export.Budgets)
+	export.AlertRules = nonNil(export.AlertRules)
+	export.ImportProfiles = nonNil(export.ImportProfiles)
+	return export, nil
+}
+
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+// Purge erases a user and everything stored for them. The user is
+// deactivated first, so neither they nor the background jobs can add data
+// while it is erased, and their record is only reduced to its ID at the end,
+// so a purge that fails halfway can simply be run again. It holds the user's
+// lock throughout, so the user cannot be reactivated or changed meanwhile.
+func (userDataService *UserDataService) Purge(ctx context.Context, userID int) error {
+	defer userDataService.Users.changing.lock(userID)()
+	user, err := findUser(userDataService.Users.Storage, userID)
+	if err != nil {
+		return err
+	}
+	if user.Status {
+		user.Status = false
+		if err := userDataService.Users.Storage.Update(user); err != nil {
+			return err
+		}
+	}
+
+	// Recurring rules go first so the scheduler creates no new transactions
+	steps := []struct {
+		name  string
+		purge func() error
+	}{
+		{"recurring rules", func() error {
+			return purgeEach(userDataService.Recurring.FindByUser, userDataService.Recurring.Delete, userID,
+				func(rule model.RecurringRule) int { return rule.ID })
+		}},
+		{"alert rules", func() error {
+			return purgeEach(userDataService.Alerts.FindByUser, userDataService.Alerts.Delete, userID,
+				func(rule model.AlertRule) int { return rule.ID })
+		}},
+		{"budgets", func() error {
+			return purgeEach(userDataService.Budgets.FindByUser, userDataService.Budgets.Delete, userID,
+				func(budget model.Budget) int { return budget.ID })
+		}},
+		{"import profiles", func() error {
+			return purgeEach(userDataService.ImportProfiles.FindByUser, userDataService.ImportProfiles.Delete, userID,
+				func(profile model.ImportProfile) int { return profile.ID })
+		}},
+		{"transactions", func() error { return userDataService.purgeTransactions(userID) }},
+		{"accounts", func() error {
+			return purgeEach(userDataService.Accounts.FindByUser, userDataService.Accounts.Delete, userID,
+				func(account model.Account) int { return account.ID })
+		}},
+		{"categories", func() error {
+			return purgeEach(userDataService.Categories.FindByUser, userDataService.Categories.Delete, userID,
+				func(category model.Category) int { return category.ID })
+		}},
+	}
+	for _, step := range steps {
+		if err := step.purge(); err != nil {
+			return fmt.Errorf("purging %s: %w", step.name, err)
+		}
+	}
+
+	if err := userDataService.Users.Storage.Update(model.User{ID: userID, Purged: true}); err != nil {
+		return fmt.Errorf("purging user: %w", err)
+	}
+	slog.InfoContext(ctx, "User data purged", "user_id", userID)
+	return nil
+}
+
+// purgeTransactions deletes every transaction of the user at once, so
+// transfers never lose only one of their legs
+func (userDataService *UserDataService) purgeTransactions(userID int) error {
+	transactions, err := userDataService.Transactions.FindByUser(userID, model.TransactionFilter{})
+	if err != nil || len(transactions) == 0 {
+		return err
+	}
+	ids := make([]int, len(transactions))
+	for i, transaction := range transactions {
+		ids[i] = transaction.ID
+	}
+	return userDataService.Transactions.DeleteMany(ids)
+}
+
+// purgeEach deletes every item find returns for the user
+func purgeEach[T any](find func(userID int) ([]T, error), remove func(id int) error, userID int, id func(T) int) error {
+	items, err := find(userID)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := remove(id(item)); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
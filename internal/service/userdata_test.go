@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// seedUserData stores data of every kind for John (1) and some for Jane (2)
+func seedUserData(t *testing.T, storages *testStorages) {
+	t.Helper()
+	seed(t, storages.finance.Insert,
+		model.Transaction{UserID: 1, AccountID: 1, Type: "expense", Amount: model.Money(100_00), Date: mustParseDate(t, "2023-06-01")},
+		model.Transaction{UserID: 1, AccountID: 2, Type: "income", Amount: model.Money(100_00), Date: mustParseDate(t, "2023-06-01")},
+		model.Transaction{UserID: 2, AccountID: 3, Type: "income", Amount: model.Money(50_00), Date: mustParseDate(t, "2023-06-01")},
+	)
+	seed(t, storages.accounts.Insert, model.Account{UserID: 1}, model.Account{UserID: 1}, model.Account{UserID: 2})
+	seed(t, storages.categories.Insert, model.Category{UserID: 1, Name: "Food"}, model.Category{UserID: 2, Name: "Food"})
+	seed(t, storages.recurring.Insert, model.RecurringRule{UserID: 1}, model.RecurringRule{UserID: 2})
+	seed(t, storages.budgets.Insert, model.Budget{UserID: 1})
+	seed(t, storages.alerts.Insert, model.AlertRule{UserID: 2})
+	seed(t, storages.imports.Insert, model.ImportProfile{UserID: 1})
+}
+
+func TestExportUserData(t *testing.T) {
+	services := newTestServices(t)
+	seedUserData(t, services.storages)
+	userData := services.userData
+
+	export, err := userData.Export(1)
+	if err != nil {
+		t.Fatalf("Failed to export user data: %v", err)
+	}
+	if export.User.Email != "john@example.com" || export.ExportedAt.IsZero() {
+		t.Errorf("Expected John's profile and the export time, got %+v", export.User)
+	}
+	if len(export.Accounts) != 2 || len(export.Categories) != 1 || len(export.Transactions) != 2 ||
+		len(export.RecurringRules) != 1 || len(export.Budgets) != 1 || len(export.ImportProfiles) != 1 {
+		t.Errorf("Expected only John's data, got %+v", export)
+	}
+	if export.AlertRules == nil || len(export.AlertRules) != 0 {
+		t.Errorf("Expected an empty list of alert rules, got %#v", export.AlertRules)
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("Failed to encode export: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if _, ok := fields["user"].(map[string]any)["password"]; ok {
+		t.Error("Expected the password hash to be left out of the export")
+	}
+
+	if _, err := userData.Export(3); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected 'user not found', got %v", err)
+	}
+}
+
+func TestPurgeUserData(t *testing.T) {
+	services := newTestServices(t)
+	seedUserData(t, services.storages)
+	userData := services.userData
+
+	if err := userData.Purge(context.Background(), 1); err != nil {
+		t.Fatalf("Failed to purge user data: %v", err)
+	}
+
+	if john, _ := services.storages.users.Get(1); john != (model.User{ID: 1, Purged: true}) {
+		t.Errorf("Expected only the ID of the purged user to be kept, got %+v", john)
+	}
+	if left := transactionCount(t, services.storages); left != 1 {
+		t.Errorf("Expected only Jane's transaction to be left, got %d transactions", left)
+	}
+	export, err := userData.Export(2)
+	if err != nil {
+		t.Fatalf("Failed to export the other user: %v", err)
+	}
+	if len(export.Accounts) != 1 || len(export.Categories) != 1 || len(export.RecurringRules) != 1 || len(export.AlertRules) != 1 {
+		t.Errorf("Expected the other user's data to be kept, got %+v", export)
+	}
+	if accounts, _ := userData.Accounts.FindByUser(1); len(accounts) != 0 {
+		t.Errorf("Expected the accounts to be purged, got %+v", accounts)
+	}
+	if budgets, _ := userData.Budgets.FindByUser(1); len(budgets) != 0 {
+		t.Errorf("Expected the budgets to be purged, got %+v", budgets)
+	}
+
+	if _, err := userData.Export(1); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected the purged user to be gone, got %v", err)
+	}
+	if err := userData.Purge(context.Background(), 1); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected a second purge to find no user, got %v", err)
+	}
+}
+
+func TestPurgeUserDataCanBeRetried(t *testing.T) {
+	storages := newTestStorages(t)
+	transactions := &MockStorage{}
+	storages.finance = transactions
+	seedUserData(t, storages)
+	userData := storages.services().userData
+	transactions.failWrite = true
+
+	if err := userData.Purge(context.Background(), 1); err == nil {
+		t.Fatal("Expected the failing step to be reported")
+	}
+	if john, _ := storages.users.Get(1); john.Status || john.Purged {
+		t.Errorf("Expected the user to be deactivated but not yet purged, got %+v", john)
+	}
+
+	transactions.failWrite = false
+	if err := userData.Purge(context.Background(), 1); err != nil {
+		t.Fatalf("Expected the purge to finish on retry, got %v", err)
+	}
+	if john, _ := storages.users.Get(1); !john.Purged {
+		t.Error("Expected the user to be purged")
+	}
+}
+
+func TestPurgeWaitsForChangesToTheUser(t *testing.T) {
+	services := newTestServices(t)
+	seedUserData(t, services.storages)
+	unlock := services.users.changing.lock(1)
+
+	done := make(chan error, 1)
+	go func() { done <- services.userData.Purge(context.Background(), 1) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the purge to wait for the user's lock, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to purge user data: %v", err)
+	}
+
+	// Once purged, the user cannot be brought back
+	if _, err := services.users.ReactivateUser(1); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected the purged user not to be reactivated, got %v", err)
+	}
+	if john, _ := services.storages.users.Get(1); john != (model.User{ID: 1, Purged: true}) {
+		t.Errorf("Expected only the ID of the purged user to be kept, got %+v", john)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cell styles defined in xlsxStyles
+const (
+	xlsxDateStyle    = 1
+	xlsxMoneyStyle   = 2
+	xlsxHeadingStyle = 3
+)
+
+// xlsxEpoch is day zero of spreadsheet dates
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxHeading is a cell written in bold
+type xlsxHeading string
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="Transactions" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// xlsxSheetStart opens the sheet with its first row frozen
+const xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+	`<sheetData>`
+
+const xlsxSheetEnd = `</sheetData></worksheet>`
+
+// xlsxWriter writes a workbook with a single sheet one row at a time, so
+// only the row being written is held in memory. Text is written as inline
+// strings, which spares keeping a table of shared strings until the end.
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	rows    int
+}
+
+func newXLSXWriter(output io.Writer) (*xlsxWriter, error) {
+	archive := zip.NewWriter(output)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRelationships},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+		{"xl/styles.xml", xlsxStyles},
+		// The sheet comes last and is left open for the rows
+		{"xl/worksheets/sheet1.xml", xlsxSheetStart},
+	}
+	writer := &xlsxWriter{archive: archive}
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return nil, err
+		}
+		writer.sheet = file
+	}
+	return writer, nil
+}
+
+// xlsxColumn returns the letters naming the column at a zero-based index
+func xlsxColumn(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// writeRow appends a row to the sheet. Values can be strings, xlsxHeadings,
+// ints, Money and dates; empty strings leave the cell blank.
+func (writer *xlsxWriter) writeRow(values ...any) error {
+	writer.rows++
+	var row strings.Builder
+	fmt.Fprintf(&row, `<row r="%d">`, writer.rows)
+	for column, value := range values {
+		ref := xlsxColumn(column) + strconv.Itoa(writer.rows)
+		switch value := value.(type) {
+		case string:
+			if value == "" {
+				continue
+			}
+			fmt.Fprintf(&row, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			xml.EscapeText(&row, []byte(value))
+			row.WriteString(`</t></is></c>`)
+		case xlsxHeading:
+			fmt.Fprintf(&row, `<c r="%s" s="%d" t="inlineStr"><is><t>`, ref, xlsxHeadingStyle)
+			xml.EscapeText(&row, []byte(value))
+			row.WriteString(`</t></is></c>`)
+		case int:
+			fmt.Fprintf(&row, `<c r="%s"><v>%d</v></c>`, ref, value)
+		case model.Money:
+			fmt.Fprintf(&row, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxMoneyStyle, value)
+		case model.DateOnly:
+			days := math.Round(time.Time(value).Sub(xlsxEpoch).Hours() / 24)
+			fmt.Fprintf(&row, `<c r="%s" s="%d"><v>%d</v></c>`, ref, xlsxDateStyle, int(days))
+		default:
+			return fmt.Errorf("unsupported cell value %T", value)
+		}
+	}
+	row.WriteString(`</row>`)
+	_, err := io.WriteString(writer.sheet, row.String())
+	return err
+}
+
+// flush sends the rows compressed so far to the output
+func (writer *xlsxWriter) flush() error {
+	return writer.archive.Flush()
+}
+
+// close ends the sheet and writes the archive's directory
+func (writer *xlsxWriter) close() error {
+	if _, err := io.WriteString(writer.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return writer.archive.Close()
+}
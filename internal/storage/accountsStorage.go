@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// AccountStorage persists accounts one record at a time. Insert assigns the
+// ID; Update, Delete and Get return ErrNotFound for unknown IDs.
+type AccountStorage interface {
+	Insert(account model.Account) (model.Account, error)
+	Update(account model.Account) error
+	Delete(id int) error
+	Get(id int) (model.Account, error)
+	FindByUser(userID int) ([]model.Account, error)
+}
+
+type FileAccountStorage struct {
+	accounts *fileCollection[model.Account]
+}
+
+func NewFileAccountStorage(filename string) (*FileAccountStorage, error) {
+	accounts, err := newFileCollection(filename, func(account *model.Account) *int { return &account.ID })
+	if err != nil {
+		return nil, err
+	}
+	return &FileAccountStorage{accounts: accounts}, nil
+}
+
+func (f FileAccountStorage) Insert(account model.Account) (model.Account, error) {
+	return f.accounts.insert(account)
+}
+
+func (f FileAccountStorage) Update(account model.Account) error {
+	return f.accounts.update(account)
+}
+
+func (f FileAccountStorage) Delete(id int) error {
+	return f.accounts.delete(id)
+}
+
+func (f FileAccountStorage) Get(id int) (model.Account, error) {
+	return f.accounts.get(id)
+}
+
+func (f FileAccountStorage) FindByUser(userID int) ([]model.Account, error) {
+	return f.accounts.find(func(account model.Account) bool { return account.UserID == userID })
+}
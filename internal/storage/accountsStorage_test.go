@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestAccountStorageRowOperations(t *testing.T) {
+	for name, accountStorage := range backends[AccountStorage](t, NewFileAccountStorage, NewSQLiteAccountStorage) {
+		t.Run(name, func(t *testing.T) {
+			checking, err := accountStorage.Insert(model.Account{UserID: 1, Name: "Checking", Type: "checking", Currency: "BRL",
+				OpeningBalance: 1500_00, OpeningDate: mustDate(t, "2023-01-01")})
+			if err != nil {
+				t.Fatalf("Failed to insert account: %v", err)
+			}
+			card, err := accountStorage.Insert(model.Account{UserID: 1, Name: "Card", Type: "credit_card", Currency: "BRL",
+				OpeningBalance: -200_00, OpeningDate: mustDate(t, "2023-01-01")})
+			if err != nil {
+				t.Fatalf("Failed to insert account: %v", err)
+			}
+			if _, err := accountStorage.Insert(model.Account{UserID: 2, Name: "Other", Type: "cash", Currency: "USD",
+				OpeningDate: mustDate(t, "2023-01-01")}); err != nil {
+				t.Fatalf("Failed to insert account: %v", err)
+			}
+
+			card.Name = "Credit card"
+			if err := accountStorage.Update(card); err != nil {
+				t.Fatalf("Failed to update account: %v", err)
+			}
+			loaded, err := accountStorage.Get(card.ID)
+			if err != nil {
+				t.Fatalf("Failed to get account: %v", err)
+			}
+			if loaded != card {
+				t.Errorf("Expected %+v, got %+v", card, loaded)
+			}
+
+			accounts, err := accountStorage.FindByUser(1)
+			if err != nil {
+				t.Fatalf("Failed to find accounts: %v", err)
+			}
+			if len(accounts) != 2 || accounts[0] != checking {
+				t.Errorf("Expected the two accounts of user 1, got %+v", accounts)
+			}
+
+			if err := accountStorage.Delete(checking.ID); err != nil {
+				t.Fatalf("Failed to delete account: %v", err)
+			}
+			if _, err := accountStorage.Get(checking.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for deleted account, got %v", err)
+			}
+			if err := accountStorage.Update(checking); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound updating deleted account, got %v", err)
+			}
+		})
+	}
+}
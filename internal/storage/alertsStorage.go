@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// AlertStorage persists alert rules one record at a time. Insert assigns
+// the ID; Update, Delete and Get return ErrNotFound for unknown IDs.
+type AlertStorage interface {
+	Insert(rule model.AlertRule) (model.AlertRule, error)
+	Update(rule model.AlertRule) error
+	Delete(id int) error
+	Get(id int) (model.AlertRule, error)
+	FindByUser(userID int) ([]model.AlertRule, error)
+}
+
+type FileAlertStorage struct {
+	rules *fileCollection[model.AlertRule]
+}
+
+func NewFileAlertStorage(filename string) (*FileAlertStorage, error) {
+	rules, err := newFileCollection(filename, func(rule *model.AlertRule) *int { return &rule.ID })
+	if err != nil {
+		return nil, err
+	}
+	return &FileAlertStorage{rules: rules}, nil
+}
+
+func (f FileAlertStorage) Insert(rule model.AlertRule) (model.AlertRule, error) {
+	return f.rules.insert(rule)
+}
+
+func (f FileAlertStorage) Update(rule model.AlertRule) error {
+	return f.rules.update(rule)
+}
+
+func (f FileAlertStorage) Delete(id int) error {
+	return f.rules.delete(id)
+}
+
+func (f FileAlertStorage) Get(id int) (model.AlertRule, error) {
+	return f.rules.get(id)
+}
+
+func (f FileAlertStorage) FindByUser(userID int) ([]model.AlertRule, error) {
+	return f.rules.find(func(rule model.AlertRule) bool { return rule.UserID == userID })
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestAlertStorageRowOperations(t *testing.T) {
+	for name, alertStorage := range backends[AlertStorage](t, NewFileAlertStorage, NewSQLiteAlertStorage) {
+		t.Run(name, func(t *testing.T) {
+			food, err := alertStorage.Insert(model.AlertRule{UserID: 1, Kind: "category_limit", Category: "Food", Threshold: 800_00})
+			if err != nil {
+				t.Fatalf("Failed to insert rule: %v", err)
+			}
+			low, err := alertStorage.Insert(model.AlertRule{UserID: 1, Kind: "low_balance", Threshold: 100_00})
+			if err != nil {
+				t.Fatalf("Failed to insert rule: %v", err)
+			}
+			if _, err := alertStorage.Insert(model.AlertRule{UserID: 2, Kind: "large_expense", Threshold: 500_00}); err != nil {
+				t.Fatalf("Failed to insert rule: %v", err)
+			}
+
+			food.LastPeriod, food.FiredPeriods = "2023-06", []string{"2023-05", "2023-06"}
+			if err := alertStorage.Update(food); err != nil {
+				t.Fatalf("Failed to update rule: %v", err)
+			}
+			loaded, err := alertStorage.Get(food.ID)
+			if err != nil || !reflect.DeepEqual(loaded, food) {
+				t.Errorf("Expected %+v, got %+v (%v)", food, loaded, err)
+			}
+
+			rules, err := alertStorage.FindByUser(1)
+			if err != nil || len(rules) != 2 || !reflect.DeepEqual(rules[1], low) {
+				t.Errorf("Expected the two rules of user 1, got %+v (%v)", rules, err)
+			}
+
+			if err := alertStorage.Delete(low.ID); err != nil {
+				t.Fatalf("Failed to delete rule: %v", err)
+			}
+			if _, err := alertStorage.Get(low.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for deleted rule, got %v", err)
+			}
+		})
+	}
+}
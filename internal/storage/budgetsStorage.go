@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// BudgetStorage persists budgets one record at a time. Insert assigns the
+// ID; Update, Delete and Get return ErrNotFound for unknown IDs.
+type BudgetStorage interface {
+	Insert(budget model.Budget) (model.Budget, error)
+	Update(budget model.Budget) error
+	Delete(id int) error
+	Get(id int) (model.Budget, error)
+	FindByUser(userID int) ([]model.Budget, error)
+}
+
+type FileBudgetStorage struct {
+	budgets *fileCollection[model.Budget]
+}
+
+func NewFileBudgetStorage(filename string) (*FileBudgetStorage, error) {
+	budgets, err := newFileCollection(filename, func(budget *model.Budget) *int { return &budget.ID })
+	if err != nil {
+		return nil, err
+	}
+	return &FileBudgetStorage{budgets: budgets}, nil
+}
+
+func (f FileBudgetStorage) Insert(budget model.Budget) (model.Budget, error) {
+	return f.budgets.insert(budget)
+}
+
+func (f FileBudgetStorage) Update(budget model.Budget) error {
+	return f.budgets.update(budget)
+}
+
+func (f FileBudgetStorage) Delete(id int) error {
+	return f.budgets.delete(id)
+}
+
+func (f FileBudgetStorage) Get(id int) (model.Budget, error) {
+	return f.budgets.get(id)
+}
+
+func (f FileBudgetStorage) FindByUser(userID int) ([]model.Budget, error) {
+	return f.budgets.find(func(budget model.Budget) bool { return budget.UserID == userID })
+}
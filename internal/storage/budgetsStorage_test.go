@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestBudgetStorageRowOperations(t *testing.T) {
+	for name, budgetStorage := range backends[BudgetStorage](t, NewFileBudgetStorage, NewSQLiteBudgetStorage) {
+		t.Run(name, func(t *testing.T) {
+			overall, err := budgetStorage.Insert(model.Budget{UserID: 1, Limit: 3000_00, StartMonth: "2023-01"})
+			if err != nil {
+				t.Fatalf("Failed to insert budget: %v", err)
+			}
+			food, err := budgetStorage.Insert(model.Budget{UserID: 1, Category: "Food", Limit: 800_00, StartMonth: "2023-01"})
+			if err != nil {
+				t.Fatalf("Failed to insert budget: %v", err)
+			}
+			if _, err := budgetStorage.Insert(model.Budget{UserID: 2, Category: "Food", Limit: 100_00, StartMonth: "2023-03"}); err != nil {
+				t.Fatalf("Failed to insert budget: %v", err)
+			}
+
+			food.Limit, food.Rollover = 900_00, true
+			if err := budgetStorage.Update(food); err != nil {
+				t.Fatalf("Failed to update budget: %v", err)
+			}
+			loaded, err := budgetStorage.Get(food.ID)
+			if err != nil {
+				t.Fatalf("Failed to get budget: %v", err)
+			}
+			if loaded != food {
+				t.Errorf("Expected %+v, got %+v", food, loaded)
+			}
+
+			budgets, err := budgetStorage.FindByUser(1)
+			if err != nil {
+				t.Fatalf("Failed to find budgets: %v", err)
+			}
+			if len(budgets) != 2 || budgets[0] != overall {
+				t.Errorf("Expected the two budgets of user 1, got %+v", budgets)
+			}
+
+			if err := budgetStorage.Delete(overall.ID); err != nil {
+				t.Fatalf("Failed to delete budget: %v", err)
+			}
+			if _, err := budgetStorage.Get(overall.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for deleted budget, got %v", err)
+			}
+			if err := budgetStorage.Update(model.Budget{ID: 999}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound updating unknown budget, got %v", err)
+			}
+		})
+	}
+}
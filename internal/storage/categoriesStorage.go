@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// CategoryStorage persists categories one record at a time. Insert assigns the
+// ID; Update, Delete and Get return ErrNotFound for unknown IDs.
+type CategoryStorage interface {
+	Insert(category model.Category) (model.Category, error)
+	Update(category model.Category) error
+	Delete(id int) error
+	Get(id int) (model.Category, error)
+	FindByUser(userID int) ([]model.Category, error)
+}
+
+type FileCategoryStorage struct {
+	categories *fileCollection[model.Category]
+}
+
+func NewFileCategoryStorage(filename string) (*FileCategoryStorage, error) {
+	categories, err := newFileCollection(filename, func(category *model.Category) *int { return &category.ID })
+	if err != nil {
+		return nil, err
+	}
+	return &FileCategoryStorage{categories: categories}, nil
+}
+
+func (f FileCategoryStorage) Insert(category model.Category) (model.Category, error) {
+	return f.categories.insert(category)
+}
+
+func (f FileCategoryStorage) Update(category model.Category) error {
+	return f.categories.update(category)
+}
+
+func (f FileCategoryStorage) Delete(id int) error {
+	return f.categories.delete(id)
+}
+
+func (f FileCategoryStorage) Get(id int) (model.Category, error) {
+	return f.categories.get(id)
+}
+
+func (f FileCategoryStorage) FindByUser(userID int) ([]model.Category, error) {
+	return f.categories.find(func(category model.Category) bool { return category.UserID == userID })
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestCategoryStorageRowOperations(t *testing.T) {
+	for name, categoryStorage := range backends[CategoryStorage](t, NewFileCategoryStorage, NewSQLiteCategoryStorage) {
+		t.Run(name, func(t *testing.T) {
+			food, err := categoryStorage.Insert(model.Category{UserID: 1, Name: "Food", Type: "expense", Color: "#ff9800"})
+			if err != nil {
+				t.Fatalf("Failed to insert category: %v", err)
+			}
+			groceries, err := categoryStorage.Insert(model.Category{UserID: 1, Name: "Groceries", Type: "expense", ParentID: food.ID})
+			if err != nil {
+				t.Fatalf("Failed to insert category: %v", err)
+			}
+			if _, err := categoryStorage.Insert(model.Category{UserID: 2, Name: "Salary", Type: "income"}); err != nil {
+				t.Fatalf("Failed to insert category: %v", err)
+			}
+
+			groceries.Icon, groceries.Color = "cart", "#4caf50"
+			if err := categoryStorage.Update(groceries); err != nil {
+				t.Fatalf("Failed to update category: %v", err)
+			}
+			loaded, err := categoryStorage.Get(groceries.ID)
+			if err != nil {
+				t.Fatalf("Failed to get category: %v", err)
+			}
+			if loaded != groceries {
+				t.Errorf("Expected %+v, got %+v", groceries, loaded)
+			}
+
+			categories, err := categoryStorage.FindByUser(1)
+			if err != nil {
+				t.Fatalf("Failed to find categories: %v", err)
+			}
+			if len(categories) != 2 || categories[0] != food {
+				t.Errorf("Expected the two categories of user 1, got %+v", categories)
+			}
+
+			if err := categoryStorage.Delete(food.ID); err != nil {
+				t.Fatalf("Failed to delete category: %v", err)
+			}
+			if _, err := categoryStorage.Get(food.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for deleted category, got %v", err)
+			}
+			if err := categoryStorage.Update(model.Category{ID: 999}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound updating unknown category, got %v", err)
+			}
+		})
+	}
+}
@@ -2,20 +2,44 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/metrics"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
+// ErrNotFound is returned when a record with the requested ID does not exist
+var ErrNotFound = errors.New("record not found")
+
 type Storable interface {
 	Save(data interface{}) error
 	Load(data interface{}) error
 }
 
+// defaultBackups is how many previous generations of a file are kept
+const defaultBackups = 3
+
 type FileStorage struct {
 	Filename string
+	Backups  int
 }
 
 func NewFileStorage(filename string) *FileStorage {
+	return &FileStorage{
+		Filename: dataPath(filename),
+		Backups:  defaultBackups,
+	}
+}
+
+// dataPath resolves a file name inside the application data directory,
+// creating the directory if needed. Absolute paths are returned unchanged.
+func dataPath(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		panic(err)
@@ -25,29 +49,121 @@ func NewFileStorage(filename string) *FileStorage {
 	if err != nil {
 		panic(err)
 	}
-	return &FileStorage{
-		Filename: filepath.Join(appDir, filename),
-	}
+	return filepath.Join(appDir, filename)
 }
 
-func (f FileStorage) Save(data interface{}) error {
-	file, err := os.Create(f.Filename)
+// Save writes data to a temporary file, syncs it to disk and atomically
+// renames it over the live file, so a crash mid-write never leaves a truncated
+// file behind. The previous contents are kept as Filename.1 .. Filename.N.
+func (f FileStorage) Save(data interface{}) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveStorage(filepath.Base(f.Filename), "save", time.Since(start), err) }()
+
+	dir := filepath.Dir(f.Filename)
+	file, err := os.CreateTemp(dir, filepath.Base(f.Filename)+".tmp-*")
 	if err != nil {
 		return err
 	}
+	tempName := file.Name()
 	defer func() {
-		closeErr := file.Close()
-		if err == nil {
-			err = closeErr
+		if err != nil {
+			file.Close()
+			os.Remove(tempName)
 		}
 	}()
 
 	encoder := json.NewEncoder(file)
-	err = encoder.Encode(data)
-	return err
+	if err = encoder.Encode(data); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	if err = f.rotateBackups(); err != nil {
+		return err
+	}
+	if err = os.Rename(tempName, f.Filename); err != nil {
+		return err
+	}
+	return syncDir(dir)
 }
 
-func (f FileStorage) Load(data interface{}) error {
+// rotateBackups shifts Filename.1 .. Filename.N-1 up by one generation and
+// links the current live file as Filename.1. The live file itself is never
+// moved, so it stays readable until the new version replaces it.
+func (f FileStorage) rotateBackups() error {
+	if f.Backups <= 0 {
+		return nil
+	}
+	if _, err := os.Stat(f.Filename); os.IsNotExist(err) {
+		return nil
+	}
+
+	for generation := f.Backups - 1; generation >= 1; generation-- {
+		err := os.Rename(f.backupName(generation), f.backupName(generation+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	first := f.backupName(1)
+	if err := os.Remove(first); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(f.Filename, first); err == nil {
+		return nil
+	}
+	// Hard links are not supported everywhere; fall back to a copy
+	return copyFile(f.Filename, first)
+}
+
+func (f FileStorage) backupName(generation int) string {
+	return fmt.Sprintf("%s.%d", f.Filename, generation)
+}
+
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncDir flushes a directory entry so a completed rename survives a crash
+func syncDir(dir string) error {
+	handle, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	if err := handle.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
+
+func (f FileStorage) Load(data interface{}) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveStorage(filepath.Base(f.Filename), "load", time.Since(start), err) }()
+
 	file, err := os.Open(f.Filename)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -64,5 +180,20 @@ func (f FileStorage) Load(data interface{}) error {
 
 	decoder := json.NewDecoder(file)
 	err = decoder.Decode(data)
-	return err
+	if err != nil {
+		return fmt.Errorf("%s is not valid JSON (previous versions are kept as %s): %w", f.Filename, f.backupName(1), err)
+	}
+	return nil
+}
+
+// CheckWritable reports whether files can be created in dir, by writing and
+// removing a temporary one
+func CheckWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".ready-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	_, err = file.WriteString("ok")
+	return errors.Join(err, file.Close(), os.Remove(name))
 }
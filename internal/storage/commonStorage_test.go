@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// backends opens an empty storage on each backend, keyed by its name, so a
+// test can run the same steps against both
+func backends[T any, F any, S any](t *testing.T, file func(filename string) (F, error), sqlite func(db *sql.DB) S) map[string]T {
+	t.Helper()
+	fileStorage, err := file(filepath.Join(t.TempDir(), "storage.json"))
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	return map[string]T{
+		"file":   any(fileStorage).(T),
+		"sqlite": any(sqlite(openTestDB(t))).(T),
+	}
+}
+
+func TestFileStorageSaveAndLoad(t *testing.T) {
+	fileStorage := NewFileStorage(filepath.Join(t.TempDir(), "data.json"))
+
+	if err := fileStorage.Save([]string{"a", "b"}); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	var loaded []string
+	if err := fileStorage.Load(&loaded); err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0] != "a" || loaded[1] != "b" {
+		t.Errorf("Unexpected data loaded: %v", loaded)
+	}
+}
+
+func TestFileStorageLoadMissingFile(t *testing.T) {
+	fileStorage := NewFileStorage(filepath.Join(t.TempDir(), "missing.json"))
+
+	var loaded []string
+	if err := fileStorage.Load(&loaded); err != nil {
+		t.Errorf("Expected missing file to load as empty, got %v", err)
+	}
+	if loaded != nil {
+		t.Errorf("Expected no data, got %v", loaded)
+	}
+}
+
+func TestFileStorageKeepsBackupGenerations(t *testing.T) {
+	dir := t.TempDir()
+	fileStorage := NewFileStorage(filepath.Join(dir, "data.json"))
+
+	for version := 1; version <= 5; version++ {
+		if err := fileStorage.Save(version); err != nil {
+			t.Fatalf("Failed to save version %d: %v", version, err)
+		}
+	}
+
+	expected := map[string]string{
+		"data.json":   "5",
+		"data.json.1": "4",
+		"data.json.2": "3",
+		"data.json.3": "2",
+	}
+	for name, content := range expected {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("Expected %s to exist: %v", name, err)
+			continue
+		}
+		if strings.TrimSpace(string(data)) != content {
+			t.Errorf("Expected %s to contain %s, got %s", name, content, data)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "data.json.4")); !os.IsNotExist(err) {
+		t.Error("Expected only 3 backup generations to be kept")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("Temporary file %s was left behind", entry.Name())
+		}
+	}
+}
+
+func TestFileStorageFailedSaveKeepsLiveFile(t *testing.T) {
+	dir := t.TempDir()
+	fileStorage := NewFileStorage(filepath.Join(dir, "data.json"))
+
+	if err := fileStorage.Save([]string{"kept"}); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	// Channels cannot be encoded, so the write fails half-way
+	if err := fileStorage.Save(make(chan int)); err == nil {
+		t.Fatal("Expected saving unencodable data to fail")
+	}
+
+	var loaded []string
+	if err := fileStorage.Load(&loaded); err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0] != "kept" {
+		t.Errorf("Expected previous contents to survive a failed save, got %v", loaded)
+	}
+}
+
+func TestFileStorageLoadCorruptFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(filename, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to write corrupt file: %v", err)
+	}
+
+	var loaded []string
+	err := NewFileStorage(filename).Load(&loaded)
+	if err == nil {
+		t.Fatal("Expected an error loading a corrupt file")
+	}
+	if !strings.Contains(err.Error(), "data.json.1") {
+		t.Errorf("Expected error to point at the backup file, got %v", err)
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := CheckWritable(dir); err != nil {
+		t.Fatalf("Expected a writable directory, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected the probe file to be removed, got %v", entries)
+	}
+	if err := CheckWritable(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
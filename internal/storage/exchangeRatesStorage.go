@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"cmp"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"slices"
+	"time"
+)
+
+// ExchangeRateStorage persists dated exchange rates. Upsert stores all rates
+// or none, replacing any rate already stored for the same date and pair. Find
+// returns the rates of a pair ordered by date; an empty currency matches any.
+// Latest returns the newest rate of the pair dated on or before date, or
+// ErrNotFound.
+type ExchangeRateStorage interface {
+	Upsert(rates []model.ExchangeRate) error
+	Find(from, to string) ([]model.ExchangeRate, error)
+	Latest(from, to string, date model.DateOnly) (model.ExchangeRate, error)
+}
+
+type FileExchangeRateStorage struct {
+	rates *fileCollection[model.ExchangeRate]
+}
+
+func NewFileExchangeRateStorage(filename string) (*FileExchangeRateStorage, error) {
+	rates, err := newFileCollection(filename, func(rate *model.ExchangeRate) *int { return &rate.ID })
+	if err != nil {
+		return nil, err
+	}
+	return &FileExchangeRateStorage{rates: rates}, nil
+}
+
+func (f FileExchangeRateStorage) Upsert(rates []model.ExchangeRate) error {
+	return f.rates.upsert(rates, func(a, b model.ExchangeRate) bool {
+		return a.From == b.From && a.To == b.To && a.Date == b.Date
+	})
+}
+
+func (f FileExchangeRateStorage) Find(from, to string) ([]model.ExchangeRate, error) {
+	rates, err := f.rates.find(func(rate model.ExchangeRate) bool {
+		return (from == "" || rate.From == from) && (to == "" || rate.To == to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(rates, func(a, b model.ExchangeRate) int {
+		return cmp.Or(time.Time(a.Date).Compare(time.Time(b.Date)), cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	})
+	return rates, nil
+}
+
+func (f FileExchangeRateStorage) Latest(from, to string, date model.DateOnly) (model.ExchangeRate, error) {
+	rates, err := f.Find(from, to)
+	if err != nil {
+		return model.ExchangeRate{}, err
+	}
+	for index := len(rates) - 1; index >= 0; index-- {
+		if !time.Time(rates[index].Date).After(time.Time(date)) {
+			return rates[index], nil
+		}
+	}
+	return model.ExchangeRate{}, ErrNotFound
+}
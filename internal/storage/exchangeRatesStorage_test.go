@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestExchangeRateStorageUpsertAndLookup(t *testing.T) {
+	for name, rateStorage := range backends[ExchangeRateStorage](t, NewFileExchangeRateStorage, NewSQLiteExchangeRateStorage) {
+		t.Run(name, func(t *testing.T) {
+			err := rateStorage.Upsert([]model.ExchangeRate{
+				{Date: mustDate(t, "2023-06-01"), From: "USD", To: "BRL", Rate: "4.90"},
+				{Date: mustDate(t, "2023-07-01"), From: "USD", To: "BRL", Rate: "4.80"},
+				{Date: mustDate(t, "2023-06-01"), From: "EUR", To: "BRL", Rate: "5.30"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to upsert rates: %v", err)
+			}
+
+			// Re-importing a date and pair replaces the rate instead of duplicating it
+			err = rateStorage.Upsert([]model.ExchangeRate{{Date: mustDate(t, "2023-07-01"), From: "USD", To: "BRL", Rate: "4.85"}})
+			if err != nil {
+				t.Fatalf("Failed to upsert rates: %v", err)
+			}
+
+			rates, err := rateStorage.Find("USD", "BRL")
+			if err != nil {
+				t.Fatalf("Failed to find rates: %v", err)
+			}
+			if len(rates) != 2 || rates[0].Rate != "4.90" || rates[1].Rate != "4.85" {
+				t.Errorf("Expected two USD/BRL rates ordered by date, got %+v", rates)
+			}
+
+			all, err := rateStorage.Find("", "")
+			if err != nil {
+				t.Fatalf("Failed to find rates: %v", err)
+			}
+			if len(all) != 3 {
+				t.Errorf("Expected 3 rates in total, got %d", len(all))
+			}
+
+			latest, err := rateStorage.Latest("USD", "BRL", mustDate(t, "2023-06-20"))
+			if err != nil {
+				t.Fatalf("Failed to get latest rate: %v", err)
+			}
+			if latest.Rate != "4.90" {
+				t.Errorf("Expected the June rate to apply on 2023-06-20, got %s", latest.Rate)
+			}
+
+			latest, err = rateStorage.Latest("USD", "BRL", mustDate(t, "2023-07-01"))
+			if err != nil || latest.Rate != "4.85" {
+				t.Errorf("Expected the July rate to apply from its own date, got %s (%v)", latest.Rate, err)
+			}
+
+			if _, err := rateStorage.Latest("USD", "BRL", mustDate(t, "2023-05-31")); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound before the first rate, got %v", err)
+			}
+			if _, err := rateStorage.Latest("BRL", "USD", mustDate(t, "2023-07-01")); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for an unknown pair, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFileStorageDefaultsLegacyCurrencies(t *testing.T) {
+	dir := t.TempDir()
+	legacy := FileStorage{Filename: filepath.Join(dir, "finances.json")}
+	if err := legacy.Save([]model.Transaction{{ID: 1, UserID: 1, Type: "income", Amount: 100_00}}); err != nil {
+		t.Fatalf("Failed to write legacy file: %v", err)
+	}
+
+	financeStorage, err := NewFileFinanceStorage(filepath.Join(dir, "finances.json"))
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	transaction, err := financeStorage.Get(1)
+	if err != nil {
+		t.Fatalf("Failed to get transaction: %v", err)
+	}
+	if transaction.Currency != model.DefaultCurrency {
+		t.Errorf("Expected legacy transaction in %s, got %q", model.DefaultCurrency, transaction.Currency)
+	}
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"slices"
+	"sync"
+)
+
+// fileCollection keeps the records of a JSON file in memory and rewrites the
+// file after every change. It provides the row-level operations shared by all
+// file-backed storages; id returns a pointer to the record's ID field.
+type fileCollection[T any] struct {
+	file    FileStorage
+	id      func(*T) *int
+	mu      sync.Mutex
+	records []T
+	nextID  int
+}
+
+// newFileCollection loads the file up front. A file that exists but cannot be
+// parsed is an error: starting empty would overwrite it on the next write.
+func newFileCollection[T any](filename string, id func(*T) *int) (*fileCollection[T], error) {
+	c := &fileCollection[T]{
+		file:   *NewFileStorage(filename),
+		id:     id,
+		nextID: 1,
+	}
+	if err := c.file.Load(&c.records); err != nil {
+		return nil, err
+	}
+	for index := range c.records {
+		if id := *c.id(&c.records[index]); id >= c.nextID {
+			c.nextID = id + 1
+		}
+	}
+	return c, nil
+}
+
+func (c *fileCollection[T]) indexOf(id int) int {
+	for index := range c.records {
+		if *c.id(&c.records[index]) == id {
+			return index
+		}
+	}
+	return -1
+}
+
+// save persists the current records, restoring previous on failure so the
+// in-memory view never diverges from the file
+func (c *fileCollection[T]) save(previous []T) error {
+	if err := c.file.Save(c.records); err != nil {
+		c.records = previous
+		return err
+	}
+	return nil
+}
+
+func (c *fileCollection[T]) insert(record T) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	*c.id(&record) = c.nextID
+	previous := c.records
+	c.records = append(c.records[:len(c.records):len(c.records)], record)
+	if err := c.save(previous); err != nil {
+		var zero T
+		return zero, err
+	}
+	c.nextID++
+	return record, nil
+}
+
+// insertMany assigns IDs to every record, lets link adjust them now that the
+// IDs are known, and writes them all at once
+func (c *fileCollection[T]) insertMany(records []T, link func(records []T)) ([]T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records = append([]T(nil), records...)
+	for index := range records {
+		*c.id(&records[index]) = c.nextID + index
+	}
+	if link != nil {
+		link(records)
+	}
+	previous := c.records
+	c.records = append(c.records[:len(c.records):len(c.records)], records...)
+	if err := c.save(previous); err != nil {
+		return nil, err
+	}
+	c.nextID += len(records)
+	return records, nil
+}
+
+func (c *fileCollection[T]) update(record T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index := c.indexOf(*c.id(&record))
+	if index < 0 {
+		return ErrNotFound
+	}
+	previous := c.records
+	c.records = append([]T(nil), c.records...)
+	c.records[index] = record
+	return c.save(previous)
+}
+
+// updateMany replaces several records at once; nothing changes unless all exist
+func (c *fileCollection[T]) updateMany(records []T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.records
+	c.records = append([]T(nil), c.records...)
+	for _, record := range records {
+		index := c.indexOf(*c.id(&record))
+		if index < 0 {
+			c.records = previous
+			return ErrNotFound
+		}
+		c.records[index] = record
+	}
+	return c.save(previous)
+}
+
+func (c *fileCollection[T]) delete(id int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index := c.indexOf(id)
+	if index < 0 {
+		return ErrNotFound
+	}
+	previous := c.records
+	c.records = append(append([]T(nil), c.records[:index]...), c.records[index+1:]...)
+	return c.save(previous)
+}
+
+// deleteMany removes several records at once; nothing changes unless all exist
+func (c *fileCollection[T]) deleteMany(ids []int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range ids {
+		if c.indexOf(id) < 0 {
+			return ErrNotFound
+		}
+	}
+	previous := c.records
+	c.records = slices.DeleteFunc(append([]T(nil), c.records...), func(record T) bool {
+		return slices.Contains(ids, *c.id(&record))
+	})
+	return c.save(previous)
+}
+
+func (c *fileCollection[T]) get(id int) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero T
+	index := c.indexOf(id)
+	if index < 0 {
+		return zero, ErrNotFound
+	}
+	return c.records[index], nil
+}
+
+// upsert replaces every existing record that same matches with the new one,
+// keeping its ID, and inserts the rest. All records are written at once.
+func (c *fileCollection[T]) upsert(records []T, same func(a, b T) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.records
+	c.records = append([]T(nil), c.records...)
+	nextID := c.nextID
+	for _, record := range records {
+		index := slices.IndexFunc(c.records, func(existing T) bool { return same(existing, record) })
+		if index >= 0 {
+			*c.id(&record) = *c.id(&c.records[index])
+			c.records[index] = record
+			continue
+		}
+		*c.id(&record) = nextID
+		nextID++
+		c.records = append(c.records, record)
+	}
+	if err := c.save(previous); err != nil {
+		return err
+	}
+	c.nextID = nextID
+	return nil
+}
+
+func (c *fileCollection[T]) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.records)
+}
+
+// find returns a copy of every record accepted by match
+func (c *fileCollection[T]) find(match func(T) bool) ([]T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var result []T
+	for _, record := range c.records {
+		if match(record) {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
@@ -1,30 +1,115 @@
 package storage
 
 import (
+	"cmp"
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"slices"
+	"time"
 )
 
+// FinanceStorage persists transactions one record at a time. Insert assigns
+// the ID; Update, Delete and Get return ErrNotFound for unknown IDs.
+// InsertPair stores two transactions linked to each other through LinkedID,
+// InsertMany stores a batch, and UpdateMany and DeleteMany change several
+// transactions; each of them applies every change or none.
+// FindPageByUser reads transactions a page at a time in date then ID order,
+// continuing after the last transaction of the previous page.
 type FinanceStorage interface {
-	Save([]model.Transaction) error
-	Load() ([]model.Transaction, error)
+	Insert(transaction model.Transaction) (model.Transaction, error)
+	InsertPair(first, second model.Transaction) (model.Transaction, model.Transaction, error)
+	InsertMany(transactions []model.Transaction) ([]model.Transaction, error)
+	Update(transaction model.Transaction) error
+	UpdateMany(transactions []model.Transaction) error
+	Delete(id int) error
+	DeleteMany(ids []int) error
+	Get(id int) (model.Transaction, error)
+	FindByUser(userID int, filter model.TransactionFilter) ([]model.Transaction, error)
+	FindPageByUser(userID int, filter model.TransactionFilter, after model.Transaction, limit int) ([]model.Transaction, error)
+	Count() (int, error)
 }
 
 type FileFinanceStorage struct {
-	FileStorage
+	transactions *fileCollection[model.Transaction]
 }
 
-func NewFileFinanceStorage(filename string) *FileFinanceStorage {
-	return &FileFinanceStorage{
-		FileStorage: *NewFileStorage(filename),
+func NewFileFinanceStorage(filename string) (*FileFinanceStorage, error) {
+	transactions, err := newFileCollection(filename, func(transaction *model.Transaction) *int { return &transaction.ID })
+	if err != nil {
+		return nil, err
 	}
+	// Transactions written before currencies were recorded are in the default currency
+	for index := range transactions.records {
+		if transactions.records[index].Currency == "" {
+			transactions.records[index].Currency = model.DefaultCurrency
+		}
+	}
+	return &FileFinanceStorage{transactions: transactions}, nil
+}
+
+func (f FileFinanceStorage) Insert(transaction model.Transaction) (model.Transaction, error) {
+	return f.transactions.insert(transaction)
+}
+
+func (f FileFinanceStorage) InsertPair(first, second model.Transaction) (model.Transaction, model.Transaction, error) {
+	pair, err := f.transactions.insertMany([]model.Transaction{first, second}, func(pair []model.Transaction) {
+		pair[0].LinkedID, pair[1].LinkedID = pair[1].ID, pair[0].ID
+	})
+	if err != nil {
+		return model.Transaction{}, model.Transaction{}, err
+	}
+	return pair[0], pair[1], nil
+}
+
+func (f FileFinanceStorage) InsertMany(transactions []model.Transaction) ([]model.Transaction, error) {
+	return f.transactions.insertMany(transactions, nil)
+}
+
+func (f FileFinanceStorage) Update(transaction model.Transaction) error {
+	return f.transactions.update(transaction)
+}
+
+func (f FileFinanceStorage) UpdateMany(transactions []model.Transaction) error {
+	return f.transactions.updateMany(transactions)
 }
 
-func (f FileFinanceStorage) Save(transactions []model.Transaction) error {
-	return f.FileStorage.Save(transactions)
+func (f FileFinanceStorage) Delete(id int) error {
+	return f.transactions.delete(id)
+}
+
+func (f FileFinanceStorage) DeleteMany(ids []int) error {
+	return f.transactions.deleteMany(ids)
+}
+
+func (f FileFinanceStorage) Get(id int) (model.Transaction, error) {
+	return f.transactions.get(id)
+}
+
+// Count returns the number of transactions of every user
+func (f FileFinanceStorage) Count() (int, error) {
+	return f.transactions.count(), nil
+}
+
+func (f FileFinanceStorage) FindByUser(userID int, filter model.TransactionFilter) ([]model.Transaction, error) {
+	return f.transactions.find(func(transaction model.Transaction) bool {
+		return transaction.UserID == userID && filter.Matches(transaction)
+	})
+}
+
+// FindPageByUser returns up to limit transactions of the user matching filter
+// that come after the transaction after in date then ID order; a zero after
+// starts from the first one
+func (f FileFinanceStorage) FindPageByUser(userID int, filter model.TransactionFilter, after model.Transaction, limit int) ([]model.Transaction, error) {
+	transactions, err := f.transactions.find(func(transaction model.Transaction) bool {
+		return transaction.UserID == userID && filter.Matches(transaction) && compareDateID(transaction, after) > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(transactions, compareDateID)
+	return transactions[:min(limit, len(transactions))], nil
 }
 
-func (f FileFinanceStorage) Load() ([]model.Transaction, error) {
-	var transactions []model.Transaction
-	err := f.FileStorage.Load(&transactions)
-	return transactions, err
+// compareDateID orders transactions by date, then by ID
+func compareDateID(a, b model.Transaction) int {
+	return cmp.Or(time.Time(a.Date).Compare(time.Time(b.Date)), cmp.Compare(a.ID, b.ID))
 }
@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestFinanceStorageRowOperations(t *testing.T) {
+	for name, financeStorage := range backends[FinanceStorage](t, NewFileFinanceStorage, NewSQLiteFinanceStorage) {
+		t.Run(name, func(t *testing.T) {
+			date, _ := model.ParseDateOnly("2023-06-15")
+
+			first, err := financeStorage.Insert(model.Transaction{UserID: 1, Type: "income", Amount: 3000_00, Category: "Salary", Date: date, Description: "June salary"})
+			if err != nil {
+				t.Fatalf("Failed to insert transaction: %v", err)
+			}
+			second, err := financeStorage.Insert(model.Transaction{UserID: 1, Type: "expense", Amount: 1000_00, Category: "Rent", Date: date, Description: "June rent", RecurringID: 4, CategoryID: 7, Tags: []string{"home", "fixed"},
+				Splits: []model.Split{{Category: "Rent", Amount: 900_00}, {Category: "Utilities", CategoryID: 8, Amount: 100_00, Note: "Water"}}})
+			if err != nil {
+				t.Fatalf("Failed to insert transaction: %v", err)
+			}
+			if first.ID == 0 || second.ID == first.ID {
+				t.Fatalf("Expected distinct non-zero IDs, got %d and %d", first.ID, second.ID)
+			}
+
+			second.Amount = 1100_00
+			if err := financeStorage.Update(second); err != nil {
+				t.Fatalf("Failed to update transaction: %v", err)
+			}
+
+			loaded, err := financeStorage.Get(second.ID)
+			if err != nil {
+				t.Fatalf("Failed to get transaction: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, second) {
+				t.Errorf("Expected %+v, got %+v", second, loaded)
+			}
+
+			if err := financeStorage.Delete(first.ID); err != nil {
+				t.Fatalf("Failed to delete transaction: %v", err)
+			}
+
+			if _, err := financeStorage.Get(first.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for deleted transaction, got %v", err)
+			}
+			if err := financeStorage.Delete(first.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+			}
+			if err := financeStorage.Update(model.Transaction{ID: 999}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound updating unknown transaction, got %v", err)
+			}
+			if count, err := financeStorage.Count(); err != nil || count != 1 {
+				t.Errorf("Expected one transaction left, got %d (%v)", count, err)
+			}
+		})
+	}
+}
+
+func TestFinanceStorageFindByUser(t *testing.T) {
+	for name, financeStorage := range backends[FinanceStorage](t, NewFileFinanceStorage, NewSQLiteFinanceStorage) {
+		t.Run(name, func(t *testing.T) {
+			for _, transaction := range []model.Transaction{
+				{UserID: 1, Type: "income", Amount: 3000_00, Category: "Salary", Date: mustDate(t, "2023-05-31")},
+				{UserID: 1, Type: "expense", Amount: 1000_00, Category: "Rent", Date: mustDate(t, "2023-06-01"), Tags: []string{"home"}},
+				{UserID: 1, AccountID: 7, Type: "expense", Amount: 200_00, Category: "food", Date: mustDate(t, "2023-06-15"), Tags: []string{"trip-2023", "reimbursable"}},
+				{UserID: 1, AccountID: 7, Type: "expense", Amount: 50_00, Category: "Food", Date: mustDate(t, "2023-07-01")},
+				{UserID: 1, Type: "expense", Amount: 100_00, Category: "Shopping", Date: mustDate(t, "2023-06-20"),
+					Splits: []model.Split{{Category: "FOOD", Amount: 60_00}, {Category: "Household", Amount: 40_00}}},
+				{UserID: 2, Type: "expense", Amount: 80_00, Category: "Food", Date: mustDate(t, "2023-06-10")},
+			} {
+				if _, err := financeStorage.Insert(transaction); err != nil {
+					t.Fatalf("Failed to insert transaction: %v", err)
+				}
+			}
+
+			tests := []struct {
+				name     string
+				filter   model.TransactionFilter
+				expected int
+			}{
+				{"no filter", model.TransactionFilter{}, 5},
+				{"date range", model.TransactionFilter{From: mustDate(t, "2023-06-01"), To: mustDate(t, "2023-06-30")}, 3},
+				{"type", model.TransactionFilter{Type: "expense"}, 4},
+				{"category ignores case", model.TransactionFilter{Category: "food"}, 3},
+				{"category of a split line", model.TransactionFilter{Category: "household"}, 1},
+				{"split transactions ignore their own category", model.TransactionFilter{Category: "Shopping"}, 0},
+				{"account", model.TransactionFilter{AccountID: 7}, 2},
+				{"any tag", model.TransactionFilter{Tags: []string{"home", "Reimbursable"}}, 2},
+				{"all tags", model.TransactionFilter{Tags: []string{"trip-2023", "reimbursable"}, AllTags: true}, 1},
+				{"all tags missing one", model.TransactionFilter{Tags: []string{"home", "reimbursable"}, AllTags: true}, 0},
+			}
+			for _, tt := range tests {
+				result, err := financeStorage.FindByUser(1, tt.filter)
+				if err != nil {
+					t.Fatalf("%s: failed to find transactions: %v", tt.name, err)
+				}
+				if len(result) != tt.expected {
+					t.Errorf("%s: expected %d transactions, got %d", tt.name, tt.expected, len(result))
+				}
+				for _, transaction := range result {
+					if transaction.UserID != 1 {
+						t.Errorf("%s: got transaction of user %d", tt.name, transaction.UserID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFinanceStorageFiltersFoldUnicodeCase(t *testing.T) {
+	for name, financeStorage := range backends[FinanceStorage](t, NewFileFinanceStorage, NewSQLiteFinanceStorage) {
+		t.Run(name, func(t *testing.T) {
+			for _, transaction := range []model.Transaction{
+				{UserID: 1, Type: "expense", Amount: 10_00, Category: "Café", Date: mustDate(t, "2023-06-01"), Tags: []string{"été"}},
+				{UserID: 1, Type: "expense", Amount: 20_00, Category: "Lunch", Date: mustDate(t, "2023-06-02"),
+					Splits: []model.Split{{Category: "ÇA", Amount: 20_00}}},
+				{UserID: 1, Type: "expense", Amount: 30_00, Category: "Cafe", Date: mustDate(t, "2023-06-03"), Tags: []string{"ete"}},
+			} {
+				if _, err := financeStorage.Insert(transaction); err != nil {
+					t.Fatalf("Failed to insert transaction: %v", err)
+				}
+			}
+
+			// The same matches as strings.EqualFold, beyond ASCII letters
+			tests := []struct {
+				name     string
+				filter   model.TransactionFilter
+				expected int
+			}{
+				{"category", model.TransactionFilter{Category: "CAFÉ"}, 1},
+				{"category of a split line", model.TransactionFilter{Category: "ça"}, 1},
+				{"tag", model.TransactionFilter{Tags: []string{"ÉTÉ"}}, 1},
+			}
+			for _, tt := range tests {
+				result, err := financeStorage.FindByUser(1, tt.filter)
+				if err != nil {
+					t.Fatalf("%s: failed to find transactions: %v", tt.name, err)
+				}
+				if len(result) != tt.expected {
+					t.Errorf("%s: expected %d transactions, got %+v", tt.name, tt.expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestFinanceStorageFindPageByUser(t *testing.T) {
+	for name, financeStorage := range backends[FinanceStorage](t, NewFileFinanceStorage, NewSQLiteFinanceStorage) {
+		t.Run(name, func(t *testing.T) {
+			for _, transaction := range []model.Transaction{
+				{UserID: 1, Type: "expense", Amount: 10_00, Date: mustDate(t, "2023-06-15")},
+				{UserID: 1, Type: "expense", Amount: 20_00, Date: mustDate(t, "2023-06-01")},
+				{UserID: 2, Type: "expense", Amount: 30_00, Date: mustDate(t, "2023-06-05")},
+				{UserID: 1, Type: "income", Amount: 40_00, Date: mustDate(t, "2023-06-15")},
+				{UserID: 1, Type: "expense", Amount: 50_00, Date: mustDate(t, "2023-06-10")},
+			} {
+				if _, err := financeStorage.Insert(transaction); err != nil {
+					t.Fatalf("Failed to insert transaction: %v", err)
+				}
+			}
+
+			// Pages follow each other by date, then by ID
+			var ids []int
+			var after model.Transaction
+			for pages := 0; ; pages++ {
+				page, err := financeStorage.FindPageByUser(1, model.TransactionFilter{}, after, 2)
+				if err != nil {
+					t.Fatalf("Failed to find transactions: %v", err)
+				}
+				if len(page) == 0 {
+					if pages != 2 {
+						t.Errorf("Expected 2 full pages, got %d", pages)
+					}
+					break
+				}
+				for _, transaction := range page {
+					ids = append(ids, transaction.ID)
+				}
+				after = page[len(page)-1]
+			}
+			if expected := []int{2, 5, 1, 4}; !reflect.DeepEqual(ids, expected) {
+				t.Errorf("Expected transactions %v, got %v", expected, ids)
+			}
+
+			page, err := financeStorage.FindPageByUser(1, model.TransactionFilter{Type: "expense"}, model.Transaction{}, 10)
+			if err != nil || len(page) != 3 {
+				t.Errorf("Expected the 3 expenses, got %+v (%v)", page, err)
+			}
+		})
+	}
+}
+
+func TestFileFinanceStoragePersistsAcrossInstances(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "finances.json")
+
+	original, err := NewFileFinanceStorage(filename)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	first, err := original.Insert(model.Transaction{UserID: 1, Type: "income", Amount: 10_00})
+	if err != nil {
+		t.Fatalf("Failed to insert transaction: %v", err)
+	}
+
+	reopened, err := NewFileFinanceStorage(filename)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	second, err := reopened.Insert(model.Transaction{UserID: 1, Type: "income", Amount: 20_00})
+	if err != nil {
+		t.Fatalf("Failed to insert transaction: %v", err)
+	}
+	if second.ID != first.ID+1 {
+		t.Errorf("Expected ID %d after reopening, got %d", first.ID+1, second.ID)
+	}
+
+	loaded, err := reopened.Get(first.ID)
+	if err != nil || loaded.Amount != 10_00 {
+		t.Errorf("Expected first transaction to be persisted, got %+v (%v)", loaded, err)
+	}
+}
+
+func TestFileFinanceStorageRefusesCorruptFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "finances.json")
+	if err := os.WriteFile(filename, []byte(`[{"id": 1, "amount": 10`), 0644); err != nil {
+		t.Fatalf("Failed to write corrupt file: %v", err)
+	}
+
+	if _, err := NewFileFinanceStorage(filename); err == nil {
+		t.Fatal("Expected an error when the transactions file cannot be parsed")
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil || string(content) != `[{"id": 1, "amount": 10` {
+		t.Error("Corrupt file should have been left untouched")
+	}
+}
+
+func mustDate(t *testing.T, value string) model.DateOnly {
+	t.Helper()
+	date, err := model.ParseDateOnly(value)
+	if err != nil {
+		t.Fatalf("Failed to parse date %q: %v", value, err)
+	}
+	return date
+}
+
+func TestFinanceStorageLinkedPairs(t *testing.T) {
+	for name, financeStorage := range backends[FinanceStorage](t, NewFileFinanceStorage, NewSQLiteFinanceStorage) {
+		t.Run(name, func(t *testing.T) {
+			date := mustDate(t, "2023-06-15")
+			source, destination, err := financeStorage.InsertPair(
+				model.Transaction{UserID: 1, AccountID: 1, Type: "transfer", Amount: 100_00, Date: date, TransferRole: "source"},
+				model.Transaction{UserID: 1, AccountID: 2, Type: "transfer", Amount: 100_00, Date: date, TransferRole: "destination"},
+			)
+			if err != nil {
+				t.Fatalf("Failed to insert pair: %v", err)
+			}
+			if source.ID == 0 || destination.ID == 0 || source.LinkedID != destination.ID || destination.LinkedID != source.ID {
+				t.Fatalf("Expected the halves to point at each other, got %+v and %+v", source, destination)
+			}
+			loaded, err := financeStorage.Get(source.ID)
+			if err != nil || !reflect.DeepEqual(loaded, source) {
+				t.Errorf("Expected stored source %+v, got %+v (%v)", source, loaded, err)
+			}
+
+			source.Amount, destination.Amount = 150_00, 150_00
+			if err := financeStorage.UpdateMany([]model.Transaction{source, destination}); err != nil {
+				t.Fatalf("Failed to update pair: %v", err)
+			}
+
+			// A missing record rolls back the whole batch
+			missing := model.Transaction{ID: 999, UserID: 1, Type: "income", Date: date}
+			source.Amount = 1_00
+			if err := financeStorage.UpdateMany([]model.Transaction{source, missing}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for a missing record, got %v", err)
+			}
+			if loaded, _ := financeStorage.Get(source.ID); loaded.Amount != 150_00 {
+				t.Errorf("Expected the failed batch to leave the amount at 150.00, got %s", loaded.Amount)
+			}
+			if err := financeStorage.DeleteMany([]int{source.ID, 999}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for a missing record, got %v", err)
+			}
+			if _, err := financeStorage.Get(source.ID); err != nil {
+				t.Errorf("Expected the failed delete to keep the source, got %v", err)
+			}
+
+			if err := financeStorage.DeleteMany([]int{source.ID, destination.ID}); err != nil {
+				t.Fatalf("Failed to delete pair: %v", err)
+			}
+			remaining, err := financeStorage.FindByUser(1, model.TransactionFilter{})
+			if err != nil || len(remaining) != 0 {
+				t.Errorf("Expected no transactions left, got %+v (%v)", remaining, err)
+			}
+		})
+	}
+}
+
+func TestFinanceStorageInsertMany(t *testing.T) {
+	for name, financeStorage := range backends[FinanceStorage](t, NewFileFinanceStorage, NewSQLiteFinanceStorage) {
+		t.Run(name, func(t *testing.T) {
+			date := mustDate(t, "2023-06-15")
+			inserted, err := financeStorage.InsertMany([]model.Transaction{
+				{UserID: 1, Type: "expense", Amount: 12_50, Category: "Food", Date: date, Description: "Bakery"},
+				{UserID: 1, Type: "income", Amount: 40_00, Category: "Refunds", Date: date, Tags: []string{"imported"}, ExternalID: "20230615001"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to insert batch: %v", err)
+			}
+			if len(inserted) != 2 || inserted[0].ID == 0 || inserted[1].ID == inserted[0].ID {
+				t.Fatalf("Expected two transactions with distinct IDs, got %+v", inserted)
+			}
+			for _, transaction := range inserted {
+				loaded, err := financeStorage.Get(transaction.ID)
+				if err != nil || !reflect.DeepEqual(loaded, transaction) {
+					t.Errorf("Expected stored transaction %+v, got %+v (%v)", transaction, loaded, err)
+				}
+			}
+
+			next, err := financeStorage.Insert(model.Transaction{UserID: 1, Type: "income", Amount: 1_00, Date: date})
+			if err != nil || next.ID <= inserted[1].ID {
+				t.Errorf("Expected IDs to continue after the batch, got %+v (%v)", next, err)
+			}
+		})
+	}
+}
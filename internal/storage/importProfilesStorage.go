@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// ImportProfileStorage persists import profiles one record at a time. Insert
+// assigns the ID; Update, Delete and Get return ErrNotFound for unknown IDs.
+type ImportProfileStorage interface {
+	Insert(profile model.ImportProfile) (model.ImportProfile, error)
+	Update(profile model.ImportProfile) error
+	Delete(id int) error
+	Get(id int) (model.ImportProfile, error)
+	FindByUser(userID int) ([]model.ImportProfile, error)
+}
+
+type FileImportProfileStorage struct {
+	profiles *fileCollection[model.ImportProfile]
+}
+
+func NewFileImportProfileStorage(filename string) (*FileImportProfileStorage, error) {
+	profiles, err := newFileCollection(filename, func(profile *model.ImportProfile) *int { return &profile.ID })
+	if err != nil {
+		return nil, err
+	}
+	return &FileImportProfileStorage{profiles: profiles}, nil
+}
+
+func (f FileImportProfileStorage) Insert(profile model.ImportProfile) (model.ImportProfile, error) {
+	return f.profiles.insert(profile)
+}
+
+func (f FileImportProfileStorage) Update(profile model.ImportProfile) error {
+	return f.profiles.update(profile)
+}
+
+func (f FileImportProfileStorage) Delete(id int) error {
+	return f.profiles.delete(id)
+}
+
+func (f FileImportProfileStorage) Get(id int) (model.ImportProfile, error) {
+	return f.profiles.get(id)
+}
+
+func (f FileImportProfileStorage) FindByUser(userID int) ([]model.ImportProfile, error) {
+	return f.profiles.find(func(profile model.ImportProfile) bool { return profile.UserID == userID })
+}
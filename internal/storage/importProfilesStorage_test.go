@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestImportProfileStorageRowOperations(t *testing.T) {
+	for name, profileStorage := range backends[ImportProfileStorage](t, NewFileImportProfileStorage, NewSQLiteImportProfileStorage) {
+		t.Run(name, func(t *testing.T) {
+			bank, err := profileStorage.Insert(model.ImportProfile{UserID: 1, Name: "Bank", Delimiter: ";", SkipRows: 2,
+				DateColumn: "Data", AmountColumn: "Valor", DescriptionColumn: "Histórico", DateFormat: "dd/mm/yyyy",
+				DecimalSeparator: ",", SignConvention: "negative_expense", AccountID: 3})
+			if err != nil {
+				t.Fatalf("Failed to insert profile: %v", err)
+			}
+			card, err := profileStorage.Insert(model.ImportProfile{UserID: 1, Name: "Card", Delimiter: ",",
+				DateColumn: "date", AmountColumn: "amount", CategoryColumn: "category", DateFormat: "mm/dd/yyyy",
+				DecimalSeparator: ".", SignConvention: "positive_expense"})
+			if err != nil {
+				t.Fatalf("Failed to insert profile: %v", err)
+			}
+			if _, err := profileStorage.Insert(model.ImportProfile{UserID: 2, Name: "Other"}); err != nil {
+				t.Fatalf("Failed to insert profile: %v", err)
+			}
+
+			card.SkipRows, card.DescriptionColumn = 1, "merchant"
+			if err := profileStorage.Update(card); err != nil {
+				t.Fatalf("Failed to update profile: %v", err)
+			}
+			loaded, err := profileStorage.Get(card.ID)
+			if err != nil {
+				t.Fatalf("Failed to get profile: %v", err)
+			}
+			if loaded != card {
+				t.Errorf("Expected %+v, got %+v", card, loaded)
+			}
+
+			profiles, err := profileStorage.FindByUser(1)
+			if err != nil {
+				t.Fatalf("Failed to find profiles: %v", err)
+			}
+			if len(profiles) != 2 || profiles[0] != bank {
+				t.Errorf("Expected the two profiles of user 1, got %+v", profiles)
+			}
+
+			if err := profileStorage.Delete(bank.ID); err != nil {
+				t.Fatalf("Failed to delete profile: %v", err)
+			}
+			if _, err := profileStorage.Get(bank.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for deleted profile, got %v", err)
+			}
+			if err := profileStorage.Update(model.ImportProfile{ID: 999}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound updating unknown profile, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+// RecurringStorage persists recurring rules one record at a time. Insert
+// assigns the ID; Update, Delete and Get return ErrNotFound for unknown IDs.
+// List returns the rules of every user for the scheduler.
+type RecurringStorage interface {
+	Insert(rule model.RecurringRule) (model.RecurringRule, error)
+	Update(rule model.RecurringRule) error
+	Delete(id int) error
+	Get(id int) (model.RecurringRule, error)
+	FindByUser(userID int) ([]model.RecurringRule, error)
+	List() ([]model.RecurringRule, error)
+}
+
+type FileRecurringStorage struct {
+	rules *fileCollection[model.RecurringRule]
+}
+
+func NewFileRecurringStorage(filename string) (*FileRecurringStorage, error) {
+	rules, err := newFileCollection(filename, func(rule *model.RecurringRule) *int { return &rule.ID })
+	if err != nil {
+		return nil, err
+	}
+	return &FileRecurringStorage{rules: rules}, nil
+}
+
+func (f FileRecurringStorage) Insert(rule model.RecurringRule) (model.RecurringRule, error) {
+	return f.rules.insert(rule)
+}
+
+func (f FileRecurringStorage) Update(rule model.RecurringRule) error {
+	return f.rules.update(rule)
+}
+
+func (f FileRecurringStorage) Delete(id int) error {
+	return f.rules.delete(id)
+}
+
+func (f FileRecurringStorage) Get(id int) (model.RecurringRule, error) {
+	return f.rules.get(id)
+}
+
+func (f FileRecurringStorage) FindByUser(userID int) ([]model.RecurringRule, error) {
+	return f.rules.find(func(rule model.RecurringRule) bool { return rule.UserID == userID })
+}
+
+func (f FileRecurringStorage) List() ([]model.RecurringRule, error) {
+	return f.rules.find(func(model.RecurringRule) bool { return true })
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestRecurringStorageRowOperations(t *testing.T) {
+	for name, recurringStorage := range backends[RecurringStorage](t, NewFileRecurringStorage, NewSQLiteRecurringStorage) {
+		t.Run(name, func(t *testing.T) {
+			endDate := mustDate(t, "2023-12-31")
+			rent, err := recurringStorage.Insert(model.RecurringRule{UserID: 1, Frequency: "monthly", Interval: 1,
+				StartDate: mustDate(t, "2023-01-31"), EndDate: &endDate, Type: "expense", Amount: 1500_00,
+				Currency: "BRL", Category: "Rent", Description: "Monthly rent", AccountID: 3})
+			if err != nil {
+				t.Fatalf("Failed to insert rule: %v", err)
+			}
+			salary, err := recurringStorage.Insert(model.RecurringRule{UserID: 1, Frequency: "monthly", Interval: 1,
+				StartDate: mustDate(t, "2023-01-05"), Count: 12, Type: "income", Amount: 5000_00, Currency: "BRL"})
+			if err != nil {
+				t.Fatalf("Failed to insert rule: %v", err)
+			}
+			if _, err := recurringStorage.Insert(model.RecurringRule{UserID: 2, Frequency: "weekly", Interval: 2,
+				StartDate: mustDate(t, "2023-01-01"), Type: "expense", Amount: 50_00}); err != nil {
+				t.Fatalf("Failed to insert rule: %v", err)
+			}
+
+			lastDate := mustDate(t, "2023-02-28")
+			amount := model.Money(1600_00)
+			note := "Rent with fees"
+			rent.LastDate = &lastDate
+			rent.Overrides = []model.OccurrenceOverride{
+				{Date: mustDate(t, "2023-03-31"), Skip: true},
+				{Date: mustDate(t, "2023-04-30"), Amount: &amount, Description: &note},
+			}
+			if err := recurringStorage.Update(rent); err != nil {
+				t.Fatalf("Failed to update rule: %v", err)
+			}
+			loaded, err := recurringStorage.Get(rent.ID)
+			if err != nil {
+				t.Fatalf("Failed to get rule: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, rent) {
+				t.Errorf("Expected %+v, got %+v", rent, loaded)
+			}
+
+			rules, err := recurringStorage.FindByUser(1)
+			if err != nil {
+				t.Fatalf("Failed to find rules: %v", err)
+			}
+			if len(rules) != 2 || !reflect.DeepEqual(rules[1], salary) {
+				t.Errorf("Expected the two rules of user 1, got %+v", rules)
+			}
+			all, err := recurringStorage.List()
+			if err != nil || len(all) != 3 {
+				t.Errorf("Expected 3 rules in total, got %d (%v)", len(all), err)
+			}
+
+			if err := recurringStorage.Delete(salary.ID); err != nil {
+				t.Fatalf("Failed to delete rule: %v", err)
+			}
+			if _, err := recurringStorage.Get(salary.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for deleted rule, got %v", err)
+			}
+			if err := recurringStorage.Update(model.RecurringRule{ID: 999}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound updating unknown rule, got %v", err)
+			}
+		})
+	}
+}
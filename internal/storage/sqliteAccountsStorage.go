@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+const accountColumns = `id, user_id, name, type, currency, opening_balance, opening_date`
+
+type SQLiteAccountStorage struct {
+	db sqliteTable
+}
+
+func NewSQLiteAccountStorage(db *sql.DB) *SQLiteAccountStorage {
+	return &SQLiteAccountStorage{db: sqliteTable{db: db, table: "accounts"}}
+}
+
+func (s SQLiteAccountStorage) Insert(account model.Account) (model.Account, error) {
+	result, err := s.db.Exec(`INSERT INTO accounts (user_id, name, type, currency, opening_balance, opening_date)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		account.UserID, account.Name, account.Type, account.Currency, account.OpeningBalance, account.OpeningDate.String())
+	if err != nil {
+		return model.Account{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.Account{}, err
+	}
+	account.ID = int(id)
+	return account, nil
+}
+
+func (s SQLiteAccountStorage) Update(account model.Account) error {
+	result, err := s.db.Exec(`UPDATE accounts
+		SET user_id = ?, name = ?, type = ?, currency = ?, opening_balance = ?, opening_date = ?
+		WHERE id = ?`,
+		account.UserID, account.Name, account.Type, account.Currency, account.OpeningBalance,
+		account.OpeningDate.String(), account.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteAccountStorage) Delete(id int) error {
+	result, err := s.db.Exec(`DELETE FROM accounts WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteAccountStorage) Get(id int) (model.Account, error) {
+	row := s.db.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE id = ?`, id)
+	account, err := scanAccount(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Account{}, ErrNotFound
+	}
+	return account, err
+}
+
+func (s SQLiteAccountStorage) FindByUser(userID int) ([]model.Account, error) {
+	rows, err := s.db.Query(`SELECT `+accountColumns+` FROM accounts WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []model.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+func scanAccount(row rowScanner) (model.Account, error) {
+	var account model.Account
+	var openingDate string
+	err := row.Scan(&account.ID, &account.UserID, &account.Name, &account.Type, &account.Currency,
+		&account.OpeningBalance, &openingDate)
+	if err != nil {
+		return model.Account{}, err
+	}
+	account.OpeningDate, err = model.ParseDateOnly(openingDate)
+	return account, err
+}
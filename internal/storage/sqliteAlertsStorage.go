@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+const alertColumns = `id, user_id, kind, category, threshold, last_period, fired_periods`
+
+type SQLiteAlertStorage struct {
+	db sqliteTable
+}
+
+func NewSQLiteAlertStorage(db *sql.DB) *SQLiteAlertStorage {
+	return &SQLiteAlertStorage{db: sqliteTable{db: db, table: "alert_rules"}}
+}
+
+func (s SQLiteAlertStorage) Insert(rule model.AlertRule) (model.AlertRule, error) {
+	fired, err := encodeList(rule.FiredPeriods)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	result, err := s.db.Exec(`INSERT INTO alert_rules (user_id, kind, category, threshold, last_period, fired_periods)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		rule.UserID, rule.Kind, rule.Category, rule.Threshold, rule.LastPeriod, fired)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	rule.ID = int(id)
+	return rule, nil
+}
+
+func (s SQLiteAlertStorage) Update(rule model.AlertRule) error {
+	fired, err := encodeList(rule.FiredPeriods)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(`UPDATE alert_rules
+		SET user_id = ?, kind = ?, category = ?, threshold = ?, last_period = ?, fired_periods = ?
+		WHERE id = ?`,
+		rule.UserID, rule.Kind, rule.Category, rule.Threshold, rule.LastPeriod, fired, rule.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteAlertStorage) Delete(id int) error {
+	result, err := s.db.Exec(`DELETE FROM alert_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteAlertStorage) Get(id int) (model.AlertRule, error) {
+	row := s.db.QueryRow(`SELECT `+alertColumns+` FROM alert_rules WHERE id = ?`, id)
+	rule, err := scanAlertRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.AlertRule{}, ErrNotFound
+	}
+	return rule, err
+}
+
+func (s SQLiteAlertStorage) FindByUser(userID int) ([]model.AlertRule, error) {
+	rows, err := s.db.Query(`SELECT `+alertColumns+` FROM alert_rules WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []model.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func scanAlertRule(row rowScanner) (model.AlertRule, error) {
+	var rule model.AlertRule
+	var fired string
+	err := row.Scan(&rule.ID, &rule.UserID, &rule.Kind, &rule.Category, &rule.Threshold, &rule.LastPeriod, &fired)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	rule.FiredPeriods, err = decodeList[string](fired)
+	return rule, err
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+const budgetColumns = `id, user_id, category, amount_limit, rollover, start_month`
+
+type SQLiteBudgetStorage struct {
+	db sqliteTable
+}
+
+func NewSQLiteBudgetStorage(db *sql.DB) *SQLiteBudgetStorage {
+	return &SQLiteBudgetStorage{db: sqliteTable{db: db, table: "budgets"}}
+}
+
+func (s SQLiteBudgetStorage) Insert(budget model.Budget) (model.Budget, error) {
+	result, err := s.db.Exec(`INSERT INTO budgets (user_id, category, amount_limit, rollover, start_month)
+		VALUES (?, ?, ?, ?, ?)`,
+		budget.UserID, budget.Category, budget.Limit, budget.Rollover, budget.StartMonth)
+	if err != nil {
+		return model.Budget{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.Budget{}, err
+	}
+	budget.ID = int(id)
+	return budget, nil
+}
+
+func (s SQLiteBudgetStorage) Update(budget model.Budget) error {
+	result, err := s.db.Exec(`UPDATE budgets
+		SET user_id = ?, category = ?, amount_limit = ?, rollover = ?, start_month = ?
+		WHERE id = ?`,
+		budget.UserID, budget.Category, budget.Limit, budget.Rollover, budget.StartMonth, budget.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteBudgetStorage) Delete(id int) error {
+	result, err := s.db.Exec(`DELETE FROM budgets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteBudgetStorage) Get(id int) (model.Budget, error) {
+	row := s.db.QueryRow(`SELECT `+budgetColumns+` FROM budgets WHERE id = ?`, id)
+	budget, err := scanBudget(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Budget{}, ErrNotFound
+	}
+	return budget, err
+}
+
+func (s SQLiteBudgetStorage) FindByUser(userID int) ([]model.Budget, error) {
+	rows, err := s.db.Query(`SELECT `+budgetColumns+` FROM budgets WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []model.Budget
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, rows.Err()
+}
+
+func scanBudget(row rowScanner) (model.Budget, error) {
+	var budget model.Budget
+	err := row.Scan(&budget.ID, &budget.UserID, &budget.Category, &budget.Limit, &budget.Rollover, &budget.StartMonth)
+	return budget, err
+}
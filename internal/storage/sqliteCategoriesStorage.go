@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+const categoryColumns = `id, user_id, name, type, parent_id, color, icon`
+
+type SQLiteCategoryStorage struct {
+	db sqliteTable
+}
+
+func NewSQLiteCategoryStorage(db *sql.DB) *SQLiteCategoryStorage {
+	return &SQLiteCategoryStorage{db: sqliteTable{db: db, table: "categories"}}
+}
+
+func (s SQLiteCategoryStorage) Insert(category model.Category) (model.Category, error) {
+	result, err := s.db.Exec(`INSERT INTO categories (user_id, name, type, parent_id, color, icon)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		category.UserID, category.Name, category.Type, category.ParentID, category.Color, category.Icon)
+	if err != nil {
+		return model.Category{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.Category{}, err
+	}
+	category.ID = int(id)
+	return category, nil
+}
+
+func (s SQLiteCategoryStorage) Update(category model.Category) error {
+	result, err := s.db.Exec(`UPDATE categories
+		SET user_id = ?, name = ?, type = ?, parent_id = ?, color = ?, icon = ?
+		WHERE id = ?`,
+		category.UserID, category.Name, category.Type, category.ParentID, category.Color, category.Icon, category.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteCategoryStorage) Delete(id int) error {
+	result, err := s.db.Exec(`DELETE FROM categories WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteCategoryStorage) Get(id int) (model.Category, error) {
+	row := s.db.QueryRow(`SELECT `+categoryColumns+` FROM categories WHERE id = ?`, id)
+	category, err := scanCategory(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Category{}, ErrNotFound
+	}
+	return category, err
+}
+
+func (s SQLiteCategoryStorage) FindByUser(userID int) ([]model.Category, error) {
+	rows, err := s.db.Query(`SELECT `+categoryColumns+` FROM categories WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []model.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func scanCategory(row rowScanner) (model.Category, error) {
+	var category model.Category
+	err := row.Scan(&category.ID, &category.UserID, &category.Name, &category.Type, &category.ParentID,
+		&category.Color, &category.Icon)
+	return category, err
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+const exchangeRateColumns = `id, date, from_currency, to_currency, rate`
+
+type SQLiteExchangeRateStorage struct {
+	db sqliteTable
+}
+
+func NewSQLiteExchangeRateStorage(db *sql.DB) *SQLiteExchangeRateStorage {
+	return &SQLiteExchangeRateStorage{db: sqliteTable{db: db, table: "exchange_rates"}}
+}
+
+func (s SQLiteExchangeRateStorage) Upsert(rates []model.ExchangeRate) error {
+	return s.db.inTx(func(tx *sql.Tx) error {
+		for _, rate := range rates {
+			_, err := tx.Exec(`INSERT INTO exchange_rates (date, from_currency, to_currency, rate) VALUES (?, ?, ?, ?)
+				ON CONFLICT (date, from_currency, to_currency) DO UPDATE SET rate = excluded.rate`,
+				rate.Date.String(), rate.From, rate.To, rate.Rate)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s SQLiteExchangeRateStorage) Find(from, to string) ([]model.ExchangeRate, error) {
+	rows, err := s.db.Query(`SELECT `+exchangeRateColumns+` FROM exchange_rates
+		WHERE (? = '' OR from_currency = ?) AND (? = '' OR to_currency = ?)
+		ORDER BY date, from_currency, to_currency`, from, from, to, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []model.ExchangeRate
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+func (s SQLiteExchangeRateStorage) Latest(from, to string, date model.DateOnly) (model.ExchangeRate, error) {
+	row := s.db.QueryRow(`SELECT `+exchangeRateColumns+` FROM exchange_rates
+		WHERE from_currency = ? AND to_currency = ? AND date <= ?
+		ORDER BY date DESC LIMIT 1`, from, to, date.String())
+	rate, err := scanExchangeRate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ExchangeRate{}, ErrNotFound
+	}
+	return rate, err
+}
+
+func scanExchangeRate(row rowScanner) (model.ExchangeRate, error) {
+	var rate model.ExchangeRate
+	var date string
+	err := row.Scan(&rate.ID, &date, &rate.From, &rate.To, &rate.Rate)
+	if err != nil {
+		return model.ExchangeRate{}, err
+	}
+	rate.Date, err = model.ParseDateOnly(date)
+	return rate, err
+}
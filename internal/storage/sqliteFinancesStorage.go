@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+	"strings"
+)
+
+const transactionColumns = `id, user_id, account_id, type, amount, currency, category, date, description, linked_id, transfer_role, recurring_id, category_id, tags, splits, external_id`
+
+type SQLiteFinanceStorage struct {
+	db sqliteTable
+}
+
+func NewSQLiteFinanceStorage(db *sql.DB) *SQLiteFinanceStorage {
+	return &SQLiteFinanceStorage{db: sqliteTable{db: db, table: "transactions"}}
+}
+
+// execer is satisfied by both sqliteTable and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// encodeList turns tags or split lines into the JSON array kept in their
+// column; nil becomes an empty array
+func encodeList[T any](list []T) (string, error) {
+	if list == nil {
+		return "[]", nil
+	}
+	encoded, err := json.Marshal(list)
+	return string(encoded), err
+}
+
+// decodeList reads a JSON array written by encodeList; an empty array
+// becomes nil
+func decodeList[T any](encoded string) ([]T, error) {
+	var list []T
+	if err := json.Unmarshal([]byte(encoded), &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list, nil
+}
+
+func insertTransaction(db execer, transaction model.Transaction) (model.Transaction, error) {
+	tags, err := encodeList(transaction.Tags)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	splits, err := encodeList(transaction.Splits)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	result, err := db.Exec(`INSERT INTO transactions
+		(user_id, account_id, type, amount, currency, category, date, description, linked_id, transfer_role, recurring_id,
+			category_id, tags, splits, external_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		transaction.UserID, transaction.AccountID, transaction.Type, transaction.Amount, transaction.Currency,
+		transaction.Category, transaction.Date.String(), transaction.Description, transaction.LinkedID, transaction.TransferRole,
+		transaction.RecurringID, transaction.CategoryID, tags, splits, transaction.ExternalID)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	transaction.ID = int(id)
+	return transaction, nil
+}
+
+func updateTransaction(db execer, transaction model.Transaction) error {
+	tags, err := encodeList(transaction.Tags)
+	if err != nil {
+		return err
+	}
+	splits, err := encodeList(transaction.Splits)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(`UPDATE transactions
+		SET user_id = ?, account_id = ?, type = ?, amount = ?, currency = ?, category = ?, date = ?,
+			description = ?, linked_id = ?, transfer_role = ?, recurring_id = ?, category_id = ?, tags = ?, splits = ?,
+			external_id = ?
+		WHERE id = ?`,
+		transaction.UserID, transaction.AccountID, transaction.Type, transaction.Amount, transaction.Currency,
+		transaction.Category, transaction.Date.String(), transaction.Description, transaction.LinkedID,
+		transaction.TransferRole, transaction.RecurringID, transaction.CategoryID, tags, splits, transaction.ExternalID,
+		transaction.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func deleteTransaction(db execer, id int) error {
+	result, err := db.Exec(`DELETE FROM transactions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteFinanceStorage) Insert(transaction model.Transaction) (model.Transaction, error) {
+	return insertTransaction(s.db, transaction)
+}
+
+func (s SQLiteFinanceStorage) InsertPair(first, second model.Transaction) (model.Transaction, model.Transaction, error) {
+	err := s.db.inTx(func(tx *sql.Tx) error {
+		var err error
+		if first, err = insertTransaction(tx, first); err != nil {
+			return err
+		}
+		second.LinkedID = first.ID
+		if second, err = insertTransaction(tx, second); err != nil {
+			return err
+		}
+		first.LinkedID = second.ID
+		return updateTransaction(tx, first)
+	})
+	if err != nil {
+		return model.Transaction{}, model.Transaction{}, err
+	}
+	return first, second, nil
+}
+
+func (s SQLiteFinanceStorage) InsertMany(transactions []model.Transaction) ([]model.Transaction, error) {
+	inserted := make([]model.Transaction, len(transactions))
+	err := s.db.inTx(func(tx *sql.Tx) error {
+		for index, transaction := range transactions {
+			var err error
+			if inserted[index], err = insertTransaction(tx, transaction); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+func (s SQLiteFinanceStorage) Update(transaction model.Transaction) error {
+	return updateTransaction(s.db, transaction)
+}
+
+func (s SQLiteFinanceStorage) UpdateMany(transactions []model.Transaction) error {
+	return s.db.inTx(func(tx *sql.Tx) error {
+		for _, transaction := range transactions {
+			if err := updateTransaction(tx, transaction); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s SQLiteFinanceStorage) Delete(id int) error {
+	return deleteTransaction(s.db, id)
+}
+
+func (s SQLiteFinanceStorage) DeleteMany(ids []int) error {
+	return s.db.inTx(func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if err := deleteTransaction(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s SQLiteFinanceStorage) Get(id int) (model.Transaction, error) {
+	row := s.db.QueryRow(`SELECT `+transactionColumns+` FROM transactions WHERE id = ?`, id)
+	transaction, err := scanTransaction(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Transaction{}, ErrNotFound
+	}
+	return transaction, err
+}
+
+// Count returns the number of transactions of every user
+func (s SQLiteFinanceStorage) Count() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM transactions`).Scan(&count)
+	return count, err
+}
+
+func (s SQLiteFinanceStorage) FindByUser(userID int, filter model.TransactionFilter) ([]model.Transaction, error) {
+	conditions, args := transactionConditions(userID, filter)
+	return s.queryTransactions(`SELECT `+transactionColumns+` FROM transactions WHERE `+
+		strings.Join(conditions, " AND ")+` ORDER BY id`, args...)
+}
+
+// FindPageByUser returns up to limit transactions of the user matching filter
+// that come after the transaction after in date then ID order; a zero after
+// starts from the first one
+func (s SQLiteFinanceStorage) FindPageByUser(userID int, filter model.TransactionFilter, after model.Transaction, limit int) ([]model.Transaction, error) {
+	conditions, args := transactionConditions(userID, filter)
+	conditions = append(conditions, "(date > ? OR (date = ? AND id > ?))")
+	args = append(args, after.Date.String(), after.Date.String(), after.ID, limit)
+	return s.queryTransactions(`SELECT `+transactionColumns+` FROM transactions WHERE `+
+		strings.Join(conditions, " AND ")+` ORDER BY date, id LIMIT ?`, args...)
+}
+
+// transactionConditions turns a filter into the conditions of a WHERE clause
+// on the user's transactions and their arguments
+func transactionConditions(userID int, filter model.TransactionFilter) ([]string, []interface{}) {
+	conditions := []string{"user_id = ?"}
+	args := []interface{}{userID}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "date >= ?")
+		args = append(args, filter.From.String())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "date <= ?")
+		args = append(args, filter.To.String())
+	}
+	if filter.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, filter.Type)
+	}
+	if filter.Category != "" {
+		// Split transactions are in the categories of their lines
+		conditions = append(conditions, `CASE WHEN splits = '[]' THEN category = ? COLLATE `+foldCollation+`
+			ELSE EXISTS (SELECT 1 FROM json_each(splits) WHERE json_extract(value, '$.category') = ? COLLATE `+foldCollation+`) END`)
+		args = append(args, filter.Category, filter.Category)
+	}
+	if filter.AccountID != 0 {
+		conditions = append(conditions, "account_id = ?")
+		args = append(args, filter.AccountID)
+	}
+	if len(filter.Tags) > 0 {
+		var tagConditions []string
+		for _, tag := range filter.Tags {
+			tagConditions = append(tagConditions, "EXISTS (SELECT 1 FROM json_each(tags) WHERE value = ? COLLATE "+foldCollation+")")
+			args = append(args, tag)
+		}
+		if filter.AllTags {
+			conditions = append(conditions, strings.Join(tagConditions, " AND "))
+		} else {
+			conditions = append(conditions, "("+strings.Join(tagConditions, " OR ")+")")
+		}
+	}
+	return conditions, args
+}
+
+func (s SQLiteFinanceStorage) queryTransactions(query string, args ...interface{}) ([]model.Transaction, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []model.Transaction
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+func scanTransaction(row rowScanner) (model.Transaction, error) {
+	var transaction model.Transaction
+	var date, tags, splits string
+	err := row.Scan(&transaction.ID, &transaction.UserID, &transaction.AccountID, &transaction.Type, &transaction.Amount,
+		&transaction.Currency, &transaction.Category, &date, &transaction.Description, &transaction.LinkedID,
+		&transaction.TransferRole, &transaction.RecurringID, &transaction.CategoryID, &tags, &splits, &transaction.ExternalID)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	if transaction.Date, err = model.ParseDateOnly(date); err != nil {
+		return model.Transaction{}, err
+	}
+	if transaction.Tags, err = decodeList[string](tags); err != nil {
+		return model.Transaction{}, err
+	}
+	if transaction.Splits, err = decodeList[model.Split](splits); err != nil {
+		return model.Transaction{}, err
+	}
+	return transaction, nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+const importProfileColumns = `id, user_id, name, delimiter, skip_rows, date_column, amount_column,
+	description_column, category_column, date_format, decimal_separator, sign_convention, account_id`
+
+type SQLiteImportProfileStorage struct {
+	db sqliteTable
+}
+
+func NewSQLiteImportProfileStorage(db *sql.DB) *SQLiteImportProfileStorage {
+	return &SQLiteImportProfileStorage{db: sqliteTable{db: db, table: "import_profiles"}}
+}
+
+func (s SQLiteImportProfileStorage) Insert(profile model.ImportProfile) (model.ImportProfile, error) {
+	result, err := s.db.Exec(`INSERT INTO import_profiles (user_id, name, delimiter, skip_rows, date_column,
+		amount_column, description_column, category_column, date_format, decimal_separator, sign_convention, account_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		profile.UserID, profile.Name, profile.Delimiter, profile.SkipRows, profile.DateColumn, profile.AmountColumn,
+		profile.DescriptionColumn, profile.CategoryColumn, profile.DateFormat, profile.DecimalSeparator,
+		profile.SignConvention, profile.AccountID)
+	if err != nil {
+		return model.ImportProfile{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.ImportProfile{}, err
+	}
+	profile.ID = int(id)
+	return profile, nil
+}
+
+func (s SQLiteImportProfileStorage) Update(profile model.ImportProfile) error {
+	result, err := s.db.Exec(`UPDATE import_profiles
+		SET user_id = ?, name = ?, delimiter = ?, skip_rows = ?, date_column = ?, amount_column = ?,
+			description_column = ?, category_column = ?, date_format = ?, decimal_separator = ?,
+			sign_convention = ?, account_id = ?
+		WHERE id = ?`,
+		profile.UserID, profile.Name, profile.Delimiter, profile.SkipRows, profile.DateColumn, profile.AmountColumn,
+		profile.DescriptionColumn, profile.CategoryColumn, profile.DateFormat, profile.DecimalSeparator,
+		profile.SignConvention, profile.AccountID, profile.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteImportProfileStorage) Delete(id int) error {
+	result, err := s.db.Exec(`DELETE FROM import_profiles WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteImportProfileStorage) Get(id int) (model.ImportProfile, error) {
+	row := s.db.QueryRow(`SELECT `+importProfileColumns+` FROM import_profiles WHERE id = ?`, id)
+	profile, err := scanImportProfile(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ImportProfile{}, ErrNotFound
+	}
+	return profile, err
+}
+
+func (s SQLiteImportProfileStorage) FindByUser(userID int) ([]model.ImportProfile, error) {
+	rows, err := s.db.Query(`SELECT `+importProfileColumns+` FROM import_profiles WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []model.ImportProfile
+	for rows.Next() {
+		profile, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
+}
+
+func scanImportProfile(row rowScanner) (model.ImportProfile, error) {
+	var profile model.ImportProfile
+	err := row.Scan(&profile.ID, &profile.UserID, &profile.Name, &profile.Delimiter, &profile.SkipRows,
+		&profile.DateColumn, &profile.AmountColumn, &profile.DescriptionColumn, &profile.CategoryColumn,
+		&profile.DateFormat, &profile.DecimalSeparator, &profile.SignConvention, &profile.AccountID)
+	return profile, err
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+const recurringColumns = `id, user_id, frequency, interval, start_date, end_date, count, type, amount, currency,
+	category, description, account_id, to_account_id, last_date, overrides`
+
+type SQLiteRecurringStorage struct {
+	db sqliteTable
+}
+
+func NewSQLiteRecurringStorage(db *sql.DB) *SQLiteRecurringStorage {
+	return &SQLiteRecurringStorage{db: sqliteTable{db: db, table: "recurring_rules"}}
+}
+
+// recurringValues returns the column values of a rule in recurringColumns
+// order, without the ID
+func recurringValues(rule model.RecurringRule) ([]interface{}, error) {
+	overrides, err := json.Marshal(rule.Overrides)
+	if err != nil {
+		return nil, err
+	}
+	if rule.Overrides == nil {
+		overrides = []byte("[]")
+	}
+	return []interface{}{
+		rule.UserID, rule.Frequency, rule.Interval, rule.StartDate.String(), optionalDate(rule.EndDate), rule.Count,
+		rule.Type, rule.Amount, rule.Currency, rule.Category, rule.Description, rule.AccountID, rule.ToAccountID,
+		optionalDate(rule.LastDate), string(overrides),
+	}, nil
+}
+
+func optionalDate(date *model.DateOnly) string {
+	if date == nil {
+		return ""
+	}
+	return date.String()
+}
+
+func parseOptionalDate(value string) (*model.DateOnly, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := model.ParseDateOnly(value)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+func (s SQLiteRecurringStorage) Insert(rule model.RecurringRule) (model.RecurringRule, error) {
+	values, err := recurringValues(rule)
+	if err != nil {
+		return model.RecurringRule{}, err
+	}
+	result, err := s.db.Exec(`INSERT INTO recurring_rules (user_id, frequency, interval, start_date, end_date, count,
+		type, amount, currency, category, description, account_id, to_account_id, last_date, overrides)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, values...)
+	if err != nil {
+		return model.RecurringRule{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.RecurringRule{}, err
+	}
+	rule.ID = int(id)
+	return rule, nil
+}
+
+func (s SQLiteRecurringStorage) Update(rule model.RecurringRule) error {
+	values, err := recurringValues(rule)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(`UPDATE recurring_rules
+		SET user_id = ?, frequency = ?, interval = ?, start_date = ?, end_date = ?, count = ?, type = ?, amount = ?,
+			currency = ?, category = ?, description = ?, account_id = ?, to_account_id = ?, last_date = ?, overrides = ?
+		WHERE id = ?`, append(values, rule.ID)...)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteRecurringStorage) Delete(id int) error {
+	result, err := s.db.Exec(`DELETE FROM recurring_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteRecurringStorage) Get(id int) (model.RecurringRule, error) {
+	row := s.db.QueryRow(`SELECT `+recurringColumns+` FROM recurring_rules WHERE id = ?`, id)
+	rule, err := scanRecurringRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.RecurringRule{}, ErrNotFound
+	}
+	return rule, err
+}
+
+func (s SQLiteRecurringStorage) FindByUser(userID int) ([]model.RecurringRule, error) {
+	return s.query(`SELECT `+recurringColumns+` FROM recurring_rules WHERE user_id = ? ORDER BY id`, userID)
+}
+
+func (s SQLiteRecurringStorage) List() ([]model.RecurringRule, error) {
+	return s.query(`SELECT ` + recurringColumns + ` FROM recurring_rules ORDER BY id`)
+}
+
+func (s SQLiteRecurringStorage) query(query string, args ...interface{}) ([]model.RecurringRule, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []model.RecurringRule
+	for rows.Next() {
+		rule, err := scanRecurringRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func scanRecurringRule(row rowScanner) (model.RecurringRule, error) {
+	var rule model.RecurringRule
+	var startDate, endDate, lastDate, overrides string
+	err := row.Scan(&rule.ID, &rule.UserID, &rule.Frequency, &rule.Interval, &startDate, &endDate, &rule.Count,
+		&rule.Type, &rule.Amount, &rule.Currency, &rule.Category, &rule.Description, &rule.AccountID,
+		&rule.ToAccountID, &lastDate, &overrides)
+	if err != nil {
+		return model.RecurringRule{}, err
+	}
+	if rule.StartDate, err = model.ParseDateOnly(startDate); err != nil {
+		return model.RecurringRule{}, err
+	}
+	if rule.EndDate, err = parseOptionalDate(endDate); err != nil {
+		return model.RecurringRule{}, err
+	}
+	if rule.LastDate, err = parseOptionalDate(lastDate); err != nil {
+		return model.RecurringRule{}, err
+	}
+	if err := json.Unmarshal([]byte(overrides), &rule.Overrides); err != nil {
+		return model.RecurringRule{}, err
+	}
+	if len(rule.Overrides) == 0 {
+		rule.Overrides = nil
+	}
+	return rule, nil
+}
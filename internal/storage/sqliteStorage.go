@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/metrics"
+	"modernc.org/sqlite"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// foldCollation compares text the way strings.EqualFold does, so names and
+// tags match the same records as in the file backend. SQLite's own NOCASE
+// only folds ASCII letters.
+const foldCollation = "FOLD"
+
+func init() {
+	sqlite.MustRegisterCollationUtf8(foldCollation, func(left, right string) int {
+		return strings.Compare(foldKey(left), foldKey(right))
+	})
+}
+
+// foldKey replaces every rune with the smallest rune it folds to, so two
+// strings have the same key exactly when strings.EqualFold reports them equal
+func foldKey(text string) string {
+	return strings.Map(func(r rune) rune {
+		smallest := r
+		for folded := unicode.SimpleFold(r); folded != r; folded = unicode.SimpleFold(folded) {
+			smallest = min(smallest, folded)
+		}
+		return smallest
+	}, text)
+}
+
+// migrations holds the schema changes applied in order. Never edit an entry
+// that has been released; append a new one instead.
+var migrations = []string{
+	`CREATE TABLE users (
+		id       INTEGER PRIMARY KEY,
+		name     TEXT    NOT NULL DEFAULT '',
+		email    TEXT    NOT NULL,
+		password TEXT    NOT NULL,
+		status   INTEGER NOT NULL DEFAULT 1
+	);
+	CREATE INDEX idx_users_email ON users (email);
+
+	CREATE TABLE transactions (
+		id          INTEGER PRIMARY KEY,
+		user_id     INTEGER NOT NULL,
+		type        TEXT    NOT NULL,
+		amount      REAL    NOT NULL,
+		category    TEXT    NOT NULL DEFAULT '',
+		date        TEXT    NOT NULL,
+		description TEXT    NOT NULL DEFAULT ''
+	);
+	CREATE INDEX idx_transactions_user_date ON transactions (user_id, date);
+	CREATE INDEX idx_transactions_date ON transactions (date);`,
+
+	// Amounts become integer cents
+	`ALTER TABLE transactions ADD COLUMN amount_cents INTEGER NOT NULL DEFAULT 0;
+	UPDATE transactions SET amount_cents = CAST(ROUND(amount * 100) AS INTEGER);
+	ALTER TABLE transactions DROP COLUMN amount;
+	ALTER TABLE transactions RENAME COLUMN amount_cents TO amount;`,
+
+	// Currencies and exchange rates; existing rows are in the default currency
+	`ALTER TABLE transactions ADD COLUMN currency TEXT NOT NULL DEFAULT 'BRL';
+	ALTER TABLE users ADD COLUMN base_currency TEXT NOT NULL DEFAULT 'BRL';
+
+	CREATE TABLE exchange_rates (
+		id            INTEGER PRIMARY KEY,
+		date          TEXT NOT NULL,
+		from_currency TEXT NOT NULL,
+		to_currency   TEXT NOT NULL,
+		rate          TEXT NOT NULL,
+		UNIQUE (date, from_currency, to_currency)
+	);
+	CREATE INDEX idx_exchange_rates_pair_date ON exchange_rates (from_currency, to_currency, date);`,
+
+	// Accounts; transactions recorded before accounts existed keep account_id 0
+	`CREATE TABLE accounts (
+		id              INTEGER PRIMARY KEY,
+		user_id         INTEGER NOT NULL,
+		name            TEXT    NOT NULL,
+		type            TEXT    NOT NULL,
+		currency        TEXT    NOT NULL,
+		opening_balance INTEGER NOT NULL DEFAULT 0,
+		opening_date    TEXT    NOT NULL
+	);
+	CREATE INDEX idx_accounts_user ON accounts (user_id);
+
+	ALTER TABLE transactions ADD COLUMN account_id INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX idx_transactions_account ON transactions (account_id);`,
+
+	// Transfers are stored as two transactions linked to each other
+	`ALTER TABLE transactions ADD COLUMN linked_id INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE transactions ADD COLUMN transfer_role TEXT NOT NULL DEFAULT '';`,
+
+	// Recurring rules; overrides of single occurrences are kept as JSON
+	`CREATE TABLE recurring_rules (
+		id            INTEGER PRIMARY KEY,
+		user_id       INTEGER NOT NULL,
+		frequency     TEXT    NOT NULL,
+		interval      INTEGER NOT NULL DEFAULT 1,
+		start_date    TEXT    NOT NULL,
+		end_date      TEXT    NOT NULL DEFAULT '',
+		count         INTEGER NOT NULL DEFAULT 0,
+		type          TEXT    NOT NULL,
+		amount        INTEGER NOT NULL,
+		currency      TEXT    NOT NULL DEFAULT '',
+		category      TEXT    NOT NULL DEFAULT '',
+		description   TEXT    NOT NULL DEFAULT '',
+		account_id    INTEGER NOT NULL DEFAULT 0,
+		to_account_id INTEGER NOT NULL DEFAULT 0,
+		last_date     TEXT    NOT NULL DEFAULT '',
+		overrides     TEXT    NOT NULL DEFAULT '[]'
+	);
+	CREATE INDEX idx_recurring_rules_user ON recurring_rules (user_id);
+
+	ALTER TABLE transactions ADD COLUMN recurring_id INTEGER NOT NULL DEFAULT 0;`,
+
+	// Monthly budgets; an empty category is the overall budget
+	`CREATE TABLE budgets (
+		id           INTEGER PRIMARY KEY,
+		user_id      INTEGER NOT NULL,
+		category     TEXT    NOT NULL DEFAULT '',
+		amount_limit INTEGER NOT NULL,
+		rollover     INTEGER NOT NULL DEFAULT 0,
+		start_month  TEXT    NOT NULL
+	);
+	CREATE INDEX idx_budgets_user ON budgets (user_id);`,
+
+	// Alert rules and the per-user opt-out
+	`CREATE TABLE alert_rules (
+		id          INTEGER PRIMARY KEY,
+		user_id     INTEGER NOT NULL,
+		kind        TEXT    NOT NULL,
+		category    TEXT    NOT NULL DEFAULT '',
+		threshold   INTEGER NOT NULL,
+		last_period TEXT    NOT NULL DEFAULT ''
+	);
+	CREATE INDEX idx_alert_rules_user ON alert_rules (user_id);
+
+	ALTER TABLE users ADD COLUMN alerts_opt_out INTEGER NOT NULL DEFAULT 0;`,
+
+	// Category catalog; transactions keep the category name and remember the
+	// catalog entry it was resolved to
+	`CREATE TABLE categories (
+		id        INTEGER PRIMARY KEY,
+		user_id   INTEGER NOT NULL,
+		name      TEXT    NOT NULL,
+		type      TEXT    NOT NULL,
+		parent_id INTEGER NOT NULL DEFAULT 0,
+		color     TEXT    NOT NULL DEFAULT '',
+		icon      TEXT    NOT NULL DEFAULT ''
+	);
+	CREATE INDEX idx_categories_user ON categories (user_id);
+
+	ALTER TABLE transactions ADD COLUMN category_id INTEGER NOT NULL DEFAULT 0;`,
+
+	// Transaction tags, kept as a JSON array
+	`ALTER TABLE transactions ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';`,
+
+	// Split lines of transactions divided across categories, kept as JSON
+	`ALTER TABLE transactions ADD COLUMN splits TEXT NOT NULL DEFAULT '[]';`,
+
+	// Saved column mappings for CSV bank statement imports
+	`CREATE TABLE import_profiles (
+		id                 INTEGER PRIMARY KEY,
+		user_id            INTEGER NOT NULL,
+		name               TEXT    NOT NULL,
+		delimiter          TEXT    NOT NULL,
+		skip_rows          INTEGER NOT NULL DEFAULT 0,
+		date_column        TEXT    NOT NULL,
+		amount_column      TEXT    NOT NULL,
+		description_column TEXT    NOT NULL DEFAULT '',
+		category_column    TEXT    NOT NULL DEFAULT '',
+		date_format        TEXT    NOT NULL,
+		decimal_separator  TEXT    NOT NULL,
+		sign_convention    TEXT    NOT NULL,
+		account_id         INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX idx_import_profiles_user ON import_profiles (user_id);`,
+
+	// Bank identifiers of imported transactions, such as OFX FITIDs
+	`ALTER TABLE transactions ADD COLUMN external_id TEXT NOT NULL DEFAULT '';`,
+
+	// Users whose data was erased keep their row, so their ID is never reused
+	`ALTER TABLE users ADD COLUMN purged INTEGER NOT NULL DEFAULT 0;`,
+
+	// Months an alert rule fired in, kept as a JSON array
+	`ALTER TABLE alert_rules ADD COLUMN fired_periods TEXT NOT NULL DEFAULT '[]';`,
+}
+
+// OpenSQLite opens (or creates) the SQLite database and brings its schema up
+// to date. Relative file names are resolved inside the application data directory.
+func OpenSQLite(filename string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", dataPath(filename))
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialising access through one
+	// connection avoids "database is locked" errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// CheckSQLite reports whether the database can be written, by taking the
+// write lock and releasing it without changing anything. It waits for
+// requests holding the single connection until ctx expires.
+func CheckSQLite(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return err
+	}
+	// The lock is released even if ctx expired meanwhile, or the
+	// connection would go back to the pool inside a transaction
+	_, err = conn.ExecContext(context.Background(), `ROLLBACK`)
+	return err
+}
+
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("applying migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteTable runs the queries of the storage of one table, recording how
+// long they take and which fail in the storage metrics, as the file backend
+// does for its data files. Queries count as loads, changes as saves.
+type sqliteTable struct {
+	db    *sql.DB
+	table string
+}
+
+func (t sqliteTable) observe(operation string, start time.Time, err error) {
+	metrics.ObserveStorage(t.table, operation, time.Since(start), err)
+}
+
+func (t sqliteTable) Exec(query string, args ...interface{}) (result sql.Result, err error) {
+	start := time.Now()
+	defer func() { t.observe("save", start, err) }()
+	return t.db.Exec(query, args...)
+}
+
+func (t sqliteTable) Query(query string, args ...interface{}) (rows *sql.Rows, err error) {
+	start := time.Now()
+	defer func() { t.observe("load", start, err) }()
+	return t.db.Query(query, args...)
+}
+
+// QueryRow counts a row that is not found as a successful load
+func (t sqliteTable) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := t.db.QueryRow(query, args...)
+	t.observe("load", start, row.Err())
+	return row
+}
+
+// inTx runs fn in a transaction, committing only if it succeeds. The whole
+// transaction counts as a single save.
+func (t sqliteTable) inTx(fn func(tx *sql.Tx) error) (err error) {
+	start := time.Now()
+	defer func() { t.observe("save", start, err) }()
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// requireAffected turns an UPDATE or DELETE that touched no rows into ErrNotFound
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
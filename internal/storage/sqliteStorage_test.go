@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/metrics"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestOpenSQLiteIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	for i := 0; i < 2; i++ {
+		db, err := OpenSQLite(path)
+		if err != nil {
+			t.Fatalf("Failed to open SQLite database on attempt %d: %v", i+1, err)
+		}
+		var version int
+		if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+			t.Fatalf("Failed to read schema version: %v", err)
+		}
+		if version != len(migrations) {
+			t.Errorf("Expected schema version %d, got %d", len(migrations), version)
+		}
+		db.Close()
+	}
+}
+
+func TestMigrationConvertsAmountsToCents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	// Recreate the schema as released before amounts were stored in cents
+	_, err = db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY);
+		INSERT INTO schema_migrations (version) VALUES (1);`)
+	if err != nil {
+		t.Fatalf("Failed to create migrations table: %v", err)
+	}
+	if _, err := db.Exec(migrations[0]); err != nil {
+		t.Fatalf("Failed to apply first migration: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO transactions (user_id, type, amount, date) VALUES
+		(1, 'expense', 0.29, '2023-06-15'), (1, 'income', 1234.5, '2023-06-16')`)
+	if err != nil {
+		t.Fatalf("Failed to insert legacy rows: %v", err)
+	}
+	db.Close()
+
+	db, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	defer db.Close()
+
+	transactions, err := NewSQLiteFinanceStorage(db).FindByUser(1, model.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("Failed to load migrated transactions: %v", err)
+	}
+	if len(transactions) != 2 || transactions[0].Amount != 29 || transactions[1].Amount != 123450 {
+		t.Errorf("Expected amounts of 29 and 123450 cents, got %+v", transactions)
+	}
+	if transactions[0].Currency != model.DefaultCurrency {
+		t.Errorf("Expected existing transactions in %s, got %q", model.DefaultCurrency, transactions[0].Currency)
+	}
+}
+
+func TestCheckSQLite(t *testing.T) {
+	db := openTestDB(t)
+	if err := CheckSQLite(context.Background(), db); err != nil {
+		t.Fatalf("Expected a writable database, got %v", err)
+	}
+
+	// While a request holds the only connection the check gives up with ctx
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := CheckSQLite(ctx, db); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the check to time out, got %v", err)
+	}
+	tx.Rollback()
+
+	if _, err := db.Exec(`INSERT INTO users (email, password) VALUES ('a@example.com', 'x')`); err != nil {
+		t.Errorf("Expected the database to stay writable after the check, got %v", err)
+	}
+}
+
+func TestFoldKeyMatchesEqualFold(t *testing.T) {
+	words := []string{"food", "FOOD", "Café", "CAFÉ", "cafe", "straße", "STRASSE", "ſ", "s", "K", "K", "Σ", "σ", "ς", ""}
+	for _, a := range words {
+		for _, b := range words {
+			if (foldKey(a) == foldKey(b)) != strings.EqualFold(a, b) {
+				t.Errorf("Expected %q and %q to fold like strings.EqualFold", a, b)
+			}
+		}
+	}
+}
+
+// storageMetric reads the value of a series served at /metrics, 0 if absent
+func storageMetric(t *testing.T, series string) float64 {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, series+" "); ok {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", line, err)
+			}
+			return number
+		}
+	}
+	return 0
+}
+
+func TestSQLiteStorageRecordsMetrics(t *testing.T) {
+	const (
+		saves    = `myfinance_storage_operation_duration_seconds_count{file="budgets",operation="save"}`
+		loads    = `myfinance_storage_operation_duration_seconds_count{file="budgets",operation="load"}`
+		failures = `myfinance_storage_errors_total{file="budgets",operation="load"}`
+	)
+	db := openTestDB(t)
+	budgetStorage := NewSQLiteBudgetStorage(db)
+	savesBefore, loadsBefore, failuresBefore := storageMetric(t, saves), storageMetric(t, loads), storageMetric(t, failures)
+
+	budget, err := budgetStorage.Insert(model.Budget{UserID: 1, Category: "Food", Limit: 500_00, StartMonth: "2023-06"})
+	if err != nil {
+		t.Fatalf("Failed to insert budget: %v", err)
+	}
+	if _, err := budgetStorage.Get(budget.ID); err != nil {
+		t.Fatalf("Failed to get budget: %v", err)
+	}
+	db.Close()
+	if _, err := budgetStorage.FindByUser(1); err == nil {
+		t.Fatal("Expected an error from a closed database")
+	}
+
+	if count := storageMetric(t, saves) - savesBefore; count != 1 {
+		t.Errorf("Expected 1 save, got %v", count)
+	}
+	if count := storageMetric(t, loads) - loadsBefore; count != 2 {
+		t.Errorf("Expected 2 loads, got %v", count)
+	}
+	if count := storageMetric(t, failures) - failuresBefore; count != 1 {
+		t.Errorf("Expected 1 failed load, got %v", count)
+	}
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+const userColumns = `id, name, email, password, status, base_currency, alerts_opt_out, purged`
+
+type SQLiteUserStorage struct {
+	db sqliteTable
+}
+
+func NewSQLiteUserStorage(db *sql.DB) *SQLiteUserStorage {
+	return &SQLiteUserStorage{db: sqliteTable{db: db, table: "users"}}
+}
+
+func (s SQLiteUserStorage) Insert(user model.User) (model.User, error) {
+	result, err := s.db.Exec(`INSERT INTO users (name, email, password, status, base_currency, alerts_opt_out, purged)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.Name, user.Email, user.Password, user.Status, user.BaseCurrency, user.AlertsOptOut, user.Purged)
+	if err != nil {
+		return model.User{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.User{}, err
+	}
+	user.ID = int(id)
+	return user, nil
+}
+
+func (s SQLiteUserStorage) Update(user model.User) error {
+	result, err := s.db.Exec(`UPDATE users
+		SET name = ?, email = ?, password = ?, status = ?, base_currency = ?, alerts_opt_out = ?, purged = ?
+		WHERE id = ?`,
+		user.Name, user.Email, user.Password, user.Status, user.BaseCurrency, user.AlertsOptOut, user.Purged, user.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteUserStorage) Delete(id int) error {
+	result, err := s.db.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s SQLiteUserStorage) Get(id int) (model.User, error) {
+	return s.queryOne(`SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+}
+
+func (s SQLiteUserStorage) FindByEmail(email string) (model.User, error) {
+	return s.queryOne(`SELECT `+userColumns+` FROM users WHERE email = ? ORDER BY id LIMIT 1`, email)
+}
+
+func (s SQLiteUserStorage) List() ([]model.User, error) {
+	rows, err := s.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Status, &user.BaseCurrency, &user.AlertsOptOut, &user.Purged); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s SQLiteUserStorage) queryOne(query string, args ...interface{}) (model.User, error) {
+	var user model.User
+	err := s.db.QueryRow(query, args...).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Status, &user.BaseCurrency, &user.AlertsOptOut, &user.Purged)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, ErrNotFound
+	}
+	return user, err
+}
@@ -4,27 +4,62 @@ import (
 	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
 )
 
+// UserStorage persists users one record at a time. Insert assigns the ID;
+// Update, Delete, Get and FindByEmail return ErrNotFound when nothing matches.
 type UserStorage interface {
-	Save([]model.User) error
-	Load() ([]model.User, error)
+	Insert(user model.User) (model.User, error)
+	Update(user model.User) error
+	Delete(id int) error
+	Get(id int) (model.User, error)
+	FindByEmail(email string) (model.User, error)
+	List() ([]model.User, error)
 }
 
 type FileUserStorage struct {
-	FileStorage
+	users *fileCollection[model.User]
 }
 
-func NewFileUserStorage(filename string) *FileUserStorage {
-	return &FileUserStorage{
-		FileStorage: *NewFileStorage(filename),
+func NewFileUserStorage(filename string) (*FileUserStorage, error) {
+	users, err := newFileCollection(filename, func(user *model.User) *int { return &user.ID })
+	if err != nil {
+		return nil, err
 	}
+	// Users registered before base currencies existed use the default currency
+	for index := range users.records {
+		if users.records[index].BaseCurrency == "" {
+			users.records[index].BaseCurrency = model.DefaultCurrency
+		}
+	}
+	return &FileUserStorage{users: users}, nil
+}
+
+func (f FileUserStorage) Insert(user model.User) (model.User, error) {
+	return f.users.insert(user)
 }
 
-func (f FileUserStorage) Save(users []model.User) error {
-	return f.FileStorage.Save(users)
+func (f FileUserStorage) Update(user model.User) error {
+	return f.users.update(user)
+}
+
+func (f FileUserStorage) Delete(id int) error {
+	return f.users.delete(id)
+}
+
+func (f FileUserStorage) Get(id int) (model.User, error) {
+	return f.users.get(id)
+}
+
+func (f FileUserStorage) FindByEmail(email string) (model.User, error) {
+	users, err := f.users.find(func(user model.User) bool { return user.Email == email })
+	if err != nil {
+		return model.User{}, err
+	}
+	if len(users) == 0 {
+		return model.User{}, ErrNotFound
+	}
+	return users[0], nil
 }
 
-func (f FileUserStorage) Load() ([]model.User, error) {
-	var users []model.User
-	err := f.FileStorage.Load(&users)
-	return users, err
+func (f FileUserStorage) List() ([]model.User, error) {
+	return f.users.find(func(model.User) bool { return true })
 }
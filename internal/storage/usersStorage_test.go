@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mth-ribeiro-dev/finance-api-go.git/internal/model"
+)
+
+func TestUserStorageRowOperations(t *testing.T) {
+	for name, userStorage := range backends[UserStorage](t, NewFileUserStorage, NewSQLiteUserStorage) {
+		t.Run(name, func(t *testing.T) {
+			john, err := userStorage.Insert(model.User{Name: "John Doe", Email: "john@example.com", Password: "hash", Status: true})
+			if err != nil {
+				t.Fatalf("Failed to insert user: %v", err)
+			}
+			jane, err := userStorage.Insert(model.User{Name: "Jane Doe", Email: "jane@example.com", Password: "hash", Status: true})
+			if err != nil {
+				t.Fatalf("Failed to insert user: %v", err)
+			}
+
+			jane.Status, jane.AlertsOptOut, jane.Purged = false, true, true
+			if err := userStorage.Update(jane); err != nil {
+				t.Fatalf("Failed to update user: %v", err)
+			}
+
+			found, err := userStorage.FindByEmail("jane@example.com")
+			if err != nil {
+				t.Fatalf("Failed to find user by email: %v", err)
+			}
+			if found != jane {
+				t.Errorf("Expected %+v, got %+v", jane, found)
+			}
+
+			if _, err := userStorage.FindByEmail("nobody@example.com"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for unknown email, got %v", err)
+			}
+
+			users, err := userStorage.List()
+			if err != nil {
+				t.Fatalf("Failed to list users: %v", err)
+			}
+			if len(users) != 2 || users[0] != john || users[1] != jane {
+				t.Errorf("Unexpected users listed: %+v", users)
+			}
+
+			if err := userStorage.Delete(john.ID); err != nil {
+				t.Fatalf("Failed to delete user: %v", err)
+			}
+			if _, err := userStorage.Get(john.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for deleted user, got %v", err)
+			}
+		})
+	}
+}